@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/eargollo/ditto/internal/api"
 	"github.com/eargollo/ditto/internal/config"
@@ -23,8 +27,14 @@ var version = "dev"
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to config file")
+	showVersion := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Printf("ditto %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		return
+	}
+
 	// ── Logging (initial — overridden below once config is loaded) ─────────
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -49,6 +59,15 @@ func main() {
 		"scan_paths", cfg.ScanPaths)
 
 	// ── Database ───────────────────────────────────────────────────────────
+	// Refuse to share the database with another running instance: two
+	// processes would both write and both run the scheduler.
+	dbLock, err := db.AcquireLock(cfg.DBPath)
+	if err != nil {
+		slog.Error("acquire database lock", "error", err)
+		os.Exit(1)
+	}
+	defer dbLock.Release()
+
 	database, err := db.Open(cfg.DBPath)
 	if err != nil {
 		slog.Error("open database", "error", err)
@@ -67,7 +86,7 @@ func main() {
 
 	// Read-only connection pool for parallel cache lookups during scans.
 	// Falls back to the main DB if the pool cannot be opened.
-	readDB, err := db.OpenReadPool(cfg.DBPath, 4)
+	readDB, err := db.OpenReadPool(cfg.DBPath, cfg.DBReadConns)
 	if err != nil {
 		slog.Warn("open read pool, falling back to main db", "error", err)
 		readDB = database
@@ -82,36 +101,55 @@ func main() {
 
 	// ── Scan manager ───────────────────────────────────────────────────────
 	scanCfg := scan.Config{
-		Walkers:        cfg.ScanWorkers.Walkers,
-		CacheCheckers:  cfg.ScanWorkers.CacheCheckers,
-		PartialHashers: cfg.ScanWorkers.PartialHashers,
-		FullHashers:    cfg.ScanWorkers.FullHashers,
-		BatchSize:      1000,
-		ReadDB:         readDB,
+		Walkers:           cfg.ScanWorkers.Walkers,
+		CacheCheckers:     cfg.ScanWorkers.CacheCheckers,
+		PartialHashers:    cfg.ScanWorkers.PartialHashers,
+		FullHashers:       cfg.ScanWorkers.FullHashers,
+		BatchSize:         1000,
+		CacheBatchSize:    cfg.CacheBatchSize,
+		PartialHashAlgo:   cfg.PartialHashAlgo,
+		MaxOpenFiles:      cfg.ScanWorkers.MaxOpenFiles,
+		SniffContentTypes: cfg.SniffContentTypes,
+		GroupByExtension:  cfg.GroupByExtension,
+		ReadDB:            readDB,
+
+		SkipRecentlyModified:      time.Duration(cfg.SkipRecentlyModifiedSeconds) * time.Second,
+		SizeTolerance:             cfg.SizeTolerancePercent / 100,
+		HeadBytes:                 cfg.CandidateHeadBytes,
+		CPUPercent:                cfg.ScanCPUPercent,
+		SkipPartialHashAbove:      cfg.PartialHashSkipAboveBytes,
+		ApproximateSampleBytes:    cfg.ApproximateSampleBytes,
+		MetadataOnly:              cfg.MetadataOnly,
+		SummarizePermissionErrors: cfg.SummarizePermissionErrors,
+		FileInventory:             cfg.FileInventory,
+		ReconcileVanishedGroups:   cfg.ReconcileVanishedGroups,
+		CacheFirst:                cfg.CacheFirstLookup,
+		QuarantineAfter:           cfg.HashQuarantineAfter,
+		InternalDirs:              internalDirs(cfg),
 	}
 	mgr := scan.NewManager(database, cfg.ScanPaths, cfg.ExcludePaths, scanCfg)
 
 	// ── Trash manager ──────────────────────────────────────────────────────
-	trashMgr := trash.New(database, cfg.TrashDir)
+	trashMgr := trash.New(database, cfg.TrashDir, cfg.ArchiveDir, cfg.TrashMinFreeBytes, cfg.CompressTrash, cfg.RestoreFallbackDir)
 
 	// ── Scheduler ──────────────────────────────────────────────────────────
 	sched := scheduler.New()
 	if !cfg.ScanPaused && cfg.Schedule != "" {
-		if err := sched.SetJob(cfg.Schedule, func() {
+		if err := sched.SetJob(cfg.Schedule, func() error {
 			slog.Info("scheduled scan triggered")
-			if _, err := mgr.Start(context.Background(), "schedule"); err != nil {
-				slog.Warn("scheduled scan start", "error", err)
-			}
+			_, err := mgr.Start(context.Background(), "schedule")
+			return err
 		}); err != nil {
 			slog.Warn("invalid cron expression", "expr", cfg.Schedule, "error", err)
 		}
 	}
 
-	if err := sched.AddJob("0 3 * * *", func() {
+	if cfg.ReadOnly {
+		slog.Info("read-only mode: deletion endpoints and auto-purge are disabled")
+	} else if err := sched.AddJob("auto-purge", "0 3 * * *", func() error {
 		slog.Info("auto-purge triggered")
-		if err := trashMgr.AutoPurge(context.Background()); err != nil {
-			slog.Error("auto-purge failed", "error", err)
-		}
+		_, _, err := trashMgr.AutoPurge(context.Background())
+		return err
 	}); err != nil {
 		slog.Warn("failed to register auto-purge job", "error", err)
 	}
@@ -119,6 +157,18 @@ func main() {
 	sched.Start()
 	defer sched.Stop()
 
+	// ── Startup scan ───────────────────────────────────────────────────────
+	if cfg.ScanOnStartup && !cfg.ScanPaused {
+		if due, err := startupScanDue(database, cfg.ScanIfStaleHours); err != nil {
+			slog.Warn("startup scan: read last scan", "error", err)
+		} else if due {
+			slog.Info("startup scan triggered", "stale_hours", cfg.ScanIfStaleHours)
+			if _, err := mgr.Start(context.Background(), "schedule"); err != nil {
+				slog.Warn("startup scan start", "error", err)
+			}
+		}
+	}
+
 	// ── HTTP server ────────────────────────────────────────────────────────
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -128,9 +178,42 @@ func main() {
 		slog.Error("server error", "error", err)
 		os.Exit(1)
 	}
+
+	// Stop any running scan before the deferred DB closes run.
+	scanCtx, cancelScan := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	if err := mgr.Shutdown(scanCtx); err != nil {
+		slog.Warn("scan did not stop before shutdown timeout", "error", err)
+	}
+	cancelScan()
 	slog.Info("ditto stopped")
 }
 
+// startupScanDue reports whether scan_on_startup should start a scan: always
+// when staleHours is 0, otherwise when no scan completed within staleHours.
+func startupScanDue(database *sql.DB, staleHours int) (bool, error) {
+	if staleHours == 0 {
+		return true, nil
+	}
+	var finishedAt sql.NullInt64
+	if err := database.QueryRow(
+		`SELECT MAX(finished_at) FROM scan_history WHERE status = 'completed'`,
+	).Scan(&finishedAt); err != nil {
+		return false, err
+	}
+	cutoff := time.Now().Add(-time.Duration(staleHours) * time.Hour)
+	return !finishedAt.Valid || time.Unix(finishedAt.Int64, 0).Before(cutoff), nil
+}
+
+// internalDirs returns the directories ditto writes to itself, which every
+// scan skips: trash_dir and, when set, archive_dir.
+func internalDirs(cfg *config.Config) []string {
+	dirs := []string{cfg.TrashDir}
+	if cfg.ArchiveDir != "" {
+		dirs = append(dirs, cfg.ArchiveDir)
+	}
+	return dirs
+}
+
 // parseLogLevel converts a config string ("debug", "info", "warn", "error")
 // to its slog.Level equivalent. Unknown values default to Info.
 func parseLogLevel(s string) slog.Level {
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// CSRF protection for the /ui/* form actions uses a double-submit token: the
+// token lives in a SameSite=Strict cookie set when a page is rendered, and
+// every form posts it back as csrf_token. A foreign page can neither read the
+// cookie nor get the browser to send it, so it cannot forge either half.
+const (
+	csrfCookie = "ditto_csrf"
+	csrfField  = "csrf_token"
+)
+
+// csrfToken returns the request's CSRF token, issuing a new cookie when the
+// request has none.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 64 {
+		return c.Value, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate CSRF token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// csrfFuncs returns the template functions bound to this request:
+// {{csrfField}} renders the hidden input every POST form must include. The
+// token is resolved here, before any of the body is written, so a new cookie
+// still makes it into the response headers.
+func csrfFuncs(w http.ResponseWriter, r *http.Request) (template.FuncMap, error) {
+	token, err := csrfToken(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return template.FuncMap{
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + csrfField + `" value="` + token + `">`)
+		},
+	}, nil
+}
+
+// verifyCSRF rejects state-changing requests whose csrf_token form value does
+// not match the CSRF cookie.
+func verifyCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c, err := r.Cookie(csrfCookie)
+		sent := r.PostFormValue(csrfField)
+		if err != nil || sent == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(sent)) != 1 {
+			http.Error(w, "Invalid or missing CSRF token; reload the page and try again.", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCSRF(t *testing.T) {
+	token := strings.Repeat("ab", 32)
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		sent   string
+		want   int
+	}{
+		{"matching token", http.MethodPost, token, token, http.StatusNoContent},
+		{"missing cookie", http.MethodPost, "", token, http.StatusForbidden},
+		{"missing field", http.MethodPost, token, "", http.StatusForbidden},
+		{"mismatched token", http.MethodPost, token, strings.Repeat("cd", 32), http.StatusForbidden},
+		{"GET is not checked", http.MethodGet, "", "", http.StatusNoContent},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.sent != "" {
+				form.Set(csrfField, tt.sent)
+			}
+			req := httptest.NewRequest(tt.method, "/ui/scans", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: csrfCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			verifyCSRF(next).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// TestCSRFToken verifies that a request without the cookie is issued a new
+// token and one that carries it keeps its token.
+func TestCSRFToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	token, err := csrfToken(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("csrfToken: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(token) != 64 || len(cookies) != 1 || cookies[0].Name != csrfCookie || cookies[0].Value != token {
+		t.Fatalf("token %q, cookies %v; want a 64-char token set as %s", token, cookies, csrfCookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	again, err := csrfToken(rec, req)
+	if err != nil || again != token || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("with the cookie: token %q, err %v, cookies %v; want the same token and no new cookie",
+			again, err, rec.Result().Cookies())
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLog logs one structured line per request: method, path, status, bytes
+// written, duration and the request ID. Requests whose path matches one of
+// the skip patterns (path.Match syntax, e.g. "/api/files/*/thumbnail") are
+// served without logging, to keep UI polling and thumbnail grids out of the
+// log.
+func AccessLog(skip []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skipAccessLog(skip, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK // nothing written: net/http sends 200
+				}
+				slog.Info("http request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"duration_ms", float64(time.Since(start).Microseconds())/1000,
+					"request_id", middleware.GetReqID(r.Context()),
+					"remote", r.RemoteAddr,
+				)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+func skipAccessLog(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes actions to actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor attributes each request to the value of the header named by header
+// (e.g. Remote-User from an authenticating reverse proxy), or to name when
+// the header is unset or empty. Only name a header a proxy in front of ditto
+// always sets or strips — clients could otherwise claim any identity.
+func Actor(header, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := name
+			if header != "" {
+				if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+					actor = v
+				}
+			}
+			if actor == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), actor)))
+		})
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
 	"strconv"
-	"sync"
+	"time"
 
 	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
@@ -19,39 +22,89 @@ type ConfigHandler struct {
 	DB      *sql.DB
 	Cfg     *config.Config
 	Manager *scan.Manager
-	mu      sync.Mutex // guards Cfg mutations
 }
 
 // ConfigPatch describes the fields that can be updated at runtime.
 // Only supplied (non-nil) fields are applied.
 type ConfigPatch struct {
-	ScanPaths          []string     `json:"scan_paths"`
-	ExcludePaths       []string     `json:"exclude_paths"`
-	Schedule           *string      `json:"schedule"`
-	ScanPaused         *bool        `json:"scan_paused"`
-	TrashRetentionDays *int         `json:"trash_retention_days"`
-	ScanWorkers        *WorkerPatch `json:"scan_workers"`
+	ScanPaths             []string     `json:"scan_paths"`
+	ExcludePaths          []string     `json:"exclude_paths"`
+	Schedule              *string      `json:"schedule"`
+	ScanPaused            *bool        `json:"scan_paused"`
+	TrashRetentionDays    *int         `json:"trash_retention_days"`
+	ScanWorkers           *WorkerPatch `json:"scan_workers"`
+	Theme                 *string      `json:"theme"`
+	StatusPollSeconds     *int         `json:"status_poll_seconds"`
+	StatusPollIdleSeconds *int         `json:"status_poll_idle_seconds"`
+	DefaultGroupType      *string      `json:"default_group_type"`
+}
+
+// NextScanMessage warns that a config change reached the scan manager while
+// a scan was running: that scan keeps the roots and workers it started with.
+const NextScanMessage = "A scan is in progress; it keeps its current paths and workers, and these changes apply from the next scan"
+
+// affectsScan reports whether p changes anything a running scan was started with.
+func (p ConfigPatch) affectsScan() bool {
+	return p.ScanPaths != nil || p.ExcludePaths != nil || p.ScanWorkers != nil
 }
 
 // WorkerPatch holds optional updates for scan worker counts.
 type WorkerPatch struct {
 	Walkers        *int `json:"walkers"`
+	CacheCheckers  *int `json:"cache_checkers"`
 	PartialHashers *int `json:"partial_hashers"`
 	FullHashers    *int `json:"full_hashers"`
 }
 
 // Get handles GET /api/config.
 func (h *ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.Cfg.Lock()
+	defer h.Cfg.Unlock()
 	writeJSON(w, http.StatusOK, h.Cfg)
 }
 
+// configFile is the config file as currently on disk.
+type configFile struct {
+	Path    string `json:"path"`
+	Exists  bool   `json:"exists"`
+	Content string `json:"content,omitempty"`
+}
+
+// Sources handles GET /api/config/sources — every config field with its
+// effective value and origin (default, file, env or db), plus the raw config
+// file as it is on disk now. The file is only read at startup, so edits made
+// since then show up in content but not in the values.
+func (h *ConfigHandler) Sources(w http.ResponseWriter, r *http.Request) {
+	settings, err := db.LoadSettings(h.DB)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	h.Cfg.Lock()
+	fields := h.Cfg.Sources(settings)
+	file := configFile{Path: h.Cfg.Path()}
+	h.Cfg.Unlock()
+
+	if file.Path != "" {
+		if data, err := os.ReadFile(file.Path); err == nil {
+			file.Exists = true
+			file.Content = string(data)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"file":   file,
+		"fields": fields,
+	})
+}
+
 // Apply acquires the config lock, applies each non-nil patch field to h.Cfg,
 // persists each change to the settings table, and propagates to scan.Manager.
 func (h *ConfigHandler) Apply(_ context.Context, patch ConfigPatch) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.Cfg.Lock()
+	defer h.Cfg.Unlock()
 
 	if patch.ScanPaths != nil {
 		h.Cfg.ScanPaths = patch.ScanPaths
@@ -81,11 +134,58 @@ func (h *ConfigHandler) Apply(_ context.Context, patch ConfigPatch) error {
 		h.Cfg.TrashRetentionDays = v
 		db.SaveSetting(h.DB, "trash_retention_days", strconv.Itoa(v))
 	}
+	if patch.Theme != nil {
+		v := *patch.Theme
+		if v != "light" && v != "dark" {
+			return fmt.Errorf("theme must be \"light\" or \"dark\"")
+		}
+		h.Cfg.Theme = v
+		db.SaveSetting(h.DB, "theme", v)
+	}
+	if patch.StatusPollSeconds != nil {
+		v := *patch.StatusPollSeconds
+		if v < 1 {
+			return fmt.Errorf("status_poll_seconds must be at least 1")
+		}
+		h.Cfg.StatusPollSeconds = v
+		db.SaveSetting(h.DB, "status_poll_seconds", strconv.Itoa(v))
+	}
+	if patch.StatusPollIdleSeconds != nil {
+		v := *patch.StatusPollIdleSeconds
+		if v < 1 {
+			return fmt.Errorf("status_poll_idle_seconds must be at least 1")
+		}
+		h.Cfg.StatusPollIdleSeconds = v
+		db.SaveSetting(h.DB, "status_poll_idle_seconds", strconv.Itoa(v))
+	}
+	if patch.DefaultGroupType != nil {
+		v := *patch.DefaultGroupType
+		if v == "all" {
+			v = ""
+		}
+		if !config.ValidGroupType(v) {
+			return fmt.Errorf("default_group_type must be \"image\", \"video\", \"document\", \"other\" or \"all\"")
+		}
+		h.Cfg.DefaultGroupType = v
+		// "" would not override the file on restart; "all" does.
+		stored := v
+		if stored == "" {
+			stored = "all"
+		}
+		db.SaveSetting(h.DB, "default_group_type", stored)
+	}
 	if patch.ScanWorkers != nil {
 		if patch.ScanWorkers.Walkers != nil {
 			h.Cfg.ScanWorkers.Walkers = *patch.ScanWorkers.Walkers
 			db.SaveSetting(h.DB, "walkers", strconv.Itoa(*patch.ScanWorkers.Walkers))
 		}
+		if patch.ScanWorkers.CacheCheckers != nil {
+			if *patch.ScanWorkers.CacheCheckers < 1 {
+				return fmt.Errorf("cache_checkers must be at least 1")
+			}
+			h.Cfg.ScanWorkers.CacheCheckers = *patch.ScanWorkers.CacheCheckers
+			db.SaveSetting(h.DB, "cache_checkers", strconv.Itoa(*patch.ScanWorkers.CacheCheckers))
+		}
 		if patch.ScanWorkers.PartialHashers != nil {
 			h.Cfg.ScanWorkers.PartialHashers = *patch.ScanWorkers.PartialHashers
 			db.SaveSetting(h.DB, "partial_hashers", strconv.Itoa(*patch.ScanWorkers.PartialHashers))
@@ -98,18 +198,43 @@ func (h *ConfigHandler) Apply(_ context.Context, patch ConfigPatch) error {
 
 	// Propagate updated roots/excludes/workers to the scan manager.
 	if h.Manager != nil {
-		scanCfg := scan.Config{
-			Walkers:        h.Cfg.ScanWorkers.Walkers,
-			PartialHashers: h.Cfg.ScanWorkers.PartialHashers,
-			FullHashers:    h.Cfg.ScanWorkers.FullHashers,
-			BatchSize:      1000,
-		}
-		h.Manager.UpdateConfig(h.Cfg.ScanPaths, h.Cfg.ExcludePaths, scanCfg)
+		h.Manager.UpdateConfig(h.Cfg.ScanPaths, h.Cfg.ExcludePaths, scanConfig(h.Cfg))
 	}
 
 	return nil
 }
 
+// scanConfig builds the scan.Config for cfg. Every runtime rebuild of the
+// scan manager's config must go through here so no tuning field is dropped
+// (ReadDB is carried forward by scan.Manager.UpdateConfig).
+func scanConfig(cfg *config.Config) scan.Config {
+	return scan.Config{
+		Walkers:           cfg.ScanWorkers.Walkers,
+		CacheCheckers:     cfg.ScanWorkers.CacheCheckers,
+		PartialHashers:    cfg.ScanWorkers.PartialHashers,
+		FullHashers:       cfg.ScanWorkers.FullHashers,
+		BatchSize:         1000,
+		CacheBatchSize:    cfg.CacheBatchSize,
+		PartialHashAlgo:   cfg.PartialHashAlgo,
+		MaxOpenFiles:      cfg.ScanWorkers.MaxOpenFiles,
+		SniffContentTypes: cfg.SniffContentTypes,
+		GroupByExtension:  cfg.GroupByExtension,
+
+		SkipRecentlyModified:      time.Duration(cfg.SkipRecentlyModifiedSeconds) * time.Second,
+		SizeTolerance:             cfg.SizeTolerancePercent / 100,
+		HeadBytes:                 cfg.CandidateHeadBytes,
+		CPUPercent:                cfg.ScanCPUPercent,
+		SkipPartialHashAbove:      cfg.PartialHashSkipAboveBytes,
+		ApproximateSampleBytes:    cfg.ApproximateSampleBytes,
+		MetadataOnly:              cfg.MetadataOnly,
+		SummarizePermissionErrors: cfg.SummarizePermissionErrors,
+		FileInventory:             cfg.FileInventory,
+		ReconcileVanishedGroups:   cfg.ReconcileVanishedGroups,
+		CacheFirst:                cfg.CacheFirstLookup,
+		QuarantineAfter:           cfg.HashQuarantineAfter,
+	}
+}
+
 // Update handles PATCH /api/config.
 func (h *ConfigHandler) Update(w http.ResponseWriter, r *http.Request) {
 	var patch ConfigPatch
@@ -123,7 +248,15 @@ func (h *ConfigHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	writeJSON(w, http.StatusOK, h.Cfg)
+	resp := struct {
+		*config.Config
+		Warning string `json:"warning,omitempty"`
+	}{Config: h.Cfg}
+	if patch.affectsScan() && h.Manager != nil && h.Manager.ActiveScan() != nil {
+		resp.Warning = NextScanMessage
+	}
+
+	h.Cfg.Lock()
+	defer h.Cfg.Unlock()
+	writeJSON(w, http.StatusOK, resp)
 }
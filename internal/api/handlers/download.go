@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// downloadManifest is the name of the text entry, written last, that lists
+// which group files the zip holds and why the others were left out.
+const downloadManifest = "MANIFEST.txt"
+
+// downloadName flattens path into a zip entry name ("/a/b/c.jpg" becomes
+// "a_b_c.jpg"), adding a counter when the name is already taken.
+func downloadName(path string, taken map[string]bool) string {
+	name := strings.ReplaceAll(strings.TrimLeft(path, "/"), "/", "_")
+	if name == "" {
+		name = "file"
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		ext := ""
+		if dot := strings.LastIndex(name, "."); dot > 0 {
+			ext = name[dot:]
+		}
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// Download handles GET /api/groups/:id/download — streams a zip of every
+// file in the group, each named after its path, for comparing them offline.
+// Files missing on disk, and files that would take the zip past
+// group_download_max_bytes, are left out and listed in MANIFEST.txt. Files
+// are stored uncompressed: most large duplicates are media that would not
+// shrink, and the stream stays cheap to produce. 413 DOWNLOAD_TOO_LARGE if
+// no file fits under the cap.
+func (h *GroupsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ID", "Invalid group ID")
+		return
+	}
+	var hash string
+	err = h.DB.QueryRowContext(r.Context(),
+		`SELECT content_hash FROM duplicate_groups WHERE id = ?`, groupID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	rows, err := h.DB.QueryContext(r.Context(),
+		`SELECT path FROM duplicate_files WHERE group_id = ? ORDER BY path`, groupID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		paths = append(paths, p)
+	}
+	rows.Close()
+
+	var maxBytes int64 = -1
+	if h.Cfg != nil {
+		maxBytes = h.Cfg.GroupDownloadMaxBytes
+	}
+
+	// Decide what goes in before the first byte is sent, so the cap can
+	// still be answered with an error.
+	type entry struct {
+		path, name string
+		info       os.FileInfo
+	}
+	var include []entry
+	var manifest []string
+	var total int64
+	taken := map[string]bool{downloadManifest: true}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		switch {
+		case err != nil:
+			manifest = append(manifest, fmt.Sprintf("omitted  %s: missing on disk (%v)", p, err))
+		case !info.Mode().IsRegular():
+			manifest = append(manifest, fmt.Sprintf("omitted  %s: not a regular file", p))
+		case maxBytes >= 0 && total+info.Size() > maxBytes:
+			manifest = append(manifest, fmt.Sprintf("omitted  %s: would exceed group_download_max_bytes (%d)", p, maxBytes))
+		default:
+			total += info.Size()
+			include = append(include, entry{path: p, name: downloadName(p, taken), info: info})
+		}
+	}
+	if len(include) == 0 {
+		if maxBytes >= 0 && len(paths) > 0 {
+			writeError(w, http.StatusRequestEntityTooLarge, "DOWNLOAD_TOO_LARGE",
+				fmt.Sprintf("no file of group %d fits under group_download_max_bytes (%d)", groupID, maxBytes))
+			return
+		}
+		writeError(w, http.StatusNotFound, "FILES_MISSING", "None of the group's files exist on disk")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="group-%d.zip"`, groupID))
+	zw := zip.NewWriter(w)
+	for _, e := range include {
+		line, err := addZipFile(zw, e.path, e.name, e.info)
+		if err != nil {
+			// The client went away; nothing more can be sent.
+			slog.Warn("group download: write", "group_id", groupID, "error", err)
+			return
+		}
+		manifest = append(manifest, line)
+	}
+
+	hdr := &zip.FileHeader{Name: downloadManifest, Method: zip.Deflate, Modified: time.Now()}
+	mw, err := zw.CreateHeader(hdr)
+	if err == nil {
+		fmt.Fprintf(mw, "ditto group %d\ncontent hash %s\n\n%s\n", groupID, hash, strings.Join(manifest, "\n"))
+		err = zw.Close()
+	}
+	if err != nil {
+		slog.Warn("group download: finish", "group_id", groupID, "error", err)
+	}
+}
+
+// addZipFile stores the file at path in zw as name and returns its manifest
+// line. A file that cannot be opened, or fails part way, is noted in the
+// line; only an error writing to zw is returned.
+func addZipFile(zw *zip.Writer, path, name string, info os.FileInfo) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Sprintf("omitted  %s: %v", path, err), nil
+	}
+	defer f.Close()
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Sprintf("omitted  %s: %v", path, err), nil
+	}
+	hdr.Name = name
+	hdr.Method = zip.Store
+	fw, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			return fmt.Sprintf("partial  %s -> %s: read failed (%v)", path, name, err), nil
+		}
+		return "", err
+	}
+	return fmt.Sprintf("included %s -> %s", path, name), nil
+}
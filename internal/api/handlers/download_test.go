@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadName(t *testing.T) {
+	taken := map[string]bool{downloadManifest: true}
+	for _, tt := range []struct{ path, want string }{
+		{"/a/b/c.jpg", "a_b_c.jpg"},
+		{"/a_b/c.jpg", "a_b_c-2.jpg"},
+		{"/a/b_c.jpg", "a_b_c-3.jpg"},
+		{"/MANIFEST.txt", "MANIFEST-2.txt"},
+		{"/", "file"},
+		{"/x/noext", "x_noext"},
+		{"/x_noext", "x_noext-2"},
+	} {
+		if got := downloadName(tt.path, taken); got != tt.want {
+			t.Errorf("downloadName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// readZip returns the entries of the zip in body, by name.
+func readZip(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open entry %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read entry %s: %v", f.Name, err)
+		}
+		entries[f.Name] = string(content)
+	}
+	return entries
+}
+
+// TestDownload verifies that the zip holds every file of the group under a
+// distinct name, even files sharing a basename or flattening to the same
+// name, and that MANIFEST.txt lists what was included and left out.
+func TestDownload(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	h.Cfg.GroupDownloadMaxBytes = -1
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a", "same.txt"),
+		filepath.Join(dir, "b", "same.txt"),
+		filepath.Join(dir, "c_d", "e.txt"),
+		filepath.Join(dir, "c", "d_e.txt"),
+		filepath.Join(dir, "gone", "f.txt"),
+	}
+	groupID, _ := mustInsertGroup(t, h.DB, "aaaa", paths...)
+	if err := os.Remove(paths[4]); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(groupRoutes(h), http.MethodGet, fmt.Sprintf("/api/groups/%d/download", groupID), "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status %d, content type %q, body %.200s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	entries := readZip(t, rec.Body.Bytes())
+	manifest, ok := entries[downloadManifest]
+	if !ok {
+		t.Fatalf("zip has no %s; entries %v", downloadManifest, entries)
+	}
+	delete(entries, downloadManifest)
+	if len(entries) != 4 {
+		t.Errorf("zip has %d files, want 4: %v", len(entries), entries)
+	}
+	for name, content := range entries {
+		if content != "duplicate content" {
+			t.Errorf("entry %s = %q, want the file's content", name, content)
+		}
+	}
+	for _, p := range paths[:4] {
+		if !strings.Contains(manifest, "included "+p+" -> ") {
+			t.Errorf("manifest does not list %s as included:\n%s", p, manifest)
+		}
+	}
+	if !strings.Contains(manifest, "omitted  "+paths[4]+": missing on disk") {
+		t.Errorf("manifest does not list %s as missing:\n%s", paths[4], manifest)
+	}
+}
+
+// TestDownloadMaxBytes verifies that files past group_download_max_bytes are
+// left out and listed in the manifest, and that 413 answers a group none of
+// whose files fit.
+func TestDownloadMaxBytes(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	groupID, _ := mustInsertGroup(t, h.DB, "aaaa", paths...)
+	target := fmt.Sprintf("/api/groups/%d/download", groupID)
+
+	h.Cfg.GroupDownloadMaxBytes = int64(len("duplicate content"))
+	rec := serve(groupRoutes(h), http.MethodGet, target, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %.200s", rec.Code, rec.Body)
+	}
+	entries := readZip(t, rec.Body.Bytes())
+	if len(entries) != 2 || !strings.Contains(entries[downloadManifest], "omitted  "+paths[1]+": would exceed") {
+		t.Errorf("entries %v, want one file and a manifest leaving out %s", entries, paths[1])
+	}
+
+	h.Cfg.GroupDownloadMaxBytes = 1
+	rec = serve(groupRoutes(h), http.MethodGet, target, "")
+	if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec.Body.Bytes()) != "DOWNLOAD_TOO_LARGE" {
+		t.Errorf("status %d, body %s; want 413 DOWNLOAD_TOO_LARGE", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// duplicateDirSet is a set of directories holding the same files.
+type duplicateDirSet struct {
+	// FileCount and TotalSize describe one directory of the set.
+	FileCount int   `json:"file_count"`
+	TotalSize int64 `json:"total_size"`
+	// ReclaimableBytes is what deleting all but one directory frees.
+	ReclaimableBytes int64    `json:"reclaimable_bytes"`
+	Dirs             []string `json:"dirs"`
+}
+
+// dirContents is what duplicate_files says about one directory.
+type dirContents struct {
+	hashes []string
+	size   int64
+}
+
+// DuplicateDirs handles GET /api/reports/duplicate-dirs — directories whose
+// files are copies of each other: every regular file directly inside them is
+// in an exact-hash duplicate group that is not gone, and their content hashes
+// form the same multiset.
+// Only each directory's own files are compared; subdirectories are compared
+// on their own. ?min_files=N (default 2) leaves out smaller directories.
+// Computed from duplicate_files; each candidate directory is listed once to
+// make sure it holds no file the groups do not know about. Sets are sorted
+// by reclaimable bytes, largest first, and paginated.
+func (h *ReportsHandler) DuplicateDirs(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+	minFiles := 2
+	if v := r.URL.Query().Get("min_files"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "min_files must be a positive integer")
+			return
+		}
+		minFiles = n
+	}
+
+	dirs, err := h.loadDirContents(r.Context())
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		slog.Error("reports duplicate-dirs: load files", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	sets, err := duplicateDirSets(r.Context(), dirs, minFiles)
+	if err != nil {
+		return // request cancelled
+	}
+
+	total := len(sets)
+	page := []duplicateDirSet{}
+	if offset < total {
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		page = sets[offset:end]
+	}
+	writeList(w, ListResponse[duplicateDirSet]{
+		Items:  page,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// loadDirContents reads the content hash and size of every file in an
+// exact-hash group, keyed by the directory it is in. Approximate and
+// metadata-only groups do not prove their files are copies, and gone groups
+// no longer describe the disk, so their files are left out.
+func (h *ReportsHandler) loadDirContents(ctx context.Context) (map[string]*dirContents, error) {
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT d.path, g.content_hash, g.file_size
+		FROM duplicate_files d
+		JOIN duplicate_groups g ON g.id = d.group_id
+		WHERE g.status != 'gone'
+		  AND g.content_hash NOT LIKE ? AND g.content_hash NOT LIKE ?`,
+		scan.ApproximateHashPrefix+"%", scan.MetadataHashPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dirs := make(map[string]*dirContents)
+	for rows.Next() {
+		var path, hash string
+		var size int64
+		if err := rows.Scan(&path, &hash, &size); err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(path)
+		dc := dirs[dir]
+		if dc == nil {
+			dc = &dirContents{}
+			dirs[dir] = dc
+		}
+		dc.hashes = append(dc.hashes, hash)
+		dc.size += size
+	}
+	return dirs, rows.Err()
+}
+
+// duplicateDirSets groups the directories with at least minFiles files by
+// the sorted list of their content hashes, then drops directories holding
+// regular files that are in no group — they are not complete copies. Only
+// sets left with two or more directories are returned.
+func duplicateDirSets(ctx context.Context, dirs map[string]*dirContents, minFiles int) ([]duplicateDirSet, error) {
+	bySignature := make(map[string][]string)
+	for dir, dc := range dirs {
+		if len(dc.hashes) < minFiles {
+			continue
+		}
+		sort.Strings(dc.hashes)
+		sig := strings.Join(dc.hashes, "\n")
+		bySignature[sig] = append(bySignature[sig], dir)
+	}
+
+	sets := []duplicateDirSet{}
+	for _, candidates := range bySignature {
+		if len(candidates) < 2 {
+			continue
+		}
+		var complete []string
+		for _, dir := range candidates {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if regularFileCount(dir) == len(dirs[dir].hashes) {
+				complete = append(complete, dir)
+			}
+		}
+		if len(complete) < 2 {
+			continue
+		}
+		sort.Strings(complete)
+		dc := dirs[complete[0]]
+		sets = append(sets, duplicateDirSet{
+			FileCount:        len(dc.hashes),
+			TotalSize:        dc.size,
+			ReclaimableBytes: dc.size * int64(len(complete)-1),
+			Dirs:             complete,
+		})
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].ReclaimableBytes != sets[j].ReclaimableBytes {
+			return sets[i].ReclaimableBytes > sets[j].ReclaimableBytes
+		}
+		return sets[i].Dirs[0] < sets[j].Dirs[0]
+	})
+	return sets, nil
+}
+
+// regularFileCount returns the number of regular files directly in dir, or
+// -1 when it cannot be listed.
+func regularFileCount(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Debug("reports duplicate-dirs: list dir", "dir", dir, "error", err)
+		return -1
+	}
+	n := 0
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// TestDuplicateDirsExactGroupsOnly verifies that only exact-hash groups that
+// are not gone make directories copies of each other: directories matched
+// through an approximate, metadata-only or gone group are not reported.
+func TestDuplicateDirsExactGroupsOnly(t *testing.T) {
+	db := mustOpenDB(t)
+	h := &ReportsHandler{DB: db, Cfg: mustDefaultConfig(t)}
+	dir := t.TempDir()
+	in := func(sub, name string) string { return filepath.Join(dir, sub, name) }
+
+	mustInsertGroup(t, db, "h1", in("a", "f1"), in("b", "f1"))
+	mustInsertGroup(t, db, "h2", in("a", "f2"), in("b", "f2"))
+	mustInsertGroup(t, db, "approx:h3", in("c", "f"), in("d", "f"))
+	mustInsertGroup(t, db, "meta:h4", in("e", "f"), in("f", "f"))
+	gone, _ := mustInsertGroup(t, db, "h5", in("g", "f"), in("h", "f"))
+	if _, err := db.Exec(`UPDATE duplicate_groups SET status = 'gone' WHERE id = ?`, gone); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(http.HandlerFunc(h.DuplicateDirs), http.MethodGet, "/api/reports/duplicate-dirs?min_files=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp ListResponse[duplicateDirSet]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var got []string
+	for _, set := range resp.Items {
+		got = append(got, fmt.Sprint(set.Dirs))
+	}
+	want := fmt.Sprint([]string{in("a", ""), in("b", "")})
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("sets = %v, want only %s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// estimateBasisScans is how many recent completed scans the estimate uses.
+const estimateBasisScans = 5
+
+type estimateBasis struct {
+	ScanID          int64   `json:"scan_id"`
+	StartedAt       string  `json:"started_at"`
+	FilesDiscovered int64   `json:"files_discovered"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	CacheHitRate    float64 `json:"cache_hit_rate"`
+	SecondsPerFile  float64 `json:"seconds_per_file"`
+}
+
+type scanEstimate struct {
+	// EstimatedSeconds is nil when no completed scan can serve as a basis.
+	EstimatedSeconds     *int64          `json:"estimated_seconds"`
+	ExpectedFiles        int64           `json:"expected_files"`
+	ExpectedCacheHitRate float64         `json:"expected_cache_hit_rate"`
+	SecondsPerFile       float64         `json:"seconds_per_file"`
+	ConfigChanged        bool            `json:"config_changed"`
+	Basis                []estimateBasis `json:"basis"`
+}
+
+// Estimate handles GET /api/scans/estimate — a rough duration for a scan
+// started now, from the last few completed scans. Each basis scan gives a
+// cost per discovered file and a cache hit rate; when the hit rates differ,
+// cost is fitted as a straight line over the miss rate, otherwise averaged.
+// The estimate is that cost at the most recent scan's hit rate, times the
+// files it discovered. config_changed is set when the scan roots or excludes
+// differ from that scan's, in which case the file count may be far off.
+func (h *ScansHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT id, started_at, files_discovered, duration_seconds,
+		       cache_hits, cache_misses, config_snapshot
+		FROM scan_history
+		WHERE status = 'completed' AND files_discovered > 0 AND duration_seconds IS NOT NULL
+		ORDER BY started_at DESC
+		LIMIT ?`, estimateBasisScans)
+	if err != nil {
+		slog.Error("scans estimate: query", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	est := scanEstimate{Basis: []estimateBasis{}}
+	var latestSnapshot sql.NullString
+	for rows.Next() {
+		var b estimateBasis
+		var startedAt, hits, misses int64
+		var snapshot sql.NullString
+		if err := rows.Scan(&b.ScanID, &startedAt, &b.FilesDiscovered, &b.DurationSeconds,
+			&hits, &misses, &snapshot); err != nil {
+			continue
+		}
+		b.StartedAt = time.Unix(startedAt, 0).UTC().Format(time.RFC3339)
+		if hits+misses > 0 {
+			b.CacheHitRate = float64(hits) / float64(hits+misses)
+		}
+		b.SecondsPerFile = float64(b.DurationSeconds) / float64(b.FilesDiscovered)
+		if len(est.Basis) == 0 {
+			latestSnapshot = snapshot
+		}
+		est.Basis = append(est.Basis, b)
+	}
+	rows.Close()
+
+	if len(est.Basis) == 0 {
+		writeJSON(w, http.StatusOK, est)
+		return
+	}
+	latest := est.Basis[0]
+	est.ExpectedFiles = latest.FilesDiscovered
+	est.ExpectedCacheHitRate = latest.CacheHitRate
+	est.SecondsPerFile = secondsPerFileAt(est.Basis, 1-latest.CacheHitRate)
+	seconds := int64(est.SecondsPerFile*float64(est.ExpectedFiles) + 0.5)
+	est.EstimatedSeconds = &seconds
+
+	if h.Manager != nil && latestSnapshot.Valid {
+		var snap scan.ConfigSnapshot
+		if json.Unmarshal([]byte(latestSnapshot.String), &snap) == nil {
+			roots, excludes := h.Manager.Paths()
+			est.ConfigChanged = !slices.Equal(roots, snap.ScanPaths) || !slices.Equal(excludes, snap.ExcludePaths)
+		}
+	}
+	writeJSON(w, http.StatusOK, est)
+}
+
+// secondsPerFileAt predicts the cost per file at the given cache miss rate by
+// a least-squares line through the basis scans' (miss rate, cost) points. It
+// falls back to the mean cost when the miss rates barely differ or the fit
+// would make misses cheaper than hits.
+func secondsPerFileAt(basis []estimateBasis, missRate float64) float64 {
+	n := float64(len(basis))
+	var sumX, sumY, sumXX, sumXY float64
+	for _, b := range basis {
+		x := 1 - b.CacheHitRate
+		sumX += x
+		sumY += b.SecondsPerFile
+		sumXX += x * x
+		sumXY += x * b.SecondsPerFile
+	}
+	mean := sumY / n
+	denom := n*sumXX - sumX*sumX
+	if denom < 1e-9 {
+		return mean
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	if slope <= 0 {
+		return mean
+	}
+	intercept := (sumY - slope*sumX) / n
+	return max(intercept+slope*missRate, 0)
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -18,6 +20,9 @@ import (
 // FilesHandler handles file-level API endpoints.
 type FilesHandler struct {
 	DB *sql.DB
+	// MaxPreviewBytes refuses non-range previews of larger files (0 = no cap).
+	MaxPreviewBytes int64
+	thumbs          thumbCache // file thumbnails by content hash
 }
 
 // fileInfoResponse is returned by GET /api/files/{id}/info.
@@ -66,7 +71,19 @@ func (h *FilesHandler) Info(w http.ResponseWriter, r *http.Request) {
 		FileType: fileType,
 	}
 
-	if fileType == string(media.FileTypeImage) {
+	// Extensionless / unknown files: classify lazily by their leading bytes.
+	if fileType == string(media.FileTypeOther) || resp.MimeType == "application/octet-stream" {
+		if ft, ct := media.Sniff(path); ct != "" {
+			if fileType == string(media.FileTypeOther) {
+				resp.FileType = string(ft)
+			}
+			if resp.MimeType == "application/octet-stream" {
+				resp.MimeType = ct
+			}
+		}
+	}
+
+	if resp.FileType == string(media.FileTypeImage) {
 		meta := media.ExtractImageMeta(path)
 		resp.Image = &meta
 	}
@@ -83,11 +100,12 @@ func (h *FilesHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var path string
-	var fileType string
-	err = h.DB.QueryRowContext(r.Context(),
-		`SELECT path, file_type FROM duplicate_files WHERE id = ?`, id,
-	).Scan(&path, &fileType)
+	var path, fileType, hash string
+	err = h.DB.QueryRowContext(r.Context(), `
+		SELECT f.path, f.file_type, g.content_hash
+		FROM duplicate_files f JOIN duplicate_groups g ON g.id = f.group_id
+		WHERE f.id = ?`, id,
+	).Scan(&path, &fileType, &hash)
 	if errors.Is(err, sql.ErrNoRows) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "file not found or not previewable")
 		return
@@ -103,7 +121,7 @@ func (h *FilesHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	thumb, err := media.Thumbnail(path, 320, 320)
+	thumb, err := h.thumbnail(hash, path)
 	if err != nil {
 		slog.Error("files thumbnail: generate", "id", id, "path", path, "error", err)
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "thumbnail generation failed")
@@ -122,6 +140,9 @@ func (h *FilesHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 
 // Preview handles GET /api/files/:id/preview.
 // Serves the original file with the correct Content-Type for lightbox use.
+// Range requests are honoured (http.ServeFile), so video players can stream;
+// a file above MaxPreviewBytes is refused with 403 unless the request asks
+// for a range. ?download=1 serves it as an attachment named after the file.
 func (h *FilesHandler) Preview(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -144,13 +165,29 @@ func (h *FilesHandler) Preview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify file still exists on disk.
-	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+	info, statErr := os.Stat(path)
+	if os.IsNotExist(statErr) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "file not found or not previewable")
 		return
 	}
+	if statErr == nil && h.MaxPreviewBytes > 0 && info.Size() > h.MaxPreviewBytes &&
+		r.Header.Get("Range") == "" {
+		writeError(w, http.StatusForbidden, "PREVIEW_TOO_LARGE",
+			fmt.Sprintf("file is %d bytes, above max_preview_bytes (%d); request a byte range instead", info.Size(), h.MaxPreviewBytes))
+		return
+	}
 
 	ct := media.ContentType(path)
+	if ct == "application/octet-stream" {
+		if _, sniffed := media.Sniff(path); sniffed != "" {
+			ct = sniffed
+		}
+	}
 	w.Header().Set("Content-Type", ct)
 	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)}))
+	}
 	http.ServeFile(w, r, path)
 }
@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -26,7 +31,11 @@ type GroupsHandler struct {
 	Trash   *trash.Manager
 	Cfg     *config.Config
 	ScanMgr *scan.Manager
-	mu      sync.Mutex // guards Cfg mutations for dir-type ignore
+	thumbs  thumbCache // group thumbnails by content hash
+	// beforeMove, when set, is called with each file's path right before
+	// trashGroupFiles re-stats and moves it; tests use it to change the file
+	// after validation.
+	beforeMove func(path string)
 }
 
 type groupItem struct {
@@ -37,24 +46,164 @@ type groupItem struct {
 	ReclaimableBytes int64   `json:"reclaimable_bytes"`
 	FileType         string  `json:"file_type"`
 	Status           string  `json:"status"`
+	Pinned           bool    `json:"pinned"`
+	// Approximate is set for groups found by an approximate scan; they
+	// cannot be deleted until an exact scan confirms them.
+	Approximate      bool    `json:"approximate"`
+	// MetadataOnly is set for groups found by a metadata-only scan (same
+	// name, size and mtime); they cannot be deleted until a content scan
+	// confirms them.
+	MetadataOnly     bool    `json:"metadata_only"`
+	// Incomplete is set when the last scan could not fully hash a file of
+	// this size and partial hash, which may be a missing copy: FileCount
+	// and ReclaimableBytes may understate.
+	Incomplete       bool    `json:"incomplete"`
+	// LastVerifiedAt is when the files were last confirmed against disk (by
+	// a scan or a delete's pre-check); nil if never.
+	LastVerifiedAt   *string `json:"last_verified_at"`
 	ThumbnailURL     string  `json:"thumbnail_url"`
 	CreatedAt        string  `json:"created_at"`
 	UpdatedAt        string  `json:"updated_at"`
 }
 
-// List handles GET /api/groups.
-// Default filter (no status param, or status=active) returns unresolved and watching_alert groups.
-func (h *GroupsHandler) List(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	status := q.Get("status")
-	fileType := q.Get("type")
-	limit, offset := parsePagination(r)
+// excludeDir adds dir to the in-memory exclude paths so a dir-type ignore
+// takes effect on the next scan. A dir already excluded is not added again.
+func (h *GroupsHandler) excludeDir(dir string) {
+	if h.Cfg == nil || h.ScanMgr == nil {
+		return
+	}
+	h.Cfg.Lock()
+	if slices.Contains(h.Cfg.ExcludePaths, dir) {
+		h.Cfg.Unlock()
+		return
+	}
+	h.Cfg.ExcludePaths = append(h.Cfg.ExcludePaths, dir)
+	excludes := append([]string{}, h.Cfg.ExcludePaths...)
+	scanPaths := append([]string{}, h.Cfg.ScanPaths...)
+	scanCfg := scanConfig(h.Cfg)
+	h.Cfg.Unlock()
+	h.ScanMgr.UpdateConfig(scanPaths, excludes, scanCfg)
+}
+
+// IgnoreGroupsUnderDir marks the active (unresolved or watching_alert)
+// groups whose files all lie under dir as ignored and returns their IDs, so a
+// dir-type ignore also covers the groups found before it
+// (ignore_dir_existing_groups). Pinned groups are left alone. The groups are
+// selected and updated in one transaction.
+func IgnoreGroupsUnderDir(ctx context.Context, database *sql.DB, dir string) ([]int64, error) {
+	dir = filepath.Clean(dir)
+	under := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM duplicate_groups
+		WHERE status IN ('unresolved','watching_alert') AND `+pinnedColumn+` = 0
+		  AND NOT EXISTS (SELECT 1 FROM duplicate_files d
+		                  WHERE d.group_id = duplicate_groups.id
+		                    AND d.path != ? AND substr(d.path, 1, length(?)) != ?)`,
+		dir, under, under)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	now := time.Now().Unix()
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE duplicate_groups SET status='ignored', ignored_at=?, updated_at=? WHERE id=?`,
+			now, now, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ignoreExistingUnderDir applies IgnoreGroupsUnderDir for a dir-type ignore
+// when ignore_dir_existing_groups is set, recording each group in its
+// history. It returns the IDs of the groups it ignored.
+func (h *GroupsHandler) ignoreExistingUnderDir(r *http.Request, dir string) []int64 {
+	ids := []int64{}
+	if h.Cfg == nil || !h.Cfg.IgnoreDirExistingGroups {
+		return ids
+	}
+	ignored, err := IgnoreGroupsUnderDir(r.Context(), h.DB, dir)
+	if err != nil {
+		slog.Error("group ignore: ignore existing groups under dir", "dir", dir, "error", err)
+	}
+	for _, id := range ignored {
+		h.audit(r, id, "ignore", map[string]interface{}{
+			"type":   "dir",
+			"value":  dir,
+			"status": "ignored",
+		})
+	}
+	return append(ids, ignored...)
+}
+
+// pinnedColumn selects a duplicate_groups row's pinned flag, which lives in
+// group_overrides so it survives rescans.
+const pinnedColumn = `COALESCE((SELECT o.pinned FROM group_overrides o
+		         WHERE o.content_hash = duplicate_groups.content_hash), 0)`
+
+// groupPinned reports whether the group is pinned, i.e. excluded from bulk
+// operations.
+func (h *GroupsHandler) groupPinned(ctx context.Context, groupID int64) (bool, error) {
+	var pinned bool
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT `+pinnedColumn+` FROM duplicate_groups WHERE id = ?`, groupID,
+	).Scan(&pinned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errGroupNotFound
+	}
+	return pinned, err
+}
+
+// groupActive reports whether the group is unresolved or watching_alert, the
+// statuses bulk operations act on.
+func (h *GroupsHandler) groupActive(ctx context.Context, groupID int64) (bool, error) {
+	var status string
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT status FROM duplicate_groups WHERE id = ?`, groupID,
+	).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errGroupNotFound
+	}
+	return status == "unresolved" || status == "watching_alert", err
+}
+
+// errRelativePrefix is returned by groupFilter for a relative path_prefix.
+var errRelativePrefix = errors.New("path_prefix must be an absolute path")
+
+// groupFilter returns the " AND …" conditions on duplicate_groups, and their
+// arguments, for the status, type and path_prefix filters of GET
+// /api/groups. status "" or "active" selects unresolved and watching_alert
+// groups, "all" every group; path_prefix keeps groups with at least one file
+// under that directory.
+func groupFilter(status, fileType, prefix string) (string, []interface{}, error) {
 	args := []interface{}{}
 	where := ""
 
-	useActiveFilter := status == "" || status == "active"
-	if useActiveFilter {
+	if status == "" || status == "active" {
 		where += " AND status IN ('unresolved','watching_alert')"
 	} else if status != "all" {
 		where += " AND status = ?"
@@ -64,21 +213,131 @@ func (h *GroupsHandler) List(w http.ResponseWriter, r *http.Request) {
 		where += " AND file_type = ?"
 		args = append(args, fileType)
 	}
+	if prefix != "" {
+		if !filepath.IsAbs(prefix) {
+			return "", nil, errRelativePrefix
+		}
+		prefix = filepath.Clean(prefix)
+		under := strings.TrimSuffix(prefix, string(filepath.Separator)) + string(filepath.Separator)
+		where += ` AND EXISTS (SELECT 1 FROM duplicate_files d
+		                WHERE d.group_id = duplicate_groups.id
+		                  AND (d.path = ? OR substr(d.path, 1, length(?)) = ?))`
+		args = append(args, prefix, under, under)
+	}
+	return where, args, nil
+}
+
+// groupSortColumns maps the sort values accepted by GET /api/groups and the
+// groups page to duplicate_groups columns; the short names are the original
+// aliases. Only these columns ever reach an ORDER BY.
+var groupSortColumns = map[string]string{
+	"reclaimable_bytes": "reclaimable_bytes",
+	"reclaimable":       "reclaimable_bytes",
+	"file_size":         "file_size",
+	"size":              "file_size",
+	"file_count":        "file_count",
+	"count":             "file_count",
+	"created_at":        "created_at",
+	"updated_at":        "updated_at",
+	"newest":            "updated_at",
+}
+
+// errInvalidSort is returned by GroupOrderBy for a value outside the allowlist.
+var errInvalidSort = errors.New("sort must be reclaimable_bytes, file_size, file_count, created_at or updated_at, and order asc or desc")
+
+// GroupOrderBy returns the ORDER BY clause for listing groups by sortKey
+// ("" = reclaimable_bytes) in order "asc" or "desc" ("" = desc), and the
+// column it resolved sortKey to. Ties fall back to the group ID so pages do
+// not overlap.
+func GroupOrderBy(sortKey, order string) (column, orderBy string, err error) {
+	column = "reclaimable_bytes"
+	if sortKey != "" {
+		var ok bool
+		if column, ok = groupSortColumns[sortKey]; !ok {
+			return "", "", errInvalidSort
+		}
+	}
+	dir := "DESC"
+	switch order {
+	case "", "desc":
+	case "asc":
+		dir = "ASC"
+	default:
+		return "", "", errInvalidSort
+	}
+	return column, column + " " + dir + ", id " + dir, nil
+}
+
+// List handles GET /api/groups.
+// Default filter (no status param, or status=active) returns unresolved and watching_alert groups.
+// min_reclaimable defaults to the min_reclaimable_bytes setting; 0 lifts it.
+// path_prefix=/abs/dir keeps groups with at least one file under that
+// directory; cross_root=true keeps only groups whose files span two or more
+// scan roots; incomplete=true keeps only groups that may be missing a copy
+// (see groupItem.Incomplete). after=<id> switches to keyset pagination by
+// group ID (see parseCursor). Without a type param the default_group_type
+// setting applies; type=all lifts it.
+func (h *GroupsHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	fileType := q.Get("type")
+	if !q.Has("type") && h.Cfg != nil {
+		h.Cfg.Lock()
+		fileType = h.Cfg.DefaultGroupType
+		h.Cfg.Unlock()
+	}
+	if fileType == "all" {
+		fileType = ""
+	}
+	limit, offset := parsePagination(r)
+	after, cursor, err := parseCursor(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	where, args, err := groupFilter(status, fileType, q.Get("path_prefix"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PATH", err.Error())
+		return
+	}
+	var minReclaimable int64
+	if h.Cfg != nil {
+		minReclaimable = h.Cfg.MinReclaimableBytes
+	}
 	if minR := q.Get("min_reclaimable"); minR != "" {
 		if v, err := strconv.ParseInt(minR, 10, 64); err == nil {
-			where += " AND reclaimable_bytes >= ?"
-			args = append(args, v)
+			minReclaimable = v
 		}
 	}
-
-	sortOrders := map[string]string{
-		"size": "file_size DESC", "count": "file_count DESC", "newest": "updated_at DESC",
+	if minReclaimable > 0 {
+		where += " AND reclaimable_bytes >= ?"
+		args = append(args, minReclaimable)
+	}
+	if q.Get("incomplete") == "true" {
+		where += " AND incomplete = 1"
 	}
-	orderBy := "reclaimable_bytes DESC"
-	if s := q.Get("sort"); s != "" {
-		if mapped, ok := sortOrders[s]; ok {
-			orderBy = mapped
+	if q.Get("cross_root") == "true" {
+		var roots []string
+		if h.Cfg != nil {
+			h.Cfg.Lock()
+			roots = append(roots, h.Cfg.ScanPaths...)
+			h.Cfg.Unlock()
 		}
+		rootExpr, rootArgs := scanRootExpr(roots)
+		where += ` AND (SELECT COUNT(DISTINCT ` + rootExpr + `) FROM duplicate_files d
+		                WHERE d.group_id = duplicate_groups.id) >= 2`
+		args = append(args, rootArgs...)
+	}
+
+	_, orderBy, err := GroupOrderBy(q.Get("sort"), q.Get("order"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+	if cursor && (q.Get("sort") != "" || q.Get("order") != "") {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "after cannot be combined with sort or order")
+		return
 	}
 
 	countArgs := append([]interface{}{}, args...)
@@ -88,10 +347,19 @@ func (h *GroupsHandler) List(w http.ResponseWriter, r *http.Request) {
 		countArgs...,
 	).Scan(&total)
 
-	queryArgs := append(args, limit, offset)
+	// A cursor page walks the primary key and reads one extra row to learn
+	// whether another page follows.
+	pageLimit := limit
+	if cursor {
+		where += " AND id > ?"
+		args = append(args, after)
+		orderBy = "id ASC"
+		pageLimit = limit + 1
+	}
+	queryArgs := append(args, pageLimit, offset)
 	rows, err := h.DB.QueryContext(r.Context(), `
 		SELECT id, content_hash, file_size, file_count, reclaimable_bytes,
-		       file_type, status, created_at, updated_at
+		       file_type, status, `+pinnedColumn+`, incomplete, created_at, updated_at, last_verified_at
 		FROM duplicate_groups
 		WHERE 1=1`+where+`
 		ORDER BY `+orderBy+`
@@ -107,29 +375,64 @@ func (h *GroupsHandler) List(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var g groupItem
 		var createdAt, updatedAt int64
+		var verifiedAt sql.NullInt64
 		if err := rows.Scan(
 			&g.ID, &g.ContentHash, &g.FileSize, &g.FileCount,
-			&g.ReclaimableBytes, &g.FileType, &g.Status,
-			&createdAt, &updatedAt,
+			&g.ReclaimableBytes, &g.FileType, &g.Status, &g.Pinned, &g.Incomplete,
+			&createdAt, &updatedAt, &verifiedAt,
 		); err != nil {
 			slog.Error("groups list: scan row", "error", err)
 			continue
 		}
 		g.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
 		g.UpdatedAt = time.Unix(updatedAt, 0).UTC().Format(time.RFC3339)
+		g.Approximate = scan.IsApproximateHash(g.ContentHash)
+		g.MetadataOnly = scan.IsMetadataHash(g.ContentHash)
+		if verifiedAt.Valid {
+			v := time.Unix(verifiedAt.Int64, 0).UTC().Format(time.RFC3339)
+			g.LastVerifiedAt = &v
+		}
 		g.ThumbnailURL = "/api/groups/" + strconv.FormatInt(g.ID, 10) + "/thumbnail"
 		items = append(items, g)
 	}
 
-	writeJSON(w, http.StatusOK, ListResponse[groupItem]{
-		Items:  items,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
+	var next *int64
+	if cursor && len(items) > limit {
+		items = items[:limit]
+		next = &items[limit-1].ID
+	}
+	writeList(w, ListResponse[groupItem]{
+		Items:      items,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		NextCursor: next,
 	})
 }
 
-// Get handles GET /api/groups/:id.
+// scanRootExpr returns an SQL expression mapping d.path to the index of the
+// scan root it lies under, or NULL when it is under none. Roots are tried
+// longest first so a nested root wins over its parent.
+func scanRootExpr(roots []string) (string, []interface{}) {
+	sorted := append([]string{}, roots...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	expr := "CASE"
+	args := []interface{}{}
+	for i, root := range sorted {
+		root = filepath.Clean(root)
+		prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+		expr += fmt.Sprintf(" WHEN d.path = ? OR substr(d.path, 1, length(?)) = ? THEN %d", i)
+		args = append(args, root, prefix, prefix)
+	}
+	if len(sorted) == 0 {
+		return "NULL", args
+	}
+	return expr + " END", args
+}
+
+// Get handles GET /api/groups/:id. The group's files are paginated with
+// ?limit= (default 50, max 200) and ?offset=; files_total gives the full count.
 func (h *GroupsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -137,51 +440,135 @@ func (h *GroupsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limit, offset := parsePagination(r)
+	detail, err := h.loadGroupDetail(r.Context(), id, limit, offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+		return
+	}
+	if err != nil {
+		slog.Error("groups get", "group_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// Find handles GET /api/groups/find?path=/abs/path — the reverse lookup of a
+// file path to the duplicate group containing it, with its siblings paginated
+// as for Get.
+func (h *GroupsHandler) Find(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" || !filepath.IsAbs(path) {
+		writeError(w, http.StatusBadRequest, "INVALID_PATH", "path must be an absolute file path")
+		return
+	}
+	path = filepath.Clean(path)
+
+	// Served by idx_dup_files_path.
+	var groupID int64
+	err := h.DB.QueryRowContext(r.Context(),
+		`SELECT group_id FROM duplicate_files WHERE path = ? LIMIT 1`, path,
+	).Scan(&groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No group contains this path")
+		return
+	}
+	if err != nil {
+		slog.Error("groups find: query", "path", path, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	detail, err := h.loadGroupDetail(r.Context(), groupID, limit, offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No group contains this path")
+		return
+	}
+	if err != nil {
+		slog.Error("groups find", "group_id", groupID, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+type groupFileItem struct {
+	ID           int64  `json:"id"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	MTime        string `json:"mtime"`
+	FileType     string `json:"file_type"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	PreviewURL   string `json:"preview_url"`
+	// SuggestedKeeper marks the one file of the group the keeper_heuristic
+	// setting would keep; computed over all files, not just this page.
+	SuggestedKeeper bool `json:"suggested_keeper"`
+}
+
+type groupDetail struct {
+	groupItem
+	Files []groupFileItem `json:"files"`
+	// FilesTotal is the number of files in the group; Files holds the page
+	// selected by FilesLimit / FilesOffset.
+	FilesTotal  int `json:"files_total"`
+	FilesLimit  int `json:"files_limit"`
+	FilesOffset int `json:"files_offset"`
+}
+
+// loadGroupDetail reads a group and one page of its files, ordered by path.
+// Returns sql.ErrNoRows when the group does not exist.
+func (h *GroupsHandler) loadGroupDetail(ctx context.Context, id int64, limit, offset int) (*groupDetail, error) {
 	var g groupItem
 	var createdAt, updatedAt int64
-	err = h.DB.QueryRowContext(r.Context(), `
+	var verifiedAt sql.NullInt64
+	err := h.DB.QueryRowContext(ctx, `
 		SELECT id, content_hash, file_size, file_count, reclaimable_bytes,
-		       file_type, status, created_at, updated_at
+		       file_type, status, `+pinnedColumn+`, incomplete, created_at, updated_at, last_verified_at
 		FROM duplicate_groups WHERE id = ?`, id,
 	).Scan(
 		&g.ID, &g.ContentHash, &g.FileSize, &g.FileCount,
-		&g.ReclaimableBytes, &g.FileType, &g.Status,
-		&createdAt, &updatedAt,
+		&g.ReclaimableBytes, &g.FileType, &g.Status, &g.Pinned, &g.Incomplete,
+		&createdAt, &updatedAt, &verifiedAt,
 	)
-	if errors.Is(err, sql.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
-		return
-	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, err
 	}
 	g.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
 	g.UpdatedAt = time.Unix(updatedAt, 0).UTC().Format(time.RFC3339)
+	g.Approximate = scan.IsApproximateHash(g.ContentHash)
+	g.MetadataOnly = scan.IsMetadataHash(g.ContentHash)
+	if verifiedAt.Valid {
+		v := time.Unix(verifiedAt.Int64, 0).UTC().Format(time.RFC3339)
+		g.LastVerifiedAt = &v
+	}
+
+	var total int
+	if err := h.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM duplicate_files WHERE group_id = ?`, id,
+	).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
 
-	type fileItem struct {
-		ID           int64  `json:"id"`
-		Path         string `json:"path"`
-		Size         int64  `json:"size"`
-		MTime        string `json:"mtime"`
-		FileType     string `json:"file_type"`
-		ThumbnailURL string `json:"thumbnail_url"`
-		PreviewURL   string `json:"preview_url"`
+	keeperID, err := h.suggestedKeeper(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("suggest keeper: %w", err)
 	}
-	fileRows, err := h.DB.QueryContext(r.Context(), `
+
+	fileRows, err := h.DB.QueryContext(ctx, `
 		SELECT id, path, size, mtime, file_type
 		FROM duplicate_files WHERE group_id = ?
-		ORDER BY path`, id)
+		ORDER BY path
+		LIMIT ? OFFSET ?`, id, limit, offset)
 	if err != nil {
-		slog.Error("groups get: query files", "error", err)
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, fmt.Errorf("query files: %w", err)
 	}
 	defer fileRows.Close()
 
-	var files []fileItem
+	files := []groupFileItem{}
 	for fileRows.Next() {
-		var f fileItem
+		var f groupFileItem
 		var mtime int64
 		if err := fileRows.Scan(&f.ID, &f.Path, &f.Size, &mtime, &f.FileType); err != nil {
 			continue
@@ -190,22 +577,25 @@ func (h *GroupsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		fid := strconv.FormatInt(f.ID, 10)
 		f.ThumbnailURL = "/api/files/" + fid + "/thumbnail"
 		f.PreviewURL = "/api/files/" + fid + "/preview"
+		f.SuggestedKeeper = f.ID == keeperID
 		files = append(files, f)
 	}
-	if files == nil {
-		files = []fileItem{}
-	}
-
-	type groupDetail struct {
-		groupItem
-		Files []fileItem `json:"files"`
-	}
-	writeJSON(w, http.StatusOK, groupDetail{groupItem: g, Files: files})
+	return &groupDetail{
+		groupItem:   g,
+		Files:       files,
+		FilesTotal:  total,
+		FilesLimit:  limit,
+		FilesOffset: offset,
+	}, nil
 }
 
 // Delete handles POST /api/groups/:id/delete.
 // Validates files have not changed since last scan, then moves selected files to trash.
 func (h *GroupsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if h.Cfg != nil && h.Cfg.ReadOnly {
+		writeReadOnly(w)
+		return
+	}
 	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "INVALID_ID", "Invalid group ID")
@@ -214,73 +604,269 @@ func (h *GroupsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
 		DeleteFileIDs []int64 `json:"delete_file_ids"`
+		// Mode is "trash" (default) or "archive".
+		Mode string `json:"mode"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.DeleteFileIDs) == 0 {
 		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "delete_file_ids is required and must be non-empty")
 		return
 	}
-
-	// Load group metadata.
-	var contentHash string
-	var fileSize int64
-	err = h.DB.QueryRowContext(r.Context(),
-		`SELECT content_hash, file_size FROM duplicate_groups WHERE id = ?`, groupID,
-	).Scan(&contentHash, &fileSize)
-	if errors.Is(err, sql.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+	if body.Mode == "" {
+		body.Mode = modeTrash
+	}
+	if body.Mode != modeTrash && body.Mode != modeArchive {
+		writeError(w, http.StatusBadRequest, "INVALID_MODE", "mode must be 'trash' or 'archive'")
 		return
 	}
+	if body.Mode == modeArchive && !h.Trash.ArchiveEnabled() {
+		writeError(w, http.StatusBadRequest, "ARCHIVE_DISABLED", "archive_dir is not configured")
+		return
+	}
+
+	res, err := h.trashGroupFiles(r.Context(), groupID, body.DeleteFileIDs, body.Mode)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeTrashGroupError(w, err)
 		return
 	}
+	h.audit(r, groupID, "delete", map[string]interface{}{
+		"mode":    body.Mode,
+		"trashed": res.Trashed,
+		"skipped": res.Skipped,
+		"status":  res.Status,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"trashed": res.Trashed,
+		"skipped": res.Skipped,
+		"group": map[string]interface{}{
+			"id":                groupID,
+			"file_count":        res.FileCount,
+			"reclaimable_bytes": res.ReclaimableBytes,
+			"status":            res.Status,
+		},
+	})
+}
 
-	// Load all files in the group.
-	type fileRecord struct {
-		ID    int64
-		Path  string
-		Size  int64
-		MTime int64
+// Values of symlink_delete: what deleting a duplicate that has become a
+// symlink since the scan does.
+const (
+	SymlinkRefuse   = "refuse"    // fail validation with FILE_SYMLINK
+	SymlinkMoveLink = "move_link" // move the link itself, leaving its target alone
+)
+
+// IsSymlink reports whether path is itself a symbolic link (os.Lstat), as
+// opposed to the file os.Stat would follow it to.
+func IsSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// RestatReason re-checks a file about to be moved against its scanned size
+// and mtime, for restat_before_trash. It returns "" when the file may still
+// be moved, otherwise the reason it must be left alone: FILE_MISSING,
+// FILE_MODIFIED, or FILE_SYMLINK when it became a link and symlinkMode does
+// not allow moving links.
+func RestatReason(path string, size, mtime int64, symlinkMode string) string {
+	if IsSymlink(path) {
+		if symlinkMode == SymlinkMoveLink {
+			return ""
+		}
+		return "FILE_SYMLINK"
+	}
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return "FILE_MISSING"
+	case err == nil && (info.Size() != size || info.ModTime().Unix() != mtime):
+		return "FILE_MODIFIED"
 	}
-	fileRows, err := h.DB.QueryContext(r.Context(),
+	return ""
+}
+
+// errGroupNotFound is returned by trashGroupFiles when the group does not exist.
+var errGroupNotFound = errors.New("group not found")
+
+// errNoKeeper is returned by trashGroupFiles when the delete set would leave
+// the group without any file.
+var errNoKeeper = errors.New("at least one file must be kept in the group")
+
+// errApproximateGroup is returned by trashGroupFiles for a group found by an
+// approximate scan: its files only share sampled bytes, not proven content.
+var errApproximateGroup = errors.New("group is approximate; run an exact scan to confirm it")
+
+// errMetadataGroup is returned by trashGroupFiles for a group found by a
+// metadata-only scan: its files only share name, size and mtime.
+var errMetadataGroup = errors.New("group is metadata-matched; run a content scan to confirm it")
+
+// validationFailure describes a file that failed the pre-deletion disk check.
+type validationFailure struct {
+	FileID int64  `json:"file_id"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// validationError is returned by trashGroupFiles when one or more files have
+// changed on disk since the last scan.
+type validationError struct {
+	Failures []validationFailure
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("%d file(s) changed since the last scan", len(e.Failures))
+}
+
+// Dispositions accepted by trashGroupFiles.
+const (
+	modeTrash   = "trash"
+	modeArchive = "archive"
+)
+
+// trashedItem describes a single file moved to trash (TrashID/ExpiresAt) or
+// to the archive (ArchiveID/ArchivePath).
+type trashedItem struct {
+	FileID       int64  `json:"file_id"`
+	TrashID      int64  `json:"trash_id,omitempty"`
+	ArchiveID    int64  `json:"archive_id,omitempty"`
+	OriginalPath string `json:"original_path"`
+	ArchivePath  string `json:"archive_path,omitempty"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
+}
+
+// trashGroupResult is the outcome of trashGroupFiles.
+type trashGroupResult struct {
+	Trashed          []trashedItem
+	Skipped          []validationFailure // left in place by restat_before_trash
+	FileCount        int
+	ReclaimableBytes int64
+	Status           string
+}
+
+// groupFileRecord is a duplicate_files row as needed by the deletion path.
+type groupFileRecord struct {
+	ID    int64
+	Path  string
+	Size  int64
+	MTime int64
+}
+
+// loadGroupFiles returns every file of the group keyed by file ID.
+func (h *GroupsHandler) loadGroupFiles(ctx context.Context, groupID int64) (map[int64]groupFileRecord, error) {
+	fileRows, err := h.DB.QueryContext(ctx,
 		`SELECT id, path, size, mtime FROM duplicate_files WHERE group_id = ?`, groupID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, err
 	}
 	defer fileRows.Close()
 
-	allFiles := map[int64]fileRecord{}
+	allFiles := map[int64]groupFileRecord{}
 	for fileRows.Next() {
-		var f fileRecord
+		var f groupFileRecord
 		if err := fileRows.Scan(&f.ID, &f.Path, &f.Size, &f.MTime); err != nil {
 			continue
 		}
 		allFiles[f.ID] = f
 	}
-	fileRows.Close()
+	return allFiles, fileRows.Err()
+}
+
+// suggestedKeeper returns the ID of the file the configured keeper heuristic
+// picks among all files of the group (0 for an empty group).
+func (h *GroupsHandler) suggestedKeeper(ctx context.Context, groupID int64) (int64, error) {
+	files, err := h.loadGroupFiles(ctx, groupID)
+	if err != nil {
+		return 0, err
+	}
+	candidates := make([]KeeperCandidate, 0, len(files))
+	for _, f := range files {
+		candidates = append(candidates, KeeperCandidate{ID: f.ID, Path: f.Path, MTime: f.MTime})
+	}
+	heuristic, preferred, protected := KeeperOldest, []string(nil), []string(nil)
+	if h.Cfg != nil {
+		h.Cfg.Lock()
+		heuristic = h.Cfg.KeeperHeuristic
+		preferred = append(preferred, h.Cfg.KeeperPreferredDirs...)
+		protected = append(protected, h.Cfg.ProtectedDirs...)
+		h.Cfg.Unlock()
+	}
+	return SuggestKeeper(heuristic, preferred, protected, candidates), nil
+}
+
+// trashGroupFiles validates that every file in the group is unchanged on disk,
+// moves deleteIDs to trash (or to the archive when mode is modeArchive),
+// removes them from duplicate_files and updates the group's stats. With
+// restat_before_trash each file is checked again right before it is moved,
+// and one that changed since validation is skipped and reported in
+// Skipped. It is shared by the single-group delete and bulk resolve paths.
+func (h *GroupsHandler) trashGroupFiles(ctx context.Context, groupID int64, deleteIDs []int64, mode string) (*trashGroupResult, error) {
+	// Load group metadata.
+	var contentHash string
+	var fileSize int64
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT content_hash, file_size FROM duplicate_groups WHERE id = ?`, groupID,
+	).Scan(&contentHash, &fileSize)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if scan.IsApproximateHash(contentHash) {
+		return nil, errApproximateGroup
+	}
+	if scan.IsMetadataHash(contentHash) {
+		return nil, errMetadataGroup
+	}
+
+	allFiles, err := h.loadGroupFiles(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build delete set and validate at least one keeper.
-	deleteSet := make(map[int64]bool, len(body.DeleteFileIDs))
-	for _, id := range body.DeleteFileIDs {
+	deleteSet := make(map[int64]bool, len(deleteIDs))
+	for _, id := range deleteIDs {
 		deleteSet[id] = true
 	}
 	keepCount := len(allFiles) - len(deleteSet)
 	if keepCount < 1 {
-		writeError(w, http.StatusBadRequest, "NO_KEEPER", "At least one file must be kept in the group")
-		return
+		return nil, errNoKeeper
 	}
 
 	// Pre-deletion validation: stat every file.
-	type validationFailure struct {
-		FileID int64  `json:"file_id"`
-		Path   string `json:"path"`
-		Reason string `json:"reason"`
+	symlinkMode := SymlinkRefuse
+	var protected []string
+	if h.Cfg != nil {
+		h.Cfg.Lock()
+		if h.Cfg.SymlinkDelete != "" {
+			symlinkMode = h.Cfg.SymlinkDelete
+		}
+		protected = append(protected, h.Cfg.ProtectedDirs...)
+		h.Cfg.Unlock()
 	}
 	var failures []validationFailure
-
+	verified := true
 	for id, f := range allFiles {
+		if deleteSet[id] && UnderProtectedDir(f.Path, protected) {
+			failures = append(failures, validationFailure{id, f.Path, "PROTECTED_PATH"})
+			continue
+		}
+		if IsSymlink(f.Path) {
+			// The file was replaced by a link since the scan. A keeper link
+			// may point at a copy being deleted; a link to delete is either
+			// refused or moved as a link, never followed.
+			verified = false
+			switch {
+			case !deleteSet[id]:
+				failures = append(failures, validationFailure{id, f.Path, "KEEPER_SYMLINK"})
+			case symlinkMode != SymlinkMoveLink:
+				failures = append(failures, validationFailure{id, f.Path, "FILE_SYMLINK"})
+			}
+			continue
+		}
 		info, statErr := os.Stat(f.Path)
+		if statErr != nil {
+			verified = false
+		}
 		if deleteSet[id] {
 			if os.IsNotExist(statErr) {
 				failures = append(failures, validationFailure{id, f.Path, "FILE_MISSING"})
@@ -295,18 +881,15 @@ func (h *GroupsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-
 	if len(failures) > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":     "VALIDATION_FAILED",
-				"message":  "One or more files have changed since the last scan. Please re-scan.",
-				"failures": failures,
-			},
-		})
-		return
+		return nil, &validationError{Failures: failures}
+	}
+	if verified {
+		if _, err := h.DB.ExecContext(ctx,
+			`UPDATE duplicate_groups SET last_verified_at = ? WHERE id = ?`,
+			time.Now().Unix(), groupID); err != nil {
+			slog.Warn("group delete: record verification", "group_id", groupID, "error", err)
+		}
 	}
 
 	// Move files to trash (outside any DB transaction — MoveToTrash has its own DB writes).
@@ -315,25 +898,52 @@ func (h *GroupsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		retentionDays = h.Cfg.TrashRetentionDays
 	}
 
-	type trashedItem struct {
-		FileID       int64  `json:"file_id"`
-		TrashID      int64  `json:"trash_id"`
-		OriginalPath string `json:"original_path"`
-		ExpiresAt    string `json:"expires_at"`
+	// Refuse up front rather than after some files were moved.
+	paths := make([]string, 0, len(deleteIDs))
+	for _, fileID := range deleteIDs {
+		paths = append(paths, allFiles[fileID].Path)
+	}
+	if err := h.Trash.CheckSpace(paths, mode == modeArchive); err != nil {
+		return nil, err
 	}
 
-	var trashed []trashedItem
+	restat := h.Cfg != nil && h.Cfg.RestatBeforeTrash
+	res := &trashGroupResult{Skipped: []validationFailure{}}
 	expiresAt := time.Now().Add(time.Duration(retentionDays) * 24 * time.Hour).UTC()
 
-	for _, fileID := range body.DeleteFileIDs {
+	for _, fileID := range deleteIDs {
 		f := allFiles[fileID]
-		trashID, err := h.Trash.MoveToTrash(r.Context(), f.Path, groupID, contentHash, retentionDays)
+		if h.beforeMove != nil {
+			h.beforeMove(f.Path)
+		}
+		if restat {
+			if reason := RestatReason(f.Path, f.Size, f.MTime, symlinkMode); reason != "" {
+				slog.Warn("group delete: file changed before move, skipped",
+					"file_id", fileID, "path", f.Path, "reason", reason)
+				res.Skipped = append(res.Skipped, validationFailure{fileID, f.Path, reason})
+				continue
+			}
+		}
+		if mode == modeArchive {
+			archiveID, archivePath, err := h.Trash.MoveToArchive(ctx, f.Path, groupID, contentHash)
+			if err != nil {
+				slog.Error("group delete: move to archive", "file_id", fileID, "path", f.Path, "error", err)
+				return nil, fmt.Errorf("move file to archive: %w", err)
+			}
+			res.Trashed = append(res.Trashed, trashedItem{
+				FileID:       fileID,
+				ArchiveID:    archiveID,
+				OriginalPath: f.Path,
+				ArchivePath:  archivePath,
+			})
+			continue
+		}
+		trashID, err := h.Trash.MoveToTrash(ctx, f.Path, groupID, contentHash, retentionDays)
 		if err != nil {
 			slog.Error("group delete: move to trash", "file_id", fileID, "path", f.Path, "error", err)
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to move file to trash: "+err.Error())
-			return
+			return nil, fmt.Errorf("move file to trash: %w", err)
 		}
-		trashed = append(trashed, trashedItem{
+		res.Trashed = append(res.Trashed, trashedItem{
 			FileID:       fileID,
 			TrashID:      trashID,
 			OriginalPath: f.Path,
@@ -342,63 +952,149 @@ func (h *GroupsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove trashed files from duplicate_files and update group stats.
-	tx, err := h.DB.BeginTx(r.Context(), nil)
+	tx, err := h.DB.BeginTx(ctx, nil)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	for _, fileID := range body.DeleteFileIDs {
-		if _, err := tx.ExecContext(r.Context(),
-			`DELETE FROM duplicate_files WHERE id = ?`, fileID); err != nil {
-			slog.Error("group delete: remove duplicate_file", "file_id", fileID, "error", err)
+	for _, item := range res.Trashed {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM duplicate_files WHERE id = ?`, item.FileID); err != nil {
+			slog.Error("group delete: remove duplicate_file", "file_id", item.FileID, "error", err)
 		}
 	}
 
-	remainingCount := len(allFiles) - len(body.DeleteFileIDs)
-	newStatus := "unresolved"
-	newReclaimable := fileSize * int64(remainingCount-1)
-	if remainingCount <= 1 {
-		newStatus = "resolved"
-		newReclaimable = 0
+	res.FileCount = len(allFiles) - len(res.Trashed)
+	res.Status = "unresolved"
+	res.ReclaimableBytes = fileSize * int64(res.FileCount-1)
+	if res.FileCount <= 1 {
+		res.Status = "resolved"
+		res.ReclaimableBytes = 0
 	}
 
 	now := time.Now().Unix()
-	resolvedAt := "NULL"
-	_ = resolvedAt
-	if newStatus == "resolved" {
-		if _, err := tx.ExecContext(r.Context(), `
+	if res.Status == "resolved" {
+		if _, err := tx.ExecContext(ctx, `
 			UPDATE duplicate_groups
 			SET file_count=?, reclaimable_bytes=?, status=?, resolved_at=?, updated_at=?
 			WHERE id=?`,
-			remainingCount, newReclaimable, newStatus, now, now, groupID); err != nil {
+			res.FileCount, res.ReclaimableBytes, res.Status, now, now, groupID); err != nil {
 			slog.Error("group delete: update group (resolved)", "error", err)
 		}
 	} else {
-		if _, err := tx.ExecContext(r.Context(), `
+		if _, err := tx.ExecContext(ctx, `
 			UPDATE duplicate_groups
 			SET file_count=?, reclaimable_bytes=?, status=?, updated_at=?
 			WHERE id=?`,
-			remainingCount, newReclaimable, newStatus, now, groupID); err != nil {
+			res.FileCount, res.ReclaimableBytes, res.Status, now, groupID); err != nil {
 			slog.Error("group delete: update group", "error", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, err
 	}
+	return res, nil
+}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"trashed": trashed,
-		"group": map[string]interface{}{
-			"id":                groupID,
-			"file_count":        remainingCount,
-			"reclaimable_bytes": newReclaimable,
-			"status":            newStatus,
-		},
+// TrashFiles moves deleteIDs of the group to the trash with the checks and
+// bookkeeping of POST /api/groups/{id}/delete and records the delete in the
+// audit log. It backs the UI delete form, which shows TrashErrorMessage(err)
+// on failure; status is the group's status afterwards.
+func (h *GroupsHandler) TrashFiles(r *http.Request, groupID int64, deleteIDs []int64) (trashed, skipped int, status string, err error) {
+	res, err := h.trashGroupFiles(r.Context(), groupID, deleteIDs, modeTrash)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	h.audit(r, groupID, "delete", map[string]interface{}{
+		"mode":    modeTrash,
+		"trashed": res.Trashed,
+		"skipped": res.Skipped,
+		"status":  res.Status,
 	})
+	return len(res.Trashed), len(res.Skipped), res.Status, nil
+}
+
+// TrashErrorMessage turns a TrashFiles error into a message for the UI. Of
+// several validation failures it names a protected path first, otherwise
+// the first file that failed.
+func TrashErrorMessage(err error) string {
+	var verr *validationError
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		return "Group not found"
+	case errors.Is(err, errNoKeeper):
+		return "At least one file must be kept"
+	case errors.Is(err, errApproximateGroup):
+		return "This group was found by an approximate scan; run an exact scan to confirm it before deleting"
+	case errors.Is(err, errMetadataGroup):
+		return "This group was matched on name, size and mtime only; run a content scan to confirm it before deleting"
+	case errors.As(err, new(*trash.ErrInsufficientSpace)):
+		return "Not trashed: " + err.Error()
+	case errors.As(err, &verr) && len(verr.Failures) > 0:
+		f := verr.Failures[0]
+		for _, v := range verr.Failures {
+			if v.Reason == "PROTECTED_PATH" {
+				f = v
+				break
+			}
+		}
+		switch f.Reason {
+		case "PROTECTED_PATH":
+			return "File is in a protected directory and cannot be deleted: " + f.Path
+		case "KEEPER_SYMLINK":
+			return "Keeper is now a symlink: " + f.Path + ". Please re-scan."
+		case "FILE_SYMLINK":
+			return "File is now a symlink: " + f.Path + ". Please re-scan."
+		case "FILE_MISSING":
+			return "File missing: " + f.Path + ". Please re-scan."
+		case "KEEPER_MISSING":
+			return "Keeper missing: " + f.Path + ". Please re-scan."
+		case "KEEPER_MODIFIED":
+			return "Keeper modified: " + f.Path + ". Please re-scan."
+		default:
+			return "File modified: " + f.Path + ". Please re-scan."
+		}
+	}
+	return "Failed to trash: " + err.Error()
+}
+
+// writeTrashGroupError maps a trashGroupFiles error to the API error envelope.
+func writeTrashGroupError(w http.ResponseWriter, err error) {
+	var verr *validationError
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+	case errors.Is(err, errNoKeeper):
+		writeError(w, http.StatusBadRequest, "NO_KEEPER", "At least one file must be kept in the group")
+	case errors.Is(err, errApproximateGroup):
+		writeError(w, http.StatusConflict, "APPROXIMATE_GROUP",
+			"Group was found by an approximate scan; run an exact scan (approximate_sample_bytes: 0) to confirm it before deleting")
+	case errors.Is(err, errMetadataGroup):
+		writeError(w, http.StatusConflict, "METADATA_GROUP",
+			"Group was matched on name, size and mtime only; run a content scan (metadata_only: false) to confirm it before deleting")
+	case errors.As(err, new(*trash.ErrArchiveConflict)):
+		writeError(w, http.StatusConflict, "ARCHIVE_CONFLICT", err.Error())
+	case errors.As(err, new(*trash.ErrInsufficientSpace)):
+		writeError(w, http.StatusInsufficientStorage, "INSUFFICIENT_SPACE", err.Error())
+	case errors.As(err, &verr):
+		msg := "One or more files have changed since the last scan. Please re-scan."
+		failures := make([]interface{}, len(verr.Failures))
+		for i, f := range verr.Failures {
+			failures[i] = f
+			if f.Reason == "PROTECTED_PATH" {
+				msg = "One or more files are in a protected directory and cannot be deleted."
+			}
+		}
+		writeJSON(w, http.StatusConflict, ErrorBody{Error: APIError{
+			Code:     "VALIDATION_FAILED",
+			Message:  msg,
+			Failures: failures,
+		}})
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
 }
 
 // Ignore handles POST /api/groups/:id/ignore.
@@ -421,18 +1117,68 @@ func (h *GroupsHandler) Ignore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Load group.
+	res, err := h.ignoreGroup(r, groupID, body.Type, body.Path)
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+		return
+	case errors.Is(err, errMissingIgnorePath):
+		writeError(w, http.StatusBadRequest, "MISSING_PATH", err.Error())
+		return
+	case errors.Is(err, errInvalidIgnoreType), errors.Is(err, errTooFewForWatch):
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{
+		"whitelist_id": res.WhitelistID,
+		"type":         body.Type,
+		"value":        res.Value,
+		"group": map[string]interface{}{
+			"id":     groupID,
+			"status": res.Status,
+		},
+	}
+	if body.Type == "dir" {
+		resp["also_ignored"] = res.AlsoIgnored
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// errInvalidIgnoreType, errMissingIgnorePath and errTooFewForWatch are the
+// request errors of ignoreGroup.
+var (
+	errInvalidIgnoreType = errors.New("type must be 'hash', 'path_pair', or 'dir'")
+	errMissingIgnorePath = errors.New("path is required for type=dir")
+	errTooFewForWatch    = errors.New("group must have at least 2 files for a path_pair watch")
+)
+
+// ignoreResult is what ignoreGroup did to a group.
+type ignoreResult struct {
+	WhitelistID int64
+	Value       string
+	Status      string
+	// AlsoIgnored lists the other groups a dir-type ignore marked ignored.
+	AlsoIgnored []int64
+}
+
+// ignoreGroup adds the whitelist entry of type typ ("hash", "path_pair" or
+// "dir", which needs dir) for the group, sets the group's status to ignored
+// or watching, and records it in the audit log.
+func (h *GroupsHandler) ignoreGroup(r *http.Request, groupID int64, typ, dir string) (ignoreResult, error) {
+	ctx := r.Context()
 	var contentHash string
-	err = h.DB.QueryRowContext(r.Context(),
+	err := h.DB.QueryRowContext(ctx,
 		`SELECT content_hash FROM duplicate_groups WHERE id = ?`, groupID,
 	).Scan(&contentHash)
 	if errors.Is(err, sql.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
-		return
+		return ignoreResult{}, errGroupNotFound
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return ignoreResult{}, err
 	}
 
 	now := time.Now().Unix()
@@ -440,33 +1186,31 @@ func (h *GroupsHandler) Ignore(w http.ResponseWriter, r *http.Request) {
 	var whitelistValue string
 	var newGroupStatus string
 
-	switch body.Type {
+	switch typ {
 	case "hash":
 		whitelistValue = contentHash
 		newGroupStatus = "ignored"
 
-		res, err := h.DB.ExecContext(r.Context(),
+		res, err := h.DB.ExecContext(ctx,
 			`INSERT OR IGNORE INTO whitelist (type, value, added_by, added_at)
-			 VALUES ('hash', ?, 'user', ?)`,
-			whitelistValue, now)
+			 VALUES ('hash', ?, ?, ?)`,
+			whitelistValue, RequestActor(r), now)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
+			return ignoreResult{}, err
 		}
 		whitelistID, _ = res.LastInsertId()
 		if whitelistID == 0 {
-			h.DB.QueryRowContext(r.Context(),
+			h.DB.QueryRowContext(ctx,
 				`SELECT id FROM whitelist WHERE type='hash' AND value=?`, whitelistValue,
 			).Scan(&whitelistID)
 		}
 
 	case "path_pair":
 		// Load all paths sorted for a stable canonical value.
-		pathRows, err := h.DB.QueryContext(r.Context(),
+		pathRows, err := h.DB.QueryContext(ctx,
 			`SELECT path FROM duplicate_files WHERE group_id = ? ORDER BY path`, groupID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
+			return ignoreResult{}, err
 		}
 		var paths []string
 		for pathRows.Next() {
@@ -478,91 +1222,73 @@ func (h *GroupsHandler) Ignore(w http.ResponseWriter, r *http.Request) {
 		pathRows.Close()
 
 		if len(paths) < 2 {
-			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Group must have at least 2 files for a path_pair watch")
-			return
+			return ignoreResult{}, errTooFewForWatch
 		}
 		sort.Strings(paths)
 		pathJSON, _ := json.Marshal(paths)
 		whitelistValue = string(pathJSON)
 		newGroupStatus = "watching"
 
-		res, err := h.DB.ExecContext(r.Context(),
+		res, err := h.DB.ExecContext(ctx,
 			`INSERT OR IGNORE INTO whitelist (type, value, expected_hash, added_by, added_at)
-			 VALUES ('path_pair', ?, ?, 'user', ?)`,
-			whitelistValue, contentHash, now)
+			 VALUES ('path_pair', ?, ?, ?, ?)`,
+			whitelistValue, contentHash, RequestActor(r), now)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
+			return ignoreResult{}, err
 		}
 		whitelistID, _ = res.LastInsertId()
 		if whitelistID == 0 {
-			h.DB.QueryRowContext(r.Context(),
+			h.DB.QueryRowContext(ctx,
 				`SELECT id FROM whitelist WHERE type='path_pair' AND value=?`, whitelistValue,
 			).Scan(&whitelistID)
 		}
 
 	case "dir":
-		if body.Path == "" {
-			writeError(w, http.StatusBadRequest, "MISSING_PATH", "path is required for type=dir")
-			return
+		if dir == "" {
+			return ignoreResult{}, errMissingIgnorePath
 		}
-		whitelistValue = body.Path
+		whitelistValue = dir
 		newGroupStatus = "ignored"
 
-		res, err := h.DB.ExecContext(r.Context(),
+		res, err := h.DB.ExecContext(ctx,
 			`INSERT OR IGNORE INTO whitelist (type, value, added_by, added_at)
-			 VALUES ('dir', ?, 'user', ?)`,
-			whitelistValue, now)
+			 VALUES ('dir', ?, ?, ?)`,
+			whitelistValue, RequestActor(r), now)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
+			return ignoreResult{}, err
 		}
 		whitelistID, _ = res.LastInsertId()
 		if whitelistID == 0 {
-			h.DB.QueryRowContext(r.Context(),
+			h.DB.QueryRowContext(ctx,
 				`SELECT id FROM whitelist WHERE type='dir' AND value=?`, whitelistValue,
 			).Scan(&whitelistID)
 		}
 
-		// Update in-memory config so the exclusion takes effect on the next scan.
-		if h.Cfg != nil && h.ScanMgr != nil {
-			h.mu.Lock()
-			h.Cfg.ExcludePaths = append(h.Cfg.ExcludePaths, body.Path)
-			excludes := append([]string{}, h.Cfg.ExcludePaths...)
-			scanPaths := append([]string{}, h.Cfg.ScanPaths...)
-			scanCfg := scan.Config{
-				Walkers:        h.Cfg.ScanWorkers.Walkers,
-				PartialHashers: h.Cfg.ScanWorkers.PartialHashers,
-				FullHashers:    h.Cfg.ScanWorkers.FullHashers,
-				BatchSize:      1000,
-			}
-			h.mu.Unlock()
-			h.ScanMgr.UpdateConfig(scanPaths, excludes, scanCfg)
-		}
+		h.excludeDir(dir)
 
 	default:
-		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "type must be 'hash', 'path_pair', or 'dir'")
-		return
+		return ignoreResult{}, errInvalidIgnoreType
 	}
 
 	// Update group status.
-	if _, err := h.DB.ExecContext(r.Context(), `
+	if _, err := h.DB.ExecContext(ctx, `
 		UPDATE duplicate_groups
 		SET status=?, ignored_at=?, updated_at=?
 		WHERE id=?`,
 		newGroupStatus, now, now, groupID); err != nil {
 		slog.Error("group ignore: update status", "group_id", groupID, "error", err)
 	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"whitelist_id": whitelistID,
-		"type":         body.Type,
-		"value":        whitelistValue,
-		"group": map[string]interface{}{
-			"id":     groupID,
-			"status": newGroupStatus,
-		},
+	h.audit(r, groupID, "ignore", map[string]interface{}{
+		"type":   typ,
+		"value":  whitelistValue,
+		"status": newGroupStatus,
 	})
+
+	res := ignoreResult{WhitelistID: whitelistID, Value: whitelistValue, Status: newGroupStatus}
+	if typ == "dir" {
+		res.AlsoIgnored = h.ignoreExistingUnderDir(r, dir)
+	}
+	return res, nil
 }
 
 // Reset handles POST /api/groups/:id/reset.
@@ -586,12 +1312,106 @@ func (h *GroupsHandler) Reset(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "group not found")
 		return
 	}
+	h.audit(r, groupID, "reset", map[string]interface{}{"status": "unresolved"})
 	writeJSON(w, http.StatusOK, map[string]interface{}{"id": groupID, "status": "unresolved"})
 }
 
+// Update handles PATCH /api/groups/:id.
+// Supports overriding the group's file_type and pinning it ("pinned": true
+// excludes the group from bulk operations such as resolve while leaving it
+// listed and actionable). Both are stored against the content hash in
+// group_overrides so later scans keep them.
+func (h *GroupsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ID", "Invalid group ID")
+		return
+	}
+	var body struct {
+		FileType *string `json:"file_type"`
+		Pinned   *bool   `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if body.FileType == nil && body.Pinned == nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "no updatable fields supplied")
+		return
+	}
+	if body.FileType != nil && !media.ValidFileType(*body.FileType) {
+		writeError(w, http.StatusBadRequest, "INVALID_FILE_TYPE",
+			"file_type must be one of: image, video, document, other")
+		return
+	}
+
+	var hash string
+	if err := h.DB.QueryRowContext(r.Context(),
+		`SELECT content_hash FROM duplicate_groups WHERE id=?`, groupID,
+	).Scan(&hash); err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "group not found")
+		return
+	} else if err != nil {
+		slog.Error("group update: query", "group_id", groupID, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer tx.Rollback()
+	changes := map[string]interface{}{}
+	if body.FileType != nil {
+		if _, err := tx.ExecContext(r.Context(), `
+			INSERT INTO group_overrides (content_hash, file_type, updated_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT(content_hash) DO UPDATE SET
+				file_type = excluded.file_type, updated_at = excluded.updated_at`,
+			hash, *body.FileType, now); err != nil {
+			slog.Error("group update: save override", "group_id", groupID, "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if _, err := tx.ExecContext(r.Context(),
+			`UPDATE duplicate_groups SET file_type=?, updated_at=? WHERE id=?`,
+			*body.FileType, now, groupID); err != nil {
+			slog.Error("group update: update group", "group_id", groupID, "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		changes["file_type"] = *body.FileType
+	}
+	if body.Pinned != nil {
+		if _, err := tx.ExecContext(r.Context(), `
+			INSERT INTO group_overrides (content_hash, pinned, updated_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT(content_hash) DO UPDATE SET
+				pinned = excluded.pinned, updated_at = excluded.updated_at`,
+			hash, *body.Pinned, now); err != nil {
+			slog.Error("group update: save pinned", "group_id", groupID, "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		changes["pinned"] = *body.Pinned
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	h.audit(r, groupID, "update", changes)
+	changes["id"] = groupID
+	writeJSON(w, http.StatusOK, changes)
+}
+
 // Thumbnail handles GET /api/groups/:id/thumbnail.
 // Finds the first image file in the group, generates a 320x320 JPEG thumbnail,
 // and returns it. Returns 404 if no image file exists or thumbnail fails.
+// Thumbnails are cached in memory by content hash, which also serves as the
+// ETag so browsers revalidate with a 304.
 func (h *GroupsHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -599,6 +1419,36 @@ func (h *GroupsHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var hash string
+	err = h.DB.QueryRowContext(r.Context(),
+		`SELECT content_hash FROM duplicate_groups WHERE id = ?`, groupID,
+	).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Group not found")
+		return
+	}
+	if err != nil {
+		slog.Error("groups thumbnail: query group", "group_id", groupID, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	etag := `"` + hash + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeThumb := func(thumb []byte) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+		w.Write(thumb) //nolint:errcheck
+	}
+	if thumb, ok := h.thumbs.get(hash); ok {
+		writeThumb(thumb)
+		return
+	}
+
 	// Find the first image file in the group (ordered by path for determinism).
 	rows, err := h.DB.QueryContext(r.Context(),
 		`SELECT path FROM duplicate_files
@@ -637,10 +1487,8 @@ func (h *GroupsHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 		if thumb == nil {
 			continue
 		}
-		w.Header().Set("Content-Type", "image/jpeg")
-		w.Header().Set("Cache-Control", "public, max-age=3600")
-		w.WriteHeader(http.StatusOK)
-		w.Write(thumb) //nolint:errcheck
+		h.thumbs.put(hash, thumb)
+		writeThumb(thumb)
 		return
 	}
 
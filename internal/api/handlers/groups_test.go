@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/scan"
+	"github.com/eargollo/ditto/internal/trash"
+)
+
+// listGroups calls GET /api/groups with query and returns the items.
+func listGroups(t *testing.T, h *GroupsHandler, query string) []groupItem {
+	t.Helper()
+	rec := serve(groupRoutes(h), http.MethodGet, "/api/groups?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list %q: status %d, body %s", query, rec.Code, rec.Body)
+	}
+	var resp ListResponse[groupItem]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	return resp.Items
+}
+
+// rescan writes the files at paths as one group keyed by hash, as a scan
+// that finds them again does.
+func rescan(t *testing.T, h *GroupsHandler, hash string, paths ...string) {
+	t.Helper()
+	in := make(chan scan.HashedFile, len(paths))
+	for _, p := range paths {
+		in <- scan.HashedFile{FileInfo: scan.FileInfo{Path: p, Size: 17, MTime: time.Unix(1000, 0)}, Hash: hash}
+	}
+	close(in)
+	if _, err := scan.RunDBWriter(context.Background(), h.DB, mustInsertScan(t, h.DB), 100, in, nil, scan.WriterOptions{}); err != nil {
+		t.Fatalf("RunDBWriter: %v", err)
+	}
+}
+
+// TestFileTypeOverrideSurvivesRescan verifies that a file_type set with
+// PATCH /api/groups/:id is kept by later scans, including one that has to
+// recreate the group row.
+func TestFileTypeOverrideSurvivesRescan(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")}
+	groupID, _ := mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	rec := serve(groupRoutes(h), http.MethodPatch, fmt.Sprintf("/api/groups/%d", groupID), `{"file_type":"video"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rescan(t, h, "aaaa", paths...)
+	if items := listGroups(t, h, "type=video"); len(items) != 1 || items[0].ID != groupID {
+		t.Errorf("after rescan, type=video lists %+v, want group %d", items, groupID)
+	}
+
+	if _, err := h.DB.Exec(`DELETE FROM duplicate_groups WHERE id = ?`, groupID); err != nil {
+		t.Fatal(err)
+	}
+	rescan(t, h, "aaaa", paths...)
+	items := listGroups(t, h, "type=all")
+	if len(items) != 1 || items[0].FileType != "video" {
+		t.Errorf("recreated group = %+v, want file_type video", items)
+	}
+}
+
+// errorCode returns the code of an error envelope.
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper()
+	var e ErrorBody
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("decode error body %s: %v", body, err)
+	}
+	return e.Error.Code
+}
+
+// TestDeleteArchive verifies mode=archive: refused without archive_dir,
+// refused when the archive already holds the path, and otherwise moving the
+// file into the archive and recording it there.
+func TestDeleteArchive(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a", "f1"), filepath.Join(dir, "a", "f2")}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+	target := fmt.Sprintf("/api/groups/%d/delete", groupID)
+	body := fmt.Sprintf(`{"delete_file_ids":[%d],"mode":"archive"}`, fileIDs[1])
+
+	rec := serve(groupRoutes(h), http.MethodPost, target, body)
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec.Body.Bytes()) != "ARCHIVE_DISABLED" {
+		t.Errorf("without archive_dir: status %d, body %s; want 400 ARCHIVE_DISABLED", rec.Code, rec.Body)
+	}
+
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	h.Trash = trash.New(h.DB, filepath.Join(t.TempDir(), "trash"), archiveDir, 0, false, "")
+	archived := filepath.Join(archiveDir, paths[1])
+	if err := os.MkdirAll(filepath.Dir(archived), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archived, []byte("older copy"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(groupRoutes(h), http.MethodPost, target, body)
+	if rec.Code != http.StatusConflict || errorCode(t, rec.Body.Bytes()) != "ARCHIVE_CONFLICT" {
+		t.Errorf("occupied archive path: status %d, body %s; want 409 ARCHIVE_CONFLICT", rec.Code, rec.Body)
+	}
+	assertExists(t, paths[1])
+
+	if err := os.Remove(archived); err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(groupRoutes(h), http.MethodPost, target, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("archive: status %d, body %s", rec.Code, rec.Body)
+	}
+	assertGone(t, paths[1])
+	assertExists(t, paths[0])
+	if got, err := os.ReadFile(archived); err != nil || string(got) != "duplicate content" {
+		t.Errorf("archived file = %q, %v; want the moved content", got, err)
+	}
+	var original, archivePath string
+	var gid int64
+	if err := h.DB.QueryRow(`SELECT group_id, original_path, archive_path FROM archive`).
+		Scan(&gid, &original, &archivePath); err != nil {
+		t.Fatalf("read archive row: %v", err)
+	}
+	if gid != groupID || original != paths[1] || archivePath != archived {
+		t.Errorf("archive row = (%d, %s, %s), want (%d, %s, %s)", gid, original, archivePath, groupID, paths[1], archived)
+	}
+	var trashed int
+	h.DB.QueryRow(`SELECT COUNT(*) FROM trash`).Scan(&trashed)
+	if trashed != 0 {
+		t.Errorf("trash rows = %d, want 0", trashed)
+	}
+}
+
+// TestDeleteInsufficientSpace verifies that a delete whose files would not
+// fit in the trash while keeping min_free_bytes is refused with 507 before
+// any file is moved.
+func TestDeleteInsufficientSpace(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	// Compressed files always need space, even on the trash's filesystem.
+	h.Trash = trash.New(h.DB, filepath.Join(t.TempDir(), "trash"), "", 1<<62, true, "")
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2"), filepath.Join(dir, "f3")}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+		fmt.Sprintf(`{"delete_file_ids":[%d,%d]}`, fileIDs[1], fileIDs[2]))
+	if rec.Code != http.StatusInsufficientStorage || errorCode(t, rec.Body.Bytes()) != "INSUFFICIENT_SPACE" {
+		t.Fatalf("status %d, body %s; want 507 INSUFFICIENT_SPACE", rec.Code, rec.Body)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+	var trashed, files int
+	h.DB.QueryRow(`SELECT COUNT(*) FROM trash`).Scan(&trashed)
+	h.DB.QueryRow(`SELECT COUNT(*) FROM duplicate_files WHERE group_id = ?`, groupID).Scan(&files)
+	if trashed != 0 || files != 3 {
+		t.Errorf("trash rows = %d, group files = %d; want 0 and 3", trashed, files)
+	}
+}
+
+// TestListCrossRoot verifies that cross_root=true keeps only groups whose
+// files lie under two or more scan roots, with a nested root counted apart
+// from its parent and files outside every root not counted.
+func TestListCrossRoot(t *testing.T) {
+	dir := t.TempDir()
+	rootA, rootB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	nested := filepath.Join(rootA, "nested")
+	cfg := mustDefaultConfig(t)
+	cfg.ScanPaths = []string{rootA, rootB, nested}
+	h := newTestGroupsHandler(t, cfg)
+
+	spanning, _ := mustInsertGroup(t, h.DB, "aaaa", filepath.Join(rootA, "f"), filepath.Join(rootB, "f"))
+	mustInsertGroup(t, h.DB, "bbbb", filepath.Join(rootA, "g1"), filepath.Join(rootA, "sub", "g2"))
+	withNested, _ := mustInsertGroup(t, h.DB, "cccc", filepath.Join(rootA, "h"), filepath.Join(nested, "h"))
+	mustInsertGroup(t, h.DB, "dddd", filepath.Join(rootB, "i"), filepath.Join(dir, "b2", "i"))
+
+	var got []int64
+	for _, g := range listGroups(t, h, "cross_root=true") {
+		got = append(got, g.ID)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if fmt.Sprint(got) != fmt.Sprint([]int64{spanning, withNested}) {
+		t.Errorf("cross_root groups = %v, want %v", got, []int64{spanning, withNested})
+	}
+	if n := len(listGroups(t, h, "")); n != 4 {
+		t.Errorf("unfiltered list has %d groups, want 4", n)
+	}
+
+	h.Cfg = nil
+	if n := len(listGroups(t, h, "cross_root=true")); n != 0 {
+		t.Errorf("without a config cross_root kept %d groups, want 0", n)
+	}
+}
+
+// TestDeleteReadOnly verifies that read_only refuses a group delete with
+// READ_ONLY and leaves the files on disk.
+func TestDeleteReadOnly(t *testing.T) {
+	cfg := mustDefaultConfig(t)
+	cfg.ReadOnly = true
+	h := newTestGroupsHandler(t, cfg)
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2")}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+		fmt.Sprintf(`{"keeper_id":%d}`, fileIDs[0]))
+	if rec.Code != http.StatusForbidden || errorCode(t, rec.Body.Bytes()) != "READ_ONLY" {
+		t.Fatalf("status %d, body %s; want 403 READ_ONLY", rec.Code, rec.Body)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+}
+
+// TestListPathPrefix verifies that path_prefix matches whole directories
+// literally: LIKE wildcards in the prefix match only themselves, and /a/b
+// does not take in its sibling /a/bc.
+func TestListPathPrefix(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	groups := map[string]int64{}
+	for i, sub := range []string{"a%b", "a_b", "aXb", "a/b", "a/bc"} {
+		id, _ := mustInsertGroup(t, h.DB, fmt.Sprintf("hash%d", i),
+			filepath.Join(dir, sub, "f1"), filepath.Join(dir, sub, "f2"))
+		groups[sub] = id
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"a%b", []string{"a%b"}},
+		{"a_b", []string{"a_b"}},
+		{"a%", nil},
+		{"a/b", []string{"a/b"}},
+		{"a/b/", []string{"a/b"}},
+		{"a/b/f1", []string{"a/b"}},
+		{"a", []string{"a/b", "a/bc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			var got, want []int64
+			for _, g := range listGroups(t, h, "path_prefix="+url.QueryEscape(filepath.Join(dir, tt.prefix))) {
+				got = append(got, g.ID)
+			}
+			for _, sub := range tt.want {
+				want = append(want, groups[sub])
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("groups = %v, want %v", got, want)
+			}
+		})
+	}
+
+	rec := serve(groupRoutes(h), http.MethodGet, "/api/groups?path_prefix=a/b", "")
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec.Body.Bytes()) != "INVALID_PATH" {
+		t.Errorf("relative prefix: status %d, body %s; want 400 INVALID_PATH", rec.Code, rec.Body)
+	}
+}
+
+// TestListDefaultGroupTypeWhilePatched verifies that the list reads
+// default_group_type under the lock PATCH /api/config writes it under; run
+// with -race to catch an unsynchronized read.
+func TestListDefaultGroupTypeWhilePatched(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	configH := &ConfigHandler{DB: h.DB, Cfg: h.Cfg}
+	dir := t.TempDir()
+	mustInsertGroup(t, h.DB, "aaaa", filepath.Join(dir, "f1"), filepath.Join(dir, "f2"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			v := "image"
+			if i%2 == 1 {
+				v = "other"
+			}
+			if err := configH.Apply(context.Background(), ConfigPatch{DefaultGroupType: &v}); err != nil {
+				t.Errorf("Apply: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if n := len(listGroups(t, h, "")); n > 1 {
+			t.Fatalf("list returned %d groups, want at most 1", n)
+		}
+	}
+	<-done
+
+	v := "image"
+	if err := configH.Apply(context.Background(), ConfigPatch{DefaultGroupType: &v}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(listGroups(t, h, "")); n != 0 {
+		t.Errorf("default_group_type=image: list returned %d groups, want 0", n)
+	}
+}
+
+func TestRestatReason(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	size, mtime := info.Size(), info.ModTime().Unix()
+
+	tests := []struct {
+		name        string
+		path        string
+		size, mtime int64
+		symlinkMode string
+		want        string
+	}{
+		{"unchanged", path, size, mtime, SymlinkRefuse, ""},
+		{"size changed", path, size + 1, mtime, SymlinkRefuse, "FILE_MODIFIED"},
+		{"mtime changed", path, size, mtime - 1, SymlinkRefuse, "FILE_MODIFIED"},
+		{"missing", filepath.Join(dir, "gone"), size, mtime, SymlinkRefuse, "FILE_MISSING"},
+		{"symlink refused", link, size, mtime, SymlinkRefuse, "FILE_SYMLINK"},
+		{"symlink moved as link", link, 0, 0, SymlinkMoveLink, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RestatReason(tt.path, tt.size, tt.mtime, tt.symlinkMode); got != tt.want {
+				t.Errorf("RestatReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDeleteRestatBeforeTrash verifies that with restat_before_trash a file
+// modified between the delete's validation and its move is skipped and left
+// on disk, while the other files are trashed.
+func TestDeleteRestatBeforeTrash(t *testing.T) {
+	cfg := mustDefaultConfig(t)
+	cfg.RestatBeforeTrash = true
+	h := newTestGroupsHandler(t, cfg)
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2"), filepath.Join(dir, "f3")}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+	h.beforeMove = func(path string) {
+		if path == paths[1] {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+		fmt.Sprintf(`{"delete_file_ids":[%d,%d]}`, fileIDs[1], fileIDs[2]))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Trashed []trashedItem       `json:"trashed"`
+		Skipped []validationFailure `json:"skipped"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Skipped) != 1 || resp.Skipped[0] != (validationFailure{fileIDs[1], paths[1], "FILE_MODIFIED"}) {
+		t.Errorf("skipped = %+v, want %s as FILE_MODIFIED", resp.Skipped, paths[1])
+	}
+	if len(resp.Trashed) != 1 || resp.Trashed[0].FileID != fileIDs[2] {
+		t.Errorf("trashed = %+v, want only file %d", resp.Trashed, fileIDs[2])
+	}
+	assertExists(t, paths[0])
+	assertExists(t, paths[1])
+	assertGone(t, paths[2])
+}
+
+// TestGetMetadataGroup verifies that a group found by a metadata-only scan
+// is reported as metadata_only by both the list and the detail endpoint,
+// and that it cannot be deleted.
+func TestGetMetadataGroup(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a", "f.jpg"), filepath.Join(dir, "b", "f.jpg")}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, scan.MetadataHashPrefix+"f.jpg:17:1000", paths...)
+	exact, _ := mustInsertGroup(t, h.DB, "aaaa", filepath.Join(dir, "c1"), filepath.Join(dir, "c2"))
+
+	got := mustGetGroup(t, h, groupID)
+	if !got.MetadataOnly || got.Approximate {
+		t.Errorf("detail metadata_only = %v, approximate = %v; want true, false", got.MetadataOnly, got.Approximate)
+	}
+	for _, g := range listGroups(t, h, "") {
+		if g.MetadataOnly != (g.ID == groupID) {
+			t.Errorf("list: group %d metadata_only = %v", g.ID, g.MetadataOnly)
+		}
+	}
+	if g := mustGetGroup(t, h, exact); g.MetadataOnly {
+		t.Errorf("exact group %d reported as metadata_only", exact)
+	}
+
+	rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+		fmt.Sprintf(`{"delete_file_ids":[%d]}`, fileIDs[1]))
+	if rec.Code != http.StatusConflict || errorCode(t, rec.Body.Bytes()) != "METADATA_GROUP" {
+		t.Errorf("delete: status %d, body %s; want 409 METADATA_GROUP", rec.Code, rec.Body)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+}
+
+// mustGetGroup calls GET /api/groups/:id and returns the group.
+func mustGetGroup(t *testing.T, h *GroupsHandler, id int64) groupDetail {
+	t.Helper()
+	rec := serve(groupRoutes(h), http.MethodGet, fmt.Sprintf("/api/groups/%d", id), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get group %d: status %d, body %s", id, rec.Code, rec.Body)
+	}
+	var g groupDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &g); err != nil {
+		t.Fatalf("decode group: %v", err)
+	}
+	return g
+}
+
+// TestDeleteProtectedPath verifies that a delete touching a file under
+// protected_dirs is refused with 409 and a PROTECTED_PATH failure, and that
+// no file is moved, not even the unprotected ones.
+func TestDeleteProtectedPath(t *testing.T) {
+	cfg := mustDefaultConfig(t)
+	dir := t.TempDir()
+	cfg.ProtectedDirs = []string{filepath.Join(dir, "photos")}
+	h := newTestGroupsHandler(t, cfg)
+	paths := []string{
+		filepath.Join(dir, "keep", "f"),
+		filepath.Join(dir, "photos", "f"),
+		filepath.Join(dir, "photos2", "f"),
+	}
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+		fmt.Sprintf(`{"delete_file_ids":[%d,%d]}`, fileIDs[1], fileIDs[2]))
+	var body struct {
+		Error struct {
+			Code     string              `json:"code"`
+			Failures []validationFailure `json:"failures"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if rec.Code != http.StatusConflict || body.Error.Code != "VALIDATION_FAILED" {
+		t.Fatalf("status %d, body %s; want 409 VALIDATION_FAILED", rec.Code, rec.Body)
+	}
+	want := validationFailure{fileIDs[1], paths[1], "PROTECTED_PATH"}
+	if len(body.Error.Failures) != 1 || body.Error.Failures[0] != want {
+		t.Errorf("failures = %+v, want only %+v", body.Error.Failures, want)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+}
+
+// TestIgnoreGroupsUnderDir verifies that the groups ignored with a directory
+// are the active (unresolved or watching_alert), unpinned ones whose files
+// all lie under it.
+func TestIgnoreGroupsUnderDir(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	photos := filepath.Join(dir, "photos")
+	group := func(hash, status string, paths ...string) int64 {
+		id, _ := mustInsertGroup(t, h.DB, hash, paths...)
+		if _, err := h.DB.Exec(`UPDATE duplicate_groups SET status = ? WHERE id = ?`, status, id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	unresolved := group("h1", "unresolved", filepath.Join(photos, "a"), filepath.Join(photos, "sub", "a"))
+	alert := group("h2", "watching_alert", filepath.Join(photos, "b"), filepath.Join(photos, "c"))
+	resolved := group("h3", "resolved", filepath.Join(photos, "d"))
+	pinned := group("h4", "unresolved", filepath.Join(photos, "e"), filepath.Join(photos, "f"))
+	outside := group("h5", "unresolved", filepath.Join(photos, "g"), filepath.Join(dir, "photos2", "g"))
+	if _, err := h.DB.Exec(`INSERT INTO group_overrides (content_hash, pinned, updated_at) VALUES ('h4', 1, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := IgnoreGroupsUnderDir(context.Background(), h.DB, photos+string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("IgnoreGroupsUnderDir: %v", err)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if fmt.Sprint(ids) != fmt.Sprint([]int64{unresolved, alert}) {
+		t.Errorf("ignored %v, want %v", ids, []int64{unresolved, alert})
+	}
+	want := map[int64]string{
+		unresolved: "ignored",
+		alert:      "ignored",
+		resolved:   "resolved",
+		pinned:     "unresolved",
+		outside:    "unresolved",
+	}
+	for id, status := range want {
+		if g := mustGetGroup(t, h, id); g.Status != status {
+			t.Errorf("group %d: status %q, want %q", id, g.Status, status)
+		}
+	}
+}
+
+// TestDeleteSymlink verifies what deleting a duplicate that became a symlink
+// since the scan does: with symlink_delete: refuse the delete fails with
+// FILE_SYMLINK and moves nothing; with move_link the link is trashed as a
+// link and its target stays where it is.
+func TestDeleteSymlink(t *testing.T) {
+	tests := []struct {
+		mode       string
+		wantStatus int
+	}{
+		{SymlinkRefuse, http.StatusConflict},
+		{SymlinkMoveLink, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := mustDefaultConfig(t)
+			cfg.SymlinkDelete = tt.mode
+			h := newTestGroupsHandler(t, cfg)
+			dir := t.TempDir()
+			paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2")}
+			groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", paths...)
+			target := filepath.Join(dir, "target")
+			if err := os.WriteFile(target, []byte("duplicate content"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(paths[1]); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(target, paths[1]); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := serve(groupRoutes(h), http.MethodPost, fmt.Sprintf("/api/groups/%d/delete", groupID),
+				fmt.Sprintf(`{"delete_file_ids":[%d]}`, fileIDs[1]))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, body %s; want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+			assertExists(t, paths[0])
+			assertExists(t, target)
+
+			var trashPath string
+			err := h.DB.QueryRow(`SELECT trash_path FROM trash WHERE status = 'trashed'`).Scan(&trashPath)
+			if tt.mode == SymlinkRefuse {
+				if code := errorCode(t, rec.Body.Bytes()); code != "VALIDATION_FAILED" {
+					t.Errorf("error code %q, want VALIDATION_FAILED", code)
+				}
+				assertExists(t, paths[1])
+				if err == nil {
+					t.Errorf("trash has %s, want nothing trashed", trashPath)
+				}
+				return
+			}
+			assertGone(t, paths[1])
+			if err != nil {
+				t.Fatalf("trash row: %v", err)
+			}
+			if dest, err := os.Readlink(trashPath); err != nil || dest != target {
+				t.Errorf("trashed %s is not the link to %s (readlink %q, %v)", trashPath, target, dest, err)
+			}
+		})
+	}
+}
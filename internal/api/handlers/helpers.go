@@ -4,14 +4,18 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 )
 
-// ListResponse is the standard paginated list envelope.
+// ListResponse is the standard paginated list envelope. NextCursor is set
+// only when the list was read with ?after= and more items follow; pass it
+// back as ?after= for the next page.
 type ListResponse[T any] struct {
-	Items  []T `json:"items"`
-	Total  int `json:"total"`
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor *int64 `json:"next_cursor,omitempty"`
 }
 
 // ErrorBody is the standard error envelope.
@@ -35,9 +39,32 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	}
 }
 
+// writeList writes a 200 list response. The paging fields are repeated in
+// X-Total-Count, X-Limit and X-Offset headers for table components that read
+// totals from headers.
+func writeList[T any](w http.ResponseWriter, resp ListResponse[T]) {
+	setPaginationHeaders(w, resp.Total, resp.Limit, resp.Offset)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// setPaginationHeaders sets X-Total-Count, X-Limit and X-Offset.
+func setPaginationHeaders(w http.ResponseWriter, total, limit, offset int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("X-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-Offset", strconv.Itoa(offset))
+}
+
 // writeError writes a standard error response.
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, ErrorBody{
 		Error: APIError{Code: code, Message: message},
 	})
 }
+
+// ReadOnlyMessage explains why an action that removes files was refused.
+const ReadOnlyMessage = "Read-only mode (read_only: true): deleting, archiving and purging files are disabled"
+
+// writeReadOnly answers a deletion request refused by read_only.
+func writeReadOnly(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, "READ_ONLY", ReadOnlyMessage)
+}
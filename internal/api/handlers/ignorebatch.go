@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// ignoreBatchRequest is the body of POST /api/groups/ignore-batch.
+type ignoreBatchRequest struct {
+	// Filter selects the groups like the query parameters of GET
+	// /api/groups, among active groups only; an empty status means all of
+	// them.
+	Filter struct {
+		Status     string `json:"status"`
+		Type       string `json:"type"`
+		PathPrefix string `json:"path_prefix"`
+	} `json:"filter"`
+	// Type and Path are applied to every matching group as in POST
+	// /api/groups/:id/ignore.
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// Confirm must be true when the filter has neither type nor
+	// path_prefix, i.e. would select every active group.
+	Confirm bool `json:"confirm"`
+}
+
+type ignoreBatchResult struct {
+	GroupID int64  `json:"group_id"`
+	Status  string `json:"status,omitempty"`
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// IgnoreBatch handles POST /api/groups/ignore-batch — ignores (or watches)
+// every active group matching a filter in one call, as Ignore does for one
+// group. A filter without type or path_prefix needs confirm. Pinned groups and groups the ignore does not apply to are skipped and
+// reported; the rest proceed.
+func (h *GroupsHandler) IgnoreBatch(w http.ResponseWriter, r *http.Request) {
+	var body ignoreBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	switch body.Type {
+	case "hash", "path_pair":
+	case "dir":
+		if body.Path == "" {
+			writeError(w, http.StatusBadRequest, "MISSING_PATH", errMissingIgnorePath.Error())
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", errInvalidIgnoreType.Error())
+		return
+	}
+	switch body.Filter.Status {
+	case "", "active", "unresolved", "watching_alert":
+	default:
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST",
+			"filter.status must be active, unresolved or watching_alert")
+		return
+	}
+	if body.Filter.Type == "" && body.Filter.PathPrefix == "" && !body.Confirm {
+		writeError(w, http.StatusBadRequest, "CONFIRMATION_REQUIRED",
+			"Set filter.type or filter.path_prefix, or confirm: true to ignore every active group")
+		return
+	}
+	where, args, err := groupFilter(body.Filter.Status, body.Filter.Type, body.Filter.PathPrefix)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_PATH", err.Error())
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(),
+		`SELECT id, `+pinnedColumn+` FROM duplicate_groups WHERE 1=1`+where+` ORDER BY id`, args...)
+	if err != nil {
+		slog.Error("groups ignore-batch: query groups", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	type match struct {
+		id     int64
+		pinned bool
+	}
+	var matches []match
+	for rows.Next() {
+		var m match
+		if err := rows.Scan(&m.id, &m.pinned); err != nil {
+			rows.Close()
+			slog.Error("groups ignore-batch: scan group", "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		slog.Error("groups ignore-batch: read groups", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	results := []ignoreBatchResult{}
+	var ignored int
+	for _, m := range matches {
+		if r.Context().Err() != nil {
+			break
+		}
+		res := ignoreBatchResult{GroupID: m.id}
+		if m.pinned {
+			res.Skipped = "PINNED"
+			results = append(results, res)
+			continue
+		}
+		ir, err := h.ignoreGroup(r, m.id, body.Type, body.Path)
+		switch {
+		case err == nil:
+			res.Status = ir.Status
+			ignored++
+		case errors.Is(err, errGroupNotFound):
+			res.Skipped = "NOT_FOUND"
+		case errors.Is(err, errTooFewForWatch):
+			res.Skipped = "TOO_FEW_FILES"
+		default:
+			slog.Error("groups ignore-batch: ignore", "group_id", m.id, "error", err)
+			res.Skipped = err.Error()
+		}
+		results = append(results, res)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":          body.Type,
+		"groups":        results,
+		"ignored_count": ignored,
+		"skipped_count": len(results) - ignored,
+	})
+}
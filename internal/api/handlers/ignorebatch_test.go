@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// TestIgnoreBatch verifies that ignore-batch only ever selects active
+// groups, refuses a filter selecting all of them unless confirmed, and
+// skips pinned groups.
+func TestIgnoreBatch(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	photos := filepath.Join(dir, "photos")
+	group := func(hash, status string, paths ...string) int64 {
+		id, _ := mustInsertGroup(t, h.DB, hash, paths...)
+		if _, err := h.DB.Exec(`UPDATE duplicate_groups SET status = ? WHERE id = ?`, status, id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	under := group("h1", "unresolved", filepath.Join(photos, "a1"), filepath.Join(photos, "a2"))
+	alert := group("h2", "watching_alert", filepath.Join(photos, "b1"), filepath.Join(photos, "b2"))
+	resolved := group("h3", "resolved", filepath.Join(photos, "c1"), filepath.Join(photos, "c2"))
+	gone := group("h4", "gone", filepath.Join(photos, "d1"), filepath.Join(photos, "d2"))
+	outside := group("h5", "unresolved", filepath.Join(dir, "e1"), filepath.Join(dir, "e2"))
+	pinned := group("h6", "unresolved", filepath.Join(dir, "f1"), filepath.Join(dir, "f2"))
+	if _, err := h.DB.Exec(`INSERT INTO group_overrides (content_hash, pinned, updated_at) VALUES ('h6', 1, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	statuses := func() map[int64]string {
+		got := map[int64]string{}
+		for _, id := range []int64{under, alert, resolved, gone, outside, pinned} {
+			got[id] = mustGetGroup(t, h, id).Status
+		}
+		return got
+	}
+	before := statuses()
+
+	refused := []struct {
+		name, body, code string
+	}{
+		{"empty filter", `{"type":"hash"}`, "CONFIRMATION_REQUIRED"},
+		{"status only", `{"type":"hash","filter":{"status":"unresolved"}}`, "CONFIRMATION_REQUIRED"},
+		{"status all", `{"type":"hash","filter":{"status":"all"},"confirm":true}`, "BAD_REQUEST"},
+		{"status resolved", `{"type":"hash","filter":{"status":"resolved","path_prefix":"` + photos + `"}}`, "BAD_REQUEST"},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/ignore-batch", tt.body)
+			if rec.Code != http.StatusBadRequest || errorCode(t, rec.Body.Bytes()) != tt.code {
+				t.Errorf("status %d, body %s; want 400 %s", rec.Code, rec.Body, tt.code)
+			}
+		})
+	}
+	if got := statuses(); fmt.Sprint(got) != fmt.Sprint(before) {
+		t.Fatalf("refused requests changed statuses: %v, want %v", got, before)
+	}
+
+	ignoreBatch := func(body string) (ignored, skipped int) {
+		t.Helper()
+		rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/ignore-batch", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", body, rec.Code, rec.Body)
+		}
+		var resp struct {
+			Ignored int `json:"ignored_count"`
+			Skipped int `json:"skipped_count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.Ignored, resp.Skipped
+	}
+
+	if ig, sk := ignoreBatch(`{"type":"hash","filter":{"path_prefix":"` + photos + `"}}`); ig != 2 || sk != 0 {
+		t.Errorf("path_prefix: ignored %d, skipped %d; want 2 and 0", ig, sk)
+	}
+	if ig, sk := ignoreBatch(`{"type":"hash","confirm":true}`); ig != 1 || sk != 1 {
+		t.Errorf("confirm: ignored %d, skipped %d; want 1 and the pinned group", ig, sk)
+	}
+	want := map[int64]string{
+		under:    "ignored",
+		alert:    "ignored",
+		resolved: "resolved",
+		gone:     "gone",
+		outside:  "ignored",
+		pinned:   "unresolved",
+	}
+	if got := statuses(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
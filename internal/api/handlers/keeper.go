@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Keeper heuristics selectable with the keeper_heuristic setting.
+const (
+	KeeperOldest       = "oldest"        // earliest mtime
+	KeeperShortestPath = "shortest_path" // fewest characters in the full path
+	KeeperPreferredDir = "preferred_dir" // first match in keeper_preferred_dirs, else oldest
+)
+
+// KeeperCandidate is what the keeper heuristics know about a file.
+type KeeperCandidate struct {
+	ID    int64
+	Path  string
+	MTime int64
+}
+
+// SuggestKeeper returns the ID of the file heuristic would keep, or 0 when
+// files is empty. Ties are broken by the lexically smallest path so the
+// suggestion is stable across requests. An unknown heuristic falls back to
+// KeeperOldest.
+//
+// Files under one of protectedDirs, which are never deleted, come first:
+// when any file is under one, the heuristic only chooses among those.
+//
+// With KeeperPreferredDir the files under the earliest listed preferred
+// directory are considered (oldest among them wins); when no file is under
+// any of them, the oldest file overall is suggested.
+func SuggestKeeper(heuristic string, preferredDirs, protectedDirs []string, files []KeeperCandidate) int64 {
+	pool := files
+	var protected []KeeperCandidate
+	for _, f := range files {
+		if UnderProtectedDir(f.Path, protectedDirs) {
+			protected = append(protected, f)
+		}
+	}
+	if len(protected) > 0 {
+		pool = protected
+	}
+	if heuristic == KeeperPreferredDir {
+		for _, dir := range preferredDirs {
+			var under []KeeperCandidate
+			for _, f := range pool {
+				if pathUnder(f.Path, dir) {
+					under = append(under, f)
+				}
+			}
+			if len(under) > 0 {
+				pool = under
+				break
+			}
+		}
+	}
+
+	var best *KeeperCandidate
+	for i := range pool {
+		f := &pool[i]
+		if best == nil || keeperLess(heuristic, f, best) {
+			best = f
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.ID
+}
+
+// keeperLess reports whether a is a better keeper than b.
+func keeperLess(heuristic string, a, b *KeeperCandidate) bool {
+	if heuristic == KeeperShortestPath {
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+	} else if a.MTime != b.MTime {
+		return a.MTime < b.MTime
+	}
+	return a.Path < b.Path
+}
+
+// UnderProtectedDir reports whether path is one of dirs (the protected_dirs
+// setting) or lies below one. Such a file is never deleted.
+func UnderProtectedDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if pathUnder(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathUnder reports whether path is dir or lies below it.
+func pathUnder(path, dir string) bool {
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
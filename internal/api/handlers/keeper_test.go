@@ -0,0 +1,26 @@
+package handlers
+
+import "testing"
+
+func TestUnderProtectedDir(t *testing.T) {
+	tests := []struct {
+		path string
+		dirs []string
+		want bool
+	}{
+		{"/data/photos", []string{"/data/photos"}, true},
+		{"/data/photos/a.jpg", []string{"/data/photos"}, true},
+		{"/data/photos/2020/a.jpg", []string{"/data/photos/"}, true},
+		{"/data/photos2/a.jpg", []string{"/data/photos"}, false},
+		{"/data/photos.jpg", []string{"/data/photos"}, false},
+		{"/data/a.jpg", []string{"/data/photos"}, false},
+		{"/data/a.jpg", []string{"/data/photos", "/data"}, true},
+		{"/data/a.jpg", []string{"/"}, true},
+		{"/data/a.jpg", nil, false},
+	}
+	for _, tt := range tests {
+		if got := UnderProtectedDir(tt.path, tt.dirs); got != tt.want {
+			t.Errorf("UnderProtectedDir(%q, %q) = %v, want %v", tt.path, tt.dirs, got, tt.want)
+		}
+	}
+}
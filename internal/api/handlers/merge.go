@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+var errHashMismatch = errors.New("groups hold different content")
+
+// Merge handles POST /api/groups/merge — a manual repair tool that folds
+// groups holding the same content into one. The first id in group_ids is kept:
+// every duplicate_files row of the others is moved under it, its stats are
+// recomputed, and the now-empty groups are deleted. Groups whose mergeKey
+// differs are never merged.
+func (h *GroupsHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		GroupIDs []int64 `json:"group_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	seen := make(map[int64]bool, len(body.GroupIDs))
+	for _, id := range body.GroupIDs {
+		if seen[id] {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "group_ids must not repeat")
+			return
+		}
+		seen[id] = true
+	}
+	if len(body.GroupIDs) < 2 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "group_ids must list at least two groups")
+		return
+	}
+
+	target, sources := body.GroupIDs[0], body.GroupIDs[1:]
+	err := h.mergeGroups(r.Context(), target, sources)
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	case errors.Is(err, errHashMismatch):
+		writeError(w, http.StatusConflict, "HASH_MISMATCH", "Only groups holding the same content can be merged")
+		return
+	case err != nil:
+		slog.Error("groups merge", "group_ids", body.GroupIDs, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	h.audit(r, target, "merge", map[string]interface{}{"merged_group_ids": sources})
+	for _, id := range sources {
+		h.audit(r, id, "merge", map[string]interface{}{"merged_into": target})
+	}
+
+	limit, offset := parsePagination(r)
+	detail, err := h.loadGroupDetail(r.Context(), target, limit, offset)
+	if err != nil {
+		slog.Error("groups merge: reload", "group_id", target, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// mergeKey returns the content hash a group key stands for: content_hash
+// without the approx: or meta: prefix and without the ":<ext>" suffix added
+// by group_by_extension. content_hash is unique, so two groups can only hold
+// the same content under keys that differ in those parts — typically the
+// same photo grouped once as .jpg and once as .jpeg.
+func mergeKey(hash string) string {
+	for _, prefix := range []string{scan.ApproximateHashPrefix, scan.MetadataHashPrefix} {
+		hash = strings.TrimPrefix(hash, prefix)
+	}
+	key, _, _ := strings.Cut(hash, ":")
+	return key
+}
+
+// mergeGroups moves the files of sources into target in one transaction.
+// Trash and archive entries follow their files so restores still find a group.
+func (h *GroupsHandler) mergeGroups(ctx context.Context, target int64, sources []int64) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hash, status string
+	var fileSize int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT content_hash, file_size, status FROM duplicate_groups WHERE id = ?`, target,
+	).Scan(&hash, &fileSize, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("group %d: %w", target, errGroupNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("query group %d: %w", target, err)
+	}
+
+	for _, id := range sources {
+		var srcHash string
+		err := tx.QueryRowContext(ctx,
+			`SELECT content_hash FROM duplicate_groups WHERE id = ?`, id,
+		).Scan(&srcHash)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("group %d: %w", id, errGroupNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("query group %d: %w", id, err)
+		}
+		if mergeKey(srcHash) != mergeKey(hash) {
+			return errHashMismatch
+		}
+
+		for _, q := range []string{
+			`UPDATE duplicate_files SET group_id = ? WHERE group_id = ?`,
+			`UPDATE trash SET group_id = ? WHERE group_id = ?`,
+			`UPDATE archive SET group_id = ? WHERE group_id = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, q, target, id); err != nil {
+				return fmt.Errorf("move rows of group %d: %w", id, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM duplicate_groups WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("delete group %d: %w", id, err)
+		}
+	}
+
+	var fileCount int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM duplicate_files WHERE group_id = ?`, target,
+	).Scan(&fileCount); err != nil {
+		return fmt.Errorf("count files: %w", err)
+	}
+	reclaimable := fileSize * int64(fileCount-1)
+	if fileCount <= 1 {
+		reclaimable = 0
+	} else if status == "resolved" {
+		// A resolved group that regains copies needs review again.
+		status = "unresolved"
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE duplicate_groups
+		SET file_count=?, reclaimable_bytes=?, status=?,
+		    resolved_at = CASE WHEN status = 'resolved' AND ? = 'resolved' THEN resolved_at END,
+		    updated_at=?
+		WHERE id=?`,
+		fileCount, reclaimable, status, status, time.Now().Unix(), target); err != nil {
+		return fmt.Errorf("update group: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeKey(t *testing.T) {
+	tests := map[string]string{
+		"aaaa":            "aaaa",
+		"aaaa:jpg":        "aaaa",
+		"approx:aaaa":     "aaaa",
+		"approx:aaaa:jpg": "aaaa",
+		"meta:aaaa":       "aaaa",
+	}
+	for hash, want := range tests {
+		if got := mergeKey(hash); got != want {
+			t.Errorf("mergeKey(%q) = %q, want %q", hash, got, want)
+		}
+	}
+}
+
+func TestMergeRejects(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	a, _ := mustInsertGroup(t, h.DB, "aaaa", filepath.Join(dir, "a1"), filepath.Join(dir, "a2"))
+	b, _ := mustInsertGroup(t, h.DB, "bbbb:jpg", filepath.Join(dir, "b1"), filepath.Join(dir, "b2"))
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		wantCode string
+	}{
+		{"different content", fmt.Sprintf(`{"group_ids":[%d,%d]}`, a, b), http.StatusConflict, "HASH_MISMATCH"},
+		{"missing group", fmt.Sprintf(`{"group_ids":[%d,999]}`, a), http.StatusNotFound, "NOT_FOUND"},
+		{"missing target", fmt.Sprintf(`{"group_ids":[999,%d]}`, a), http.StatusNotFound, "NOT_FOUND"},
+		{"repeated id", fmt.Sprintf(`{"group_ids":[%d,%d]}`, a, a), http.StatusBadRequest, "BAD_REQUEST"},
+		{"single id", fmt.Sprintf(`{"group_ids":[%d]}`, a), http.StatusBadRequest, "BAD_REQUEST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/merge", tt.body)
+			if rec.Code != tt.status || errorCode(t, rec.Body.Bytes()) != tt.wantCode {
+				t.Errorf("status %d, body %s; want %d %s", rec.Code, rec.Body, tt.status, tt.wantCode)
+			}
+		})
+	}
+
+	var groups, files int
+	h.DB.QueryRow(`SELECT COUNT(*) FROM duplicate_groups`).Scan(&groups)
+	h.DB.QueryRow(`SELECT COUNT(*) FROM duplicate_files`).Scan(&files)
+	if groups != 2 || files != 4 {
+		t.Errorf("after rejected merges: %d groups, %d files; want 2 and 4", groups, files)
+	}
+}
+
+// TestMerge verifies that the groups an extension split apart are folded
+// into the first one listed: its files, stats and status are recomputed,
+// trash entries follow their group, and the other group is deleted.
+func TestMerge(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	target, _ := mustInsertGroup(t, h.DB, "aaaa:jpg", filepath.Join(dir, "a.jpg"))
+	source, _ := mustInsertGroup(t, h.DB, "aaaa:jpeg", filepath.Join(dir, "b.jpeg"), filepath.Join(dir, "c.jpeg"))
+	if _, err := h.DB.Exec(`UPDATE duplicate_groups SET status = 'resolved', resolved_at = 1 WHERE id = ?`, target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.Exec(`
+		INSERT INTO trash (group_id, original_path, trash_path, file_size, content_hash, trashed_at, expires_at)
+		VALUES (?, '/old/d.jpeg', '/trash/d.jpeg', 17, 'aaaa:jpeg', 0, 0)`, source); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/merge", fmt.Sprintf(`{"group_ids":[%d,%d]}`, target, source))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("merge: status %d, body %s", rec.Code, rec.Body)
+	}
+	var got groupDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode merged group: %v", err)
+	}
+	if got.ID != target || got.ContentHash != "aaaa:jpg" || got.FileCount != 3 || got.FilesTotal != 3 ||
+		got.ReclaimableBytes != 2*17 || got.Status != "unresolved" {
+		t.Errorf("merged group = %+v, want group %d with 3 files, 34 reclaimable, unresolved", got.groupItem, target)
+	}
+
+	var sourceRows, trashGroup int64
+	h.DB.QueryRow(`SELECT COUNT(*) FROM duplicate_groups WHERE id = ?`, source).Scan(&sourceRows)
+	h.DB.QueryRow(`SELECT group_id FROM trash`).Scan(&trashGroup)
+	if sourceRows != 0 || trashGroup != target {
+		t.Errorf("source rows = %d, trash group = %d; want 0 and %d", sourceRows, trashGroup, target)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+type hashFailure struct {
+	Path          string  `json:"path"`
+	Stage         string  `json:"stage"`
+	Code          string  `json:"code"`
+	Error         string  `json:"error"`
+	Failures      int     `json:"failures"`
+	FirstFailedAt string  `json:"first_failed_at"`
+	LastFailedAt  string  `json:"last_failed_at"`
+	QuarantinedAt *string `json:"quarantined_at"`
+}
+
+// Quarantine handles GET /api/reports/quarantine — the paths scans skip
+// because they failed to hash in hash_quarantine_after consecutive scans,
+// most recently quarantined first. ?all=true also lists the paths still
+// counting up to the threshold. Paginated.
+func (h *ReportsHandler) Quarantine(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+	where := " WHERE quarantined_at IS NOT NULL"
+	if r.URL.Query().Get("all") == "true" {
+		where = ""
+	}
+
+	var total int
+	if err := h.DB.QueryRowContext(r.Context(),
+		`SELECT COUNT(*) FROM hash_failures`+where).Scan(&total); err != nil {
+		slog.Error("reports quarantine: count", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT path, stage, code, error, failures, first_failed_at, last_failed_at, quarantined_at
+		FROM hash_failures`+where+`
+		ORDER BY quarantined_at IS NULL, quarantined_at DESC, last_failed_at DESC, path
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		slog.Error("reports quarantine: query", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer rows.Close()
+
+	items := []hashFailure{}
+	for rows.Next() {
+		var f hashFailure
+		var firstAt, lastAt int64
+		var quarantinedAt sql.NullInt64
+		if err := rows.Scan(&f.Path, &f.Stage, &f.Code, &f.Error, &f.Failures,
+			&firstAt, &lastAt, &quarantinedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		f.FirstFailedAt = time.Unix(firstAt, 0).UTC().Format(time.RFC3339)
+		f.LastFailedAt = time.Unix(lastAt, 0).UTC().Format(time.RFC3339)
+		if quarantinedAt.Valid {
+			v := time.Unix(quarantinedAt.Int64, 0).UTC().Format(time.RFC3339)
+			f.QuarantinedAt = &v
+		}
+		items = append(items, f)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeList(w, ListResponse[hashFailure]{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+// ClearQuarantine handles DELETE /api/reports/quarantine — forgets the
+// failures recorded for ?path=, or for every path when it is omitted, so the
+// next scan tries those files again.
+func (h *ReportsHandler) ClearQuarantine(w http.ResponseWriter, r *http.Request) {
+	query, args := `DELETE FROM hash_failures`, []interface{}{}
+	if path := r.URL.Query().Get("path"); path != "" {
+		if !filepath.IsAbs(path) {
+			writeError(w, http.StatusBadRequest, "INVALID_PATH", "path must be an absolute path")
+			return
+		}
+		query += ` WHERE path = ?`
+		args = append(args, filepath.Clean(path))
+	}
+	res, err := h.DB.ExecContext(r.Context(), query, args...)
+	if err != nil {
+		slog.Error("reports quarantine: clear", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	n, _ := res.RowsAffected()
+	slog.Info("cleared hash failures", "paths", n)
+	writeJSON(w, http.StatusOK, map[string]int64{"cleared": n})
+}
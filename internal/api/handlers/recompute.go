@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type recomputedGroup struct {
+	GroupID                int64  `json:"group_id"`
+	FileCountBefore        int64  `json:"file_count_before"`
+	FileCount              int64  `json:"file_count"`
+	ReclaimableBytesBefore int64  `json:"reclaimable_bytes_before"`
+	ReclaimableBytes       int64  `json:"reclaimable_bytes"`
+	StatusBefore           string `json:"status_before"`
+	Status                 string `json:"status"`
+}
+
+// RecomputeGroups handles POST /api/admin/recompute-groups — a repair tool
+// that recounts every group's files from duplicate_files and rewrites
+// file_count and reclaimable_bytes where they drifted. A group left with one
+// file is resolved and one left with none is marked "gone", as a scan's
+// reconciliation would. Each corrected group gets a "recompute" history
+// entry. Refused while a scan runs, since the scan writer updates the same
+// rows.
+func (h *GroupsHandler) RecomputeGroups(w http.ResponseWriter, r *http.Request) {
+	if h.ScanMgr != nil && h.ScanMgr.ActiveScan() != nil {
+		writeError(w, http.StatusConflict, "SCAN_ALREADY_RUNNING", "A scan is in progress; retry once it finishes")
+		return
+	}
+	ctx := r.Context()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT g.id, g.file_size, g.file_count, g.reclaimable_bytes, g.status,
+		       (SELECT COUNT(*) FROM duplicate_files f WHERE f.group_id = g.id)
+		FROM duplicate_groups g
+		ORDER BY g.id`)
+	if err != nil {
+		slog.Error("recompute groups: query", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	var checked int
+	corrected := []recomputedGroup{}
+	for rows.Next() {
+		var g recomputedGroup
+		var size int64
+		if err := rows.Scan(&g.GroupID, &size, &g.FileCountBefore, &g.ReclaimableBytesBefore,
+			&g.StatusBefore, &g.FileCount); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		checked++
+		g.ReclaimableBytes = size * max(g.FileCount-1, 0)
+		g.Status = g.StatusBefore
+		switch {
+		case g.FileCount == 0:
+			g.Status = "gone"
+		case g.FileCount == 1 && g.Status != "resolved":
+			g.Status = "resolved"
+		}
+		if g.FileCount != g.FileCountBefore || g.ReclaimableBytes != g.ReclaimableBytesBefore ||
+			g.Status != g.StatusBefore {
+			corrected = append(corrected, g)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, g := range corrected {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE duplicate_groups
+			SET file_count = ?, reclaimable_bytes = ?, status = ?, updated_at = ?,
+			    resolved_at = CASE WHEN ? = 'resolved' AND status != 'resolved' THEN ? ELSE resolved_at END
+			WHERE id = ?`,
+			g.FileCount, g.ReclaimableBytes, g.Status, now, g.Status, now, g.GroupID); err != nil {
+			slog.Error("recompute groups: update", "group_id", g.GroupID, "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	for _, g := range corrected {
+		h.audit(r, g.GroupID, "recompute", g)
+	}
+	if len(corrected) > 0 {
+		slog.Info("recomputed group stats", "checked", checked, "corrected", len(corrected))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"groups_checked":   checked,
+		"groups_corrected": len(corrected),
+		"groups":           corrected,
+	})
+}
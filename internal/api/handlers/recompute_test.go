@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// TestRecomputeGroups verifies that recompute-groups rewrites drifted
+// file_count and reclaimable_bytes, resolves a group left with one file,
+// marks one left with none gone, and leaves consistent groups alone.
+func TestRecomputeGroups(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	drifted, driftedFiles := mustInsertGroup(t, h.DB, "h1", file("a1"), file("a2"), file("a3"))
+	single, singleFiles := mustInsertGroup(t, h.DB, "h2", file("b1"), file("b2"))
+	empty, _ := mustInsertGroup(t, h.DB, "h3", file("c1"), file("c2"))
+	intact, _ := mustInsertGroup(t, h.DB, "h4", file("d1"), file("d2"))
+	for _, q := range []string{
+		fmt.Sprintf(`DELETE FROM duplicate_files WHERE id = %d`, driftedFiles[2]),
+		fmt.Sprintf(`DELETE FROM duplicate_files WHERE id = %d`, singleFiles[1]),
+		fmt.Sprintf(`DELETE FROM duplicate_files WHERE group_id = %d`, empty),
+	} {
+		if _, err := h.DB.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serve(http.HandlerFunc(h.RecomputeGroups), http.MethodPost, "/api/admin/recompute-groups", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Checked   int               `json:"groups_checked"`
+		Corrected int               `json:"groups_corrected"`
+		Groups    []recomputedGroup `json:"groups"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Checked != 4 || resp.Corrected != 3 || len(resp.Groups) != 3 {
+		t.Errorf("checked %d, corrected %d (%d listed); want 4, 3", resp.Checked, resp.Corrected, len(resp.Groups))
+	}
+
+	const size = int64(len("duplicate content"))
+	tests := []struct {
+		id          int64
+		count       int64
+		reclaimable int64
+		status      string
+	}{
+		{drifted, 2, size, "unresolved"},
+		{single, 1, 0, "resolved"},
+		{empty, 0, 0, "gone"},
+		{intact, 2, size, "unresolved"},
+	}
+	for _, tt := range tests {
+		var count, reclaimable int64
+		var status string
+		var resolvedAt *int64
+		if err := h.DB.QueryRow(`
+			SELECT file_count, reclaimable_bytes, status, resolved_at
+			FROM duplicate_groups WHERE id = ?`, tt.id).Scan(&count, &reclaimable, &status, &resolvedAt); err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.count || reclaimable != tt.reclaimable || status != tt.status {
+			t.Errorf("group %d: file_count %d, reclaimable %d, status %q; want %d, %d, %q",
+				tt.id, count, reclaimable, status, tt.count, tt.reclaimable, tt.status)
+		}
+		if (status == "resolved") != (resolvedAt != nil) {
+			t.Errorf("group %d: status %q with resolved_at %v", tt.id, status, resolvedAt)
+		}
+	}
+
+	var audited int
+	if err := h.DB.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE action = 'recompute'`).Scan(&audited); err != nil {
+		t.Fatal(err)
+	}
+	if audited != 3 {
+		t.Errorf("recompute audit entries = %d, want 3", audited)
+	}
+}
+
+// TestRecomputeGroupsRefusedDuringScan verifies that recompute-groups is
+// refused with SCAN_ALREADY_RUNNING while a scan runs and changes nothing.
+func TestRecomputeGroupsRefusedDuringScan(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	groupID, fileIDs := mustInsertGroup(t, h.DB, "aaaa", filepath.Join(dir, "f1"), filepath.Join(dir, "f2"))
+	if _, err := h.DB.Exec(`DELETE FROM duplicate_files WHERE id = ?`, fileIDs[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// A throttled scan over same-size files stays busy hashing well past
+	// the request below.
+	root := t.TempDir()
+	content := strings.Repeat("x", 1<<16)
+	for i := 0; i < 40; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%02d", i)), []byte(fmt.Sprintf("%02d", i)+content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := scan.DefaultConfig()
+	cfg.CPUPercent = 1
+	h.ScanMgr = scan.NewManager(mustOpenDB(t), []string{root}, nil, cfg)
+	if _, err := h.ScanMgr.Start(context.Background(), "manual"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { h.ScanMgr.Shutdown(context.Background()) })
+
+	rec := serve(http.HandlerFunc(h.RecomputeGroups), http.MethodPost, "/api/admin/recompute-groups", "")
+	if rec.Code != http.StatusConflict || errorCode(t, rec.Body.Bytes()) != "SCAN_ALREADY_RUNNING" {
+		t.Fatalf("status %d, body %s; want 409 SCAN_ALREADY_RUNNING", rec.Code, rec.Body)
+	}
+	if g := mustGetGroup(t, h, groupID); g.FileCount != 2 || g.Status != "unresolved" {
+		t.Errorf("group changed during refusal: file_count %d, status %q", g.FileCount, g.Status)
+	}
+}
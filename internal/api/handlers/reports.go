@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eargollo/ditto/internal/config"
+)
+
+// ReportsHandler handles the analytical /api/reports endpoints.
+type ReportsHandler struct {
+	DB  *sql.DB
+	Cfg *config.Config
+}
+
+type nameVariantFile struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	MTime string `json:"mtime"`
+}
+
+type nameVariantSet struct {
+	Dir   string            `json:"dir"`
+	Key   string            `json:"key"`
+	Files []nameVariantFile `json:"files"`
+}
+
+var (
+	// "name (1)", "name(12)"
+	variantCounterRe = regexp.MustCompile(`\s*\(\d+\)$`)
+	// "name - copy", "name copy", "name copy 2", "name - copy (3)"
+	variantCopySuffixRe = regexp.MustCompile(`(\s*-)?\s+copy(\s+\d+)?$`)
+	// "copy of name"
+	variantCopyPrefixRe = regexp.MustCompile(`^copy of\s+`)
+	whitespaceRe        = regexp.MustCompile(`\s+`)
+)
+
+// nameVariantKey normalises a file name so that names differing only by case,
+// surrounding or repeated whitespace, a " (N)" counter or a "copy" marker map
+// to the same key. The extension is kept (lower-cased) so "a.jpg" and "a.png"
+// are not variants of each other.
+func nameVariantKey(name string) string {
+	ext := strings.ToLower(strings.TrimSpace(filepath.Ext(name)))
+	base := strings.ToLower(strings.TrimSpace(strings.TrimSuffix(name, filepath.Ext(name))))
+	base = whitespaceRe.ReplaceAllString(base, " ")
+	for {
+		prev := base
+		base = variantCounterRe.ReplaceAllString(base, "")
+		base = variantCopySuffixRe.ReplaceAllString(base, "")
+		base = variantCopyPrefixRe.ReplaceAllString(base, "")
+		base = strings.TrimSpace(base)
+		if base == prev {
+			break
+		}
+	}
+	return base + ext
+}
+
+// NameVariants handles GET /api/reports/name-variants — walks the scan roots
+// and lists, per directory, sets of files whose names differ only by case,
+// whitespace, a " (N)" counter or a "copy" marker (e.g. "File.jpg" and
+// "file (1).jpg"). Content is not compared: the point is to surface near-
+// duplicates that do not group by hash. Optional ?path= restricts the walk to
+// one directory tree. Paginated over sets.
+func (h *ReportsHandler) NameVariants(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+
+	h.Cfg.Lock()
+	roots := append([]string{}, h.Cfg.ScanPaths...)
+	excludes := make(map[string]bool, len(h.Cfg.ExcludePaths))
+	for _, p := range h.Cfg.ExcludePaths {
+		excludes[filepath.Clean(p)] = true
+	}
+	h.Cfg.Unlock()
+
+	if p := r.URL.Query().Get("path"); p != "" {
+		roots = []string{filepath.Clean(p)}
+	}
+
+	sets := []nameVariantSet{}
+	for _, root := range roots {
+		if err := collectNameVariants(r.Context(), filepath.Clean(root), excludes, &sets); err != nil {
+			if r.Context().Err() != nil {
+				return
+			}
+			slog.Warn("reports name-variants: walk", "root", root, "error", err)
+		}
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].Dir != sets[j].Dir {
+			return sets[i].Dir < sets[j].Dir
+		}
+		return sets[i].Key < sets[j].Key
+	})
+
+	total := len(sets)
+	page := []nameVariantSet{}
+	if offset < total {
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		page = sets[offset:end]
+	}
+	writeList(w, ListResponse[nameVariantSet]{
+		Items:  page,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// collectNameVariants processes dir one directory at a time — grouping the
+// regular files it contains by nameVariantKey — then recurses into its
+// subdirectories, so memory is bounded by a single directory listing per
+// level rather than the whole tree. Unreadable subdirectories are skipped.
+func collectNameVariants(ctx context.Context, dir string, excludes map[string]bool, sets *[]nameVariantSet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if excludes[dir] {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string][]nameVariantFile)
+	var subdirs []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			subdirs = append(subdirs, path)
+			continue
+		}
+		if !e.Type().IsRegular() || excludes[path] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		key := nameVariantKey(e.Name())
+		byKey[key] = append(byKey[key], nameVariantFile{
+			Name:  e.Name(),
+			Path:  path,
+			Size:  info.Size(),
+			MTime: info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+	for key, files := range byKey {
+		if len(files) < 2 {
+			continue
+		}
+		*sets = append(*sets, nameVariantSet{Dir: dir, Key: key, Files: files})
+	}
+
+	for _, sub := range subdirs {
+		if err := collectNameVariants(ctx, sub, excludes, sets); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			slog.Debug("reports name-variants: skip dir", "dir", sub, "error", err)
+		}
+	}
+	return nil
+}
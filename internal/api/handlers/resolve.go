@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/trash"
+)
+
+// resolvePolicies lists the keeper policies accepted by POST /api/groups/resolve.
+var resolvePolicies = map[string]bool{
+	"keep_one_per_dir": true,
+}
+
+// resolveRequest is the body of POST /api/groups/resolve.
+type resolveRequest struct {
+	// Policy selects which files are kept in each group.
+	Policy string `json:"policy"`
+	// Depth is the number of path components below the file's scan root that
+	// identify a directory tree for keep_one_per_dir (1 = top-level
+	// directory). 0 means the file's own directory.
+	Depth int `json:"depth"`
+	// GroupIDs restricts the operation to these groups. When empty, every
+	// active (unresolved / watching_alert) group is considered. Inactive
+	// groups (ignored, watching, resolved, gone) and pinned groups are always
+	// skipped.
+	GroupIDs []int64 `json:"group_ids"`
+	// DryRun reports what would be trashed without touching any file.
+	DryRun bool `json:"dry_run"`
+}
+
+type resolveGroupResult struct {
+	GroupID       int64               `json:"group_id"`
+	DeleteFileIDs []int64             `json:"delete_file_ids"`
+	Trashed       []trashedItem       `json:"trashed,omitempty"`
+	Status        string              `json:"status,omitempty"`
+	Skipped       string              `json:"skipped,omitempty"`
+	SkippedFiles  []validationFailure `json:"skipped_files,omitempty"`
+}
+
+// Resolve handles POST /api/groups/resolve — applies a keeper policy to many
+// groups at once, trashing every file the policy does not keep.
+// Groups that fail validation are skipped and reported; the rest proceed.
+func (h *GroupsHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	var body resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if !resolvePolicies[body.Policy] {
+		writeError(w, http.StatusBadRequest, "INVALID_POLICY", "policy must be 'keep_one_per_dir'")
+		return
+	}
+	if body.Depth < 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_DEPTH", "depth must be >= 0")
+		return
+	}
+	if !body.DryRun && h.Cfg != nil && h.Cfg.ReadOnly {
+		writeReadOnly(w)
+		return
+	}
+
+	groupIDs := body.GroupIDs
+	if len(groupIDs) == 0 {
+		rows, err := h.DB.QueryContext(r.Context(), `
+			SELECT id FROM duplicate_groups
+			WHERE status IN ('unresolved','watching_alert')
+			ORDER BY id`)
+		if err != nil {
+			slog.Error("groups resolve: query groups", "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		for rows.Next() {
+			var id int64
+			if rows.Scan(&id) == nil {
+				groupIDs = append(groupIDs, id)
+			}
+		}
+		rows.Close()
+	}
+
+	var roots []string
+	if h.Cfg != nil {
+		h.Cfg.Lock()
+		roots = append(roots, h.Cfg.ScanPaths...)
+		h.Cfg.Unlock()
+	}
+
+	results := []resolveGroupResult{}
+	var trashedCount int
+	for _, groupID := range groupIDs {
+		if r.Context().Err() != nil {
+			break
+		}
+		res := resolveGroupResult{GroupID: groupID}
+
+		if active, err := h.groupActive(r.Context(), groupID); err != nil || !active {
+			switch {
+			case errors.Is(err, errGroupNotFound):
+				res.Skipped = "NOT_FOUND"
+			case err != nil:
+				res.Skipped = err.Error()
+			default:
+				res.Skipped = "NOT_ACTIVE"
+			}
+			results = append(results, res)
+			continue
+		}
+		if pinned, err := h.groupPinned(r.Context(), groupID); err != nil || pinned {
+			switch {
+			case pinned:
+				res.Skipped = "PINNED"
+			case errors.Is(err, errGroupNotFound):
+				res.Skipped = "NOT_FOUND"
+			default:
+				res.Skipped = err.Error()
+			}
+			results = append(results, res)
+			continue
+		}
+
+		files, err := h.loadGroupFiles(r.Context(), groupID)
+		if err != nil {
+			res.Skipped = err.Error()
+			results = append(results, res)
+			continue
+		}
+		res.DeleteFileIDs = keepOnePerDir(files, roots, body.Depth)
+		if len(res.DeleteFileIDs) == 0 {
+			res.Skipped = "NOTHING_TO_DELETE"
+			results = append(results, res)
+			continue
+		}
+		if body.DryRun {
+			results = append(results, res)
+			continue
+		}
+
+		tr, err := h.trashGroupFiles(r.Context(), groupID, res.DeleteFileIDs, modeTrash)
+		if err != nil {
+			var verr *validationError
+			switch {
+			case errors.Is(err, errGroupNotFound):
+				res.Skipped = "NOT_FOUND"
+			case errors.As(err, &verr):
+				res.Skipped = "VALIDATION_FAILED"
+			case errors.Is(err, errApproximateGroup):
+				res.Skipped = "APPROXIMATE_GROUP"
+			case errors.Is(err, errMetadataGroup):
+				res.Skipped = "METADATA_GROUP"
+			case errors.As(err, new(*trash.ErrInsufficientSpace)):
+				res.Skipped = "INSUFFICIENT_SPACE"
+			default:
+				slog.Error("groups resolve: trash", "group_id", groupID, "error", err)
+				res.Skipped = err.Error()
+			}
+			results = append(results, res)
+			continue
+		}
+		res.Trashed = tr.Trashed
+		res.SkippedFiles = tr.Skipped
+		res.Status = tr.Status
+		h.audit(r, groupID, "resolve", map[string]interface{}{
+			"policy":  body.Policy,
+			"depth":   body.Depth,
+			"trashed": tr.Trashed,
+			"skipped": tr.Skipped,
+			"status":  tr.Status,
+		})
+		trashedCount += len(tr.Trashed)
+		results = append(results, res)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"policy":        body.Policy,
+		"dry_run":       body.DryRun,
+		"groups":        results,
+		"trashed_count": trashedCount,
+	})
+}
+
+// keepOnePerDir returns the IDs of files to delete so that exactly one file
+// remains per directory tree. A file's tree is its scan root plus the first
+// depth components of its directory below that root (depth 0 = the directory
+// itself). Within a tree the file with the oldest mtime is kept, ties broken
+// by path.
+func keepOnePerDir(files map[int64]groupFileRecord, roots []string, depth int) []int64 {
+	sorted := make([]groupFileRecord, 0, len(files))
+	for _, f := range files {
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].MTime != sorted[j].MTime {
+			return sorted[i].MTime < sorted[j].MTime
+		}
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	kept := make(map[string]bool)
+	var deleteIDs []int64
+	for _, f := range sorted {
+		key := dirTreeKey(f.Path, roots, depth)
+		if kept[key] {
+			deleteIDs = append(deleteIDs, f.ID)
+			continue
+		}
+		kept[key] = true
+	}
+	return deleteIDs
+}
+
+// dirTreeKey truncates the directory of path to its scan root plus the first
+// depth components below it. Paths outside every root are measured from "/".
+func dirTreeKey(path string, roots []string, depth int) string {
+	dir := filepath.Dir(path)
+	if depth == 0 {
+		return dir
+	}
+	base := string(filepath.Separator)
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if (dir == root || strings.HasPrefix(dir, root+string(filepath.Separator))) && len(root) > len(base) {
+			base = root
+		}
+	}
+	rel, err := filepath.Rel(base, dir)
+	if err != nil || rel == "." {
+		return base
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return filepath.Join(append([]string{base}, parts...)...)
+}
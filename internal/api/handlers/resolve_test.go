@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirTreeKey(t *testing.T) {
+	roots := []string{"/data", "/data/photos", "/backup/"}
+	tests := []struct {
+		name  string
+		path  string
+		depth int
+		want  string
+	}{
+		{"depth 0 is the file's directory", "/data/a/b/f.jpg", 0, "/data/a/b"},
+		{"depth 0 ignores roots", "/elsewhere/x/f.jpg", 0, "/elsewhere/x"},
+		{"depth 1 is the top-level directory", "/data/a/b/f.jpg", 1, "/data/a"},
+		{"depth 2", "/data/a/b/c/f.jpg", 2, "/data/a/b"},
+		{"depth deeper than the path", "/data/a/f.jpg", 3, "/data/a"},
+		{"file directly in a root", "/data/f.jpg", 1, "/data"},
+		{"root with a trailing slash", "/backup/a/b/f.jpg", 1, "/backup/a"},
+		{"outside every root is measured from /", "/elsewhere/x/y/f.jpg", 1, "/elsewhere"},
+		{"nested root wins over its parent", "/data/photos/2020/trip/f.jpg", 1, "/data/photos/2020"},
+		{"sibling of a nested root uses the parent", "/data/photos2/trip/f.jpg", 1, "/data/photos2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dirTreeKey(tt.path, roots, tt.depth); got != tt.want {
+				t.Errorf("dirTreeKey(%q, %d) = %q, want %q", tt.path, tt.depth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepOnePerDir(t *testing.T) {
+	files := map[int64]groupFileRecord{
+		1: {ID: 1, Path: "/data/a/x/f", MTime: 300},
+		2: {ID: 2, Path: "/data/a/y/f", MTime: 100}, // oldest in /data/a
+		3: {ID: 3, Path: "/data/b/f", MTime: 200},
+		4: {ID: 4, Path: "/data/b/g", MTime: 200}, // ties with 3, loses on path
+	}
+	got := map[int64]bool{}
+	for _, id := range keepOnePerDir(files, []string{"/data"}, 1) {
+		got[id] = true
+	}
+	if len(got) != 2 || !got[1] || !got[4] {
+		t.Errorf("delete IDs = %v, want 1 and 4", got)
+	}
+}
+
+// resolveResponse is the body of POST /api/groups/resolve.
+type resolveResponse struct {
+	DryRun       bool                 `json:"dry_run"`
+	Groups       []resolveGroupResult `json:"groups"`
+	TrashedCount int                  `json:"trashed_count"`
+}
+
+func postResolve(t *testing.T, h *GroupsHandler, body string) resolveResponse {
+	t.Helper()
+	rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/resolve", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resolve: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp resolveResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode resolve response: %v", err)
+	}
+	return resp
+}
+
+// TestResolveDryRunTouchesNothing verifies that a dry run reports the files
+// the policy would trash but leaves them, and the group, as they are.
+func TestResolveDryRunTouchesNothing(t *testing.T) {
+	h := newTestGroupsHandler(t, mustDefaultConfig(t))
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a", "f1"), filepath.Join(dir, "a", "f2")}
+	groupID, _ := mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	resp := postResolve(t, h, `{"policy":"keep_one_per_dir","dry_run":true}`)
+	if !resp.DryRun || resp.TrashedCount != 0 || len(resp.Groups) != 1 {
+		t.Fatalf("response = %+v, want one dry-run group and nothing trashed", resp)
+	}
+	if g := resp.Groups[0]; g.GroupID != groupID || len(g.DeleteFileIDs) != 1 || g.Skipped != "" {
+		t.Errorf("group result = %+v, want one file to delete", g)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+	var trashed, files int
+	h.DB.QueryRow(`SELECT COUNT(*) FROM trash`).Scan(&trashed)
+	h.DB.QueryRow(`SELECT COUNT(*) FROM duplicate_files WHERE group_id = ?`, groupID).Scan(&files)
+	if trashed != 0 || files != 2 {
+		t.Errorf("trash rows = %d, group files = %d; want 0 and 2", trashed, files)
+	}
+}
+
+// TestResolveSkipsGroups verifies that pinned groups, groups holding a file
+// under protected_dirs and groups that are not active are left alone, even
+// when listed in group_ids, while the others are resolved.
+func TestResolveSkipsGroups(t *testing.T) {
+	cfg := mustDefaultConfig(t)
+	dir := t.TempDir()
+	cfg.ProtectedDirs = []string{filepath.Join(dir, "keep")}
+	h := newTestGroupsHandler(t, cfg)
+
+	pairs := map[string][]string{}
+	ids := map[string]int64{}
+	for _, name := range []string{"plain", "pinned", "ignored"} {
+		pairs[name] = []string{filepath.Join(dir, name, "f1"), filepath.Join(dir, name, "f2")}
+		ids[name], _ = mustInsertGroup(t, h.DB, name, pairs[name]...)
+	}
+	pairs["protected"] = []string{filepath.Join(dir, "keep", "f1"), filepath.Join(dir, "keep", "f2")}
+	ids["protected"], _ = mustInsertGroup(t, h.DB, "protected", pairs["protected"]...)
+	if _, err := h.DB.Exec(`INSERT INTO group_overrides (content_hash, pinned, updated_at) VALUES ('pinned', 1, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.Exec(`UPDATE duplicate_groups SET status = 'ignored' WHERE id = ?`, ids["ignored"]); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := postResolve(t, h, fmt.Sprintf(`{"policy":"keep_one_per_dir","group_ids":[%d,%d,%d,%d]}`,
+		ids["plain"], ids["pinned"], ids["ignored"], ids["protected"]))
+
+	want := map[int64]string{
+		ids["plain"]:     "",
+		ids["pinned"]:    "PINNED",
+		ids["ignored"]:   "NOT_ACTIVE",
+		ids["protected"]: "VALIDATION_FAILED",
+	}
+	for _, g := range resp.Groups {
+		if g.Skipped != want[g.GroupID] {
+			t.Errorf("group %d skipped = %q, want %q", g.GroupID, g.Skipped, want[g.GroupID])
+		}
+	}
+	if len(resp.Groups) != len(want) || resp.TrashedCount != 1 {
+		t.Errorf("%d group results, %d trashed; want %d and 1", len(resp.Groups), resp.TrashedCount, len(want))
+	}
+	assertGone(t, pairs["plain"][1])
+	for _, name := range []string{"pinned", "ignored", "protected"} {
+		for _, p := range pairs[name] {
+			assertExists(t, p)
+		}
+	}
+}
+
+// TestResolveReadOnly verifies that read_only refuses a resolve that is not a
+// dry run with READ_ONLY and leaves the files on disk.
+func TestResolveReadOnly(t *testing.T) {
+	cfg := mustDefaultConfig(t)
+	cfg.ReadOnly = true
+	h := newTestGroupsHandler(t, cfg)
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a", "f1"), filepath.Join(dir, "a", "f2")}
+	mustInsertGroup(t, h.DB, "aaaa", paths...)
+
+	rec := serve(groupRoutes(h), http.MethodPost, "/api/groups/resolve", `{"policy":"keep_one_per_dir"}`)
+	if rec.Code != http.StatusForbidden || errorCode(t, rec.Body.Bytes()) != "READ_ONLY" {
+		t.Fatalf("status %d, body %s; want 403 READ_ONLY", rec.Code, rec.Body)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routeBucketsMs are the upper bounds, in milliseconds, of the latency
+// histogram kept per route. A last, implicit bucket counts slower requests.
+var routeBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// RouteMetrics records handler latency per chi route pattern ("GET
+// /api/groups/{id}"), so slow endpoints can be found without log analysis.
+// Counters live in memory and start over on restart.
+type RouteMetrics struct {
+	mu     sync.Mutex
+	since  time.Time
+	routes map[string]*routeStats
+}
+
+type routeStats struct {
+	count   int64
+	totalUs int64
+	maxUs   int64
+	buckets []int64 // len(routeBucketsMs)+1
+}
+
+// NewRouteMetrics creates an empty RouteMetrics.
+func NewRouteMetrics() *RouteMetrics {
+	return &RouteMetrics{since: time.Now(), routes: make(map[string]*routeStats)}
+}
+
+// Middleware times every request and records it under its route pattern,
+// which chi only knows once routing is done. Requests that matched no route
+// are recorded as "<METHOD> (unmatched)".
+func (m *RouteMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer func() {
+			pattern := "(unmatched)"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				pattern = rctx.RoutePattern()
+			}
+			m.observe(r.Method+" "+pattern, time.Since(start).Microseconds())
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *RouteMetrics) observe(route string, us int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.routes[route]
+	if st == nil {
+		st = &routeStats{buckets: make([]int64, len(routeBucketsMs)+1)}
+		m.routes[route] = st
+	}
+	st.count++
+	st.totalUs += us
+	st.maxUs = max(st.maxUs, us)
+	st.buckets[sort.SearchFloat64s(routeBucketsMs, float64(us)/1000)]++
+}
+
+type routeMetricsItem struct {
+	Route   string  `json:"route"`
+	Count   int64   `json:"count"`
+	TotalMs float64 `json:"total_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	Buckets []int64 `json:"buckets"`
+}
+
+// ServeHTTP handles GET /api/admin/metrics: the latency histogram of every
+// route served since startup, slowest in total first.
+func (m *RouteMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	items := make([]routeMetricsItem, 0, len(m.routes))
+	for route, st := range m.routes {
+		items = append(items, routeMetricsItem{
+			Route:   route,
+			Count:   st.count,
+			TotalMs: float64(st.totalUs) / 1000,
+			MeanMs:  float64(st.totalUs/st.count) / 1000,
+			MaxMs:   float64(st.maxUs) / 1000,
+			Buckets: append([]int64(nil), st.buckets...),
+		})
+	}
+	since := m.since
+	m.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].TotalMs != items[j].TotalMs {
+			return items[i].TotalMs > items[j].TotalMs
+		}
+		return items[i].Route < items[j].Route
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"since":            since.UTC().Format(time.RFC3339),
+		"bucket_bounds_ms": routeBucketsMs,
+		"routes":           items,
+	})
+}
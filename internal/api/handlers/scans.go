@@ -3,10 +3,15 @@ package handlers
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,26 +23,320 @@ import (
 type ScansHandler struct {
 	DB      *sql.DB
 	Manager *scan.Manager
+	idemMu  sync.Mutex // serialises Create calls carrying an Idempotency-Key
 }
 
-// Create handles POST /api/scans — triggers a manual scan.
+const (
+	// idempotencyWindow is how long an Idempotency-Key replays its scan.
+	idempotencyWindow = 24 * time.Hour
+	maxIdempotencyKey = 255
+)
+
+// Create handles POST /api/scans — triggers a manual scan. An optional body
+// {"resume": true} resumes the most recent interrupted scan instead (see
+// scan.Manager.Resume); {"exclude_paths": [...]} skips those directories in
+// addition to the configured excludes, for this scan only.
+//
+// A request carrying an Idempotency-Key header already seen within
+// idempotencyWindow does not start anything: it gets 200 with the scan the
+// first request created, in its current state.
 func (h *ScansHandler) Create(w http.ResponseWriter, r *http.Request) {
-	active, err := h.Manager.Start(context.Background(), "manual")
+	var body struct {
+		Resume bool `json:"resume"`
+		// ExcludePaths are skipped in addition to the configured excludes,
+		// for this scan only.
+		ExcludePaths []string `json:"exclude_paths"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	if body.Resume && len(body.ExcludePaths) > 0 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "exclude_paths cannot be combined with resume")
+		return
+	}
+	for i, p := range body.ExcludePaths {
+		if !filepath.IsAbs(p) {
+			writeError(w, http.StatusBadRequest, "INVALID_PATH", "exclude_paths must be absolute paths: "+p)
+			return
+		}
+		body.ExcludePaths[i] = filepath.Clean(p)
+	}
+
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKey {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST",
+			fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKey))
+		return
+	}
+	if key != "" {
+		// Held until the key is recorded, so a concurrent retry sees it.
+		h.idemMu.Lock()
+		defer h.idemMu.Unlock()
+
+		resp, found, err := h.replayIdempotent(r.Context(), key)
+		if err != nil {
+			slog.Error("scans: idempotency lookup", "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if found {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+
+	var active *scan.ActiveScan
+	var err error
+	switch {
+	case body.Resume:
+		active, err = h.Manager.Resume(context.Background(), "manual")
+	case len(body.ExcludePaths) > 0:
+		active, err = h.Manager.StartExcluding(context.Background(), "manual", body.ExcludePaths)
+	default:
+		active, err = h.Manager.Start(context.Background(), "manual")
+	}
 	if err != nil {
 		if errors.Is(err, scan.ErrAlreadyRunning) {
 			writeError(w, http.StatusConflict, "SCAN_ALREADY_RUNNING", "A scan is already in progress")
 			return
 		}
+		if errors.Is(err, scan.ErrNothingToResume) {
+			writeError(w, http.StatusConflict, "NOTHING_TO_RESUME", "The most recent scan was not interrupted")
+			return
+		}
 		slog.Error("scans: start", "error", err)
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to start scan")
 		return
 	}
 
-	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+	if key != "" {
+		if err := h.recordIdempotent(r.Context(), key, active.ID); err != nil {
+			// The scan is running; only a retry's replay is lost.
+			slog.Error("scans: record idempotency key", "scan_id", active.ID, "error", err)
+		}
+	}
+
+	resp := map[string]interface{}{
 		"id":           active.ID, // may be 0 momentarily until goroutine sets it
 		"status":       "running",
 		"started_at":   active.StartedAt.UTC().Format(time.RFC3339),
 		"triggered_by": active.TriggeredBy,
+	}
+	if active.ResumedFrom != 0 {
+		resp["resumed_from_scan_id"] = active.ResumedFrom
+	}
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// replayIdempotent looks key up and, when it was recorded within
+// idempotencyWindow, returns the Create response for its scan as it is now.
+func (h *ScansHandler) replayIdempotent(ctx context.Context, key string) (map[string]interface{}, bool, error) {
+	cutoff := time.Now().Add(-idempotencyWindow).Unix()
+	var (
+		id          int64
+		status      string
+		triggeredBy string
+		startedAt   int64
+		resumedFrom sql.NullInt64
+	)
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT s.id, s.status, s.triggered_by, s.started_at, s.resumed_from_scan_id
+		FROM scan_idempotency_keys k
+		JOIN scan_history s ON s.id = k.scan_id
+		WHERE k.key = ? AND k.created_at >= ?`, key, cutoff,
+	).Scan(&id, &status, &triggeredBy, &startedAt, &resumedFrom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp := map[string]interface{}{
+		"id":           id,
+		"status":       status,
+		"started_at":   time.Unix(startedAt, 0).UTC().Format(time.RFC3339),
+		"triggered_by": triggeredBy,
+	}
+	if resumedFrom.Valid {
+		resp["resumed_from_scan_id"] = resumedFrom.Int64
+	}
+	return resp, true, nil
+}
+
+// recordIdempotent stores key against scanID and prunes expired keys.
+func (h *ScansHandler) recordIdempotent(ctx context.Context, key string, scanID int64) error {
+	now := time.Now()
+	if _, err := h.DB.ExecContext(ctx,
+		`DELETE FROM scan_idempotency_keys WHERE created_at < ?`,
+		now.Add(-idempotencyWindow).Unix(),
+	); err != nil {
+		return err
+	}
+	_, err := h.DB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO scan_idempotency_keys (key, scan_id, created_at) VALUES (?, ?, ?)`,
+		key, scanID, now.Unix())
+	return err
+}
+
+// reportGroupItem is one group in the POST /api/scans/report response.
+type reportGroupItem struct {
+	ContentHash      string   `json:"content_hash"`
+	FileSize         int64    `json:"file_size"`
+	FileType         string   `json:"file_type"`
+	FileCount        int      `json:"file_count"`
+	ReclaimableBytes int64    `json:"reclaimable_bytes"`
+	Paths            []string `json:"paths"`
+}
+
+// Report handles POST /api/scans/report — runs a report-only scan
+// synchronously and returns the duplicates it found without writing groups,
+// file_cache or scan history (see scan.Manager.Report). The groups array is
+// encoded and flushed incrementally so large reports are not built in memory
+// a second time. Disconnecting cancels the scan.
+func (h *ScansHandler) Report(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.Manager.Report(r.Context())
+	if err != nil {
+		if errors.Is(err, scan.ErrAlreadyRunning) {
+			writeError(w, http.StatusConflict, "SCAN_ALREADY_RUNNING", "A scan is already in progress")
+			return
+		}
+		if r.Context().Err() != nil {
+			slog.Info("scans report: client gone", "error", err)
+			return
+		}
+		slog.Error("scans report", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	var files, reclaimable int64
+	for _, g := range groups {
+		files += int64(len(g.Paths))
+		reclaimable += g.ReclaimableBytes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"duplicate_groups":%d,"duplicate_files":%d,"reclaimable_bytes":%d,"groups":[`,
+		len(groups), files, reclaimable)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for i, g := range groups {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		if err := enc.Encode(reportGroupItem{
+			ContentHash:      g.ContentHash,
+			FileSize:         g.FileSize,
+			FileType:         string(g.FileType),
+			FileCount:        len(g.Paths),
+			ReclaimableBytes: g.ReclaimableBytes,
+			Paths:            g.Paths,
+		}); err != nil {
+			slog.Warn("scans report: write", "error", err)
+			return
+		}
+		if flusher != nil && i%100 == 99 {
+			flusher.Flush()
+		}
+	}
+	io.WriteString(w, "]}\n")
+}
+
+// streamBuffer is the number of written groups held for a slow stream
+// consumer before further groups are dropped.
+const streamBuffer = 10_000
+
+// streamGroupRecord is a "group" line of POST /api/scans/stream.
+type streamGroupRecord struct {
+	Type    string `json:"type"`
+	GroupID int64  `json:"group_id"`
+	reportGroupItem
+}
+
+// Stream handles POST /api/scans/stream — starts a manual scan and holds the
+// connection open, writing one NDJSON record per duplicate group as soon as
+// the scan commits it: a "scan" line first, then "group" lines, then an "end"
+// line with the final status and the number of groups dropped because the
+// client read too slowly (writes never wait for the client). Disconnecting
+// stops the stream, not the scan.
+func (h *ScansHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	stream := scan.NewGroupStream(streamBuffer)
+	active, err := h.Manager.StartStream(context.Background(), "manual", stream)
+	if err != nil {
+		if errors.Is(err, scan.ErrAlreadyRunning) {
+			writeError(w, http.StatusConflict, "SCAN_ALREADY_RUNNING", "A scan is already in progress")
+			return
+		}
+		slog.Error("scans stream: start", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to start scan")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	emit := func(v interface{}) bool {
+		if err := enc.Encode(v); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	if !emit(map[string]interface{}{
+		"type":       "scan",
+		"id":         active.ID,
+		"started_at": active.StartedAt.UTC().Format(time.RFC3339),
+	}) {
+		return
+	}
+	groups := stream.Groups()
+	for {
+		var g scan.ReportGroup
+		var ok bool
+		select {
+		case <-r.Context().Done():
+			slog.Info("scans stream: client gone", "scan_id", active.ID)
+			return
+		case g, ok = <-groups:
+		}
+		if !ok {
+			break
+		}
+		if !emit(streamGroupRecord{
+			Type:    "group",
+			GroupID: g.GroupID,
+			reportGroupItem: reportGroupItem{
+				ContentHash:      g.ContentHash,
+				FileSize:         g.FileSize,
+				FileType:         string(g.FileType),
+				FileCount:        len(g.Paths),
+				ReclaimableBytes: g.ReclaimableBytes,
+				Paths:            g.Paths,
+			},
+		}) {
+			slog.Info("scans stream: client gone", "scan_id", active.ID)
+			return
+		}
+	}
+
+	var status string
+	_ = h.DB.QueryRowContext(r.Context(),
+		`SELECT status FROM scan_history WHERE id = ?`, active.ID).Scan(&status)
+	if n := stream.Dropped(); n > 0 {
+		slog.Warn("scans stream: groups dropped for a slow client", "scan_id", active.ID, "dropped", n)
+	}
+	emit(map[string]interface{}{
+		"type":    "end",
+		"id":      active.ID,
+		"status":  status,
+		"dropped": stream.Dropped(),
 	})
 }
 
@@ -66,7 +365,7 @@ func (h *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := h.DB.QueryContext(r.Context(), `
 		SELECT id, started_at, finished_at, status, triggered_by,
-		       files_discovered, files_hashed, cache_hits, cache_misses,
+		       files_discovered, files_hashed, cache_hits, cache_misses, files_added,
 		       duplicate_groups, duplicate_files, reclaimable_bytes,
 		       errors, duration_seconds
 		FROM scan_history
@@ -90,6 +389,7 @@ func (h *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 		CacheHits        int64    `json:"cache_hits"`
 		CacheMisses      int64    `json:"cache_misses"`
 		CacheHitRate     float64  `json:"cache_hit_rate"`
+		FilesAdded       int64    `json:"files_added"`
 		DuplicateGroups  int64    `json:"duplicate_groups"`
 		DuplicateFiles   int64    `json:"duplicate_files"`
 		ReclaimableBytes int64    `json:"reclaimable_bytes"`
@@ -105,7 +405,7 @@ func (h *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 		var durSecs sql.NullInt64
 		if err := rows.Scan(
 			&it.ID, &startedAt, &finishedAt, &it.Status, &it.TriggeredBy,
-			&it.FilesDiscovered, &it.FilesHashed, &it.CacheHits, &it.CacheMisses,
+			&it.FilesDiscovered, &it.FilesHashed, &it.CacheHits, &it.CacheMisses, &it.FilesAdded,
 			&it.DuplicateGroups, &it.DuplicateFiles, &it.ReclaimableBytes,
 			&it.Errors, &durSecs,
 		); err != nil {
@@ -133,7 +433,7 @@ func (h *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 	var total int
 	h.DB.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM scan_history`).Scan(&total)
 
-	writeJSON(w, http.StatusOK, ListResponse[scanItem]{
+	writeList(w, ListResponse[scanItem]{
 		Items:  items,
 		Total:  total,
 		Limit:  limit,
@@ -152,43 +452,50 @@ func (h *ScansHandler) Get(w http.ResponseWriter, r *http.Request) {
 	type errItem struct {
 		Path       string `json:"path"`
 		Stage      string `json:"stage"`
+		Code       string `json:"code"`
 		Error      string `json:"error"`
 		OccurredAt string `json:"occurred_at"`
 	}
 	type scanDetail struct {
-		ID               int64     `json:"id"`
-		StartedAt        string    `json:"started_at"`
-		FinishedAt       *string   `json:"finished_at"`
-		Status           string    `json:"status"`
-		TriggeredBy      string    `json:"triggered_by"`
-		FilesDiscovered  int64     `json:"files_discovered"`
-		FilesHashed      int64     `json:"files_hashed"`
-		CacheHits        int64     `json:"cache_hits"`
-		CacheMisses      int64     `json:"cache_misses"`
-		CacheHitRate     float64   `json:"cache_hit_rate"`
-		DuplicateGroups  int64     `json:"duplicate_groups"`
-		DuplicateFiles   int64     `json:"duplicate_files"`
-		ReclaimableBytes int64     `json:"reclaimable_bytes"`
-		Errors           int64     `json:"errors"`
-		DurationSeconds  *int64    `json:"duration_seconds"`
-		ErrorList        []errItem `json:"error_list"`
+		ID               int64            `json:"id"`
+		StartedAt        string           `json:"started_at"`
+		FinishedAt       *string          `json:"finished_at"`
+		Status           string           `json:"status"`
+		TriggeredBy      string           `json:"triggered_by"`
+		FilesDiscovered  int64            `json:"files_discovered"`
+		BytesDiscovered  int64            `json:"bytes_discovered"`
+		FilesHashed      int64            `json:"files_hashed"`
+		CacheHits        int64            `json:"cache_hits"`
+		CacheMisses      int64            `json:"cache_misses"`
+		CacheHitRate     float64          `json:"cache_hit_rate"`
+		FilesAdded       int64            `json:"files_added"`
+		DuplicateGroups  int64            `json:"duplicate_groups"`
+		DuplicateFiles   int64            `json:"duplicate_files"`
+		ReclaimableBytes int64            `json:"reclaimable_bytes"`
+		Errors           int64            `json:"errors"`
+		DurationSeconds  *int64           `json:"duration_seconds"`
+		ResumedFrom      *int64           `json:"resumed_from_scan_id"`
+		Config           json.RawMessage  `json:"config"`
+		ErrorCounts      map[string]int64 `json:"error_counts"`
+		ErrorList        []errItem        `json:"error_list"`
 	}
 
 	var d scanDetail
 	var startedAt int64
 	var finishedAt sql.NullInt64
-	var durSecs sql.NullInt64
+	var durSecs, resumedFrom sql.NullInt64
+	var snapshot sql.NullString
 	err = h.DB.QueryRowContext(r.Context(), `
 		SELECT id, started_at, finished_at, status, triggered_by,
-		       files_discovered, files_hashed, cache_hits, cache_misses,
+		       files_discovered, bytes_discovered, files_hashed, cache_hits, cache_misses, files_added,
 		       duplicate_groups, duplicate_files, reclaimable_bytes,
-		       errors, duration_seconds
+		       errors, duration_seconds, resumed_from_scan_id, config_snapshot
 		FROM scan_history WHERE id = ?`, id,
 	).Scan(
 		&d.ID, &startedAt, &finishedAt, &d.Status, &d.TriggeredBy,
-		&d.FilesDiscovered, &d.FilesHashed, &d.CacheHits, &d.CacheMisses,
+		&d.FilesDiscovered, &d.BytesDiscovered, &d.FilesHashed, &d.CacheHits, &d.CacheMisses, &d.FilesAdded,
 		&d.DuplicateGroups, &d.DuplicateFiles, &d.ReclaimableBytes,
-		&d.Errors, &durSecs,
+		&d.Errors, &durSecs, &resumedFrom, &snapshot,
 	)
 	if err == sql.ErrNoRows {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Scan not found")
@@ -206,6 +513,12 @@ func (h *ScansHandler) Get(w http.ResponseWriter, r *http.Request) {
 	if durSecs.Valid {
 		d.DurationSeconds = &durSecs.Int64
 	}
+	if resumedFrom.Valid {
+		d.ResumedFrom = &resumedFrom.Int64
+	}
+	if snapshot.Valid {
+		d.Config = json.RawMessage(snapshot.String)
+	}
 	total := d.CacheHits + d.CacheMisses
 	if total > 0 {
 		d.CacheHitRate = float64(d.CacheHits) / float64(total)
@@ -213,23 +526,27 @@ func (h *ScansHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch error list.
 	errRows, _ := h.DB.QueryContext(r.Context(), `
-		SELECT path, stage, error, occurred_at
+		SELECT path, stage, code, error, occurred_at
 		FROM scan_errors WHERE scan_id = ?
 		ORDER BY occurred_at`, id)
 	if errRows != nil {
-		defer errRows.Close()
 		for errRows.Next() {
 			var e errItem
 			var occAt int64
-			if errRows.Scan(&e.Path, &e.Stage, &e.Error, &occAt) == nil {
+			if errRows.Scan(&e.Path, &e.Stage, &e.Code, &e.Error, &occAt) == nil {
 				e.OccurredAt = time.Unix(occAt, 0).UTC().Format(time.RFC3339)
 				d.ErrorList = append(d.ErrorList, e)
 			}
 		}
+		errRows.Close()
 	}
 	if d.ErrorList == nil {
 		d.ErrorList = []errItem{}
 	}
+	if d.ErrorCounts, err = scanErrorCounts(r.Context(), h.DB, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
 
 	writeJSON(w, http.StatusOK, d)
 }
@@ -244,26 +561,29 @@ func (h *ScansHandler) Telemetry(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type telemetryResponse struct {
-		ScanID          int64   `json:"scan_id"`
-		StartedAt       string  `json:"started_at"`
-		FinishedAt      *string `json:"finished_at"`
-		Status          string  `json:"status"`
-		TriggeredBy     string  `json:"triggered_by"`
+		ScanID      int64   `json:"scan_id"`
+		StartedAt   string  `json:"started_at"`
+		FinishedAt  *string `json:"finished_at"`
+		Status      string  `json:"status"`
+		TriggeredBy string  `json:"triggered_by"`
 		// Raw counters
-		DurationSeconds  int64   `json:"duration_seconds"`
-		FilesDiscovered  int64   `json:"files_discovered"`
-		FilesHashed      int64   `json:"files_hashed"`
-		CacheHits        int64   `json:"cache_hits"`
-		CacheMisses      int64   `json:"cache_misses"`
-		DuplicateGroups  int64   `json:"duplicate_groups"`
-		DuplicateFiles   int64   `json:"duplicate_files"`
-		ReclaimableBytes int64   `json:"reclaimable_bytes"`
-		Errors           int64   `json:"errors"`
-		BytesReadMB      float64 `json:"bytes_read_mb"`
-		DiskReadMs       int64   `json:"disk_read_ms"`
-		DBReadMs         int64   `json:"db_read_ms"`
-		DBWriteMs        int64   `json:"db_write_ms"`
-		TotalTimingMs    int64   `json:"total_timing_ms"`
+		DurationSeconds  int64            `json:"duration_seconds"`
+		FilesDiscovered  int64            `json:"files_discovered"`
+		BytesDiscovered  int64            `json:"bytes_discovered"`
+		FilesHashed      int64            `json:"files_hashed"`
+		CacheHits        int64            `json:"cache_hits"`
+		CacheMisses      int64            `json:"cache_misses"`
+		FilesAdded       int64            `json:"files_added"`
+		DuplicateGroups  int64            `json:"duplicate_groups"`
+		DuplicateFiles   int64            `json:"duplicate_files"`
+		ReclaimableBytes int64            `json:"reclaimable_bytes"`
+		Errors           int64            `json:"errors"`
+		ErrorCounts      map[string]int64 `json:"error_counts"`
+		BytesReadMB      float64          `json:"bytes_read_mb"`
+		DiskReadMs       int64            `json:"disk_read_ms"`
+		DBReadMs         int64            `json:"db_read_ms"`
+		DBWriteMs        int64            `json:"db_write_ms"`
+		TotalTimingMs    int64            `json:"total_timing_ms"`
 		// Computed efficiency metrics
 		FilesPerSec        float64 `json:"files_per_sec"`
 		CandidatePct       float64 `json:"candidate_pct"`
@@ -281,14 +601,14 @@ func (h *ScansHandler) Telemetry(w http.ResponseWriter, r *http.Request) {
 	var bytesRead int64
 	err = h.DB.QueryRowContext(r.Context(), `
 		SELECT id, started_at, finished_at, status, triggered_by,
-		       files_discovered, files_hashed, cache_hits, cache_misses,
+		       files_discovered, bytes_discovered, files_hashed, cache_hits, cache_misses, files_added,
 		       duplicate_groups, duplicate_files, reclaimable_bytes,
 		       errors, duration_seconds,
 		       progress_bytes_read, disk_read_ms, db_read_ms, db_write_ms
 		FROM scan_history WHERE id = ?`, id,
 	).Scan(
 		&d.ScanID, &startedAt, &finishedAt, &d.Status, &d.TriggeredBy,
-		&d.FilesDiscovered, &d.FilesHashed, &d.CacheHits, &d.CacheMisses,
+		&d.FilesDiscovered, &d.BytesDiscovered, &d.FilesHashed, &d.CacheHits, &d.CacheMisses, &d.FilesAdded,
 		&d.DuplicateGroups, &d.DuplicateFiles, &d.ReclaimableBytes,
 		&d.Errors, &durSecs,
 		&bytesRead, &d.DiskReadMs, &d.DBReadMs, &d.DBWriteMs,
@@ -333,10 +653,118 @@ func (h *ScansHandler) Telemetry(w http.ResponseWriter, r *http.Request) {
 		d.DBWritePct = float64(d.DBWriteMs) * 100 / float64(d.TotalTimingMs)
 		d.DBReadPct = float64(d.DBReadMs) * 100 / float64(d.TotalTimingMs)
 	}
+	if d.ErrorCounts, err = scanErrorCounts(r.Context(), h.DB, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
 
 	writeJSON(w, http.StatusOK, d)
 }
 
+// scanErrorCounts returns the number of scan_errors rows per error code for
+// one scan. Codes with no errors are absent from the map.
+func scanErrorCounts(ctx context.Context, db *sql.DB, scanID int64) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT code, COUNT(*) FROM scan_errors WHERE scan_id = ? GROUP BY code`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int64{}
+	for rows.Next() {
+		var code string
+		var n int64
+		if err := rows.Scan(&code, &n); err != nil {
+			return nil, err
+		}
+		counts[code] = n
+	}
+	return counts, rows.Err()
+}
+
+type timelineSample struct {
+	T               string `json:"t"`
+	ElapsedSeconds  int64  `json:"elapsed_seconds"`
+	FilesDiscovered int64  `json:"files_discovered"`
+	FullHashed      int64  `json:"full_hashed"`
+	BytesRead       int64  `json:"bytes_read"`
+	// Rates over the interval since the previous sample (or scan start).
+	FilesHashedPerSec float64 `json:"files_hashed_per_sec"`
+	BytesReadPerSec   float64 `json:"bytes_read_per_sec"`
+}
+
+// Timeline handles GET /api/scans/:id/timeline — the scan's milestones plus
+// the periodic progress samples recorded while it ran, oldest first, with the
+// hash rate over each interval so stalls show up as dips.
+func (h *ScansHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ID", "Invalid scan ID")
+		return
+	}
+
+	var startedAt, walkFinishedAt, phase2StartedAt int64
+	var finishedAt sql.NullInt64
+	err = h.DB.QueryRowContext(r.Context(), `
+		SELECT started_at, walk_finished_at, phase2_started_at, finished_at
+		FROM scan_history WHERE id = ?`, id,
+	).Scan(&startedAt, &walkFinishedAt, &phase2StartedAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Scan not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT t, files_discovered, full_hashed, bytes_read
+		FROM scan_progress_samples WHERE scan_id = ?
+		ORDER BY t, id`, id)
+	if err != nil {
+		slog.Error("scans timeline: query samples", "scan_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer rows.Close()
+
+	samples := []timelineSample{}
+	prevT, prevHashed, prevRead := startedAt, int64(0), int64(0)
+	for rows.Next() {
+		var t int64
+		var s timelineSample
+		if err := rows.Scan(&t, &s.FilesDiscovered, &s.FullHashed, &s.BytesRead); err != nil {
+			continue
+		}
+		s.T = time.Unix(t, 0).UTC().Format(time.RFC3339)
+		s.ElapsedSeconds = t - startedAt
+		if dt := t - prevT; dt > 0 {
+			s.FilesHashedPerSec = float64(s.FullHashed-prevHashed) / float64(dt)
+			s.BytesReadPerSec = float64(s.BytesRead-prevRead) / float64(dt)
+		}
+		prevT, prevHashed, prevRead = t, s.FullHashed, s.BytesRead
+		samples = append(samples, s)
+	}
+
+	// Milestones that have not happened (yet) are null.
+	ts := func(unix int64) *string {
+		if unix == 0 {
+			return nil
+		}
+		s := time.Unix(unix, 0).UTC().Format(time.RFC3339)
+		return &s
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scan_id":           id,
+		"started_at":        time.Unix(startedAt, 0).UTC().Format(time.RFC3339),
+		"walk_finished_at":  ts(walkFinishedAt),
+		"phase2_started_at": ts(phase2StartedAt),
+		"finished_at":       ts(finishedAt.Int64),
+		"samples":           samples,
+	})
+}
+
 // parsePagination extracts limit and offset from query parameters.
 func parsePagination(r *http.Request) (limit, offset int) {
 	limit = 50
@@ -353,3 +781,23 @@ func parsePagination(r *http.Request) (limit, offset int) {
 	}
 	return
 }
+
+// errBadCursor is returned by parseCursor for an unusable ?after=.
+var errBadCursor = errors.New("after must be a non-negative ID and cannot be combined with offset")
+
+// parseCursor extracts the keyset cursor ?after=<id>. With a cursor, a list
+// is ordered by ID ascending and starts after that ID instead of skipping
+// offset rows, which stays cheap however deep the page. ok reports whether
+// a cursor was given.
+func parseCursor(r *http.Request) (after int64, ok bool, err error) {
+	q := r.URL.Query()
+	v := q.Get("after")
+	if v == "" {
+		return 0, false, nil
+	}
+	after, err = strconv.ParseInt(v, 10, 64)
+	if err != nil || after < 0 || q.Get("offset") != "" {
+		return 0, false, errBadCursor
+	}
+	return after, true, nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/eargollo/ditto/internal/scan"
+	"github.com/eargollo/ditto/internal/scheduler"
+)
+
+// SchedulesHandler handles the /api/schedules endpoints.
+type SchedulesHandler struct {
+	Sched *scheduler.Scheduler
+}
+
+// Run handles POST /api/schedules/:name/run — runs a scheduled job now
+// instead of at its next cron time: "scan" for the scan schedule,
+// "auto-purge" for the nightly trash purge. The scan job goes through the
+// scan manager, so a scan already in progress answers 409.
+func (h *SchedulesHandler) Run(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if h.Sched == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No scheduled job named "+name)
+		return
+	}
+	err := h.Sched.RunNow(name)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No scheduled job named "+name)
+		return
+	case errors.Is(err, scan.ErrAlreadyRunning):
+		writeError(w, http.StatusConflict, "SCAN_ALREADY_RUNNING", "A scan is already in progress")
+		return
+	case err != nil:
+		slog.Error("schedules: run", "name", name, "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"name": name})
+}
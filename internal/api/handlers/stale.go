@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// staleBatchSize is the number of file_cache rows read per query, so the
+	// single write connection is never held across a long run of stats.
+	staleBatchSize = 1000
+	// staleExamples caps the stale entries listed in the response.
+	staleExamples = 50
+)
+
+type staleEntry struct {
+	Path        string `json:"path"`
+	Reason      string `json:"reason"` // "missing" or "modified"
+	InGroup     bool   `json:"in_group"`
+	CachedSize  int64  `json:"cached_size"`
+	CachedMTime string `json:"cached_mtime"`
+	Size        *int64 `json:"size"`
+	MTime       string `json:"mtime,omitempty"`
+}
+
+type staleReport struct {
+	TotalCached int64 `json:"total_cached"`
+	Checked     int64 `json:"checked"`
+	Sampled     bool  `json:"sampled"`
+	Unchanged   int64 `json:"unchanged"`
+	Missing     int64 `json:"missing"`
+	Modified    int64 `json:"modified"`
+	// Unreadable counts entries whose stat failed for another reason
+	// (e.g. permission denied); they are neither stale nor unchanged.
+	Unreadable int64 `json:"unreadable"`
+	// StaleInGroups counts missing/modified entries that are listed in a
+	// duplicate group — the ones that make reclaimable numbers wrong.
+	StaleInGroups     int64        `json:"stale_in_groups"`
+	StalePercent      float64      `json:"stale_percent"`
+	RescanRecommended bool         `json:"rescan_recommended"`
+	Examples          []staleEntry `json:"examples"`
+}
+
+// Stale handles GET /api/reports/stale — compares file_cache entries with the
+// files on disk and counts those now missing or whose size/mtime changed, i.e.
+// whose hashes (and any duplicate group built on them) are out of date. By
+// default every entry is checked; ?sample=N checks N random entries instead,
+// for a quick estimate on a large cache. Nothing is modified.
+func (h *ReportsHandler) Stale(w http.ResponseWriter, r *http.Request) {
+	sample := 0
+	if v := r.URL.Query().Get("sample"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "sample must be a positive integer")
+			return
+		}
+		sample = n
+	}
+
+	rep := staleReport{Sampled: sample > 0, Examples: []staleEntry{}}
+	if err := h.DB.QueryRowContext(r.Context(),
+		`SELECT COUNT(*) FROM file_cache`).Scan(&rep.TotalCached); err != nil {
+		slog.Error("reports stale: count", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	var err error
+	if sample > 0 {
+		err = h.checkStaleSample(r.Context(), sample, &rep)
+	} else {
+		err = h.checkStaleAll(r.Context(), &rep)
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		slog.Error("reports stale", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	if rep.Checked > 0 {
+		rep.StalePercent = float64(rep.Missing+rep.Modified) * 100 / float64(rep.Checked)
+	}
+	rep.RescanRecommended = rep.StaleInGroups > 0
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// staleRow is a file_cache entry as read for the staleness check.
+type staleRow struct {
+	path    string
+	size    int64
+	mtime   int64
+	inGroup bool
+}
+
+const staleSelect = `
+	SELECT c.path, c.size, c.mtime,
+	       EXISTS (SELECT 1 FROM duplicate_files d WHERE d.path = c.path)
+	FROM file_cache c`
+
+// checkStaleAll walks file_cache in path order, one batch per query.
+func (h *ReportsHandler) checkStaleAll(ctx context.Context, rep *staleReport) error {
+	after := ""
+	for {
+		batch, err := h.queryStaleRows(ctx,
+			staleSelect+` WHERE c.path > ? ORDER BY c.path LIMIT ?`, after, staleBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			checkStaleRow(row, rep)
+		}
+		if len(batch) < staleBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].path
+	}
+}
+
+// checkStaleSample checks n random entries.
+func (h *ReportsHandler) checkStaleSample(ctx context.Context, n int, rep *staleReport) error {
+	batch, err := h.queryStaleRows(ctx, staleSelect+` ORDER BY RANDOM() LIMIT ?`, n)
+	if err != nil {
+		return err
+	}
+	for _, row := range batch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		checkStaleRow(row, rep)
+	}
+	return nil
+}
+
+// queryStaleRows reads the rows of one query and releases the connection
+// before any file is stat'ed.
+func (h *ReportsHandler) queryStaleRows(ctx context.Context, query string, args ...interface{}) ([]staleRow, error) {
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []staleRow
+	for rows.Next() {
+		var row staleRow
+		if err := rows.Scan(&row.path, &row.size, &row.mtime, &row.inGroup); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// checkStaleRow stats one cached path and tallies the result in rep. The
+// comparison matches the scan's cache check: size and whole-second mtime.
+func checkStaleRow(row staleRow, rep *staleReport) {
+	rep.Checked++
+	entry := staleEntry{
+		Path:        row.path,
+		InGroup:     row.inGroup,
+		CachedSize:  row.size,
+		CachedMTime: time.Unix(row.mtime, 0).UTC().Format(time.RFC3339),
+	}
+
+	info, err := os.Stat(row.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		rep.Missing++
+		entry.Reason = "missing"
+	case err != nil:
+		rep.Unreadable++
+		return
+	case info.Size() == row.size && info.ModTime().Unix() == row.mtime:
+		rep.Unchanged++
+		return
+	default:
+		rep.Modified++
+		entry.Reason = "modified"
+		size := info.Size()
+		entry.Size = &size
+		entry.MTime = info.ModTime().UTC().Format(time.RFC3339)
+	}
+
+	if row.inGroup {
+		rep.StaleInGroups++
+	}
+	if len(rep.Examples) < staleExamples {
+		rep.Examples = append(rep.Examples, entry)
+	}
+}
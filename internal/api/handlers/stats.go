@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 // StatsHandler handles GET /api/stats.
@@ -11,8 +13,9 @@ type StatsHandler struct {
 }
 
 type statsResponse struct {
-	Snapshots []interface{} `json:"snapshots"`
-	Totals    statsTotals   `json:"totals"`
+	Snapshots []interface{}          `json:"snapshots"`
+	Totals    statsTotals            `json:"totals"`
+	ByTrigger map[string]statsTotals `json:"by_trigger"` // "user", "auto"
 }
 
 type statsTotals struct {
@@ -24,8 +27,41 @@ type statsTotals struct {
 
 // ServeHTTP handles GET /api/stats.
 func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, statsResponse{
+	since30d := time.Now().Add(-30 * 24 * time.Hour).Unix()
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT trigger, COUNT(*), COALESCE(SUM(file_size),0),
+		       COALESCE(SUM(CASE WHEN deleted_at >= ? THEN 1 ELSE 0 END),0),
+		       COALESCE(SUM(CASE WHEN deleted_at >= ? THEN file_size ELSE 0 END),0)
+		FROM deletion_log GROUP BY trigger`, since30d, since30d)
+	if err != nil {
+		slog.Error("stats: deletion totals", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer rows.Close()
+
+	resp := statsResponse{
 		Snapshots: []interface{}{},
-		Totals:    statsTotals{},
-	})
+		ByTrigger: map[string]statsTotals{"user": {}, "auto": {}},
+	}
+	for rows.Next() {
+		var trigger string
+		var t statsTotals
+		if err := rows.Scan(&trigger, &t.DeletedFiles, &t.ReclaimedBytes, &t.DeletedFiles30d, &t.ReclaimedBytes30d); err != nil {
+			slog.Error("stats: scan deletion totals", "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		resp.ByTrigger[trigger] = t
+		resp.Totals.DeletedFiles += t.DeletedFiles
+		resp.Totals.ReclaimedBytes += t.ReclaimedBytes
+		resp.Totals.DeletedFiles30d += t.DeletedFiles30d
+		resp.Totals.ReclaimedBytes30d += t.ReclaimedBytes30d
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("stats: deletion totals", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
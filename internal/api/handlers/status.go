@@ -23,6 +23,16 @@ type statusResponse struct {
 	ActiveScan        *activeScanInfo    `json:"active_scan"`
 	Schedule          scheduleInfo       `json:"schedule"`
 	LastCompletedScan *completedScanInfo `json:"last_completed_scan"`
+	ScanWorkers       *scanWorkersInfo   `json:"scan_workers"`
+}
+
+// scanWorkersInfo reports the worker counts the scan manager will actually
+// use for the next scan (after any runtime config changes).
+type scanWorkersInfo struct {
+	Walkers        int `json:"walkers"`
+	CacheCheckers  int `json:"cache_checkers"`
+	PartialHashers int `json:"partial_hashers"`
+	FullHashers    int `json:"full_hashers"`
 }
 
 type activeScanInfo struct {
@@ -40,6 +50,12 @@ type scanProgressInfo struct {
 	BytesRead       int64 `json:"bytes_read"`
 	CacheHits       int64 `json:"cache_hits"`
 	CacheMisses     int64 `json:"cache_misses"`
+	// Phase 2 — writing duplicate groups to the DB.
+	Phase           string  `json:"phase"` // "scanning" | "writing"
+	Phase2StartedAt *string `json:"phase2_started_at"`
+	GroupsTotal     int64   `json:"groups_total"`
+	GroupsWritten   int64   `json:"groups_written"`
+	Phase2Percent   int64   `json:"phase2_percent"`
 }
 
 type scheduleInfo struct {
@@ -67,6 +83,15 @@ func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Schedule:          h.schedule(),
 		LastCompletedScan: h.lastCompletedScan(),
 	}
+	if h.Manager != nil {
+		c := h.Manager.Config()
+		resp.ScanWorkers = &scanWorkersInfo{
+			Walkers:        c.Walkers,
+			CacheCheckers:  c.CacheCheckers,
+			PartialHashers: c.PartialHashers,
+			FullHashers:    c.FullHashers,
+		}
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -79,7 +104,7 @@ func (h *StatusHandler) activeScan() *activeScanInfo {
 		return nil
 	}
 	p := a.Progress
-	return &activeScanInfo{
+	info := &activeScanInfo{
 		ID:          a.ID,
 		StartedAt:   a.StartedAt.UTC().Format(time.RFC3339),
 		TriggeredBy: a.TriggeredBy,
@@ -91,8 +116,18 @@ func (h *StatusHandler) activeScan() *activeScanInfo {
 			BytesRead:       p.BytesRead.Load(),
 			CacheHits:       p.CacheHits.Load(),
 			CacheMisses:     p.CacheMisses.Load(),
+			Phase:           "scanning",
+			GroupsTotal:     p.GroupsTotal.Load(),
+			GroupsWritten:   p.GroupsWritten.Load(),
+			Phase2Percent:   p.Phase2Percent(),
 		},
 	}
+	if started := p.Phase2StartedAt.Load(); started > 0 {
+		s := time.Unix(started, 0).UTC().Format(time.RFC3339)
+		info.Progress.Phase = "writing"
+		info.Progress.Phase2StartedAt = &s
+	}
+	return info
 }
 
 func (h *StatusHandler) schedule() scheduleInfo {
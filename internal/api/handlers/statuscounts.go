@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+)
+
+// groupStatuses are the values of duplicate_groups.status.
+var groupStatuses = []string{"unresolved", "watching", "watching_alert", "ignored", "resolved", "gone"}
+
+// GroupStatusCounts returns the number of duplicate groups in each status,
+// with every status present (0 when no group has it).
+func GroupStatusCounts(ctx context.Context, db *sql.DB) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT status, COUNT(*) FROM duplicate_groups GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64, len(groupStatuses))
+	for _, s := range groupStatuses {
+		counts[s] = 0
+	}
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// StatusCounts handles GET /api/groups/status-counts — the number of groups
+// in each status, for filter badges.
+func (h *GroupsHandler) StatusCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := GroupStatusCounts(r.Context(), h.DB)
+	if err != nil {
+		slog.Error("groups status counts", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, counts)
+}
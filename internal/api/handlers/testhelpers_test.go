@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/eargollo/ditto/internal/config"
+	internaldb "github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/trash"
+)
+
+// mustOpenDB opens a temp file SQLite database with the full schema applied.
+func mustOpenDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := internaldb.Open(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatalf("open test DB: %v", err)
+	}
+	if err := internaldb.RunMigrations(db); err != nil {
+		db.Close()
+		tb.Fatalf("run migrations: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// mustDefaultConfig returns the configuration used when no file exists.
+func mustDefaultConfig(tb testing.TB) *config.Config {
+	tb.Helper()
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		tb.Fatalf("load default config: %v", err)
+	}
+	return cfg
+}
+
+// newTestGroupsHandler returns a GroupsHandler over a fresh database, with
+// its trash in a temp dir. cfg may be nil.
+func newTestGroupsHandler(tb testing.TB, cfg *config.Config) *GroupsHandler {
+	tb.Helper()
+	db := mustOpenDB(tb)
+	return &GroupsHandler{
+		DB:    db,
+		Trash: trash.New(db, filepath.Join(tb.TempDir(), "trash"), "", 0, false, ""),
+		Cfg:   cfg,
+	}
+}
+
+// mustInsertScan inserts a completed scan_history row and returns its ID.
+func mustInsertScan(tb testing.TB, db *sql.DB) int64 {
+	tb.Helper()
+	now := time.Now().Unix()
+	res, err := db.Exec(
+		`INSERT INTO scan_history (started_at, finished_at, status, triggered_by, created_at)
+		 VALUES (?, ?, 'completed', 'manual', ?)`, now, now, now)
+	if err != nil {
+		tb.Fatalf("insert scan: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+// mustInsertGroup writes each of paths to disk with the same content and
+// records them as one unresolved duplicate group keyed by hash. It returns
+// the group ID and the file IDs, in the order of paths.
+func mustInsertGroup(tb testing.TB, db *sql.DB, hash string, paths ...string) (int64, []int64) {
+	tb.Helper()
+	const content = "duplicate content"
+	scanID := mustInsertScan(tb, db)
+	now := time.Now().Unix()
+	size := int64(len(content))
+	res, err := db.Exec(`
+		INSERT INTO duplicate_groups
+			(content_hash, file_size, file_count, reclaimable_bytes, file_type, status,
+			 first_seen_scan_id, last_seen_scan_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 'other', 'unresolved', ?, ?, ?, ?)`,
+		hash, size, len(paths), size*int64(len(paths)-1), scanID, scanID, now, now)
+	if err != nil {
+		tb.Fatalf("insert group: %v", err)
+	}
+	groupID, _ := res.LastInsertId()
+
+	var fileIDs []int64
+	for _, p := range paths {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		res, err := db.Exec(`
+			INSERT INTO duplicate_files (group_id, scan_id, path, size, mtime, file_type)
+			VALUES (?, ?, ?, ?, ?, 'other')`,
+			groupID, scanID, p, info.Size(), info.ModTime().Unix())
+		if err != nil {
+			tb.Fatalf("insert file %s: %v", p, err)
+		}
+		id, _ := res.LastInsertId()
+		fileIDs = append(fileIDs, id)
+	}
+	return groupID, fileIDs
+}
+
+// groupRoutes mounts the group endpoints of h as the API server does.
+func groupRoutes(h *GroupsHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/api/groups", h.List)
+	r.Post("/api/groups/resolve", h.Resolve)
+	r.Post("/api/groups/ignore-batch", h.IgnoreBatch)
+	r.Post("/api/groups/merge", h.Merge)
+	r.Get("/api/groups/{id}", h.Get)
+	r.Patch("/api/groups/{id}", h.Update)
+	r.Post("/api/groups/{id}/delete", h.Delete)
+	r.Get("/api/groups/{id}/download", h.Download)
+	return r
+}
+
+// serve sends a request with body (may be "") to handler and returns the
+// recorded response.
+func serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// assertExists fails the test unless a file is at path.
+func assertExists(tb testing.TB, path string) {
+	tb.Helper()
+	if _, err := os.Lstat(path); err != nil {
+		tb.Errorf("%s should still be on disk: %v", path, err)
+	}
+}
+
+// assertGone fails the test if a file is at path.
+func assertGone(tb testing.TB, path string) {
+	tb.Helper()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		tb.Errorf("%s should have been moved away (stat: %v)", path, err)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+)
+
+// thumbCacheEntries bounds the group thumbnails kept in memory (~20 KB each).
+const thumbCacheEntries = 512
+
+// thumbCache is a small LRU of generated JPEG thumbnails keyed by content
+// hash: every file in a group has the same bytes, so one thumbnail serves the
+// group for as long as the hash exists. The zero value is ready to use.
+type thumbCache struct {
+	mu    sync.Mutex
+	order *list.List // front = most recently used
+	items map[string]*list.Element
+}
+
+type thumbCacheEntry struct {
+	key  string
+	data []byte
+}
+
+func (c *thumbCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*thumbCacheEntry).data, true
+}
+
+func (c *thumbCache) put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.items = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*thumbCacheEntry).data = data
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&thumbCacheEntry{key: key, data: data})
+	for c.order.Len() > thumbCacheEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*thumbCacheEntry).key)
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eargollo/ditto/internal/media"
+)
+
+const (
+	// maxThumbnailBatch caps the file IDs accepted by one batch request.
+	maxThumbnailBatch = 200
+	// thumbnailWorkers bounds the thumbnails a batch generates at once.
+	thumbnailWorkers = 4
+)
+
+// thumbnail returns the 320x320 JPEG thumbnail of the file at path, whose
+// content hash is hash, from the cache when one was already generated for the
+// same content. It returns nil, nil for files media.Thumbnail cannot render.
+func (h *FilesHandler) thumbnail(hash, path string) ([]byte, error) {
+	if thumb, ok := h.thumbs.get(hash); ok {
+		return thumb, nil
+	}
+	thumb, err := media.Thumbnail(path, 320, 320)
+	if err != nil || thumb == nil {
+		return thumb, err
+	}
+	h.thumbs.put(hash, thumb)
+	return thumb, nil
+}
+
+type thumbnailBatchItem struct {
+	ID   int64  `json:"id"`
+	Data []byte `json:"data"` // base64-encoded JPEG
+}
+
+// Thumbnails handles POST /api/files/thumbnails — the thumbnails of up to
+// maxThumbnailBatch files in one response, for pages that would otherwise
+// request them one by one. Body: {"ids": [1, 2, ...]}. Files that do not
+// exist, are not images or cannot be rendered are listed in "missing".
+// Files sharing content are rendered once, and at most thumbnailWorkers
+// thumbnails are generated concurrently.
+func (h *FilesHandler) Thumbnails(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "ids is required and must be non-empty")
+		return
+	}
+	if len(body.IDs) > maxThumbnailBatch {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST",
+			"at most "+strconv.Itoa(maxThumbnailBatch)+" ids per request")
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(body.IDs)), ",")
+	args := make([]interface{}, len(body.IDs))
+	for i, id := range body.IDs {
+		args[i] = id
+	}
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT f.id, f.path, g.content_hash
+		FROM duplicate_files f JOIN duplicate_groups g ON g.id = f.group_id
+		WHERE f.file_type = ? AND f.id IN (`+placeholders+`)`,
+		append([]interface{}{string(media.FileTypeImage)}, args...)...)
+	if err != nil {
+		slog.Error("files thumbnails: db query", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	// One render per content hash; every requested ID of that content shares it.
+	type job struct {
+		path  string
+		ids   []int64
+		thumb []byte
+	}
+	jobs := map[string]*job{}
+	for rows.Next() {
+		var id int64
+		var path, hash string
+		if err := rows.Scan(&id, &path, &hash); err != nil {
+			continue
+		}
+		j := jobs[hash]
+		if j == nil {
+			j = &job{path: path}
+			jobs[hash] = j
+		}
+		j.ids = append(j.ids, id)
+	}
+	rows.Close()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, thumbnailWorkers)
+	for hash, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if r.Context().Err() != nil {
+				return
+			}
+			thumb, err := h.thumbnail(hash, j.path)
+			if err != nil {
+				slog.Warn("files thumbnails: generate", "path", j.path, "error", err)
+			}
+			j.thumb = thumb
+		}()
+	}
+	wg.Wait()
+
+	rendered := make(map[int64][]byte, len(body.IDs))
+	for _, j := range jobs {
+		if j.thumb == nil {
+			continue
+		}
+		for _, id := range j.ids {
+			rendered[id] = j.thumb
+		}
+	}
+	items := []thumbnailBatchItem{}
+	missing := []int64{}
+	seen := make(map[int64]bool, len(body.IDs))
+	for _, id := range body.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if thumb, ok := rendered[id]; ok {
+			items = append(items, thumbnailBatchItem{ID: id, Data: thumb})
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"content_type": "image/jpeg",
+		"items":        items,
+		"missing":      missing,
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RequestTimeout bounds GET requests to d: the request context gets a
+// deadline, so a slow query is cancelled instead of holding a connection of
+// the small SQLite pool. A handler that fails with a 5xx, or writes nothing,
+// after the deadline passed is answered with 503 TIMEOUT instead. Writes are
+// not bounded — a delete or restore stopped halfway would leave files and
+// rows out of step. d <= 0 disables the limit.
+func RequestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The handler gave up without answering.
+				tw.timedOut = true
+			}
+			if tw.timedOut {
+				writeError(w, http.StatusServiceUnavailable, "TIMEOUT",
+					"Request took longer than "+d.String()+"; try again or narrow it")
+			}
+		})
+	}
+}
+
+// timeoutWriter swallows an error response written once the deadline has
+// passed, so RequestTimeout can replace it with a 503. Anything written before
+// that goes straight through — a stream already under way is never rewritten.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if code >= 500 && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+		return
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok && !tw.timedOut {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter { return tw.ResponseWriter }
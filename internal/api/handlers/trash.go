@@ -18,18 +18,59 @@ import (
 type TrashHandler struct {
 	DB    *sql.DB
 	Trash *trash.Manager
+	// ReadOnly refuses the purge endpoints (config read_only); restores
+	// are still allowed.
+	ReadOnly bool
 }
 
-// List handles GET /api/trash — active trash items sorted by trashed_at DESC.
+// List handles GET /api/trash — active trash items sorted by trashed_at DESC,
+// or by ID with the after=<id> cursor (see parseCursor).
 func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
 	limit, offset := parsePagination(r)
+	after, cursor, err := parseCursor(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	args := []interface{}{}
+	where := ""
+	if s := q.Get("q"); s != "" {
+		where += " AND instr(lower(original_path), lower(?)) > 0"
+		args = append(args, s)
+	}
+	if g := q.Get("group_id"); g != "" {
+		groupID, err := strconv.ParseInt(g, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "group_id must be an integer")
+			return
+		}
+		where += " AND group_id = ?"
+		args = append(args, groupID)
+	}
+	if d := q.Get("expiring_within_days"); d != "" {
+		days, err := strconv.Atoi(d)
+		if err != nil || days < 0 {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "expiring_within_days must be a non-negative integer")
+			return
+		}
+		where += " AND expires_at <= ?"
+		args = append(args, time.Now().AddDate(0, 0, days).Unix())
+	}
 
+	page, pageArgs, orderBy, pageLimit := where, args, "trashed_at DESC", limit
+	if cursor {
+		page += " AND id > ?"
+		pageArgs = append(append([]interface{}{}, args...), after)
+		orderBy, pageLimit = "id ASC", limit+1
+	}
 	rows, err := h.DB.QueryContext(r.Context(), `
-		SELECT id, original_path, file_size, content_hash, trashed_at, expires_at, group_id
+		SELECT id, original_path, file_size, content_hash, trashed_at, expires_at, group_id, compressed
 		FROM trash
-		WHERE status = 'trashed'
-		ORDER BY trashed_at DESC
-		LIMIT ? OFFSET ?`, limit, offset)
+		WHERE status = 'trashed'`+page+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?`, append(pageArgs, pageLimit, offset)...)
 	if err != nil {
 		slog.Error("trash list: query", "error", err)
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
@@ -46,6 +87,7 @@ func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt     string `json:"expires_at"`
 		DaysRemaining int    `json:"days_remaining"`
 		GroupID       *int64 `json:"group_id"`
+		Compressed    bool   `json:"compressed"`
 	}
 
 	var items []trashItem
@@ -54,7 +96,7 @@ func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
 		var trashedAt, expiresAt int64
 		var groupID sql.NullInt64
 		if err := rows.Scan(&it.ID, &it.OriginalPath, &it.FileSize, &it.ContentHash,
-			&trashedAt, &expiresAt, &groupID); err != nil {
+			&trashedAt, &expiresAt, &groupID, &it.Compressed); err != nil {
 			slog.Error("trash list: scan row", "error", err)
 			continue
 		}
@@ -72,20 +114,31 @@ func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
 	if items == nil {
 		items = []trashItem{}
 	}
+	var next *int64
+	if cursor && len(items) > limit {
+		items = items[:limit]
+		next = &items[limit-1].ID
+	}
 
 	var total int
 	var totalSize int64
 	h.DB.QueryRowContext(r.Context(),
-		`SELECT COUNT(*), COALESCE(SUM(file_size),0) FROM trash WHERE status='trashed'`,
+		`SELECT COUNT(*), COALESCE(SUM(COALESCE(stored_size, file_size)),0) FROM trash WHERE status='trashed'`+where,
+		args...,
 	).Scan(&total, &totalSize)
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	resp := map[string]interface{}{
 		"items":      items,
 		"total":      total,
 		"total_size": totalSize,
 		"limit":      limit,
 		"offset":     offset,
-	})
+	}
+	if next != nil {
+		resp["next_cursor"] = *next
+	}
+	setPaginationHeaders(w, total, limit, offset)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Restore handles POST /api/trash/:id/restore.
@@ -109,7 +162,8 @@ func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Trash.Restore(r.Context(), id); err != nil {
+	restoredPath, err := h.Trash.Restore(r.Context(), id)
+	if err != nil {
 		if errors.Is(err, trash.ErrNotTrashed) {
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "Trash item not found or already purged/restored")
 			return
@@ -117,7 +171,16 @@ func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
 		var conflict *trash.ErrRestoreConflict
 		if errors.As(err, &conflict) {
 			writeError(w, http.StatusConflict, "RESTORE_PATH_CONFLICT",
-				"A file already exists at the original path")
+				"A file already exists at "+conflict.Path)
+			return
+		}
+		var unwritable *trash.ErrRestoreUnwritable
+		if errors.As(err, &unwritable) {
+			if unwritable.NoSpace {
+				writeError(w, http.StatusInsufficientStorage, "INSUFFICIENT_SPACE", err.Error())
+				return
+			}
+			writeError(w, http.StatusConflict, "RESTORE_NOT_WRITABLE", err.Error())
 			return
 		}
 		slog.Error("trash restore", "trash_id", id, "error", err)
@@ -128,6 +191,7 @@ func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"id":            id,
 		"original_path": originalPath,
+		"restored_path": restoredPath,
 		"status":        "restored",
 		"restored_at":   time.Now().UTC().Format(time.RFC3339),
 	})
@@ -135,6 +199,10 @@ func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
 
 // PurgeAll handles DELETE /api/trash — requires {"confirm": true}.
 func (h *TrashHandler) PurgeAll(w http.ResponseWriter, r *http.Request) {
+	if h.ReadOnly {
+		writeReadOnly(w)
+		return
+	}
 	var body struct {
 		Confirm bool `json:"confirm"`
 	}
@@ -156,3 +224,24 @@ func (h *TrashHandler) PurgeAll(w http.ResponseWriter, r *http.Request) {
 		"bytes_freed":  bytesFreed,
 	})
 }
+
+// PurgeExpired handles POST /api/trash/purge-expired.
+// Runs the retention purge immediately: only items past their expires_at are
+// removed, so unlike PurgeAll no confirmation is required.
+func (h *TrashHandler) PurgeExpired(w http.ResponseWriter, r *http.Request) {
+	if h.ReadOnly {
+		writeReadOnly(w)
+		return
+	}
+	count, bytesFreed, err := h.Trash.AutoPurge(r.Context())
+	if err != nil {
+		slog.Error("trash purge expired", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"purged_count": count,
+		"bytes_freed":  bytesFreed,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// trashRoutes mounts the trash endpoints of h as the API server does.
+func trashRoutes(h *TrashHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/api/trash", h.List)
+	r.Post("/api/trash/{id}/restore", h.Restore)
+	r.Delete("/api/trash", h.PurgeAll)
+	r.Post("/api/trash/purge-expired", h.PurgeExpired)
+	return r
+}
+
+// TestPurgeReadOnly verifies that read_only refuses both purge endpoints
+// with READ_ONLY and leaves the trashed file, expired or not, on disk.
+func TestPurgeReadOnly(t *testing.T) {
+	gh := newTestGroupsHandler(t, nil)
+	h := &TrashHandler{DB: gh.DB, Trash: gh.Trash, ReadOnly: true}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f1")
+	groupID, _ := mustInsertGroup(t, h.DB, "aaaa", path)
+	trashID, err := h.Trash.MoveToTrash(context.Background(), path, groupID, "aaaa", 30)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	var trashPath string
+	if err := h.DB.QueryRow(`SELECT trash_path FROM trash WHERE id = ?`, trashID).Scan(&trashPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DB.Exec(`UPDATE trash SET expires_at = 0 WHERE id = ?`, trashID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, target, body string
+	}{
+		{"purge all", http.MethodDelete, "/api/trash", `{"confirm":true}`},
+		{"purge expired", http.MethodPost, "/api/trash/purge-expired", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(trashRoutes(h), tt.method, tt.target, tt.body)
+			if rec.Code != http.StatusForbidden || errorCode(t, rec.Body.Bytes()) != "READ_ONLY" {
+				t.Fatalf("status %d, body %s; want 403 READ_ONLY", rec.Code, rec.Body)
+			}
+			assertExists(t, trashPath)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// VersionHandler handles GET /api/version.
+type VersionHandler struct {
+	Version string
+}
+
+type versionResponse struct {
+	Version     string `json:"version"`
+	GoVersion   string `json:"go_version"`
+	Module      string `json:"module,omitempty"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	VCSTime     string `json:"vcs_time,omitempty"`
+	VCSModified bool   `json:"vcs_modified"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+}
+
+// ServeHTTP returns the build version plus Go runtime and VCS build info.
+func (h *VersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildInfo(h.Version))
+}
+
+// buildInfo collects version details from the ldflags-injected version and
+// runtime/debug.ReadBuildInfo. VCS fields are empty when the binary was built
+// without VCS stamping (e.g. `go run`, or outside a git checkout).
+func buildInfo(version string) versionResponse {
+	resp := versionResponse{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return resp
+	}
+	resp.Module = bi.Main.Path
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			resp.VCSRevision = s.Value
+		case "vcs.time":
+			resp.VCSTime = s.Value
+		case "vcs.modified":
+			resp.VCSModified = s.Value == "true"
+		}
+	}
+	return resp
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// whitelistEntry is one ignore rule as exported and imported: a content
+// hash, a watched set of paths (value is their sorted JSON array) or an
+// excluded directory.
+type whitelistEntry struct {
+	Type         string  `json:"type"`
+	Value        string  `json:"value"`
+	ExpectedHash *string `json:"expected_hash,omitempty"`
+	Note         *string `json:"note,omitempty"`
+	AddedAt      string  `json:"added_at"`
+}
+
+// WhitelistExport handles GET /api/whitelist/export — every ignore rule, in
+// the shape WhitelistImport accepts, so they can be copied to another instance.
+func (h *GroupsHandler) WhitelistExport(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT type, value, expected_hash, note, added_at
+		FROM whitelist ORDER BY id`)
+	if err != nil {
+		slog.Error("whitelist export: query", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer rows.Close()
+
+	entries := []whitelistEntry{}
+	for rows.Next() {
+		var e whitelistEntry
+		var expected, note sql.NullString
+		var addedAt int64
+		if err := rows.Scan(&e.Type, &e.Value, &expected, &note, &addedAt); err != nil {
+			slog.Error("whitelist export: scan", "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if expected.Valid {
+			e.ExpectedHash = &expected.String
+		}
+		if note.Valid {
+			e.Note = &note.String
+		}
+		e.AddedAt = time.Unix(addedAt, 0).UTC().Format(time.RFC3339)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("whitelist export: rows", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+}
+
+// WhitelistImport handles POST /api/whitelist/import — merges exported ignore
+// rules into this instance's whitelist. Rules already present are skipped.
+// Imported rules act like a local ignore: groups with an imported hash are
+// marked ignored, and imported directories are excluded from the next scan
+// (and, with ignore_dir_existing_groups, ignore the groups already under them).
+func (h *GroupsHandler) WhitelistImport(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Entries []whitelistEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid request body")
+		return
+	}
+	for _, e := range body.Entries {
+		switch {
+		case e.Value == "":
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "every entry needs a value")
+			return
+		case e.Type == "dir" && !filepath.IsAbs(e.Value):
+			writeError(w, http.StatusBadRequest, "INVALID_PATH", "dir entries must be absolute paths: "+e.Value)
+			return
+		case e.Type != "hash" && e.Type != "path_pair" && e.Type != "dir":
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "type must be 'hash', 'path_pair', or 'dir'")
+			return
+		}
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var imported, skipped int
+	var dirs []string
+	for _, e := range body.Entries {
+		addedAt := now
+		if t, err := time.Parse(time.RFC3339, e.AddedAt); err == nil {
+			addedAt = t.Unix()
+		}
+		res, err := tx.ExecContext(r.Context(), `
+			INSERT OR IGNORE INTO whitelist (type, value, expected_hash, note, added_by, added_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			e.Type, e.Value, e.ExpectedHash, e.Note, RequestActor(r), addedAt)
+		if err != nil {
+			slog.Error("whitelist import: insert", "type", e.Type, "error", err)
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			skipped++
+			continue
+		}
+		imported++
+		switch e.Type {
+		case "hash":
+			if _, err := tx.ExecContext(r.Context(), `
+				UPDATE duplicate_groups SET status='ignored', ignored_at=?, updated_at=?
+				WHERE content_hash = ? AND status = 'unresolved'`,
+				now, now, e.Value); err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+				return
+			}
+		case "dir":
+			dirs = append(dirs, e.Value)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("whitelist import: commit", "error", err)
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	for _, dir := range dirs {
+		h.excludeDir(dir)
+		h.ignoreExistingUnderDir(r, dir)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"imported": imported, "skipped": skipped})
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the hand-maintained OpenAPI 3 description of the /api routes.
+// openapi_test.go keeps it in sync with the router.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// serveOpenAPI handles GET /api/openapi.json.
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
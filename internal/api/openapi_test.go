@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/eargollo/ditto/internal/config"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when an /api route is registered without
+// being described in openapi.json, or the spec describes a route that does
+// not exist.
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi version: got %q, want 3.x", spec.OpenAPI)
+	}
+
+	documented := make(map[string]bool)
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	srv := New(":0", nil, nil, &config.Config{}, nil, nil, nil, "test", nil, nil)
+	routes, ok := srv.srv.Handler.(chi.Routes)
+	if !ok {
+		t.Fatal("server handler is not a chi router")
+	}
+	registered := make(map[string]bool)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			registered[method+" "+route] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+
+	var missing, stale []string
+	for r := range registered {
+		if !documented[r] {
+			missing = append(missing, r)
+		}
+	}
+	for d := range documented {
+		if !registered[d] {
+			stale = append(stale, d)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	for _, r := range missing {
+		t.Errorf("route %s is not described in openapi.json", r)
+	}
+	for _, d := range stale {
+		t.Errorf("openapi.json describes %s, which is not registered", d)
+	}
+}
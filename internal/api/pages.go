@@ -10,8 +10,8 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,6 +21,7 @@ import (
 
 	"github.com/eargollo/ditto/internal/api/handlers"
 	"github.com/eargollo/ditto/internal/config"
+	"github.com/eargollo/ditto/internal/db"
 	"github.com/eargollo/ditto/internal/scan"
 	"github.com/eargollo/ditto/internal/scheduler"
 	"github.com/eargollo/ditto/internal/trash"
@@ -115,6 +116,7 @@ type scanStatusData struct {
 	Phase         string // "scanning" | "writing"
 	GroupsWritten int64
 	GroupsTotal   int64
+	Phase2Pct     int64
 	ETASecs       int64 // 0 = not yet estimable
 	// Last completed scan
 	HasLastScan     bool
@@ -123,6 +125,9 @@ type scanStatusData struct {
 	LastReclaimable int64
 	CronExpr        string
 	NextRunAt       string
+	// PollSeconds is when the fragment asks for its next refresh: the
+	// active interval while a scan runs, the idle one otherwise.
+	PollSeconds int
 }
 
 type scanHistoryItem struct {
@@ -137,6 +142,7 @@ type scanHistoryItem struct {
 	TriggeredBy      string
 	CacheHitStr      string // "85%" or "" when no data
 	FilesPerSec      int64  // 0 when no data
+	FilesAdded       int64  // candidates not in file_cache before the scan
 	// Timing (milliseconds)
 	DiskReadMs    int64
 	DBReadMs      int64
@@ -158,6 +164,14 @@ type dashboardData struct {
 	Groups      int64
 	Files       int64
 	Reclaimable int64
+	// MinReclaimable is min_reclaimable_bytes; when > 0, GroupsAbove and
+	// ReclaimableAbove cover only the groups reclaiming at least that much.
+	MinReclaimable   int64
+	GroupsAbove      int64
+	ReclaimableAbove int64
+	// IncompleteGroups counts the active groups that may be missing a copy
+	// that failed to hash; Reclaimable may understate when it is > 0.
+	IncompleteGroups int64
 	// Deletion history
 	DeletedAllTime   int64
 	ReclaimedAllTime int64
@@ -165,8 +179,14 @@ type dashboardData struct {
 	Reclaimed30d     int64
 	// Recent scan history
 	RecentScans []scanHistoryItem
-	// Trend chart data (populated when len >= 3)
-	Snapshots []snapshotPoint
+	// Trend chart data, limited to the last TrendDays days (0 = all) and,
+	// when TrendLimit > 0, the last TrendLimit snapshots. The charts are shown
+	// once SnapshotCount, over all time, reaches 3.
+	Snapshots     []snapshotPoint
+	SnapshotCount int64
+	TrendDays     int
+	TrendLimit    int
+	TrendWindows  []int // day windows offered as links (0 = all)
 }
 
 type groupPageItem struct {
@@ -179,8 +199,19 @@ type groupPageItem struct {
 	ReclaimableBytes int64
 	FileType         string
 	Status           string
+	// Incomplete mirrors duplicate_groups.incomplete: the group may be
+	// missing a copy that failed to hash.
+	Incomplete bool
+	// VerifiedDaysAgo is the age in days of last_verified_at (-1 = never
+	// verified); VerifyStale is set from staleVerifyDays on.
+	VerifiedDaysAgo int
+	VerifyStale     bool
 }
 
+// staleVerifyDays is the age of a group's last verification at which the
+// detail page recommends a rescan before deleting.
+const staleVerifyDays = 7
+
 // groupWithFiles is a groupPageItem with its files pre-loaded.
 type groupWithFiles struct {
 	groupPageItem
@@ -195,8 +226,10 @@ type groupsPageData struct {
 	Offset           int
 	StatusFilter     string
 	TypeFilter       string
-	SortFilter       string // "", "size", "count", "newest"
-	MinFilter        string // "", "10mb", "100mb", "1gb"
+	SortFilter       string // "" (reclaimable) or a column from handlers.GroupOrderBy
+	OrderFilter      string // "" (descending) or "asc"
+	MinFilter        string // "", "10mb", "100mb", "1gb", or "all" to lift MinDefault
+	MinDefault       int64  // min_reclaimable_bytes, applied when MinFilter is ""
 	NextOffset       int
 	PrevOffset       int
 	HasNext          bool
@@ -204,6 +237,8 @@ type groupsPageData struct {
 	TotalActiveGroups int64
 	TotalActiveFiles  int64
 	TotalReclaimable  int64
+	StatusCounts      map[string]int64 // by status, for the filter options
+	AllGroups         int64            // sum of StatusCounts
 }
 
 type groupFileItem struct {
@@ -212,13 +247,23 @@ type groupFileItem struct {
 	Size     int64
 	MTime    string
 	FileType string
+	// SuggestedKeeper pre-selects the file in the keep-one form.
+	SuggestedKeeper bool
+
+	mtime int64
 }
 
 type groupDetailData struct {
 	baseData
-	Group    groupPageItem
-	Files    []groupFileItem
-	NotFound bool
+	Group      groupPageItem
+	Files      []groupFileItem
+	FilesTotal int
+	Offset     int
+	NextOffset int
+	PrevOffset int
+	HasNext    bool
+	HasPrev    bool
+	NotFound   bool
 }
 
 type trashPageItem struct {
@@ -245,14 +290,19 @@ type trashPageData struct {
 
 type settingsPageData struct {
 	baseData
-	ScanPaths          string
-	ExcludePaths       string
-	Schedule           string
-	ScanPaused         bool
-	TrashRetentionDays int
-	Walkers            int
-	PartialHashers     int
-	FullHashers        int
+	ScanRunning           bool // changes below apply from the next scan
+	ScanPaths             string
+	ExcludePaths          string
+	Schedule              string
+	ScanPaused            bool
+	TrashRetentionDays    int
+	Walkers               int
+	CacheCheckers         int
+	PartialHashers        int
+	FullHashers           int
+	StatusPollSeconds     int
+	StatusPollIdleSeconds int
+	DefaultGroupType      string // "" = all types
 }
 
 // ── pageServer ────────────────────────────────────────────────────────────────
@@ -266,10 +316,38 @@ type pageServer struct {
 	sched       *scheduler.Scheduler
 	templatesFS fs.FS
 	cfgH        *handlers.ConfigHandler
+	groupsH     *handlers.GroupsHandler
+}
+
+// audit records a UI group action in audit_log; failures are logged only.
+func (ps *pageServer) audit(r *http.Request, groupID int64, action string, detail any) {
+	if err := db.RecordAudit(r.Context(), ps.db, groupID, action, handlers.RequestActor(r), detail); err != nil {
+		slog.Error("audit log", "group_id", groupID, "action", action, "error", err)
+	}
+}
+
+// ignoreExistingUnderDir marks the unresolved groups under a newly ignored
+// directory as ignored, as the API does with ignore_dir_existing_groups.
+func (ps *pageServer) ignoreExistingUnderDir(r *http.Request, dir string) {
+	ids, err := handlers.IgnoreGroupsUnderDir(r.Context(), ps.db, dir)
+	if err != nil {
+		slog.Error("ui group ignore: ignore existing groups under dir", "dir", dir, "error", err)
+	}
+	for _, id := range ids {
+		ps.audit(r, id, "ignore", map[string]interface{}{"type": "dir", "status": "ignored"})
+	}
 }
 
-func (ps *pageServer) renderTemplate(w http.ResponseWriter, pageName string, data any) {
-	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(ps.templatesFS, "base.html", pageName)
+func (ps *pageServer) renderTemplate(w http.ResponseWriter, r *http.Request, pageName string, data any) {
+	theme := ps.cfg.Theme
+	csrf, err := csrfFuncs(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Funcs(csrf).
+		Funcs(template.FuncMap{"theme": func() string { return theme }}).
+		ParseFS(ps.templatesFS, "base.html", pageName)
 	if err != nil {
 		http.Error(w, "template error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -280,8 +358,13 @@ func (ps *pageServer) renderTemplate(w http.ResponseWriter, pageName string, dat
 	}
 }
 
-func (ps *pageServer) renderFragment(w http.ResponseWriter, fileName, tmplName string, data any) {
-	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(ps.templatesFS, fileName)
+func (ps *pageServer) renderFragment(w http.ResponseWriter, r *http.Request, fileName, tmplName string, data any) {
+	csrf, err := csrfFuncs(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Funcs(csrf).ParseFS(ps.templatesFS, fileName)
 	if err != nil {
 		http.Error(w, "template error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -313,9 +396,18 @@ func (ps *pageServer) dashboardPage(w http.ResponseWriter, r *http.Request) {
 
 	// Current active groups.
 	ps.readDB.QueryRowContext(r.Context(), `
-		SELECT COALESCE(SUM(1),0), COALESCE(SUM(file_count),0), COALESCE(SUM(reclaimable_bytes),0)
+		SELECT COALESCE(SUM(1),0), COALESCE(SUM(file_count),0), COALESCE(SUM(reclaimable_bytes),0),
+		       COALESCE(SUM(incomplete),0)
 		FROM duplicate_groups WHERE status IN ('unresolved','watching_alert')
-	`).Scan(&d.Groups, &d.Files, &d.Reclaimable)
+	`).Scan(&d.Groups, &d.Files, &d.Reclaimable, &d.IncompleteGroups)
+	if d.MinReclaimable = ps.cfg.MinReclaimableBytes; d.MinReclaimable > 0 {
+		ps.readDB.QueryRowContext(r.Context(), `
+			SELECT COUNT(*), COALESCE(SUM(reclaimable_bytes),0)
+			FROM duplicate_groups
+			WHERE status IN ('unresolved','watching_alert') AND reclaimable_bytes >= ?`,
+			d.MinReclaimable,
+		).Scan(&d.GroupsAbove, &d.ReclaimableAbove)
+	}
 
 	// All-time deletion stats.
 	ps.readDB.QueryRowContext(r.Context(),
@@ -334,7 +426,7 @@ func (ps *pageServer) dashboardPage(w http.ResponseWriter, r *http.Request) {
 		SELECT id, started_at, COALESCE(duration_seconds,0),
 		       files_discovered, duplicate_groups, reclaimable_bytes,
 		       errors, status, triggered_by,
-		       cache_hits, cache_misses,
+		       cache_hits, cache_misses, files_added,
 		       disk_read_ms, db_read_ms, db_write_ms
 		FROM scan_history
 		WHERE status IN ('completed','failed','cancelled')
@@ -348,7 +440,7 @@ func (ps *pageServer) dashboardPage(w http.ResponseWriter, r *http.Request) {
 			if err := scanRows.Scan(&item.ID, &startedAt, &durSecs,
 				&item.FilesDiscovered, &item.DuplicateGroups, &item.ReclaimableBytes,
 				&item.ErrorCount, &item.Status, &item.TriggeredBy,
-				&cacheHits, &cacheMisses,
+				&cacheHits, &cacheMisses, &item.FilesAdded,
 				&item.DiskReadMs, &item.DBReadMs, &item.DBWriteMs); err != nil {
 				continue
 			}
@@ -368,13 +460,38 @@ func (ps *pageServer) dashboardPage(w http.ResponseWriter, r *http.Request) {
 		d.RecentScans = []scanHistoryItem{}
 	}
 
-	// Trend chart snapshots (all, ascending).
+	// Trend chart snapshots within the window, newest first, then reversed
+	// for the charts. ?trend_days=N (0 = all) overrides
+	// dashboard_trend_days; ?trend_snapshots=N keeps only the last N.
+	d.TrendWindows = []int{30, 90, 365, 0}
+	d.TrendDays = ps.cfg.DashboardTrendDays
+	if d.TrendDays < 0 {
+		d.TrendDays = 0
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("trend_days")); err == nil && v >= 0 {
+		d.TrendDays = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("trend_snapshots")); err == nil && v > 0 {
+		d.TrendLimit = v
+	}
+	var since int64
+	if d.TrendDays > 0 {
+		since = time.Now().AddDate(0, 0, -d.TrendDays).Unix()
+	}
+	limit := -1 // SQLite: no limit
+	if d.TrendLimit > 0 {
+		limit = d.TrendLimit
+	}
+	ps.readDB.QueryRowContext(r.Context(),
+		`SELECT COUNT(*) FROM scan_snapshots`).Scan(&d.SnapshotCount)
 	snapRows, err := ps.readDB.QueryContext(r.Context(), `
 		SELECT ss.snapshot_at, ss.duplicate_groups, ss.reclaimable_bytes,
 		       ss.cumulative_reclaimed_bytes, COALESCE(sh.duration_seconds, 0)
 		FROM scan_snapshots ss
 		JOIN scan_history sh ON ss.scan_id = sh.id
-		ORDER BY ss.snapshot_at ASC`)
+		WHERE ss.snapshot_at >= ?
+		ORDER BY ss.snapshot_at DESC
+		LIMIT ?`, since, limit)
 	if err == nil {
 		defer snapRows.Close()
 		for snapRows.Next() {
@@ -388,18 +505,25 @@ func (ps *pageServer) dashboardPage(w http.ResponseWriter, r *http.Request) {
 			d.Snapshots = append(d.Snapshots, sp)
 		}
 	}
+	slices.Reverse(d.Snapshots)
 	if d.Snapshots == nil {
 		d.Snapshots = []snapshotPoint{}
 	}
 
-	ps.renderTemplate(w, "dashboard.html", d)
+	ps.renderTemplate(w, r, "dashboard.html", d)
 }
 
 func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 	const pageLimit = 20
 	q := r.URL.Query()
 	statusFilter := q.Get("status")
+	// No type param applies default_group_type; "all" lifts it.
 	typeFilter := q.Get("type")
+	if !q.Has("type") {
+		ps.cfg.Lock()
+		typeFilter = ps.cfg.DefaultGroupType
+		ps.cfg.Unlock()
+	}
 	offset := 0
 	if v, err := strconv.Atoi(q.Get("offset")); err == nil && v >= 0 {
 		offset = v
@@ -415,30 +539,36 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		where += " AND status = ?"
 		args = append(args, statusFilter)
 	}
-	if typeFilter != "" {
+	if typeFilter != "" && typeFilter != "all" {
 		where += " AND file_type = ?"
 		args = append(args, typeFilter)
 	}
 
-	sortOrders := map[string]string{
-		"size": "file_size DESC", "count": "file_count DESC", "newest": "updated_at DESC",
+	orderParam := q.Get("order")
+	if orderParam != "asc" {
+		orderParam = ""
+	}
+	sortParam, orderBy, err := handlers.GroupOrderBy(q.Get("sort"), orderParam)
+	if err != nil {
+		sortParam, orderBy, _ = handlers.GroupOrderBy("", orderParam)
 	}
-	sortParam := q.Get("sort")
-	orderBy, ok := sortOrders[sortParam]
-	if !ok {
-		orderBy = "reclaimable_bytes DESC"
+	if sortParam == "reclaimable_bytes" {
 		sortParam = ""
 	}
 
 	minPresets := map[string]int64{
 		"10mb": 10_485_760, "100mb": 104_857_600, "1gb": 1_073_741_824,
 	}
+	// No min param applies min_reclaimable_bytes; "all" lifts it.
 	minParam := q.Get("min")
-	if minVal, ok := minPresets[minParam]; ok {
+	minDefault := ps.cfg.MinReclaimableBytes
+	minVal, ok := minPresets[minParam]
+	if !ok && (minParam != "all" || minDefault == 0) {
+		minParam, minVal = "", minDefault
+	}
+	if minVal > 0 {
 		where += " AND reclaimable_bytes >= ?"
 		args = append(args, minVal)
-	} else {
-		minParam = ""
 	}
 
 	// Overall active stats (always from active groups, regardless of filter).
@@ -448,6 +578,15 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		FROM duplicate_groups WHERE status IN ('unresolved','watching_alert')
 	`).Scan(&totalActiveGroups, &totalActiveFiles, &totalReclaimable)
 
+	statusCounts, err := handlers.GroupStatusCounts(r.Context(), ps.readDB)
+	if err != nil {
+		slog.Warn("groups page: status counts", "error", err)
+	}
+	var allGroups int64
+	for _, n := range statusCounts {
+		allGroups += n
+	}
+
 	var total int
 	ps.readDB.QueryRowContext(r.Context(),
 		"SELECT COUNT(*) FROM duplicate_groups WHERE 1=1"+where,
@@ -456,7 +595,7 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 
 	queryArgs := append(append([]interface{}{}, args...), pageLimit, offset)
 	rows, err := ps.readDB.QueryContext(r.Context(), `
-		SELECT id, content_hash, file_size, file_count, reclaimable_bytes, file_type, status
+		SELECT id, content_hash, file_size, file_count, reclaimable_bytes, file_type, status, incomplete
 		FROM duplicate_groups
 		WHERE 1=1`+where+`
 		ORDER BY `+orderBy+`
@@ -469,7 +608,7 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		for rows.Next() {
 			var g groupWithFiles
 			if err := rows.Scan(&g.ID, &g.ContentHash, &g.FileSize, &g.FileCount,
-				&g.ReclaimableBytes, &g.FileType, &g.Status); err != nil {
+				&g.ReclaimableBytes, &g.FileType, &g.Status, &g.Incomplete); err != nil {
 				continue
 			}
 			if len(g.ContentHash) > 8 {
@@ -509,10 +648,12 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 					continue
 				}
 				f.MTime = time.Unix(mtime, 0).Format("2006-01-02 15:04")
+				f.mtime = mtime
 				byGroup[gid] = append(byGroup[gid], f)
 			}
 			for i, g := range groups {
 				files := byGroup[g.ID]
+				markSuggestedKeeper(files, ps.suggestKeeper(files))
 				groups[i].Files = files
 				if len(files) > 0 {
 					groups[i].DisplayName = filepath.Base(files[0].Path)
@@ -529,7 +670,7 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		prevOffset = 0
 	}
 
-	ps.renderTemplate(w, "groups.html", groupsPageData{
+	ps.renderTemplate(w, r, "groups.html", groupsPageData{
 		baseData:          flashFromQuery(r),
 		Groups:            groups,
 		Total:             total,
@@ -538,7 +679,9 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		StatusFilter:      statusFilter,
 		TypeFilter:        typeFilter,
 		SortFilter:        sortParam,
+		OrderFilter:       orderParam,
 		MinFilter:         minParam,
+		MinDefault:        minDefault,
 		NextOffset:        nextOffset,
 		PrevOffset:        prevOffset,
 		HasNext:           hasNext,
@@ -546,6 +689,8 @@ func (ps *pageServer) groupsPage(w http.ResponseWriter, r *http.Request) {
 		TotalActiveGroups: totalActiveGroups,
 		TotalActiveFiles:  totalActiveFiles,
 		TotalReclaimable:  totalReclaimable,
+		StatusCounts:      statusCounts,
+		AllGroups:         allGroups,
 	})
 }
 
@@ -557,12 +702,13 @@ func (ps *pageServer) groupDetailPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var g groupPageItem
+	var verifiedAt sql.NullInt64
 	err = ps.readDB.QueryRowContext(r.Context(), `
-		SELECT id, content_hash, file_size, file_count, reclaimable_bytes, file_type, status
+		SELECT id, content_hash, file_size, file_count, reclaimable_bytes, file_type, status, incomplete, last_verified_at
 		FROM duplicate_groups WHERE id = ?`, id,
-	).Scan(&g.ID, &g.ContentHash, &g.FileSize, &g.FileCount, &g.ReclaimableBytes, &g.FileType, &g.Status)
+	).Scan(&g.ID, &g.ContentHash, &g.FileSize, &g.FileCount, &g.ReclaimableBytes, &g.FileType, &g.Status, &g.Incomplete, &verifiedAt)
 	if err == sql.ErrNoRows {
-		ps.renderTemplate(w, "group_detail.html", groupDetailData{NotFound: true})
+		ps.renderTemplate(w, r, "group_detail.html", groupDetailData{NotFound: true})
 		return
 	}
 	if err != nil {
@@ -573,10 +719,32 @@ func (ps *pageServer) groupDetailPage(w http.ResponseWriter, r *http.Request) {
 	if len(g.ContentHash) > 8 {
 		g.HashShort = g.ContentHash[:8]
 	}
+	g.VerifiedDaysAgo = -1
+	if verifiedAt.Valid {
+		g.VerifiedDaysAgo = int(time.Since(time.Unix(verifiedAt.Int64, 0)).Hours() / 24)
+		g.VerifyStale = g.VerifiedDaysAgo >= staleVerifyDays
+	}
+
+	// Large groups are listed a page at a time; the keep/delete forms act on
+	// the files shown.
+	const pageLimit = 100
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+	var total int
+	var firstPath string
+	ps.readDB.QueryRowContext(r.Context(),
+		`SELECT COUNT(*), COALESCE(MIN(path),'') FROM duplicate_files WHERE group_id = ?`, id,
+	).Scan(&total, &firstPath)
+	if offset >= total {
+		offset = 0
+	}
 
 	fileRows, err := ps.readDB.QueryContext(r.Context(), `
 		SELECT id, path, size, mtime, file_type
-		FROM duplicate_files WHERE group_id = ? ORDER BY path`, id)
+		FROM duplicate_files WHERE group_id = ? ORDER BY path
+		LIMIT ? OFFSET ?`, id, pageLimit, offset)
 	var files []groupFileItem
 	if err == nil {
 		defer fileRows.Close()
@@ -593,19 +761,69 @@ func (ps *pageServer) groupDetailPage(w http.ResponseWriter, r *http.Request) {
 	if files == nil {
 		files = []groupFileItem{}
 	}
-	if len(files) > 0 {
-		g.DisplayName = filepath.Base(files[0].Path)
+
+	// The suggestion is taken over the whole group, not just this page.
+	var all []groupFileItem
+	if allRows, err := ps.readDB.QueryContext(r.Context(),
+		`SELECT id, path, mtime FROM duplicate_files WHERE group_id = ?`, id); err == nil {
+		for allRows.Next() {
+			var f groupFileItem
+			if allRows.Scan(&f.ID, &f.Path, &f.mtime) == nil {
+				all = append(all, f)
+			}
+		}
+		allRows.Close()
+	}
+	markSuggestedKeeper(files, ps.suggestKeeper(all))
+	if firstPath != "" {
+		g.DisplayName = filepath.Base(firstPath)
 	} else {
 		g.DisplayName = g.HashShort + "…"
 	}
 
-	ps.renderTemplate(w, "group_detail.html", groupDetailData{
-		baseData: flashFromQuery(r),
-		Group:    g,
-		Files:    files,
+	prevOffset := offset - pageLimit
+	if prevOffset < 0 {
+		prevOffset = 0
+	}
+	ps.renderTemplate(w, r, "group_detail.html", groupDetailData{
+		baseData:   flashFromQuery(r),
+		Group:      g,
+		Files:      files,
+		FilesTotal: total,
+		Offset:     offset,
+		NextOffset: offset + pageLimit,
+		PrevOffset: prevOffset,
+		HasNext:    offset+pageLimit < total,
+		HasPrev:    offset > 0,
 	})
 }
 
+// suggestKeeper applies the configured keeper heuristic to files and returns
+// the ID of the file to pre-select (0 for none).
+func (ps *pageServer) suggestKeeper(files []groupFileItem) int64 {
+	candidates := make([]handlers.KeeperCandidate, len(files))
+	for i, f := range files {
+		candidates[i] = handlers.KeeperCandidate{ID: f.ID, Path: f.Path, MTime: f.mtime}
+	}
+	var heuristic string
+	var preferred, protected []string
+	if ps.cfg != nil {
+		ps.cfg.Lock()
+		heuristic = ps.cfg.KeeperHeuristic
+		preferred = append(preferred, ps.cfg.KeeperPreferredDirs...)
+		protected = append(protected, ps.cfg.ProtectedDirs...)
+		ps.cfg.Unlock()
+	}
+	return handlers.SuggestKeeper(heuristic, preferred, protected, candidates)
+}
+
+// markSuggestedKeeper flags the file with ID keeperID, if it is in files.
+func markSuggestedKeeper(files []groupFileItem, keeperID int64) {
+	for i := range files {
+		files[i].SuggestedKeeper = files[i].ID == keeperID
+	}
+}
+
 func (ps *pageServer) trashPage(w http.ResponseWriter, r *http.Request) {
 	const pageLimit = 50
 	offset := 0
@@ -616,7 +834,7 @@ func (ps *pageServer) trashPage(w http.ResponseWriter, r *http.Request) {
 	var total int
 	var totalSize int64
 	ps.readDB.QueryRowContext(r.Context(),
-		`SELECT COUNT(*), COALESCE(SUM(file_size),0) FROM trash WHERE status='trashed'`,
+		`SELECT COUNT(*), COALESCE(SUM(COALESCE(stored_size, file_size)),0) FROM trash WHERE status='trashed'`,
 	).Scan(&total, &totalSize)
 
 	rows, err := ps.readDB.QueryContext(r.Context(), `
@@ -659,7 +877,7 @@ func (ps *pageServer) trashPage(w http.ResponseWriter, r *http.Request) {
 		prevOffset = 0
 	}
 
-	ps.renderTemplate(w, "trash.html", trashPageData{
+	ps.renderTemplate(w, r, "trash.html", trashPageData{
 		baseData:   flashFromQuery(r),
 		Items:      items,
 		Total:      total,
@@ -676,11 +894,12 @@ func (ps *pageServer) trashPage(w http.ResponseWriter, r *http.Request) {
 // ── Fragment handlers ─────────────────────────────────────────────────────────
 
 func (ps *pageServer) scanStatusFragment(w http.ResponseWriter, r *http.Request) {
-	data := scanStatusData{}
+	data := scanStatusData{PollSeconds: ps.cfg.StatusPollIdleSeconds}
 
 	if ps.mgr != nil {
 		if active := ps.mgr.ActiveScan(); active != nil {
 			data.ScanRunning = true
+			data.PollSeconds = ps.cfg.StatusPollSeconds
 			data.ScanID = active.ID
 			data.StartedAt = active.StartedAt.Format("15:04:05")
 			data.TriggeredBy = active.TriggeredBy
@@ -695,6 +914,7 @@ func (ps *pageServer) scanStatusFragment(w http.ResponseWriter, r *http.Request)
 			phase2Started := p.Phase2StartedAt.Load()
 			data.GroupsWritten = p.GroupsWritten.Load()
 			data.GroupsTotal = p.GroupsTotal.Load()
+			data.Phase2Pct = p.Phase2Percent()
 			if phase2Started > 0 {
 				data.Phase = "writing"
 				if data.GroupsWritten > 0 {
@@ -726,7 +946,7 @@ func (ps *pageServer) scanStatusFragment(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	ps.renderFragment(w, "scan_status.html", "scan_status", data)
+	ps.renderFragment(w, r, "scan_status.html", "scan_status", data)
 }
 
 // ── UI action handlers ────────────────────────────────────────────────────────
@@ -767,36 +987,24 @@ func (ps *pageServer) uiGroupDelete(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	r.ParseForm()
-	keeperIDStr := r.FormValue("keeper_id")
-
-	var contentHash string
-	var fileSize int64
-	err = ps.readDB.QueryRowContext(r.Context(),
-		`SELECT content_hash, file_size FROM duplicate_groups WHERE id = ?`, groupID,
-	).Scan(&contentHash, &fileSize)
-	if err != nil {
-		uiRedirect(w, r, "/groups-ui", "error", "Group not found")
+	if ps.cfg.ReadOnly {
+		uiRedirect(w, r, "/groups-ui/"+idStr, "error", handlers.ReadOnlyMessage)
 		return
 	}
+	r.ParseForm()
+	keeperIDStr := r.FormValue("keeper_id")
 
-	type fileRecord struct {
-		ID    int64
-		Path  string
-		Size  int64
-		MTime int64
-	}
 	fileRows, err := ps.readDB.QueryContext(r.Context(),
-		`SELECT id, path, size, mtime FROM duplicate_files WHERE group_id = ?`, groupID)
+		`SELECT id FROM duplicate_files WHERE group_id = ?`, groupID)
 	if err != nil {
 		uiRedirect(w, r, "/groups-ui", "error", "Database error")
 		return
 	}
-	allFiles := map[int64]fileRecord{}
+	var fileIDs []int64
 	for fileRows.Next() {
-		var f fileRecord
-		if err := fileRows.Scan(&f.ID, &f.Path, &f.Size, &f.MTime); err == nil {
-			allFiles[f.ID] = f
+		var id int64
+		if err := fileRows.Scan(&id); err == nil {
+			fileIDs = append(fileIDs, id)
 		}
 	}
 	fileRows.Close()
@@ -805,16 +1013,14 @@ func (ps *pageServer) uiGroupDelete(w http.ResponseWriter, r *http.Request) {
 	// Mode A (keep-one): keeper_id is set — delete everything else.
 	// Mode B (select): delete_file_ids[] contains the IDs to remove.
 	var deleteIDs []int64
-	keeperID := int64(-1) // -1 means no explicit keeper (select-to-delete mode)
 	if keeperIDStr != "" {
 		// keep-one mode: delete all except the keeper
-		var parseErr error
-		keeperID, parseErr = strconv.ParseInt(keeperIDStr, 10, 64)
+		keeperID, parseErr := strconv.ParseInt(keeperIDStr, 10, 64)
 		if parseErr != nil {
 			uiRedirect(w, r, "/groups-ui/"+idStr, "error", "Invalid keeper selection")
 			return
 		}
-		for id := range allFiles {
+		for _, id := range fileIDs {
 			if id != keeperID {
 				deleteIDs = append(deleteIDs, id)
 			}
@@ -832,79 +1038,23 @@ func (ps *pageServer) uiGroupDelete(w http.ResponseWriter, r *http.Request) {
 		uiRedirect(w, r, "/groups-ui/"+idStr, "error", "No files selected for deletion")
 		return
 	}
-	if len(deleteIDs) >= len(allFiles) {
-		uiRedirect(w, r, "/groups-ui/"+idStr, "error", "At least one file must be kept")
-		return
-	}
-
-	// Validate files on disk.
-	// Build a set of IDs being deleted for O(1) lookup.
-	deleteSet := make(map[int64]bool, len(deleteIDs))
-	for _, id := range deleteIDs {
-		deleteSet[id] = true
-	}
-	for fid, f := range allFiles {
-		info, statErr := os.Stat(f.Path)
-		if deleteSet[fid] {
-			if os.IsNotExist(statErr) {
-				uiRedirect(w, r, "/groups-ui/"+idStr, "error", "File missing: "+f.Path+". Please re-scan.")
-				return
-			}
-			if statErr == nil && (info.Size() != f.Size || info.ModTime().Unix() != f.MTime) {
-				uiRedirect(w, r, "/groups-ui/"+idStr, "error", "File modified: "+f.Path+". Please re-scan.")
-				return
-			}
-		} else if keeperID == fid {
-			if os.IsNotExist(statErr) {
-				uiRedirect(w, r, "/groups-ui/"+idStr, "error", "Keeper missing: "+f.Path+". Please re-scan.")
-				return
-			}
-		}
-	}
 
-	retentionDays := 30
-	if ps.cfg != nil && ps.cfg.TrashRetentionDays > 0 {
-		retentionDays = ps.cfg.TrashRetentionDays
-	}
-	for _, fileID := range deleteIDs {
-		f := allFiles[fileID]
-		if _, err := ps.trashMgr.MoveToTrash(r.Context(), f.Path, groupID, contentHash, retentionDays); err != nil {
-			uiRedirect(w, r, "/groups-ui/"+idStr, "error", "Failed to trash: "+err.Error())
-			return
-		}
-	}
-
-	tx, err := ps.db.BeginTx(r.Context(), nil)
+	// Validation, verification stamp, trash moves and group update are the
+	// API's, so the two delete paths cannot drift apart.
+	trashed, skipped, status, err := ps.groupsH.TrashFiles(r, groupID, deleteIDs)
 	if err != nil {
-		uiRedirect(w, r, "/groups-ui", "error", "Database error")
+		uiRedirect(w, r, "/groups-ui/"+idStr, "error", handlers.TrashErrorMessage(err))
 		return
 	}
-	defer tx.Rollback()
-	for _, fileID := range deleteIDs {
-		tx.ExecContext(r.Context(), `DELETE FROM duplicate_files WHERE id = ?`, fileID)
-	}
-	remaining := len(allFiles) - len(deleteIDs)
-	newStatus := "unresolved"
-	newReclaimable := fileSize * int64(remaining-1)
-	if remaining <= 1 {
-		newStatus = "resolved"
-		newReclaimable = 0
-	}
-	now := time.Now().Unix()
-	if newStatus == "resolved" {
-		tx.ExecContext(r.Context(), `
-			UPDATE duplicate_groups SET file_count=?, reclaimable_bytes=?, status=?, resolved_at=?, updated_at=?
-			WHERE id=?`, remaining, newReclaimable, newStatus, now, now, groupID)
-	} else {
-		tx.ExecContext(r.Context(), `
-			UPDATE duplicate_groups SET file_count=?, reclaimable_bytes=?, status=?, updated_at=?
-			WHERE id=?`, remaining, newReclaimable, newStatus, now, groupID)
-	}
-	tx.Commit()
 
-	if newStatus == "resolved" {
+	switch {
+	case skipped > 0:
+		uiRedirect(w, r, "/groups-ui/"+idStr, "error",
+			fmt.Sprintf("%d file(s) deleted; %d changed on disk just before deletion and were kept. Please re-scan.",
+				trashed, skipped))
+	case status == "resolved":
 		uiRedirect(w, r, "/groups-ui", "success", "Files deleted, group resolved")
-	} else {
+	default:
 		uiRedirect(w, r, "/groups-ui/"+idStr, "success", "Files deleted")
 	}
 }
@@ -936,7 +1086,7 @@ func (ps *pageServer) uiGroupIgnore(w http.ResponseWriter, r *http.Request) {
 	case "hash":
 		ps.db.ExecContext(r.Context(),
 			`INSERT OR IGNORE INTO whitelist (type, value, added_by, added_at)
-			 VALUES ('hash', ?, 'user', ?)`, contentHash, now)
+			 VALUES ('hash', ?, ?, ?)`, contentHash, handlers.RequestActor(r), now)
 		newGroupStatus = "ignored"
 
 	case "path_pair":
@@ -958,8 +1108,8 @@ func (ps *pageServer) uiGroupIgnore(w http.ResponseWriter, r *http.Request) {
 		pathJSON, _ := json.Marshal(paths)
 		ps.db.ExecContext(r.Context(),
 			`INSERT OR IGNORE INTO whitelist (type, value, expected_hash, added_by, added_at)
-			 VALUES ('path_pair', ?, ?, 'user', ?)`,
-			string(pathJSON), contentHash, now)
+			 VALUES ('path_pair', ?, ?, ?, ?)`,
+			string(pathJSON), contentHash, handlers.RequestActor(r), now)
 		newGroupStatus = "watching"
 
 	case "dir":
@@ -969,7 +1119,7 @@ func (ps *pageServer) uiGroupIgnore(w http.ResponseWriter, r *http.Request) {
 		}
 		ps.db.ExecContext(r.Context(),
 			`INSERT OR IGNORE INTO whitelist (type, value, added_by, added_at)
-			 VALUES ('dir', ?, 'user', ?)`, dirPath, now)
+			 VALUES ('dir', ?, ?, ?)`, dirPath, handlers.RequestActor(r), now)
 		newGroupStatus = "ignored"
 
 	default:
@@ -980,6 +1130,10 @@ func (ps *pageServer) uiGroupIgnore(w http.ResponseWriter, r *http.Request) {
 	ps.db.ExecContext(r.Context(), `
 		UPDATE duplicate_groups SET status=?, ignored_at=?, updated_at=? WHERE id=?`,
 		newGroupStatus, now, now, groupID)
+	ps.audit(r, groupID, "ignore", map[string]interface{}{"type": ignoreType, "status": newGroupStatus})
+	if ignoreType == "dir" && ps.cfg.IgnoreDirExistingGroups {
+		ps.ignoreExistingUnderDir(r, dirPath)
+	}
 
 	uiRedirect(w, r, "/groups-ui", "success", "Group updated")
 }
@@ -998,6 +1152,7 @@ func (ps *pageServer) uiGroupReset(w http.ResponseWriter, r *http.Request) {
 		uiRedirect(w, r, "/groups-ui", "error", "Failed to reset group: "+err.Error())
 		return
 	}
+	ps.audit(r, groupID, "reset", map[string]interface{}{"status": "unresolved"})
 	uiRedirect(w, r, "/groups-ui", "success", "Group reset to unresolved")
 }
 
@@ -1007,14 +1162,19 @@ func (ps *pageServer) uiTrashRestore(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	if err := ps.trashMgr.Restore(r.Context(), id); err != nil {
+	restoredPath, err := ps.trashMgr.Restore(r.Context(), id)
+	if err != nil {
 		uiRedirect(w, r, "/trash-ui", "error", "Restore failed: "+err.Error())
 		return
 	}
-	uiRedirect(w, r, "/trash-ui", "success", "File restored")
+	uiRedirect(w, r, "/trash-ui", "success", "File restored to "+restoredPath)
 }
 
 func (ps *pageServer) uiTrashPurge(w http.ResponseWriter, r *http.Request) {
+	if ps.cfg.ReadOnly {
+		uiRedirect(w, r, "/trash-ui", "error", handlers.ReadOnlyMessage)
+		return
+	}
 	count, bytesFreed, err := ps.trashMgr.PurgeAll(r.Context())
 	if err != nil {
 		uiRedirect(w, r, "/trash-ui", "error", "Purge failed: "+err.Error())
@@ -1024,19 +1184,42 @@ func (ps *pageServer) uiTrashPurge(w http.ResponseWriter, r *http.Request) {
 		fmt.Sprintf("Purged %d files, freed %s", count, humanBytes(bytesFreed)))
 }
 
+// uiTheme handles POST /ui/theme — switches between the light and dark theme
+// and returns to the page the toggle was pressed on.
+func (ps *pageServer) uiTheme(w http.ResponseWriter, r *http.Request) {
+	next := "dark"
+	if ps.cfg.Theme == "dark" {
+		next = "light"
+	}
+	back := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		back = ref.Path
+	}
+	if err := ps.cfgH.Apply(r.Context(), handlers.ConfigPatch{Theme: &next}); err != nil {
+		uiRedirect(w, r, back, "error", err.Error())
+		return
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
 func (ps *pageServer) settingsPage(w http.ResponseWriter, r *http.Request) {
 	d := settingsPageData{
-		baseData:           flashFromQuery(r),
-		ScanPaths:          strings.Join(ps.cfg.ScanPaths, "\n"),
-		ExcludePaths:       strings.Join(ps.cfg.ExcludePaths, "\n"),
-		Schedule:           ps.cfg.Schedule,
-		ScanPaused:         ps.cfg.ScanPaused,
-		TrashRetentionDays: ps.cfg.TrashRetentionDays,
-		Walkers:            ps.cfg.ScanWorkers.Walkers,
-		PartialHashers:     ps.cfg.ScanWorkers.PartialHashers,
-		FullHashers:        ps.cfg.ScanWorkers.FullHashers,
-	}
-	ps.renderTemplate(w, "settings.html", d)
+		baseData:              flashFromQuery(r),
+		ScanRunning:           ps.mgr.ActiveScan() != nil,
+		ScanPaths:             strings.Join(ps.cfg.ScanPaths, "\n"),
+		ExcludePaths:          strings.Join(ps.cfg.ExcludePaths, "\n"),
+		Schedule:              ps.cfg.Schedule,
+		ScanPaused:            ps.cfg.ScanPaused,
+		TrashRetentionDays:    ps.cfg.TrashRetentionDays,
+		Walkers:               ps.cfg.ScanWorkers.Walkers,
+		CacheCheckers:         ps.cfg.ScanWorkers.CacheCheckers,
+		PartialHashers:        ps.cfg.ScanWorkers.PartialHashers,
+		FullHashers:           ps.cfg.ScanWorkers.FullHashers,
+		StatusPollSeconds:     ps.cfg.StatusPollSeconds,
+		StatusPollIdleSeconds: ps.cfg.StatusPollIdleSeconds,
+		DefaultGroupType:      ps.cfg.DefaultGroupType,
+	}
+	ps.renderTemplate(w, r, "settings.html", d)
 }
 
 func (ps *pageServer) uiSettingsSave(w http.ResponseWriter, r *http.Request) {
@@ -1071,6 +1254,11 @@ func (ps *pageServer) uiSettingsSave(w http.ResponseWriter, r *http.Request) {
 		uiRedirect(w, r, "/settings-ui", "error", "Walkers must be at least 1")
 		return
 	}
+	cacheCheckers, err := strconv.Atoi(r.FormValue("cache_checkers"))
+	if err != nil || cacheCheckers < 1 {
+		uiRedirect(w, r, "/settings-ui", "error", "Cache checkers must be at least 1")
+		return
+	}
 	partialHashers, err := strconv.Atoi(r.FormValue("partial_hashers"))
 	if err != nil || partialHashers < 1 {
 		uiRedirect(w, r, "/settings-ui", "error", "Partial hashers must be at least 1")
@@ -1081,6 +1269,17 @@ func (ps *pageServer) uiSettingsSave(w http.ResponseWriter, r *http.Request) {
 		uiRedirect(w, r, "/settings-ui", "error", "Full hashers must be at least 1")
 		return
 	}
+	pollSecs, err := strconv.Atoi(r.FormValue("status_poll_seconds"))
+	if err != nil || pollSecs < 1 {
+		uiRedirect(w, r, "/settings-ui", "error", "Scan status refresh must be at least 1 second")
+		return
+	}
+	pollIdleSecs, err := strconv.Atoi(r.FormValue("status_poll_idle_seconds"))
+	if err != nil || pollIdleSecs < 1 {
+		uiRedirect(w, r, "/settings-ui", "error", "Idle status refresh must be at least 1 second")
+		return
+	}
+	groupType := r.FormValue("default_group_type")
 
 	patch := handlers.ConfigPatch{
 		ScanPaths:          scanPaths,
@@ -1090,9 +1289,13 @@ func (ps *pageServer) uiSettingsSave(w http.ResponseWriter, r *http.Request) {
 		TrashRetentionDays: &retention,
 		ScanWorkers: &handlers.WorkerPatch{
 			Walkers:        &walkers,
+			CacheCheckers:  &cacheCheckers,
 			PartialHashers: &partialHashers,
 			FullHashers:    &fullHashers,
 		},
+		StatusPollSeconds:     &pollSecs,
+		StatusPollIdleSeconds: &pollIdleSecs,
+		DefaultGroupType:      &groupType,
 	}
 	if err := ps.cfgH.Apply(r.Context(), patch); err != nil {
 		uiRedirect(w, r, "/settings-ui", "error", err.Error())
@@ -1100,16 +1303,19 @@ func (ps *pageServer) uiSettingsSave(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if ps.sched != nil && schedule != "" {
-		if err := ps.sched.SetJob(schedule, func() {
+		if err := ps.sched.SetJob(schedule, func() error {
 			slog.Info("scheduled scan triggered")
-			if _, err := ps.mgr.Start(context.Background(), "schedule"); err != nil {
-				slog.Warn("scheduled scan start", "error", err)
-			}
+			_, err := ps.mgr.Start(context.Background(), "schedule")
+			return err
 		}); err != nil {
 			uiRedirect(w, r, "/settings-ui", "error", "Invalid cron expression: "+err.Error())
 			return
 		}
 	}
 
+	if ps.mgr.ActiveScan() != nil {
+		uiRedirect(w, r, "/settings-ui", "success", "Settings saved. "+handlers.NextScanMessage)
+		return
+	}
 	uiRedirect(w, r, "/settings-ui", "success", "Settings saved")
 }
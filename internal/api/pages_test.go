@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/api/handlers"
+	"github.com/eargollo/ditto/internal/trash"
+)
+
+// TestUIGroupDeleteInsufficientSpace verifies that the UI delete refuses,
+// before moving anything, files that would not fit in the trash while
+// keeping min_free_bytes.
+func TestUIGroupDeleteInsufficientSpace(t *testing.T) {
+	ps := newTestPageServer(t)
+	// Compressed files always need space, even on the trash's filesystem.
+	ps.trashMgr = trash.New(ps.db, filepath.Join(t.TempDir(), "trash"), "", 1<<62, true, "")
+	ps.groupsH.Trash = ps.trashMgr
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2")}
+	groupID, fileIDs := mustInsertGroup(t, ps.db, "aaaa", paths...)
+
+	rec := postForm(uiRoutes(ps), fmt.Sprintf("/ui/groups/%d/delete", groupID),
+		url.Values{"keeper_id": {strconv.FormatInt(fileIDs[0], 10)}})
+	if flash, msg := redirectFlash(t, rec); flash != "error" || !strings.Contains(msg, "not enough free space") {
+		t.Errorf("flash = %s %q, want an insufficient space error", flash, msg)
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+	var trashed int
+	ps.db.QueryRow(`SELECT COUNT(*) FROM trash`).Scan(&trashed)
+	if trashed != 0 {
+		t.Errorf("trash rows = %d, want 0", trashed)
+	}
+}
+
+// TestUIReadOnly verifies that read_only makes the UI delete and purge
+// redirect with the read-only message and leave the files on disk.
+func TestUIReadOnly(t *testing.T) {
+	ps := newTestPageServer(t)
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2")}
+	groupID, fileIDs := mustInsertGroup(t, ps.db, "aaaa", paths...)
+	trashID, err := ps.trashMgr.MoveToTrash(context.Background(), paths[1], groupID, "aaaa", 30)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	var trashPath string
+	if err := ps.db.QueryRow(`SELECT trash_path FROM trash WHERE id = ?`, trashID).Scan(&trashPath); err != nil {
+		t.Fatal(err)
+	}
+	ps.cfg.ReadOnly = true
+
+	tests := []struct {
+		name, target string
+		form         url.Values
+	}{
+		{"delete", fmt.Sprintf("/ui/groups/%d/delete", groupID), url.Values{"keeper_id": {strconv.FormatInt(fileIDs[1], 10)}}},
+		{"purge", "/ui/trash/purge", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postForm(uiRoutes(ps), tt.target, tt.form)
+			if flash, msg := redirectFlash(t, rec); flash != "error" || msg != handlers.ReadOnlyMessage {
+				t.Errorf("flash = %s %q, want the read-only error", flash, msg)
+			}
+			assertExists(t, paths[0])
+			assertExists(t, trashPath)
+		})
+	}
+}
+
+// TestUIGroupDeleteProtectedPath verifies that the UI delete refuses a
+// delete set touching protected_dirs, naming the file, and moves nothing.
+func TestUIGroupDeleteProtectedPath(t *testing.T) {
+	ps := newTestPageServer(t)
+	dir := t.TempDir()
+	ps.cfg.ProtectedDirs = []string{filepath.Join(dir, "photos")}
+	paths := []string{filepath.Join(dir, "photos2", "f"), filepath.Join(dir, "photos", "f")}
+	groupID, fileIDs := mustInsertGroup(t, ps.db, "aaaa", paths...)
+
+	rec := postForm(uiRoutes(ps), fmt.Sprintf("/ui/groups/%d/delete", groupID),
+		url.Values{"keeper_id": {strconv.FormatInt(fileIDs[0], 10)}})
+	if loc := rec.Header().Get("Location"); !strings.HasPrefix(loc, fmt.Sprintf("/groups-ui/%d?", groupID)) {
+		t.Errorf("redirect to %q, want the group page", loc)
+	}
+	flash, msg := redirectFlash(t, rec)
+	if flash != "error" || msg != "File is in a protected directory and cannot be deleted: "+paths[1] {
+		t.Errorf("flash = %s %q, want the protected directory error for %s", flash, msg, paths[1])
+	}
+	for _, p := range paths {
+		assertExists(t, p)
+	}
+}
+
+// TestUIGroupDeleteRecordsVerification verifies that the UI delete, like the
+// API's, stamps last_verified_at once every file checked out on disk, and
+// resolves a group left with its keeper only.
+func TestUIGroupDeleteRecordsVerification(t *testing.T) {
+	ps := newTestPageServer(t)
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "f1"), filepath.Join(dir, "f2")}
+	groupID, fileIDs := mustInsertGroup(t, ps.db, "aaaa", paths...)
+
+	before := time.Now().Unix()
+	rec := postForm(uiRoutes(ps), fmt.Sprintf("/ui/groups/%d/delete", groupID),
+		url.Values{"keeper_id": {strconv.FormatInt(fileIDs[0], 10)}})
+	if flash, msg := redirectFlash(t, rec); flash != "success" {
+		t.Fatalf("flash = %s %q, want success", flash, msg)
+	}
+	var status string
+	var verified sql.NullInt64
+	if err := ps.db.QueryRow(`SELECT status, last_verified_at FROM duplicate_groups WHERE id = ?`,
+		groupID).Scan(&status, &verified); err != nil {
+		t.Fatal(err)
+	}
+	if status != "resolved" {
+		t.Errorf("status = %q, want resolved", status)
+	}
+	if !verified.Valid || verified.Int64 < before {
+		t.Errorf("last_verified_at = %v, want set by the delete (>= %d)", verified, before)
+	}
+	assertExists(t, paths[0])
+}
@@ -6,6 +6,8 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -19,8 +21,10 @@ import (
 
 // Server holds the HTTP server and all handler dependencies.
 type Server struct {
-	addr string
-	srv  *http.Server
+	addr            string
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	inFlight        atomic.Int64 // requests currently being served
 }
 
 // New wires all routes and returns a Server ready to Run.
@@ -38,40 +42,79 @@ func New(
 	templatesFS fs.FS,
 	staticFS fs.FS,
 ) *Server {
+	s := &Server{addr: addr}
+	if cfg != nil && cfg.ShutdownTimeout > 0 {
+		s.shutdownTimeout = time.Duration(cfg.ShutdownTimeout) * time.Second
+	}
+
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(s.countInFlight)
 	r.Use(middleware.RequestID)
+	var accessLogSkip []string
+	if cfg != nil {
+		accessLogSkip = cfg.AccessLogSkip
+		r.Use(handlers.Actor(cfg.ActorHeader, cfg.ActorName))
+	}
+	r.Use(handlers.AccessLog(accessLogSkip))
+	routeMetrics := handlers.NewRouteMetrics()
+	r.Use(routeMetrics.Middleware)
+	r.Use(middleware.Recoverer)
+	if cfg != nil {
+		r.Use(handlers.RequestTimeout(time.Duration(cfg.RequestTimeout) * time.Second))
+	}
 
 	statusH := &handlers.StatusHandler{DB: db, Manager: mgr, Sched: sched, Version: version}
+	versionH := &handlers.VersionHandler{Version: version}
 	scansH := &handlers.ScansHandler{DB: db, Manager: mgr}
+	schedulesH := &handlers.SchedulesHandler{Sched: sched}
 	groupsH := &handlers.GroupsHandler{
 		DB:      db,
 		Trash:   trashMgr,
 		Cfg:     cfg,
 		ScanMgr: mgr,
 	}
-	filesH := &handlers.FilesHandler{DB: db}
-	trashH := &handlers.TrashHandler{DB: db, Trash: trashMgr}
+	filesH := &handlers.FilesHandler{DB: db, MaxPreviewBytes: cfg.MaxPreviewBytes}
+	trashH := &handlers.TrashHandler{DB: db, Trash: trashMgr, ReadOnly: cfg.ReadOnly}
 	statsH := &handlers.StatsHandler{DB: db}
 	configH := &handlers.ConfigHandler{DB: db, Cfg: cfg, Manager: mgr}
+	reportsH := &handlers.ReportsHandler{DB: db, Cfg: cfg}
 
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/status", statusH.ServeHTTP)
+		r.Get("/version", versionH.ServeHTTP)
+		r.Get("/openapi.json", serveOpenAPI)
 
 		r.Post("/scans", scansH.Create)
+		r.Post("/scans/report", scansH.Report)
+		r.Post("/scans/stream", scansH.Stream)
 		r.Get("/scans", scansH.List)
+		r.Get("/scans/estimate", scansH.Estimate)
 		r.Get("/scans/{id}/telemetry", scansH.Telemetry)
+		r.Get("/scans/{id}/timeline", scansH.Timeline)
 		r.Get("/scans/{id}", scansH.Get)
 		r.Delete("/scans/current", scansH.Cancel)
 
+		r.Post("/schedules/{name}/run", schedulesH.Run)
+
 		r.Get("/groups", groupsH.List)
+		r.Post("/groups/resolve", groupsH.Resolve)
+		r.Post("/groups/ignore-batch", groupsH.IgnoreBatch)
+		r.Post("/groups/merge", groupsH.Merge)
+		r.Get("/groups/find", groupsH.Find)
+		r.Get("/groups/status-counts", groupsH.StatusCounts)
 		r.Get("/groups/{id}", groupsH.Get)
+		r.Patch("/groups/{id}", groupsH.Update)
 		r.Post("/groups/{id}/delete", groupsH.Delete)
 		r.Post("/groups/{id}/ignore", groupsH.Ignore)
 		r.Post("/groups/{id}/reset", groupsH.Reset)
+		r.Get("/groups/{id}/history", groupsH.History)
 		r.Get("/groups/{id}/thumbnail", groupsH.Thumbnail)
+		r.Get("/groups/{id}/download", groupsH.Download)
+
+		r.Get("/whitelist/export", groupsH.WhitelistExport)
+		r.Post("/whitelist/import", groupsH.WhitelistImport)
 
+		r.Post("/files/thumbnails", filesH.Thumbnails)
 		r.Get("/files/{id}/info", filesH.Info)
 		r.Get("/files/{id}/thumbnail", filesH.Thumbnail)
 		r.Get("/files/{id}/preview", filesH.Preview)
@@ -79,11 +122,22 @@ func New(
 		r.Get("/trash", trashH.List)
 		r.Post("/trash/{id}/restore", trashH.Restore)
 		r.Delete("/trash", trashH.PurgeAll)
+		r.Post("/trash/purge-expired", trashH.PurgeExpired)
 
 		r.Get("/stats", statsH.ServeHTTP)
 
+		r.Get("/reports/name-variants", reportsH.NameVariants)
+		r.Get("/reports/stale", reportsH.Stale)
+		r.Get("/reports/duplicate-dirs", reportsH.DuplicateDirs)
+		r.Get("/reports/quarantine", reportsH.Quarantine)
+		r.Delete("/reports/quarantine", reportsH.ClearQuarantine)
+
 		r.Get("/config", configH.Get)
+		r.Get("/config/sources", configH.Sources)
 		r.Patch("/config", configH.Update)
+
+		r.Post("/admin/recompute-groups", groupsH.RecomputeGroups)
+		r.Get("/admin/metrics", routeMetrics.ServeHTTP)
 	})
 
 	if staticFS != nil {
@@ -103,6 +157,7 @@ func New(
 			sched:       sched,
 			templatesFS: templatesFS,
 			cfgH:        configH,
+			groupsH:     groupsH,
 		}
 		r.Get("/", ps.dashboardPage)
 		r.Get("/groups-ui", ps.groupsPage)
@@ -113,21 +168,34 @@ func New(
 		// Fragment endpoints (HTMX polling)
 		r.Get("/ui/scan-status", ps.scanStatusFragment)
 
-		// UI action endpoints (form POST → redirect)
-		r.Post("/ui/scan", ps.uiScanStart)
-		r.Post("/ui/scan/cancel", ps.uiScanCancel)
-		r.Post("/ui/groups/{id}/delete", ps.uiGroupDelete)
-		r.Post("/ui/groups/{id}/ignore", ps.uiGroupIgnore)
-		r.Post("/ui/groups/{id}/reset", ps.uiGroupReset)
-		r.Post("/ui/trash/{id}/restore", ps.uiTrashRestore)
-		r.Post("/ui/trash/purge", ps.uiTrashPurge)
-		r.Post("/ui/settings", ps.uiSettingsSave)
+		// UI action endpoints (form POST → redirect), each form carrying
+		// the CSRF token rendered by {{csrfField}}.
+		r.Group(func(r chi.Router) {
+			r.Use(verifyCSRF)
+			r.Post("/ui/scan", ps.uiScanStart)
+			r.Post("/ui/scan/cancel", ps.uiScanCancel)
+			r.Post("/ui/groups/{id}/delete", ps.uiGroupDelete)
+			r.Post("/ui/groups/{id}/ignore", ps.uiGroupIgnore)
+			r.Post("/ui/groups/{id}/reset", ps.uiGroupReset)
+			r.Post("/ui/trash/{id}/restore", ps.uiTrashRestore)
+			r.Post("/ui/trash/purge", ps.uiTrashPurge)
+			r.Post("/ui/settings", ps.uiSettingsSave)
+			r.Post("/ui/theme", ps.uiTheme)
+		})
 	}
 
-	return &Server{
-		addr: addr,
-		srv:  &http.Server{Addr: addr, Handler: r},
-	}
+	s.srv = &http.Server{Addr: addr, Handler: r}
+	return s
+}
+
+// countInFlight tracks the number of requests being served so shutdown can
+// report what it had to abandon.
+func (s *Server) countInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
 }
 
 // Run starts the HTTP server and blocks until ctx is cancelled.
@@ -142,8 +210,21 @@ func (s *Server) Run(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		slog.Info("shutting down HTTP server")
-		return s.srv.Shutdown(context.Background())
+		slog.Info("shutting down HTTP server", "in_flight", s.inFlight.Load(), "timeout", s.shutdownTimeout)
+		shutdownCtx := context.Background()
+		if s.shutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.shutdownTimeout)
+			defer cancel()
+		}
+		if err := s.srv.Shutdown(shutdownCtx); err != nil {
+			// Draining timed out (e.g. a slow client mid-preview): drop the
+			// remaining connections rather than hang the process.
+			slog.Warn("HTTP shutdown timed out, closing remaining connections",
+				"in_flight", s.inFlight.Load(), "error", err)
+			s.srv.Close()
+		}
+		return nil
 	case err := <-errCh:
 		return err
 	}
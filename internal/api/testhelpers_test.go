@@ -0,0 +1,137 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/eargollo/ditto/internal/api/handlers"
+	"github.com/eargollo/ditto/internal/config"
+	internaldb "github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/trash"
+)
+
+// newTestPageServer returns a pageServer over a fresh database, with the
+// default configuration and its trash in a temp dir. It renders no pages:
+// only the /ui action endpoints, which redirect, can be exercised.
+func newTestPageServer(tb testing.TB) *pageServer {
+	tb.Helper()
+	db, err := internaldb.Open(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatalf("open test DB: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := internaldb.RunMigrations(db); err != nil {
+		tb.Fatalf("run migrations: %v", err)
+	}
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		tb.Fatalf("load default config: %v", err)
+	}
+	trashMgr := trash.New(db, filepath.Join(tb.TempDir(), "trash"), "", 0, false, "")
+	return &pageServer{
+		db:       db,
+		readDB:   db,
+		cfg:      cfg,
+		trashMgr: trashMgr,
+		groupsH:  &handlers.GroupsHandler{DB: db, Trash: trashMgr, Cfg: cfg},
+	}
+}
+
+// uiRoutes mounts the /ui action endpoints of ps as the server does, without
+// the CSRF check.
+func uiRoutes(ps *pageServer) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/ui/groups/{id}/delete", ps.uiGroupDelete)
+	r.Post("/ui/trash/purge", ps.uiTrashPurge)
+	return r
+}
+
+// postForm posts form to target on handler and returns the response.
+func postForm(handler http.Handler, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// redirectFlash returns the flash type and message of a uiRedirect response.
+func redirectFlash(tb testing.TB, rec *httptest.ResponseRecorder) (flashType, msg string) {
+	tb.Helper()
+	if rec.Code != http.StatusSeeOther {
+		tb.Fatalf("status %d, want a 303 redirect; body %s", rec.Code, rec.Body)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		tb.Fatalf("parse Location: %v", err)
+	}
+	return loc.Query().Get("flash"), loc.Query().Get("msg")
+}
+
+// mustInsertGroup writes each of paths to disk with the same content and
+// records them as one unresolved duplicate group keyed by hash. It returns
+// the group ID and the file IDs, in the order of paths.
+func mustInsertGroup(tb testing.TB, db *sql.DB, hash string, paths ...string) (int64, []int64) {
+	tb.Helper()
+	const content = "duplicate content"
+	now := time.Now().Unix()
+	res, err := db.Exec(
+		`INSERT INTO scan_history (started_at, finished_at, status, triggered_by, created_at)
+		 VALUES (?, ?, 'completed', 'manual', ?)`, now, now, now)
+	if err != nil {
+		tb.Fatalf("insert scan: %v", err)
+	}
+	scanID, _ := res.LastInsertId()
+	size := int64(len(content))
+	res, err = db.Exec(`
+		INSERT INTO duplicate_groups
+			(content_hash, file_size, file_count, reclaimable_bytes, file_type, status,
+			 first_seen_scan_id, last_seen_scan_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 'other', 'unresolved', ?, ?, ?, ?)`,
+		hash, size, len(paths), size*int64(len(paths)-1), scanID, scanID, now, now)
+	if err != nil {
+		tb.Fatalf("insert group: %v", err)
+	}
+	groupID, _ := res.LastInsertId()
+
+	var fileIDs []int64
+	for _, p := range paths {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		res, err := db.Exec(`
+			INSERT INTO duplicate_files (group_id, scan_id, path, size, mtime, file_type)
+			VALUES (?, ?, ?, ?, ?, 'other')`,
+			groupID, scanID, p, info.Size(), info.ModTime().Unix())
+		if err != nil {
+			tb.Fatalf("insert file %s: %v", p, err)
+		}
+		id, _ := res.LastInsertId()
+		fileIDs = append(fileIDs, id)
+	}
+	return groupID, fileIDs
+}
+
+// assertExists fails the test unless a file is at path.
+func assertExists(tb testing.TB, path string) {
+	tb.Helper()
+	if _, err := os.Lstat(path); err != nil {
+		tb.Errorf("%s should still be on disk: %v", path, err)
+	}
+}
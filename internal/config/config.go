@@ -1,10 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,18 +19,212 @@ type Config struct {
 	ScanPaused         bool        `yaml:"scan_paused"          json:"scan_paused"`
 	TrashDir           string      `yaml:"trash_dir"            json:"-"`
 	TrashRetentionDays int         `yaml:"trash_retention_days" json:"trash_retention_days"`
+	ArchiveDir         string      `yaml:"archive_dir"          json:"-"`
 	DBPath             string      `yaml:"db_path"              json:"-"`
 	HTTPAddr           string      `yaml:"http_addr"            json:"-"`
+	ShutdownTimeout    int         `yaml:"shutdown_timeout"     json:"-"` // seconds
+	RequestTimeout     int         `yaml:"request_timeout"      json:"-"` // seconds
 	ScanWorkers        ScanWorkers `yaml:"scan_workers"         json:"scan_workers"`
+	PartialHashAlgo    string      `yaml:"partial_hash_algo"    json:"partial_hash_algo"`
+	SniffContentTypes  bool        `yaml:"sniff_content_types"  json:"sniff_content_types"`
+	CacheBatchSize     int         `yaml:"cache_batch_size"     json:"cache_batch_size"`
 	LogLevel           string      `yaml:"log_level"            json:"-"`
+
+	// DBReadConns is the size of the read-only SQLite connection pool used
+	// by the scan's cache checkers and the web pages, opened at startup.
+	// WAL lets these readers run alongside the single write connection.
+	// 0 (default) sizes it to scan_workers.cache_checkers plus two for the
+	// pages.
+	DBReadConns int `yaml:"db_read_conns" json:"-"`
+
+	// Theme is the web UI colour theme, "light" or "dark". It can be toggled
+	// from the UI and is stored in the settings table, so it applies to
+	// every browser using the instance.
+	Theme string `yaml:"theme" json:"theme"`
+
+	// DashboardTrendDays bounds the dashboard trend charts to the snapshots
+	// of the last N days (default 90; negative = all). The dashboard's
+	// ?trend_days= overrides it per view.
+	DashboardTrendDays int `yaml:"dashboard_trend_days" json:"-"`
+
+	// MinReclaimableBytes, when > 0, is the default min_reclaimable of GET
+	// /api/groups and the groups page, hiding groups that waste less; an
+	// explicit min_reclaimable=0 (min=all in the UI) shows them again. The
+	// dashboard reports the reclaimable total both in full and above it.
+	MinReclaimableBytes int64 `yaml:"min_reclaimable_bytes" json:"-"`
+
+	// StatusPollSeconds is how often the dashboard refreshes the scan status
+	// while a scan runs (default 3); StatusPollIdleSeconds is the slower
+	// interval used while idle (default 15). Both can be changed from the
+	// settings page.
+	StatusPollSeconds     int `yaml:"status_poll_seconds"      json:"status_poll_seconds"`
+	StatusPollIdleSeconds int `yaml:"status_poll_idle_seconds" json:"status_poll_idle_seconds"`
+
+	// DefaultGroupType is the file type ("image", "video", "document" or
+	// "other") the groups page and GET /api/groups filter on when the
+	// request has no type parameter; "" lists every type. type=all lifts
+	// it. It can be changed from the settings page.
+	DefaultGroupType string `yaml:"default_group_type" json:"default_group_type"`
+
+	// AccessLogSkip lists request paths (path.Match patterns) left out of the
+	// access log. nil selects the UI polling and thumbnail endpoints; an
+	// explicit empty list logs everything.
+	AccessLogSkip []string `yaml:"access_log_skip" json:"-"`
+
+	// ActorName is who API and UI actions are attributed to in group
+	// histories and the added_by of ignore rules. ActorHeader, when set,
+	// names a request header (e.g. Remote-User from an authenticating
+	// reverse proxy) whose value takes precedence; only set it behind a
+	// proxy that always sets or strips that header.
+	ActorName   string `yaml:"actor_name"   json:"-"`
+	ActorHeader string `yaml:"actor_header" json:"-"`
+
+	// ReadOnly disables everything that removes files from the scan roots or
+	// the trash: group deletes, bulk resolve, trash purges and the daily
+	// auto-purge. Scanning, ignoring and reports keep working. It can only be
+	// set in the config file or environment, never through the API.
+	ReadOnly bool `yaml:"read_only" json:"read_only"`
+
+	// ScanOnStartup starts a scan when ditto starts, unless scan_paused is
+	// set. With ScanIfStaleHours > 0 it only does so when the last completed
+	// scan finished more than that many hours ago (or there is none), so a
+	// scheduled scan missed or cut short by a restart is caught up.
+	ScanOnStartup    bool `yaml:"scan_on_startup" json:"scan_on_startup"`
+	ScanIfStaleHours int  `yaml:"scan_if_stale_hours" json:"scan_if_stale_hours"`
+
+	// SkipRecentlyModifiedSeconds leaves files modified within the last N
+	// seconds out of a scan as still being written (0 = off).
+	SkipRecentlyModifiedSeconds int `yaml:"skip_recently_modified_seconds" json:"skip_recently_modified_seconds"`
+
+	// GroupByExtension keeps byte-identical files with different (case-
+	// insensitive) extensions in separate groups.
+	GroupByExtension bool `yaml:"group_by_extension" json:"group_by_extension"`
+
+	// MaxPreviewBytes caps the size of files served whole by the preview
+	// endpoint; larger files are only served to Range requests (0 = no cap).
+	MaxPreviewBytes int64 `yaml:"max_preview_bytes" json:"-"`
+
+	// GroupDownloadMaxBytes caps the file data in a group's zip download;
+	// files past it are left out and listed in the zip's manifest (default
+	// 1 GiB; negative = no cap).
+	GroupDownloadMaxBytes int64 `yaml:"group_download_max_bytes" json:"-"`
+
+	// SizeTolerancePercent pairs files whose sizes differ by at most this
+	// percentage as candidates instead of requiring equal sizes (0 = exact).
+	// It never finds a group exact sizes would not: groups still need equal
+	// content, so files of different sizes never end up in one. It only
+	// makes scans hash more files, until a similarity check uses it.
+	SizeTolerancePercent float64 `yaml:"size_tolerance_percent" json:"size_tolerance_percent"`
+
+	// ScanCPUPercent caps the share of time each hashing worker spends
+	// hashing, idling between files, to keep the host responsive during
+	// scans (0 or 100 = unthrottled).
+	ScanCPUPercent int `yaml:"scan_cpu_percent" json:"scan_cpu_percent"`
+
+	// CandidateHeadBytes refines same-size candidates by their first N bytes
+	// before hashing, at the cost of one small read per candidate (0 = off).
+	CandidateHeadBytes int `yaml:"candidate_head_bytes" json:"candidate_head_bytes"`
+
+	// PartialHashSkipAboveBytes sends files larger than this straight to the
+	// full hash, without the 64 KB partial-hash pre-filter (0 = never).
+	PartialHashSkipAboveBytes int64 `yaml:"partial_hash_skip_above_bytes" json:"partial_hash_skip_above_bytes"`
+
+	// ApproximateSampleBytes switches scans to approximate mode: files larger
+	// than twice this are keyed by their first and last N bytes plus size
+	// instead of a full hash. Such groups cannot be deleted (0 = exact).
+	ApproximateSampleBytes int64 `yaml:"approximate_sample_bytes" json:"approximate_sample_bytes"`
+
+	// MetadataOnly makes scans group files by basename, size and mtime
+	// without hashing them: a fast first pass over trusted backups. Such
+	// groups cannot be deleted until a content scan confirms them.
+	MetadataOnly bool `yaml:"metadata_only" json:"metadata_only"`
+
+	// SummarizePermissionErrors records the directories a scan may not read
+	// as one summary scan error instead of one error each.
+	SummarizePermissionErrors bool `yaml:"summarize_permission_errors" json:"summarize_permission_errors"`
+
+	// FileInventory keeps the hashes of fully hashed files that have no
+	// duplicate in the file_inventory table, at the cost of database size.
+	FileInventory bool `yaml:"file_inventory" json:"file_inventory"`
+
+	// ReconcileVanishedGroups makes each completed scan drop files deleted
+	// outside ditto from the groups it did not find again: a group left
+	// with one file is resolved, one left with none is marked "gone".
+	ReconcileVanishedGroups bool `yaml:"reconcile_vanished_groups" json:"reconcile_vanished_groups"`
+
+	// CacheFirstLookup looks every walked file up in the hash cache before
+	// size matching, so unchanged cached files skip the candidate stages.
+	// Ignored with size_tolerance_percent or candidate_head_bytes.
+	CacheFirstLookup bool `yaml:"cache_first_lookup" json:"cache_first_lookup"`
+
+	// HashQuarantineAfter quarantines a path once it has failed to hash in
+	// this many consecutive scans: later scans skip it, and stop reporting
+	// its error, until it is cleared through the API (0 = off).
+	HashQuarantineAfter int `yaml:"hash_quarantine_after" json:"hash_quarantine_after"`
+
+	// IgnoreDirExistingGroups makes a dir-type ignore also mark the current
+	// active groups whose files all lie under that directory as ignored,
+	// instead of leaving them until the next scan.
+	IgnoreDirExistingGroups bool `yaml:"ignore_dir_existing_groups" json:"ignore_dir_existing_groups"`
+
+	// TrashMinFreeBytes is the free space that must remain on the trash (or
+	// archive) filesystem after moving files there from another device.
+	TrashMinFreeBytes int64 `yaml:"trash_min_free_bytes" json:"-"`
+
+	// CompressTrash gzips files moved to the trash, except already
+	// compressed formats (JPEG, MP4, ZIP, ...); restores decompress them.
+	CompressTrash bool `yaml:"compress_trash" json:"-"`
+
+	// RestoreFallbackDir receives restores whose original directory can no
+	// longer be written, mirroring the original path ("" = fail instead).
+	RestoreFallbackDir string `yaml:"restore_fallback_dir" json:"-"`
+
+	// KeeperHeuristic picks the file marked suggested_keeper in group details
+	// and pre-selected in the UI: "oldest", "shortest_path" or "preferred_dir"
+	// (first file under KeeperPreferredDirs, in list order).
+	KeeperHeuristic     string   `yaml:"keeper_heuristic"      json:"keeper_heuristic"`
+	KeeperPreferredDirs []string `yaml:"keeper_preferred_dirs" json:"keeper_preferred_dirs"`
+
+	// ProtectedDirs are never deleted from (e.g. read-only mounts of
+	// originals): the pre-deletion validation refuses any file under them
+	// with PROTECTED_PATH, and the suggested keeper is picked among them.
+	ProtectedDirs []string `yaml:"protected_dirs" json:"protected_dirs"`
+
+	// SymlinkDelete decides what deleting a duplicate that has been replaced
+	// by a symlink since the scan does: "refuse" (default) fails validation,
+	// "move_link" moves the link itself. The target is never followed.
+	SymlinkDelete string `yaml:"symlink_delete" json:"symlink_delete"`
+
+	// RestatBeforeTrash re-stats each file right before it is moved to the
+	// trash or archive, and skips the file if its size or mtime changed
+	// since the pre-deletion validation. Off by default.
+	RestatBeforeTrash bool `yaml:"restat_before_trash" json:"restat_before_trash"`
+
+	// path is the config file Load read; sources records the fields set by
+	// that file or by the environment (see Sources).
+	path    string
+	sources map[string]Source
+
+	// mu guards runtime updates; see Lock.
+	mu sync.Mutex
 }
 
+// Lock acquires the lock every holder of c shares to read or update fields
+// that change at runtime (PATCH /api/config, dir-type ignores).
+func (c *Config) Lock() { c.mu.Lock() }
+
+// Unlock releases the lock taken by Lock.
+func (c *Config) Unlock() { c.mu.Unlock() }
+
 // ScanWorkers holds concurrency knobs for the scan pipeline.
 type ScanWorkers struct {
 	Walkers        int `yaml:"walkers"         json:"walkers"`
 	CacheCheckers  int `yaml:"cache_checkers"  json:"cache_checkers"`
 	PartialHashers int `yaml:"partial_hashers" json:"partial_hashers"`
 	FullHashers    int `yaml:"full_hashers"    json:"full_hashers"`
+	// MaxOpenFiles bounds files open at once across all hashers
+	// (0 = derive from the soft RLIMIT_NOFILE).
+	MaxOpenFiles int `yaml:"max_open_files" json:"max_open_files"`
 }
 
 // applyDefaults fills zero/empty fields with sensible defaults.
@@ -48,96 +244,253 @@ func (c *Config) applyDefaults() {
 	if c.HTTPAddr == "" {
 		c.HTTPAddr = ":8080"
 	}
+	if c.KeeperHeuristic == "" {
+		c.KeeperHeuristic = "oldest"
+	}
+	if c.SymlinkDelete == "" {
+		c.SymlinkDelete = "refuse"
+	}
+	if c.Theme == "" {
+		c.Theme = "light"
+	}
+	if c.GroupDownloadMaxBytes == 0 {
+		c.GroupDownloadMaxBytes = 1 << 30
+	}
+	if c.DashboardTrendDays == 0 {
+		c.DashboardTrendDays = 90
+	}
+	if c.StatusPollSeconds == 0 {
+		c.StatusPollSeconds = 3
+	}
+	if c.StatusPollIdleSeconds == 0 {
+		c.StatusPollIdleSeconds = 15
+	}
+	if c.ActorName == "" {
+		c.ActorName = "user"
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = 30
+	}
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = 30
+	}
 	if c.ScanWorkers.Walkers == 0 {
 		c.ScanWorkers.Walkers = 4
 	}
 	if c.ScanWorkers.CacheCheckers == 0 {
 		c.ScanWorkers.CacheCheckers = 4
 	}
+	if c.DBReadConns == 0 {
+		c.DBReadConns = c.ScanWorkers.CacheCheckers + 2
+	}
 	if c.ScanWorkers.PartialHashers == 0 {
 		c.ScanWorkers.PartialHashers = 4
 	}
 	if c.ScanWorkers.FullHashers == 0 {
 		c.ScanWorkers.FullHashers = 2
 	}
+	if c.PartialHashAlgo == "" {
+		c.PartialHashAlgo = "sha256"
+	}
 	if c.LogLevel == "" {
 		c.LogLevel = "info"
 	}
+	if c.AccessLogSkip == nil {
+		c.AccessLogSkip = []string{
+			"/ui/scan-status",
+			"/api/groups/*/thumbnail",
+			"/api/files/*/thumbnail",
+			"/static/*",
+		}
+	}
 }
 
-// Load reads and parses the YAML config file at path.
-// If the file does not exist, Load returns a default Config so the server
-// can start without a mounted config file (useful for bare Docker runs).
+// Load reads and parses the YAML config file at path, then applies DITTO_*
+// environment overrides (see EnvPrefix) and finally defaults for anything
+// still unset. If the file does not exist, Load starts from an empty Config so
+// the server can run without a mounted config file (useful for bare Docker
+// runs). Settings saved from the UI are layered on top later by
+// MergeDBSettings, so the effective precedence is
+// DB settings > environment > file > defaults.
 func Load(path string) (*Config, error) {
-	f, err := os.Open(path)
-	if os.IsNotExist(err) {
-		var cfg Config
-		cfg.applyDefaults()
-		return &cfg, nil
-	}
-	if err != nil {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
 		return nil, fmt.Errorf("open config %q: %w", path, err)
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parse config %q: %w", path, err)
+		}
+		cfg.recordFileKeys(data)
 	}
-	defer f.Close()
-
-	var cfg Config
-	dec := yaml.NewDecoder(f)
-	dec.KnownFields(true)
-	if err := dec.Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("parse config %q: %w", path, err)
+	cfg.path = path
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
 	}
 	cfg.applyDefaults()
+	if cfg.ScanIfStaleHours < 0 {
+		return nil, fmt.Errorf("parse config %q: scan_if_stale_hours must be >= 0", path)
+	}
+	if cfg.MinReclaimableBytes < 0 {
+		return nil, fmt.Errorf("parse config %q: min_reclaimable_bytes must be >= 0", path)
+	}
+	if cfg.DBReadConns < 0 {
+		return nil, fmt.Errorf("parse config %q: db_read_conns must be >= 0", path)
+	}
+	if cfg.SkipRecentlyModifiedSeconds < 0 {
+		return nil, fmt.Errorf("parse config %q: skip_recently_modified_seconds must be >= 0", path)
+	}
+	if cfg.SizeTolerancePercent < 0 || cfg.SizeTolerancePercent > 50 {
+		return nil, fmt.Errorf("parse config %q: size_tolerance_percent must be between 0 and 50", path)
+	}
+	switch cfg.KeeperHeuristic {
+	case "oldest", "shortest_path", "preferred_dir":
+	default:
+		return nil, fmt.Errorf("parse config %q: keeper_heuristic must be \"oldest\", \"shortest_path\" or \"preferred_dir\", got %q", path, cfg.KeeperHeuristic)
+	}
+	if cfg.SymlinkDelete != "refuse" && cfg.SymlinkDelete != "move_link" {
+		return nil, fmt.Errorf("parse config %q: symlink_delete must be \"refuse\" or \"move_link\", got %q", path, cfg.SymlinkDelete)
+	}
+	if cfg.Theme != "light" && cfg.Theme != "dark" {
+		return nil, fmt.Errorf("parse config %q: theme must be \"light\" or \"dark\", got %q", path, cfg.Theme)
+	}
+	if !ValidGroupType(cfg.DefaultGroupType) {
+		return nil, fmt.Errorf("parse config %q: default_group_type must be \"image\", \"video\", \"document\", \"other\" or empty, got %q", path, cfg.DefaultGroupType)
+	}
+	if cfg.StatusPollSeconds < 1 || cfg.StatusPollIdleSeconds < 1 {
+		return nil, fmt.Errorf("parse config %q: status_poll_seconds and status_poll_idle_seconds must be at least 1", path)
+	}
+	if cfg.ScanCPUPercent < 0 || cfg.ScanCPUPercent > 100 {
+		return nil, fmt.Errorf("parse config %q: scan_cpu_percent must be between 0 and 100", path)
+	}
+	if cfg.CandidateHeadBytes < 0 || cfg.CandidateHeadBytes > 65536 {
+		return nil, fmt.Errorf("parse config %q: candidate_head_bytes must be between 0 and 65536", path)
+	}
+	if cfg.PartialHashSkipAboveBytes < 0 {
+		return nil, fmt.Errorf("parse config %q: partial_hash_skip_above_bytes must be >= 0", path)
+	}
+	if cfg.HashQuarantineAfter < 0 {
+		return nil, fmt.Errorf("parse config %q: hash_quarantine_after must be >= 0", path)
+	}
+	if cfg.ApproximateSampleBytes < 0 {
+		return nil, fmt.Errorf("parse config %q: approximate_sample_bytes must be >= 0", path)
+	}
+	if cfg.TrashMinFreeBytes < 0 {
+		return nil, fmt.Errorf("parse config %q: trash_min_free_bytes must be >= 0", path)
+	}
+	if cfg.MaxPreviewBytes < 0 {
+		return nil, fmt.Errorf("parse config %q: max_preview_bytes must be >= 0", path)
+	}
+	if cfg.PartialHashAlgo != "sha256" && cfg.PartialHashAlgo != "xxhash" {
+		return nil, fmt.Errorf("parse config %q: partial_hash_algo must be \"sha256\" or \"xxhash\", got %q", path, cfg.PartialHashAlgo)
+	}
 	return &cfg, nil
 }
 
 // MergeDBSettings overlays settings stored in the DB on top of the config.
 // Keys recognised: "scan_paths", "exclude_paths", "schedule", "scan_paused",
-// "trash_retention_days", "walkers", "partial_hashers", "full_hashers".
+// "trash_retention_days", "walkers", "cache_checkers", "partial_hashers",
+// "full_hashers", "theme", "status_poll_seconds", "status_poll_idle_seconds",
+// "default_group_type" (where "all" stands for no default).
 // Unknown keys and parse errors are silently ignored.
 func MergeDBSettings(cfg *Config, settings map[string]string) {
+	mergeDBSettings(cfg, settings)
+}
+
+// mergeDBSettings implements MergeDBSettings and returns the YAML keys of
+// the fields it set.
+func mergeDBSettings(cfg *Config, settings map[string]string) []string {
+	var merged []string
 	if v, ok := settings["scan_paths"]; ok && v != "" {
 		var paths []string
 		if err := json.Unmarshal([]byte(v), &paths); err == nil {
 			cfg.ScanPaths = paths
+			merged = append(merged, "scan_paths")
 		}
 	}
 	if v, ok := settings["exclude_paths"]; ok && v != "" {
 		var paths []string
 		if err := json.Unmarshal([]byte(v), &paths); err == nil {
 			cfg.ExcludePaths = paths
+			merged = append(merged, "exclude_paths")
 		}
 	}
 	if v, ok := settings["schedule"]; ok && v != "" {
 		cfg.Schedule = v
+		merged = append(merged, "schedule")
 	}
 	if v, ok := settings["scan_paused"]; ok && v != "" {
 		if b, err := strconv.ParseBool(v); err == nil {
 			cfg.ScanPaused = b
+			merged = append(merged, "scan_paused")
 		}
 	}
 	if v, ok := settings["trash_retention_days"]; ok && v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			cfg.TrashRetentionDays = n
+			merged = append(merged, "trash_retention_days")
 		}
 	}
 	if v, ok := settings["walkers"]; ok && v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			cfg.ScanWorkers.Walkers = n
+			merged = append(merged, "scan_workers.walkers")
 		}
 	}
 	if v, ok := settings["cache_checkers"]; ok && v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			cfg.ScanWorkers.CacheCheckers = n
+			merged = append(merged, "scan_workers.cache_checkers")
 		}
 	}
 	if v, ok := settings["partial_hashers"]; ok && v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			cfg.ScanWorkers.PartialHashers = n
+			merged = append(merged, "scan_workers.partial_hashers")
 		}
 	}
 	if v, ok := settings["full_hashers"]; ok && v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			cfg.ScanWorkers.FullHashers = n
+			merged = append(merged, "scan_workers.full_hashers")
 		}
 	}
+	if v, ok := settings["theme"]; ok && (v == "light" || v == "dark") {
+		cfg.Theme = v
+		merged = append(merged, "theme")
+	}
+	if v, ok := settings["status_poll_seconds"]; ok && v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 1 {
+			cfg.StatusPollSeconds = n
+			merged = append(merged, "status_poll_seconds")
+		}
+	}
+	if v, ok := settings["status_poll_idle_seconds"]; ok && v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 1 {
+			cfg.StatusPollIdleSeconds = n
+			merged = append(merged, "status_poll_idle_seconds")
+		}
+	}
+	if v, ok := settings["default_group_type"]; ok && (v == "all" || (v != "" && ValidGroupType(v))) {
+		if v == "all" {
+			v = ""
+		}
+		cfg.DefaultGroupType = v
+		merged = append(merged, "default_group_type")
+	}
+	return merged
+}
+
+// ValidGroupType reports whether t is a file type groups can be filtered
+// on, or "" for none.
+func ValidGroupType(t string) bool {
+	switch t {
+	case "", "image", "video", "document", "other":
+		return true
+	}
+	return false
 }
@@ -29,6 +29,18 @@ func TestLoad_DefaultsApplied(t *testing.T) {
 	if cfg.HTTPAddr == "" {
 		t.Error("expected default http_addr to be set")
 	}
+	if cfg.ActorName != "user" {
+		t.Errorf("ActorName = %q, want default \"user\"", cfg.ActorName)
+	}
+	if cfg.SymlinkDelete != "refuse" {
+		t.Errorf("SymlinkDelete = %q, want default \"refuse\"", cfg.SymlinkDelete)
+	}
+	if cfg.DBReadConns != cfg.ScanWorkers.CacheCheckers+2 {
+		t.Errorf("DBReadConns = %d, want cache_checkers + 2", cfg.DBReadConns)
+	}
+	if cfg.StatusPollSeconds != 3 || cfg.StatusPollIdleSeconds != 15 {
+		t.Errorf("status poll = %d/%d s, want defaults 3/15", cfg.StatusPollSeconds, cfg.StatusPollIdleSeconds)
+	}
 }
 
 func TestLoad_MissingFile(t *testing.T) {
@@ -45,3 +57,163 @@ func TestLoad_MissingFile(t *testing.T) {
 		t.Error("expected default schedule to be set")
 	}
 }
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	f, err := os.CreateTemp("", "ditto-config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("scan_paths:\n  - /from/file\nhttp_addr: \":9000\"\nscan_workers:\n  walkers: 3\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	t.Setenv("DITTO_SCAN_PATHS", "/a, /b ,")
+	t.Setenv("DITTO_HTTP_ADDR", ":7000")
+	t.Setenv("DITTO_SCAN_WORKERS_FULL_HASHERS", "6")
+	t.Setenv("DITTO_SCAN_PAUSED", "true")
+
+	cfg, err := config.Load(f.Name())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.ScanPaths) != 2 || cfg.ScanPaths[0] != "/a" || cfg.ScanPaths[1] != "/b" {
+		t.Errorf("ScanPaths = %q, want [/a /b]", cfg.ScanPaths)
+	}
+	if cfg.HTTPAddr != ":7000" {
+		t.Errorf("HTTPAddr = %q, want :7000 (env beats file)", cfg.HTTPAddr)
+	}
+	if cfg.ScanWorkers.Walkers != 3 {
+		t.Errorf("Walkers = %d, want 3 (file value kept)", cfg.ScanWorkers.Walkers)
+	}
+	if cfg.ScanWorkers.FullHashers != 6 {
+		t.Errorf("FullHashers = %d, want 6", cfg.ScanWorkers.FullHashers)
+	}
+	if !cfg.ScanPaused {
+		t.Error("expected ScanPaused from env")
+	}
+	if cfg.Schedule == "" {
+		t.Error("expected default schedule to be set")
+	}
+}
+
+func TestLoad_EnvWithoutFile(t *testing.T) {
+	t.Setenv("DITTO_DB_PATH", "/srv/ditto.db")
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DBPath != "/srv/ditto.db" {
+		t.Errorf("DBPath = %q, want /srv/ditto.db", cfg.DBPath)
+	}
+}
+
+func TestLoad_EnvInvalid(t *testing.T) {
+	t.Setenv("DITTO_TRASH_RETENTION_DAYS", "soon")
+	if _, err := config.Load("/nonexistent/path/config.yaml"); err == nil {
+		t.Fatal("expected an error for a non-numeric DITTO_TRASH_RETENTION_DAYS")
+	}
+
+	t.Setenv("DITTO_TRASH_RETENTION_DAYS", "")
+	t.Setenv("DITTO_PARTIAL_HASH_ALGO", "md5")
+	if _, err := config.Load("/nonexistent/path/config.yaml"); err == nil {
+		t.Fatal("expected env values to be validated like file values")
+	}
+}
+
+func TestMergeDBSettings_BeatsEnv(t *testing.T) {
+	t.Setenv("DITTO_SCHEDULE", "0 3 * * *")
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	config.MergeDBSettings(cfg, map[string]string{"schedule": "0 4 * * *"})
+	if cfg.Schedule != "0 4 * * *" {
+		t.Errorf("Schedule = %q, want the DB setting", cfg.Schedule)
+	}
+}
+
+func TestMergeDBSettings_StatusPoll(t *testing.T) {
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	config.MergeDBSettings(cfg, map[string]string{
+		"status_poll_seconds":      "1",
+		"status_poll_idle_seconds": "0", // invalid: keeps the default
+	})
+	if cfg.StatusPollSeconds != 1 {
+		t.Errorf("StatusPollSeconds = %d, want the DB setting 1", cfg.StatusPollSeconds)
+	}
+	if cfg.StatusPollIdleSeconds != 15 {
+		t.Errorf("StatusPollIdleSeconds = %d, want the default 15", cfg.StatusPollIdleSeconds)
+	}
+}
+
+func TestMergeDBSettings_DefaultGroupType(t *testing.T) {
+	cfg, err := config.Load("/nonexistent/path/config.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	config.MergeDBSettings(cfg, map[string]string{"default_group_type": "image"})
+	if cfg.DefaultGroupType != "image" {
+		t.Errorf("DefaultGroupType = %q, want the DB setting image", cfg.DefaultGroupType)
+	}
+	config.MergeDBSettings(cfg, map[string]string{"default_group_type": "bogus"}) // invalid: ignored
+	if cfg.DefaultGroupType != "image" {
+		t.Errorf("DefaultGroupType = %q after an invalid setting, want image kept", cfg.DefaultGroupType)
+	}
+	config.MergeDBSettings(cfg, map[string]string{"default_group_type": "all"})
+	if cfg.DefaultGroupType != "" {
+		t.Errorf("DefaultGroupType = %q, want \"all\" to clear it", cfg.DefaultGroupType)
+	}
+}
+
+func TestSources(t *testing.T) {
+	f, err := os.CreateTemp("", "ditto-config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("schedule: \"0 1 * * *\"\nhttp_addr: \":9000\"\nscan_workers:\n  walkers: 3\n  full_hashers: 2\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	t.Setenv("DITTO_HTTP_ADDR", ":9100")
+
+	cfg, err := config.Load(f.Name())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	settings := map[string]string{"schedule": "0 4 * * *", "walkers": "bogus"}
+	config.MergeDBSettings(cfg, settings)
+
+	got := map[string]config.FieldSource{}
+	for _, fs := range cfg.Sources(settings) {
+		got[fs.Key] = fs
+	}
+	for key, want := range map[string]config.Source{
+		"schedule":                    config.SourceDB,
+		"http_addr":                   config.SourceEnv,
+		"scan_workers.walkers":        config.SourceFile, // unparsable DB value is ignored
+		"scan_workers.full_hashers":   config.SourceFile,
+		"scan_workers.cache_checkers": config.SourceDefault,
+		"db_path":                     config.SourceDefault,
+	} {
+		if got[key].Source != want {
+			t.Errorf("%s: source %q, want %q", key, got[key].Source, want)
+		}
+	}
+	if v := got["schedule"].Value; v != "0 4 * * *" {
+		t.Errorf("schedule value = %v, want the DB setting", v)
+	}
+	if v := got["http_addr"].Value; v != ":9100" {
+		t.Errorf("http_addr value = %v, want the env value", v)
+	}
+	if cfg.Path() != f.Name() {
+		t.Errorf("Path() = %q, want %q", cfg.Path(), f.Name())
+	}
+}
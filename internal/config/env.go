@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to the upper-cased YAML key to form the name of the
+// environment variable overriding it: http_addr → DITTO_HTTP_ADDR. Nested keys
+// join with an underscore: scan_workers.walkers → DITTO_SCAN_WORKERS_WALKERS.
+const EnvPrefix = "DITTO_"
+
+// applyEnv overrides cfg fields from DITTO_* environment variables, looked up
+// with lookup (os.LookupEnv outside tests). Slice fields take a comma-separated
+// list. A variable that is set but cannot be parsed is an error; an empty one
+// resets the field so the default applies. Fields set this way are recorded
+// as coming from the environment.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), EnvPrefix, "", lookup, func(key string) {
+		cfg.setSource(key, SourceEnv)
+	})
+}
+
+// applyEnvStruct applies the variables for the fields of struct v; keyPrefix
+// is the dotted YAML path of v, passed to set for each field overridden.
+func applyEnvStruct(v reflect.Value, prefix, keyPrefix string, lookup func(string) (string, bool), set func(key string)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(key)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvStruct(field, name+"_", keyPrefix+key+".", lookup, set); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		raw = strings.TrimSpace(raw)
+		set(keyPrefix + key)
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Bool:
+			if raw == "" {
+				field.SetBool(false)
+				continue
+			}
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("env %s: %w", name, err)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			if raw == "" {
+				field.SetInt(0)
+				continue
+			}
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("env %s: %w", name, err)
+			}
+			field.SetInt(n)
+		case reflect.Float64:
+			if raw == "" {
+				field.SetFloat(0)
+				continue
+			}
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("env %s: %w", name, err)
+			}
+			field.SetFloat(f)
+		case reflect.Slice:
+			var items []string
+			for _, s := range strings.Split(raw, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					items = append(items, s)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("env %s: unsupported field type %s", name, field.Type())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source is where the effective value of a config field came from.
+type Source string
+
+const (
+	SourceDefault Source = "default" // not set anywhere: built-in default or zero value
+	SourceFile    Source = "file"    // config.yaml
+	SourceEnv     Source = "env"     // a DITTO_* environment variable
+	SourceDB      Source = "db"      // the settings table (saved from the UI or PATCH /api/config)
+)
+
+// FieldSource is the effective value of one config field and its origin.
+type FieldSource struct {
+	// Key is the field's YAML key; nested keys are joined with a dot
+	// (scan_workers.walkers).
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source Source `json:"source"`
+}
+
+// Path returns the config file path given to Load ("" for a Config not
+// built by Load).
+func (c *Config) Path() string { return c.path }
+
+// Sources lists every config field, in declaration order, with its effective
+// value and where that value came from, following the precedence
+// DB settings > environment > file > defaults. dbSettings is the current
+// content of the settings table: fields MergeDBSettings takes from it are
+// reported as "db", which includes changes saved since startup.
+func (c *Config) Sources(dbSettings map[string]string) []FieldSource {
+	fromDB := make(map[string]bool)
+	for _, key := range mergeDBSettings(&Config{}, dbSettings) {
+		fromDB[key] = true
+	}
+	var fields []FieldSource
+	var walk func(v reflect.Value, keyPrefix string)
+	walk = func(v reflect.Value, keyPrefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if key == "" || key == "-" {
+				continue
+			}
+			key = keyPrefix + key
+			if v.Field(i).Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			src := SourceDefault
+			switch {
+			case fromDB[key]:
+				src = SourceDB
+			case c.sources[key] != "":
+				src = c.sources[key]
+			}
+			fields = append(fields, FieldSource{Key: key, Value: v.Field(i).Interface(), Source: src})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return fields
+}
+
+// setSource records that the field at key was set from src, overriding an
+// earlier record (the environment beats the file).
+func (c *Config) setSource(key string, src Source) {
+	if c.sources == nil {
+		c.sources = make(map[string]Source)
+	}
+	c.sources[key] = src
+}
+
+// recordFileKeys records the keys present in the config file data as set
+// from the file. Nested mappings (scan_workers) record their own keys.
+func (c *Config) recordFileKeys(data []byte) {
+	var raw map[string]any
+	if yaml.Unmarshal(data, &raw) != nil {
+		return
+	}
+	for key, v := range raw {
+		if nested, ok := v.(map[string]any); ok {
+			for sub := range nested {
+				c.setSource(key+"."+sub, SourceFile)
+			}
+			continue
+		}
+		c.setSource(key, SourceFile)
+	}
+}
@@ -1,8 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -98,6 +100,29 @@ func SaveSetting(db *sql.DB, key, value string) error {
 	return nil
 }
 
+// RecordAudit appends an entry to audit_log for an action taken on a group.
+// detail is marshalled to JSON; an empty actor is recorded as "user".
+func RecordAudit(ctx context.Context, db *sql.DB, groupID int64, action, actor string, detail any) error {
+	if actor == "" {
+		actor = "user"
+	}
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("marshal audit detail: %w", err)
+	}
+	if detail == nil {
+		b = []byte("{}")
+	}
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO audit_log(group_id, action, actor, detail, created_at) VALUES(?, ?, ?, ?, ?)",
+		groupID, action, actor, string(b), time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("record audit %q for group %d: %w", action, groupID, err)
+	}
+	return nil
+}
+
 // RunMigrations applies all pending goose migrations from the embedded FS.
 func RunMigrations(db *sql.DB) error {
 	goose.SetBaseFS(migrationsFS)
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned by AcquireLock when another process holds the lock.
+var ErrLocked = errors.New("database is in use by another ditto instance")
+
+// Lock is an advisory, process-wide lock on a database path. It guards
+// against two servers sharing one ditto.db: SetMaxOpenConns(1) only
+// serialises writers within a single process.
+type Lock struct {
+	f *os.File
+}
+
+// LockPath returns the lockfile location for the database at dbPath.
+func LockPath(dbPath string) string { return dbPath + ".lock" }
+
+// AcquireLock takes an exclusive lock on the lockfile next to dbPath and
+// records the current PID in it. It fails fast (without waiting) with an error
+// wrapping ErrLocked if another process already holds the lock. The OS drops
+// the lock automatically when the process exits, so a crash never leaves a
+// stale lock behind.
+func AcquireLock(dbPath string) (*Lock, error) {
+	path := LockPath(dbPath)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lockfile %q: %w", path, err)
+	}
+	if err := lockFile(f); err != nil {
+		holder := readLockPID(f)
+		f.Close()
+		if errors.Is(err, errWouldBlock) {
+			if holder != "" {
+				return nil, fmt.Errorf("%w (pid %s, lockfile %s)", ErrLocked, holder, path)
+			}
+			return nil, fmt.Errorf("%w (lockfile %s)", ErrLocked, path)
+		}
+		return nil, fmt.Errorf("lock %q: %w", path, err)
+	}
+
+	// Best-effort: the PID is only informational.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release unlocks and closes the lockfile. The file itself is left in place;
+// removing it would race with another process that has just opened it.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	unlockFile(l.f)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// readLockPID returns the PID recorded in the lockfile, or "" if unreadable.
+func readLockPID(f *os.File) string {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	return strings.TrimSpace(string(buf[:n]))
+}
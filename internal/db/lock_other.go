@@ -0,0 +1,16 @@
+//go:build !unix
+
+package db
+
+import (
+	"errors"
+	"os"
+)
+
+var errWouldBlock = errors.New("lock held")
+
+// lockFile is a no-op on platforms without flock; the lockfile still records
+// the PID but concurrent instances are not detected.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) {}
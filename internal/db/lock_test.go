@@ -0,0 +1,46 @@
+//go:build unix
+
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestAcquireLock verifies that a second AcquireLock on the same database
+// fails with ErrLocked naming the holder's PID, and that Release lets the
+// lock be taken again.
+func TestAcquireLock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ditto.db")
+
+	first, err := AcquireLock(dbPath)
+	if err != nil {
+		t.Fatalf("first AcquireLock: %v", err)
+	}
+
+	second, err := AcquireLock(dbPath)
+	if !errors.Is(err, ErrLocked) {
+		second.Release()
+		t.Fatalf("second AcquireLock: got %v, want ErrLocked", err)
+	}
+	if pid := "pid " + strconv.Itoa(os.Getpid()); !strings.Contains(err.Error(), pid) {
+		t.Errorf("error %q does not name the holder (%s)", err, pid)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Errorf("second Release: %v", err)
+	}
+
+	again, err := AcquireLock(dbPath)
+	if err != nil {
+		t.Fatalf("AcquireLock after Release: %v", err)
+	}
+	again.Release()
+}
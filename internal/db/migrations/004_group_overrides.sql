@@ -0,0 +1,11 @@
+-- +goose Up
+-- User overrides keyed by content hash so they survive rescans, which
+-- re-create duplicate_groups rows from scratch.
+CREATE TABLE IF NOT EXISTS group_overrides (
+    content_hash TEXT    PRIMARY KEY,
+    file_type    TEXT    CHECK (file_type IS NULL OR file_type IN ('image','video','document','other')),
+    updated_at   INTEGER NOT NULL
+) STRICT;
+
+-- +goose Down
+DROP TABLE IF EXISTS group_overrides;
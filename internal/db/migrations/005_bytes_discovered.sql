@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE scan_history ADD COLUMN bytes_discovered INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
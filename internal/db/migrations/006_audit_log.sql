@@ -0,0 +1,17 @@
+-- +goose Up
+-- Who did what to a duplicate group, and when. group_id is not a foreign key
+-- so entries outlive the group row.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id    INTEGER NOT NULL,
+    action      TEXT    NOT NULL,
+    actor       TEXT    NOT NULL DEFAULT 'user',
+    detail      TEXT    NOT NULL DEFAULT '{}',
+    created_at  INTEGER NOT NULL
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_group
+    ON audit_log (group_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
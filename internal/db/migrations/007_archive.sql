@@ -0,0 +1,20 @@
+-- +goose Up
+-- Files moved to the archive tree instead of the trash. Kept indefinitely:
+-- unlike trash rows these have no expiry and are never purged.
+CREATE TABLE IF NOT EXISTS archive (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id        INTEGER,
+    original_path   TEXT    NOT NULL,
+    archive_path    TEXT    NOT NULL UNIQUE,
+    file_size       INTEGER NOT NULL,
+    content_hash    TEXT    NOT NULL,
+    archived_at     INTEGER NOT NULL,
+
+    FOREIGN KEY (group_id) REFERENCES duplicate_groups(id) ON DELETE SET NULL
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_archive_original_path
+    ON archive (original_path);
+
+-- +goose Down
+DROP TABLE IF EXISTS archive;
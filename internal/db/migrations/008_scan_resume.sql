@@ -0,0 +1,6 @@
+-- +goose Up
+-- Links a scan started with {"resume": true} to the interrupted scan it resumes.
+ALTER TABLE scan_history ADD COLUMN resumed_from_scan_id INTEGER;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
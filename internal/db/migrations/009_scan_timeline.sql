@@ -0,0 +1,21 @@
+-- +goose Up
+-- Periodic progress samples appended by the progress reporter, so a scan's
+-- throughput can be plotted after the fact (GET /api/scans/:id/timeline).
+CREATE TABLE IF NOT EXISTS scan_progress_samples (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    scan_id             INTEGER NOT NULL,
+    t                   INTEGER NOT NULL,
+    files_discovered    INTEGER NOT NULL DEFAULT 0,
+    full_hashed         INTEGER NOT NULL DEFAULT 0,
+    bytes_read          INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (scan_id) REFERENCES scan_history(id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_scan_progress_samples_scan
+    ON scan_progress_samples (scan_id, t);
+
+-- Unix time the directory walk completed (0 = not yet / cancelled first).
+ALTER TABLE scan_history ADD COLUMN walk_finished_at INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+DROP TABLE IF EXISTS scan_progress_samples;
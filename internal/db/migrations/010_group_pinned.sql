@@ -0,0 +1,8 @@
+-- +goose Up
+-- Pinned groups are left to the user: bulk operations (e.g. POST
+-- /api/groups/resolve) skip them. Keyed by content hash like file_type so the
+-- flag survives rescans.
+ALTER TABLE group_overrides ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Idempotency-Key values sent with POST /api/scans, so a retried request
+-- gets the scan the first one created instead of a conflict. Rows older than
+-- the replay window are pruned when a new key is recorded.
+CREATE TABLE IF NOT EXISTS scan_idempotency_keys (
+    key         TEXT    PRIMARY KEY,
+    scan_id     INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL
+) STRICT;
+
+-- +goose Down
+DROP TABLE IF EXISTS scan_idempotency_keys;
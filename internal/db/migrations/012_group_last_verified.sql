@@ -0,0 +1,12 @@
+-- +goose Up
+-- When the group's files were last confirmed against disk: by a scan that saw
+-- them, or by the pre-deletion check. Unlike updated_at it is not touched by
+-- status edits. Existing groups take the start of the scan that last saw them.
+ALTER TABLE duplicate_groups ADD COLUMN last_verified_at INTEGER;
+
+UPDATE duplicate_groups
+SET last_verified_at = (SELECT s.started_at FROM scan_history s
+                        WHERE s.id = duplicate_groups.last_seen_scan_id);
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
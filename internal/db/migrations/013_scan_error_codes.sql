@@ -0,0 +1,16 @@
+-- +goose Up
+-- Error kind (PERMISSION, NOT_FOUND, TIMEOUT, DECODE, IO) so scan errors can be
+-- counted by cause. Existing rows are classified from their message text.
+ALTER TABLE scan_errors ADD COLUMN code TEXT NOT NULL DEFAULT 'IO'
+    CHECK (code IN ('PERMISSION','NOT_FOUND','TIMEOUT','DECODE','IO'));
+
+UPDATE scan_errors SET code = CASE
+    WHEN error LIKE '%permission denied%' OR error LIKE '%operation not permitted%' THEN 'PERMISSION'
+    WHEN error LIKE '%no such file or directory%' OR error LIKE '%file does not exist%' THEN 'NOT_FOUND'
+    WHEN error LIKE '%i/o timeout%' OR error LIKE '%deadline exceeded%' OR error LIKE '%timed out%' THEN 'TIMEOUT'
+    WHEN error LIKE '%decode%' OR error LIKE '%malformed%' THEN 'DECODE'
+    ELSE 'IO'
+END;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Full hashes of files that ended up in no duplicate group, written only
+-- with the file_inventory option. Each completed scan replaces the table's
+-- contents, so it answers "is this content anywhere?" for unique files too.
+CREATE TABLE IF NOT EXISTS file_inventory (
+    path         TEXT    NOT NULL PRIMARY KEY,
+    content_hash TEXT    NOT NULL,
+    size         INTEGER NOT NULL,
+    mtime        INTEGER NOT NULL,
+    scan_id      INTEGER NOT NULL,
+
+    FOREIGN KEY (scan_id) REFERENCES scan_history(id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_file_inventory_content_hash
+    ON file_inventory (content_hash);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_inventory;
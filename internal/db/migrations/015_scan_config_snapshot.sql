@@ -0,0 +1,7 @@
+-- +goose Up
+-- JSON snapshot of the roots, excludes and pipeline settings a scan ran
+-- with, so old scans can be compared. NULL for scans recorded before this.
+ALTER TABLE scan_history ADD COLUMN config_snapshot TEXT;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
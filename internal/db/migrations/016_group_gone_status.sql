@@ -0,0 +1,57 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- New group status 'gone': every file of the group disappeared from disk
+-- outside ditto. SQLite cannot alter a CHECK constraint, so the table is
+-- rebuilt. Foreign keys are switched off for the swap (it cannot be done
+-- inside a transaction) so dropping the old table does not cascade to
+-- duplicate_files, trash or archive.
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE duplicate_groups_new (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_hash        TEXT    NOT NULL UNIQUE,
+    file_size           INTEGER NOT NULL,
+    file_count          INTEGER NOT NULL DEFAULT 0,
+    reclaimable_bytes   INTEGER NOT NULL DEFAULT 0,
+    file_type           TEXT    NOT NULL DEFAULT 'other'
+                            CHECK (file_type IN ('image','video','document','other')),
+    status              TEXT    NOT NULL DEFAULT 'unresolved'
+                            CHECK (status IN ('unresolved','ignored','resolved','watching','watching_alert','gone')),
+    ignored_at          INTEGER,
+    resolved_at         INTEGER,
+    first_seen_scan_id  INTEGER,
+    last_seen_scan_id   INTEGER,
+    created_at          INTEGER NOT NULL,
+    updated_at          INTEGER NOT NULL,
+    last_verified_at    INTEGER,
+
+    FOREIGN KEY (first_seen_scan_id) REFERENCES scan_history(id) ON DELETE SET NULL,
+    FOREIGN KEY (last_seen_scan_id)  REFERENCES scan_history(id) ON DELETE SET NULL
+) STRICT;
+
+INSERT INTO duplicate_groups_new
+    (id, content_hash, file_size, file_count, reclaimable_bytes, file_type,
+     status, ignored_at, resolved_at, first_seen_scan_id, last_seen_scan_id,
+     created_at, updated_at, last_verified_at)
+SELECT id, content_hash, file_size, file_count, reclaimable_bytes, file_type,
+       status, ignored_at, resolved_at, first_seen_scan_id, last_seen_scan_id,
+       created_at, updated_at, last_verified_at
+FROM duplicate_groups;
+
+DROP TABLE duplicate_groups;
+ALTER TABLE duplicate_groups_new RENAME TO duplicate_groups;
+
+CREATE INDEX IF NOT EXISTS idx_groups_filter_sort
+    ON duplicate_groups (status, file_type, reclaimable_bytes DESC);
+
+CREATE INDEX IF NOT EXISTS idx_groups_content_hash
+    ON duplicate_groups (content_hash);
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+SELECT 1; -- Keep the wider CHECK: 'gone' rows would violate the old one.
@@ -0,0 +1,33 @@
+-- +goose Up
+-- added_by records who added an ignore rule (actor_name / actor_header), so
+-- it is no longer limited to 'user' and 'config'. SQLite cannot drop a CHECK
+-- constraint, so the table is rebuilt; nothing references it.
+CREATE TABLE whitelist_new (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    type          TEXT    NOT NULL
+                      CHECK (type IN ('hash','path_pair','dir')),
+    value         TEXT    NOT NULL,
+    added_by      TEXT    NOT NULL DEFAULT 'user',
+    added_at      INTEGER NOT NULL,
+    note          TEXT,
+    expected_hash TEXT,
+
+    UNIQUE (type, value)
+) STRICT;
+
+INSERT INTO whitelist_new (id, type, value, added_by, added_at, note, expected_hash)
+SELECT id, type, value, added_by, added_at, note, expected_hash FROM whitelist;
+
+DROP TABLE whitelist;
+ALTER TABLE whitelist_new RENAME TO whitelist;
+
+CREATE INDEX IF NOT EXISTS idx_whitelist_hash
+    ON whitelist (value)
+    WHERE type = 'hash';
+
+CREATE INDEX IF NOT EXISTS idx_whitelist_dir
+    ON whitelist (value)
+    WHERE type = 'dir';
+
+-- +goose Down
+SELECT 1; -- Keep the relaxed column: other actors would violate the old CHECK.
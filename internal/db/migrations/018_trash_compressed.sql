@@ -0,0 +1,7 @@
+-- +goose Up
+-- 1 when the trashed file was gzipped on its way into the trash
+-- (compress_trash); trash_path then names the .gz file.
+ALTER TABLE trash ADD COLUMN compressed INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
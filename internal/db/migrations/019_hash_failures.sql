@@ -0,0 +1,24 @@
+-- +goose Up
+-- Per-path hashing failures across scans, kept with the hash_quarantine_after
+-- option. failures counts consecutive scans in which the path failed to hash;
+-- a completed scan in which it did not fail drops the row. Once failures
+-- reaches the threshold quarantined_at is set and scans skip the path until
+-- the row is cleared through the API.
+CREATE TABLE IF NOT EXISTS hash_failures (
+    path            TEXT    NOT NULL PRIMARY KEY,
+    stage           TEXT    NOT NULL
+                        CHECK (stage IN ('partial_hash','full_hash')),
+    code            TEXT    NOT NULL,
+    error           TEXT    NOT NULL,
+    failures        INTEGER NOT NULL,
+    first_failed_at INTEGER NOT NULL,
+    last_failed_at  INTEGER NOT NULL,
+    last_scan_id    INTEGER NOT NULL,
+    quarantined_at  INTEGER
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_hash_failures_quarantined_at
+    ON hash_failures (quarantined_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS hash_failures;
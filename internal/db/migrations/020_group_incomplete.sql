@@ -0,0 +1,9 @@
+-- +goose Up
+-- Set by a completed scan on groups that may be missing a copy: a candidate
+-- of the same size failed to fully hash, so it could not be placed. Their
+-- file_count and reclaimable_bytes may understate. Every completed scan
+-- re-evaluates the flag.
+ALTER TABLE duplicate_groups ADD COLUMN incomplete INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
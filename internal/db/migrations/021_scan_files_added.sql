@@ -0,0 +1,7 @@
+-- +goose Up
+-- Candidates the scan found no file_cache row for at all: files added (or
+-- first paired with a same-size file) since the earlier scans.
+ALTER TABLE scan_history ADD COLUMN files_added INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
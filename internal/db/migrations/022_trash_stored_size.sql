@@ -0,0 +1,8 @@
+-- +goose Up
+-- Bytes the trashed file takes in the trash: the .gz size when compressed,
+-- otherwise file_size. NULL for rows trashed before it was recorded, which
+-- count as file_size.
+ALTER TABLE trash ADD COLUMN stored_size INTEGER;
+
+-- +goose Down
+SELECT 1; -- SQLite does not support DROP COLUMN; leave columns in place.
@@ -6,8 +6,10 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"math"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -78,8 +80,8 @@ func ExtractImageMeta(path string) ImageMeta {
 	if v := exifString(x, exif.Flash); v != "" {
 		meta.Flash = v
 	}
-	if v := exifString(x, exif.Orientation); v != "" {
-		meta.Orientation = orientationLabel(v)
+	if o := exifOrientation(x); o != 0 {
+		meta.Orientation = orientationLabel(strconv.Itoa(o))
 	}
 	if v := exifString(x, exif.WhiteBalance); v != "" {
 		meta.WhiteBalance = whiteBalanceLabel(v)
@@ -146,6 +148,33 @@ func exifString(x *exif.Exif, field exif.FieldName) string {
 	return strings.TrimSpace(s)
 }
 
+// exifOrientation returns the EXIF Orientation value (1–8), or 0 when the tag
+// is missing. The tag is a SHORT, so exifString cannot read it.
+func exifOrientation(x *exif.Exif) int {
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 0
+	}
+	v, err := tag.Int(0)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// readOrientation decodes the EXIF block from r and returns its orientation,
+// defaulting to 1 (upright) when there is none.
+func readOrientation(r io.Reader) int {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return 1
+	}
+	if o := exifOrientation(x); o != 0 {
+		return o
+	}
+	return 1
+}
+
 func orientationLabel(v string) string {
 	switch v {
 	case "1":
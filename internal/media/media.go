@@ -8,6 +8,7 @@ import (
 	"image/png"
 	"io"
 	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,6 +27,15 @@ const (
 	FileTypeOther    FileType = "other"
 )
 
+// ValidFileType reports whether s names one of the known FileType values.
+func ValidFileType(s string) bool {
+	switch FileType(s) {
+	case FileTypeImage, FileTypeVideo, FileTypeDocument, FileTypeOther:
+		return true
+	}
+	return false
+}
+
 var imageExts = map[string]bool{
 	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
 	".bmp": true, ".webp": true, ".tiff": true, ".tif": true,
@@ -58,6 +68,48 @@ func Detect(path string) FileType {
 	}
 }
 
+// sniffBytes is the number of leading bytes http.DetectContentType considers.
+const sniffBytes = 512
+
+// Sniff classifies the file at path by its leading bytes rather than its
+// extension. It recognises images, videos and PDFs; anything else (including
+// unreadable files) is FileTypeOther. The detected MIME type is returned
+// alongside, or "" if the file could not be read.
+func Sniff(path string) (FileType, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileTypeOther, ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return FileTypeOther, ""
+	}
+	ct := http.DetectContentType(buf[:n])
+	switch {
+	case strings.HasPrefix(ct, "image/"):
+		return FileTypeImage, ct
+	case strings.HasPrefix(ct, "video/"):
+		return FileTypeVideo, ct
+	case ct == "application/pdf":
+		return FileTypeDocument, ct
+	default:
+		return FileTypeOther, ct
+	}
+}
+
+// DetectWithSniff is Detect with a content-sniffing fallback for files whose
+// extension is unknown or missing.
+func DetectWithSniff(path string) FileType {
+	if ft := Detect(path); ft != FileTypeOther {
+		return ft
+	}
+	ft, _ := Sniff(path)
+	return ft
+}
+
 // ContentType returns the MIME content type for the file based on its extension.
 // Returns "application/octet-stream" for unknown types.
 func ContentType(path string) string {
@@ -70,7 +122,8 @@ func ContentType(path string) string {
 }
 
 // Thumbnail generates a JPEG thumbnail for the image at path, resized to fit
-// within width x height while preserving the aspect ratio.
+// within width x height while preserving the aspect ratio. JPEGs are turned
+// upright according to their EXIF orientation.
 // Returns nil, nil for non-image files or unsupported formats (video, etc.).
 // The output is always JPEG at quality 75.
 func Thumbnail(path string, width, height int) ([]byte, error) {
@@ -94,13 +147,28 @@ func Thumbnail(path string, width, height int) ([]byte, error) {
 	}
 	defer f.Close()
 
+	// Phone cameras store pixels in sensor order and record the display
+	// rotation in EXIF; only JPEGs carry it in practice.
+	orientation := 1
+	if ext == ".jpg" || ext == ".jpeg" {
+		orientation = readOrientation(f)
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
 	src, err := decodeImage(ext, f)
 	if err != nil {
 		// Treat decode errors as "can't thumbnail" rather than hard errors.
 		return nil, nil
 	}
 
-	thumb := resizeFit(src, width, height)
+	// Orienting after the resize gives the same result on far fewer pixels;
+	// a quarter-turn swaps the box the stored image has to fit in.
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	thumb := applyOrientation(resizeFit(src, width, height), orientation)
 
 	var buf bytes.Buffer
 	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 75}); err != nil {
@@ -163,3 +231,41 @@ func resizeFit(src image.Image, dstW, dstH int) image.Image {
 	draw.BiLinear.Scale(dst, dst.Bounds(), src, srcBounds, draw.Over, nil)
 	return dst
 }
+
+// applyOrientation rotates/flips img so that it displays upright given the
+// EXIF orientation value o (1–8). Other values return img unchanged.
+func applyOrientation(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dstW, dstH := w, h
+	if o >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs 90° CW
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs 90° CCW
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
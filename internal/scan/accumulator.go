@@ -1,14 +1,29 @@
 package scan
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
 
-// RunSizeAccumulator reads all FileInfo from in, counting every file as
-// "discovered". The first file seen per size is buffered. When a second file
+// RunSizeAccumulator reads all FileInfo from in, counting every file (and its
+// size) as "discovered". The first file seen per size is buffered. When a second file
 // with the same size arrives, both are emitted to out as candidates for
 // hashing. Subsequent files with a seen size are emitted immediately.
 // Empty (zero-byte) files are skipped — they cannot be meaningful duplicates.
+// When skipRecent > 0, files modified within skipRecent of the call are
+// skipped too: they are probably still being written (e.g. a download in
+// progress) and would hash to a transient state.
 // out is closed when in is exhausted or ctx is cancelled.
-func RunSizeAccumulator(ctx context.Context, progress *Progress, in <-chan FileInfo, out chan<- FileInfo) {
+func RunSizeAccumulator(ctx context.Context, progress *Progress, skipRecent time.Duration, in <-chan FileInfo, out chan<- FileInfo) {
+	var cutoff time.Time
+	if skipRecent > 0 {
+		cutoff = time.Now().Add(-skipRecent)
+	}
 	go func() {
 		defer close(out)
 
@@ -21,13 +36,19 @@ func RunSizeAccumulator(ctx context.Context, progress *Progress, in <-chan FileI
 				return
 			case fi, ok := <-in:
 				if !ok {
+					progress.WalkFinishedAt.Store(time.Now().Unix())
 					return
 				}
 				progress.FilesDiscovered.Add(1)
+				progress.BytesDiscovered.Add(fi.Size)
 
 				if fi.Size == 0 {
 					continue
 				}
+				if !cutoff.IsZero() && fi.MTime.After(cutoff) {
+					progress.RecentlyModifiedSkipped.Add(1)
+					continue
+				}
 
 				if seen[fi.Size] {
 					progress.CandidatesFound.Add(1)
@@ -58,3 +79,292 @@ func RunSizeAccumulator(ctx context.Context, progress *Progress, in <-chan FileI
 		}
 	}()
 }
+
+// RunCacheFirstAccumulator is RunSizeAccumulator for the misses of
+// RunCacheFirstCheck. A miss may duplicate a cached file that never reaches
+// this stage, so sizes are only settled once in is exhausted (and cached is
+// complete): files of a size that has cached files go to direct, for the full
+// hasher — a partial hash would have nothing to pair with — while other
+// sizes seen at least twice go to out as usual. Candidates are only emitted
+// after the walk, so hashing starts later than with RunSizeAccumulator.
+// Every candidate counts as a cache miss. Discovery, zero-byte and
+// skipRecent handling match RunSizeAccumulator. Both outputs are closed when
+// in is exhausted or ctx is cancelled.
+func RunCacheFirstAccumulator(ctx context.Context, progress *Progress, skipRecent time.Duration, cached *CachedSizes, in <-chan FileInfo, out chan<- FileInfo, direct chan<- HashedFile) {
+	var cutoff time.Time
+	if skipRecent > 0 {
+		cutoff = time.Now().Add(-skipRecent)
+	}
+	go func() {
+		defer close(out)
+		defer close(direct)
+
+		bySize := make(map[int64][]FileInfo)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case fi, ok := <-in:
+				if !ok {
+					progress.WalkFinishedAt.Store(time.Now().Unix())
+					for size, files := range bySize {
+						toFull := cached.Has(size)
+						if !toFull && len(files) < 2 {
+							continue
+						}
+						progress.CandidatesFound.Add(int64(len(files)))
+						progress.CacheMisses.Add(int64(len(files)))
+						for _, f := range files {
+							if toFull {
+								select {
+								case direct <- HashedFile{FileInfo: f}:
+								case <-ctx.Done():
+									return
+								}
+								continue
+							}
+							select {
+							case out <- f:
+							case <-ctx.Done():
+								return
+							}
+						}
+					}
+					return
+				}
+				progress.FilesDiscovered.Add(1)
+				progress.BytesDiscovered.Add(fi.Size)
+
+				if fi.Size == 0 {
+					continue
+				}
+				if !cutoff.IsZero() && fi.MTime.After(cutoff) {
+					progress.RecentlyModifiedSkipped.Add(1)
+					continue
+				}
+				bySize[fi.Size] = append(bySize[fi.Size], fi)
+			}
+		}
+	}()
+}
+
+// toleranceBucket summarises the files seen in one logarithmic size bucket.
+type toleranceBucket struct {
+	count    int
+	min, max int64
+	pending  *FileInfo // the bucket's only file while it has no match yet
+}
+
+// RunSizeToleranceAccumulator is the fuzzy alternative to RunSizeAccumulator:
+// a file becomes a candidate when any other file's size is within tolerance
+// (a fraction, e.g. 0.01 = 1%) of the smaller of the two, rather than exactly
+// equal — the shape of
+// re-encoded copies of the same video. Sizes are bucketed on a log scale of
+// base 1+tolerance, so two files in one bucket always match and only the two
+// neighbouring buckets need an exact check.
+//
+// This widens the candidate set considerably (every file near another's size
+// gets hashed), and hashing still only groups byte-identical files: the stage
+// exists to feed a content-similarity check. Discovery, zero-byte and
+// skipRecent handling match RunSizeAccumulator. out is closed when in is
+// exhausted or ctx is cancelled.
+func RunSizeToleranceAccumulator(ctx context.Context, progress *Progress, skipRecent time.Duration, tolerance float64, in <-chan FileInfo, out chan<- FileInfo) {
+	var cutoff time.Time
+	if skipRecent > 0 {
+		cutoff = time.Now().Add(-skipRecent)
+	}
+	logBase := math.Log1p(tolerance)
+	// Measured against the smaller size, i.e. a size ratio of at most
+	// 1+tolerance, so matches never lie beyond the neighbouring buckets.
+	within := func(a, b int64) bool {
+		diff, smaller := a-b, b
+		if diff < 0 {
+			diff, smaller = -diff, a
+		}
+		return float64(diff) <= tolerance*float64(smaller)
+	}
+
+	go func() {
+		defer close(out)
+
+		buckets := make(map[int64]*toleranceBucket)
+		emit := func(fi FileInfo) bool {
+			progress.CandidatesFound.Add(1)
+			select {
+			case out <- fi:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case fi, ok := <-in:
+				if !ok {
+					progress.WalkFinishedAt.Store(time.Now().Unix())
+					return
+				}
+				progress.FilesDiscovered.Add(1)
+				progress.BytesDiscovered.Add(fi.Size)
+
+				if fi.Size == 0 {
+					continue
+				}
+				if !cutoff.IsZero() && fi.MTime.After(cutoff) {
+					progress.RecentlyModifiedSkipped.Add(1)
+					continue
+				}
+
+				k := int64(math.Log(float64(fi.Size)) / logBase)
+				b := buckets[k]
+				if b == nil {
+					b = &toleranceBucket{min: fi.Size, max: fi.Size}
+					buckets[k] = b
+				}
+				matched := b.count > 0
+
+				// Release any waiting file this one matches.
+				for _, nb := range []*toleranceBucket{b, buckets[k-1], buckets[k+1]} {
+					if nb == nil || nb.pending == nil {
+						continue
+					}
+					if nb == b || within(fi.Size, nb.pending.Size) {
+						if !emit(*nb.pending) {
+							return
+						}
+						nb.pending = nil
+						matched = true
+					}
+				}
+				if lo := buckets[k-1]; lo != nil && within(fi.Size, lo.max) {
+					matched = true
+				}
+				if hi := buckets[k+1]; hi != nil && within(fi.Size, hi.min) {
+					matched = true
+				}
+
+				b.count++
+				if fi.Size < b.min {
+					b.min = fi.Size
+				}
+				if fi.Size > b.max {
+					b.max = fi.Size
+				}
+				if matched {
+					if !emit(fi) {
+						return
+					}
+				} else {
+					pending := fi
+					b.pending = &pending
+				}
+			}
+		}
+	}()
+}
+
+// headKey is a size candidate keyed by its size and leading bytes.
+type headKey struct {
+	size int64
+	head string
+}
+
+// RunHeadFilter refines the candidates of RunSizeAccumulator by their first
+// headBytes bytes: a candidate is only passed on once another file of the
+// same size starts with the same bytes, so same-size files that differ from
+// the first byte never reach the partial hasher. numWorkers goroutines read
+// the heads (each read is one open, counted against limiter); a single
+// goroutine buckets them with the same first/seen scheme as
+// RunSizeAccumulator. A file whose head cannot be read is passed on as is,
+// so the hashers report the error. Files dropped as unique count towards
+// progress.HeadFiltered. out is closed when in is exhausted or ctx is
+// cancelled.
+func RunHeadFilter(ctx context.Context, numWorkers, headBytes int, limiter *fileLimiter, progress *Progress, in <-chan FileInfo, out chan<- FileInfo) {
+	type keyed struct {
+		fi  FileInfo
+		key headKey
+		ok  bool // false when the head could not be read
+	}
+	heads := make(chan keyed, numWorkers)
+
+	var wg sync.WaitGroup
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fi := range in {
+				t0 := time.Now()
+				head, err := readHead(fi.Path, headBytes, limiter)
+				progress.DiskReadMs.Add(time.Since(t0).Milliseconds())
+				select {
+				case heads <- keyed{fi: fi, key: headKey{fi.Size, head}, ok: err == nil}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(heads)
+	}()
+
+	go func() {
+		defer close(out)
+
+		first := make(map[headKey]FileInfo) // key → first-seen file
+		seen := make(map[headKey]bool)      // keys with ≥2 files
+		emit := func(fi FileInfo) bool {
+			select {
+			case out <- fi:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for k := range heads {
+			switch {
+			case !k.ok || seen[k.key]:
+				if !emit(k.fi) {
+					return
+				}
+			default:
+				prev, ok := first[k.key]
+				if !ok {
+					first[k.key] = k.fi
+					continue
+				}
+				seen[k.key] = true
+				delete(first, k.key)
+				if !emit(prev) || !emit(k.fi) {
+					return
+				}
+			}
+		}
+		progress.HeadFiltered.Add(int64(len(first)))
+	}()
+}
+
+// readHead returns the first n bytes of the file at path (fewer if it is
+// shorter).
+func readHead(path string, n int, limiter *fileLimiter) (string, error) {
+	limiter.acquire()
+	defer limiter.release()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return string(buf[:read]), nil
+}
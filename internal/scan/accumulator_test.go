@@ -0,0 +1,147 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestSizeAccumulatorSkipsRecentlyModified verifies that files whose mtime is
+// inside the skip window are counted as discovered but never become
+// candidates, while older files of the same size still pair up.
+func TestSizeAccumulatorSkipsRecentlyModified(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	fresh := time.Now().Add(-5 * time.Second)
+
+	for _, tc := range []struct {
+		window         time.Duration
+		wantCandidates int
+		wantSkipped    int64
+	}{
+		{window: 0, wantCandidates: 3},
+		{window: time.Minute, wantCandidates: 2, wantSkipped: 2},
+	} {
+		t.Run(fmt.Sprint(tc.window), func(t *testing.T) {
+			src := make(chan FileInfo, 4)
+			for _, fi := range []FileInfo{
+				{Path: "/a/old1", Size: 100, MTime: old},
+				{Path: "/a/old2", Size: 100, MTime: old},
+				{Path: "/a/fresh1", Size: 100, MTime: fresh},
+				{Path: "/a/fresh2", Size: 200, MTime: fresh},
+			} {
+				src <- fi
+			}
+			close(src)
+
+			p := &Progress{}
+			out := make(chan FileInfo, 4)
+			RunSizeAccumulator(context.Background(), p, tc.window, src, out)
+
+			var got []string
+			for fi := range out {
+				got = append(got, fi.Path)
+			}
+			if len(got) != tc.wantCandidates {
+				t.Errorf("candidates: got %v, want %d", got, tc.wantCandidates)
+			}
+			for _, path := range got {
+				if tc.window > 0 && (path == "/a/fresh1" || path == "/a/fresh2") {
+					t.Errorf("recently modified file %s emitted as candidate", path)
+				}
+			}
+			if n := p.FilesDiscovered.Load(); n != 4 {
+				t.Errorf("FilesDiscovered: got %d, want 4", n)
+			}
+			if n := p.RecentlyModifiedSkipped.Load(); n != tc.wantSkipped {
+				t.Errorf("RecentlyModifiedSkipped: got %d, want %d", n, tc.wantSkipped)
+			}
+		})
+	}
+}
+
+// TestSizeToleranceAccumulator verifies that files pair up when their sizes
+// are within the tolerance — including across log-bucket boundaries — while
+// a file with no near-sized peer is held back.
+func TestSizeToleranceAccumulator(t *testing.T) {
+	src := make(chan FileInfo, 6)
+	for _, fi := range []FileInfo{
+		{Path: "/v/a", Size: 1_000_000},
+		{Path: "/v/lone", Size: 5_000_000},
+		{Path: "/v/a-reencoded", Size: 1_009_000}, // +0.9%
+		{Path: "/v/b", Size: 2_000_000},
+		{Path: "/v/b-reencoded", Size: 1_985_000}, // -0.75%
+		{Path: "/v/empty", Size: 0},
+	} {
+		src <- fi
+	}
+	close(src)
+
+	p := &Progress{}
+	out := make(chan FileInfo, 6)
+	RunSizeToleranceAccumulator(context.Background(), p, 0, 0.01, src, out)
+
+	got := map[string]bool{}
+	for fi := range out {
+		if got[fi.Path] {
+			t.Errorf("%s emitted twice", fi.Path)
+		}
+		got[fi.Path] = true
+	}
+	for _, want := range []string{"/v/a", "/v/a-reencoded", "/v/b", "/v/b-reencoded"} {
+		if !got[want] {
+			t.Errorf("%s not emitted as candidate; got %v", want, got)
+		}
+	}
+	if got["/v/lone"] || got["/v/empty"] {
+		t.Errorf("unexpected candidates: %v", got)
+	}
+	if n := p.CandidatesFound.Load(); n != 4 {
+		t.Errorf("CandidatesFound: got %d, want 4", n)
+	}
+}
+
+// TestHeadFilter verifies that same-size candidates are only passed on when
+// another file shares their leading bytes, and that unreadable files pass
+// through for the hashers to report.
+func TestHeadFilter(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) FileInfo {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return FileInfo{Path: path, Size: int64(len(content))}
+	}
+
+	src := make(chan FileInfo, 5)
+	for _, fi := range []FileInfo{
+		write("a1", "AAAA-same-tail"),
+		write("b", "BBBB-same-tail"),
+		write("a2", "AAAA-diff-tail"), // same head as a1: still a candidate
+		write("c", "CCCC-same-tail"),
+		{Path: filepath.Join(dir, "missing"), Size: 14},
+	} {
+		src <- fi
+	}
+	close(src)
+
+	p := &Progress{}
+	out := make(chan FileInfo, 5)
+	RunHeadFilter(context.Background(), 2, 4, nil, p, src, out)
+
+	var got []string
+	for fi := range out {
+		got = append(got, filepath.Base(fi.Path))
+	}
+	sort.Strings(got)
+	if want := []string{"a1", "a2", "missing"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("passed on %v, want %v", got, want)
+	}
+	if n := p.HeadFiltered.Load(); n != 2 {
+		t.Errorf("HeadFiltered: got %d, want 2", n)
+	}
+}
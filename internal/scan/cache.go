@@ -9,27 +9,48 @@ import (
 	"time"
 )
 
-// cacheBatchSize is the number of candidates sent in a single SELECT … IN (…)
-// query. Larger batches mean fewer round-trips; 500 is a good balance between
-// query size and latency.
-const cacheBatchSize = 500
+// defaultCacheBatchSize is the number of candidates sent in a single
+// SELECT … IN (…) query when Config.CacheBatchSize is unset. Larger batches
+// mean fewer round-trips; 500 is a good balance between query size and latency.
+const defaultCacheBatchSize = 500
+
+// maxCacheBatchSize keeps batches under SQLite's historical default
+// SQLITE_MAX_VARIABLE_NUMBER (999) so no build fails with
+// "too many SQL variables".
+const maxCacheBatchSize = 999
+
+// clampCacheBatchSize returns n limited to [1, maxCacheBatchSize], using
+// defaultCacheBatchSize when n ≤ 0.
+func clampCacheBatchSize(n int) int {
+	switch {
+	case n <= 0:
+		return defaultCacheBatchSize
+	case n > maxCacheBatchSize:
+		return maxCacheBatchSize
+	default:
+		return n
+	}
+}
 
 // RunCacheCheck spawns numWorkers goroutines. Each worker accumulates incoming
-// FileInfos into batches of up to cacheBatchSize and looks them all up in a
-// single SELECT … WHERE path IN (…) query, reducing database round-trips by
-// ~500×.
+// FileInfos into batches of up to batchSize (clamped, 0 = default) and looks
+// them all up in a single SELECT … WHERE path IN (…) query, reducing database
+// round-trips by ~batchSize×.
 //
 // A result row whose (size, mtime) still matches → cache hit → sent to hits.
 // Everything else (no row, or stale row) → cache miss → sent to misses.
 //
 // Both hits and misses are closed when all workers finish or ctx is cancelled.
-func RunCacheCheck(ctx context.Context, db *sql.DB, progress *Progress, numWorkers int, in <-chan FileInfo, hits chan<- HashedFile, misses chan<- FileInfo) {
+func RunCacheCheck(ctx context.Context, db *sql.DB, progress *Progress, numWorkers, batchSize int, in <-chan FileInfo, hits chan<- HashedFile, misses chan<- FileInfo) {
+	batchSize = clampCacheBatchSize(batchSize)
 	var wg sync.WaitGroup
 	for range numWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			cacheWorker(ctx, db, in, hits, misses, progress)
+			cacheWorker(ctx, batchSize, in, func(batch []FileInfo) {
+				lookupBatch(ctx, db, batch, hits, misses, progress)
+			})
 		}()
 	}
 	go func() {
@@ -39,9 +60,10 @@ func RunCacheCheck(ctx context.Context, db *sql.DB, progress *Progress, numWorke
 	}()
 }
 
-// cacheWorker is the per-goroutine body of RunCacheCheck.
-func cacheWorker(ctx context.Context, db *sql.DB, in <-chan FileInfo, hits chan<- HashedFile, misses chan<- FileInfo, progress *Progress) {
-	batch := make([]FileInfo, 0, cacheBatchSize)
+// cacheWorker is the per-goroutine body of RunCacheCheck and
+// RunCacheFirstCheck: it hands each batch read from in to lookup.
+func cacheWorker(ctx context.Context, batchSize int, in <-chan FileInfo, lookup func([]FileInfo)) {
+	batch := make([]FileInfo, 0, batchSize)
 
 	for {
 		// Block until we get the first item of a new batch.
@@ -57,8 +79,8 @@ func cacheWorker(ctx context.Context, db *sql.DB, in <-chan FileInfo, hits chan<
 
 		// Greedily drain more items without blocking (fills the batch).
 		var open bool
-		batch, open = drainBatch(in, batch, cacheBatchSize)
-		lookupBatch(ctx, db, batch, hits, misses, progress)
+		batch, open = drainBatch(in, batch, batchSize)
+		lookup(batch)
 		batch = batch[:0]
 		if !open {
 			return
@@ -87,8 +109,35 @@ func drainBatch(in <-chan FileInfo, batch []FileInfo, maxSize int) ([]FileInfo,
 // lookupBatch issues a single batched SELECT for all paths in batch and routes
 // each item to hits (cache hit with matching size+mtime) or misses.
 func lookupBatch(ctx context.Context, db *sql.DB, batch []FileInfo, hits chan<- HashedFile, misses chan<- FileInfo, progress *Progress) {
+	cached := cachedHashes(ctx, db, batch, progress)
+
+	// Route each item in batch order.
+	for _, fi := range batch {
+		if hash, ok := cached[fi.Path]; ok {
+			progress.CacheHits.Add(1)
+			select {
+			case hits <- HashedFile{FileInfo: fi, Hash: hash}:
+			case <-ctx.Done():
+				return
+			}
+		} else {
+			progress.CacheMisses.Add(1)
+			select {
+			case misses <- fi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// cachedHashes issues a single batched SELECT for all paths in batch and
+// returns the full hash of each file whose file_cache row still matches its
+// size and mtime, keyed by path. A failed query yields no hits. Files with
+// no row at all are counted in progress.FilesAdded.
+func cachedHashes(ctx context.Context, db *sql.DB, batch []FileInfo, progress *Progress) map[string]string {
 	if len(batch) == 0 {
-		return
+		return nil
 	}
 
 	// Build: SELECT path, size, mtime, full_hash FROM file_cache WHERE path IN (?,?,...).
@@ -104,47 +153,126 @@ func lookupBatch(ctx context.Context, db *sql.DB, batch []FileInfo, hits chan<-
 		"SELECT path, size, mtime, full_hash FROM file_cache WHERE path IN ("+placeholders+")",
 		args...)
 	progress.DBReadMs.Add(time.Since(t0).Milliseconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("cache check batch query", "error", err)
+		}
+		return nil
+	}
+	defer rows.Close()
 
 	// Build a map of path → cached entry from the result set.
 	type cacheEntry struct {
 		size, mtime int64
 		hash        string
 	}
-	cached := make(map[string]cacheEntry, len(batch))
-	if err != nil {
-		if ctx.Err() == nil {
-			slog.Warn("cache check batch query", "error", err)
-		}
-	} else {
-		defer rows.Close()
-		for rows.Next() {
-			var path, hash string
-			var size, mtime int64
-			if rows.Scan(&path, &size, &mtime, &hash) == nil {
-				cached[path] = cacheEntry{size, mtime, hash}
-			}
-		}
-		if rerr := rows.Err(); rerr != nil && ctx.Err() == nil {
-			slog.Warn("cache check batch rows error", "error", rerr)
+	entries := make(map[string]cacheEntry, len(batch))
+	for rows.Next() {
+		var path, hash string
+		var size, mtime int64
+		if rows.Scan(&path, &size, &mtime, &hash) == nil {
+			entries[path] = cacheEntry{size, mtime, hash}
 		}
 	}
+	if rerr := rows.Err(); rerr != nil && ctx.Err() == nil {
+		slog.Warn("cache check batch rows error", "error", rerr)
+	}
 
-	// Route each item in batch order.
+	cached := make(map[string]string, len(entries))
 	for _, fi := range batch {
-		if e, ok := cached[fi.Path]; ok && e.size == fi.Size && e.mtime == fi.MTime.Unix() {
-			progress.CacheHits.Add(1)
-			select {
-			case hits <- HashedFile{FileInfo: fi, Hash: e.hash}:
-			case <-ctx.Done():
-				return
+		e, ok := entries[fi.Path]
+		if !ok {
+			progress.FilesAdded.Add(1)
+		} else if e.size == fi.Size && e.mtime == fi.MTime.Unix() {
+			cached[fi.Path] = e.hash
+		}
+	}
+	return cached
+}
+
+// CachedSizes is the set of file sizes RunCacheFirstCheck found cached
+// files of. It is safe for concurrent use.
+type CachedSizes struct {
+	mu    sync.Mutex
+	sizes map[int64]struct{}
+}
+
+// NewCachedSizes returns an empty CachedSizes.
+func NewCachedSizes() *CachedSizes {
+	return &CachedSizes{sizes: make(map[int64]struct{})}
+}
+
+func (c *CachedSizes) add(size int64) {
+	c.mu.Lock()
+	c.sizes[size] = struct{}{}
+	c.mu.Unlock()
+}
+
+// Has reports whether a cached file of the given size was found.
+func (c *CachedSizes) Has(size int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.sizes[size]
+	return ok
+}
+
+// RunCacheFirstCheck is the cache check moved ahead of the size accumulator,
+// for repeated scans of a mostly static tree: every walked file is looked up,
+// and a hit — whose full hash is already known — is counted as discovered
+// and sent to hits for the writer without being size-accumulated, partially
+// hashed or grouped. Its size is recorded in cached so that
+// RunCacheFirstAccumulator still pairs new files with it. Misses, and empty
+// or recently modified files (never looked up), go to misses for the
+// accumulator; they are not counted as cache misses here, since most of
+// them never become candidates. Both outputs are closed when all workers
+// finish or ctx is cancelled.
+func RunCacheFirstCheck(ctx context.Context, db *sql.DB, progress *Progress, numWorkers, batchSize int, skipRecent time.Duration, cached *CachedSizes, in <-chan FileInfo, hits chan<- HashedFile, misses chan<- FileInfo) {
+	batchSize = clampCacheBatchSize(batchSize)
+	var cutoff time.Time
+	if skipRecent > 0 {
+		cutoff = time.Now().Add(-skipRecent)
+	}
+	route := func(batch []FileInfo) {
+		lookup := make([]FileInfo, 0, len(batch))
+		for _, fi := range batch {
+			if fi.Size > 0 && (cutoff.IsZero() || !fi.MTime.After(cutoff)) {
+				lookup = append(lookup, fi)
 			}
-		} else {
-			progress.CacheMisses.Add(1)
-			select {
-			case misses <- fi:
-			case <-ctx.Done():
-				return
+		}
+		hashes := cachedHashes(ctx, db, lookup, progress)
+		for _, fi := range batch {
+			if hash, ok := hashes[fi.Path]; ok {
+				progress.FilesDiscovered.Add(1)
+				progress.BytesDiscovered.Add(fi.Size)
+				progress.CandidatesFound.Add(1)
+				progress.CacheHits.Add(1)
+				cached.add(fi.Size)
+				select {
+				case hits <- HashedFile{FileInfo: fi, Hash: hash}:
+				case <-ctx.Done():
+					return
+				}
+			} else {
+				select {
+				case misses <- fi:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}
+
+	var wg sync.WaitGroup
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cacheWorker(ctx, batchSize, in, route)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(hits)
+		close(misses)
+	}()
 }
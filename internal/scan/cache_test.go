@@ -3,6 +3,8 @@ package scan
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -23,7 +25,7 @@ func TestCacheCheckRoutesHitsAndMisses(t *testing.T) {
 	hits := make(chan HashedFile, numCached+numNew)
 	misses := make(chan FileInfo, numCached+numNew)
 
-	RunCacheCheck(context.Background(), db, progress, 2, in, hits, misses)
+	RunCacheCheck(context.Background(), db, progress, 2, 0, in, hits, misses)
 
 	// Send files that are in cache.
 	for i := 0; i < numCached; i++ {
@@ -83,7 +85,7 @@ func TestCacheCheckAllHits(t *testing.T) {
 	in := make(chan FileInfo, n)
 	hits := make(chan HashedFile, n)
 	misses := make(chan FileInfo, n)
-	RunCacheCheck(context.Background(), db, progress, 1, in, hits, misses)
+	RunCacheCheck(context.Background(), db, progress, 1, 0, in, hits, misses)
 
 	for i := 0; i < n; i++ {
 		in <- FileInfo{
@@ -129,7 +131,7 @@ func TestCacheCheckParallelConsistency(t *testing.T) {
 		in := make(chan FileInfo, numCached+numNew)
 		hitsCh := make(chan HashedFile, numCached+numNew)
 		missesCh := make(chan FileInfo, numCached+numNew)
-		RunCacheCheck(context.Background(), db, progress, numWorkers, in, hitsCh, missesCh)
+		RunCacheCheck(context.Background(), db, progress, numWorkers, 0, in, hitsCh, missesCh)
 
 		for i := 0; i < numCached; i++ {
 			in <- FileInfo{
@@ -175,3 +177,147 @@ func TestCacheCheckParallelConsistency(t *testing.T) {
 		t.Errorf("hits: got %d, want %d", h1, numCached)
 	}
 }
+
+// TestCacheCheckBatchSizes verifies routing is unaffected by the configured
+// batch size, including values above the SQLite variable limit (clamped).
+func TestCacheCheckBatchSizes(t *testing.T) {
+	db := mustOpenDB(t)
+	scanID := mustInsertScan(t, db)
+	const n = 1200
+	seedFileCache(t, db, scanID, n)
+
+	for _, batchSize := range []int{3, 5000} {
+		progress := &Progress{}
+		in := make(chan FileInfo, n)
+		hits := make(chan HashedFile, n)
+		misses := make(chan FileInfo, n)
+		RunCacheCheck(context.Background(), db, progress, 2, batchSize, in, hits, misses)
+		for i := 0; i < n; i++ {
+			in <- FileInfo{
+				Path:  fmt.Sprintf("/cached/file%04d.txt", i),
+				Size:  int64(i*100 + 1),
+				MTime: time.Unix(int64(1000+i), 0),
+			}
+		}
+		close(in)
+		for range hits {
+		}
+		for range misses {
+		}
+		if got := progress.CacheHits.Load(); got != n {
+			t.Errorf("batch size %d: hits got %d, want %d", batchSize, got, n)
+		}
+	}
+}
+
+// TestCacheFirstPairsNewFilesWithCachedOnes verifies that a cache-first scan
+// still finds cached duplicates, and that a new file whose only same-size
+// partner is cached — a file the accumulator never sees — joins its group.
+func TestCacheFirstPairsNewFilesWithCachedOnes(t *testing.T) {
+	root := t.TempDir()
+	small := []byte("small duplicate content")
+	large := make([]byte, partialHashBytes+1024)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	write := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("small_a.txt", small)
+	write("small_b.txt", small)
+	write("large_a.bin", large)
+	write("unique.txt", []byte("nothing else is this long"))
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.CacheFirst = true
+	s := New(db, []string{root}, nil, cfg)
+	if _, err := s.Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+
+	// large_b pairs with the cached large_a only; small_c with two cached files.
+	write("large_b.bin", large)
+	write("small_c.txt", small)
+	p := &Progress{}
+	scanID, err := s.Run(context.Background(), "manual", p)
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+
+	if got := p.CacheHits.Load(); got != 2 {
+		t.Errorf("CacheHits: got %d, want 2 (small_a, small_b)", got)
+	}
+	if got := p.CacheMisses.Load(); got != 3 {
+		t.Errorf("CacheMisses: got %d, want 3 (large_a, large_b, small_c)", got)
+	}
+	if got := p.FilesDiscovered.Load(); got != 6 {
+		t.Errorf("FilesDiscovered: got %d, want 6", got)
+	}
+
+	rows, err := db.Query(`
+		SELECT size, COUNT(*) FROM duplicate_files
+		WHERE scan_id = ?
+		GROUP BY group_id`, scanID)
+	if err != nil {
+		t.Fatalf("query groups: %v", err)
+	}
+	defer rows.Close()
+	got := map[int64]int{}
+	for rows.Next() {
+		var size int64
+		var n int
+		if err := rows.Scan(&size, &n); err != nil {
+			t.Fatal(err)
+		}
+		got[size] = n
+	}
+	want := map[int64]int{int64(len(small)): 3, int64(len(large)): 2}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("group sizes → file counts: got %v, want %v", got, want)
+	}
+}
+
+// TestFilesAddedCountsUncachedCandidates verifies that scan_history
+// files_added counts candidates no earlier scan hashed, and not those whose
+// cache row is merely stale.
+func TestFilesAddedCountsUncachedCandidates(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "duplicate")
+	write("b.txt", "duplicate")
+
+	db := mustOpenDB(t)
+	filesAdded := func() int64 {
+		t.Helper()
+		id, err := New(db, []string{root}, nil, DefaultConfig()).Run(context.Background(), "manual", &Progress{})
+		if err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		var n int64
+		if err := db.QueryRow(`SELECT files_added FROM scan_history WHERE id = ?`, id).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := filesAdded(); n != 2 {
+		t.Errorf("first scan: files_added = %d, want 2", n)
+	}
+
+	write("c.txt", "duplicate")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "a.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if n := filesAdded(); n != 1 {
+		t.Errorf("second scan: files_added = %d, want 1 (c.txt only)", n)
+	}
+}
@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Error codes stored in scan_errors.code, so errors can be counted by kind
+// without parsing their messages.
+const (
+	ErrCodePermission = "PERMISSION"
+	ErrCodeNotFound   = "NOT_FOUND"
+	ErrCodeTimeout    = "TIMEOUT"
+	ErrCodeDecode     = "DECODE"
+	ErrCodeIO         = "IO"
+)
+
+// ClassifyError maps an error message to one of the ErrCode* values.
+// Reporters only receive err.Error(), so classification matches the standard
+// library's message text; anything unrecognised is ErrCodeIO.
+func ClassifyError(errMsg string) string {
+	msg := strings.ToLower(errMsg)
+	switch {
+	case strings.Contains(msg, fs.ErrPermission.Error()),
+		strings.Contains(msg, "operation not permitted"):
+		return ErrCodePermission
+	case strings.Contains(msg, "no such file or directory"),
+		strings.Contains(msg, fs.ErrNotExist.Error()):
+		return ErrCodeNotFound
+	case strings.Contains(msg, os.ErrDeadlineExceeded.Error()),
+		strings.Contains(msg, "deadline exceeded"),
+		strings.Contains(msg, "timed out"):
+		return ErrCodeTimeout
+	case strings.Contains(msg, "decode"),
+		strings.Contains(msg, "malformed"):
+		return ErrCodeDecode
+	default:
+		return ErrCodeIO
+	}
+}
@@ -0,0 +1,27 @@
+package scan
+
+import "testing"
+
+// TestClassifyError checks the error messages the walker and hashers
+// produce map to the expected codes.
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		msg  string
+		want string
+	}{
+		{"open /a: permission denied", ErrCodePermission},
+		{"permission denied on 3 path(s), including this one", ErrCodePermission},
+		{"lstat /b: no such file or directory", ErrCodeNotFound},
+		{"open /c: file does not exist", ErrCodeNotFound},
+		{"read /d: i/o timeout", ErrCodeTimeout},
+		{"context deadline exceeded", ErrCodeTimeout},
+		{"decode header: malformed data", ErrCodeDecode},
+		{"read /e: input/output error", ErrCodeIO},
+		{"unexpected EOF", ErrCodeIO},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.msg); got != c.want {
+			t.Errorf("ClassifyError(%q) = %s, want %s", c.msg, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package scan
+
+// maxDefaultOpenFiles caps the derived default so a huge rlimit does not turn
+// the limiter into a no-op that still allocates a large channel buffer.
+const maxDefaultOpenFiles = 1024
+
+// fileLimiter bounds the number of files held open concurrently across all
+// hashing stages. A nil *fileLimiter imposes no limit.
+type fileLimiter struct {
+	slots chan struct{}
+}
+
+// newFileLimiter returns a limiter allowing n concurrently open files.
+// n ≤ 0 derives the limit from the process's soft RLIMIT_NOFILE, leaving half
+// of it for the database, HTTP connections and the walkers' directory reads.
+func newFileLimiter(n int) *fileLimiter {
+	if n <= 0 {
+		n = defaultMaxOpenFiles()
+	}
+	return &fileLimiter{slots: make(chan struct{}, n)}
+}
+
+// defaultMaxOpenFiles derives a limit from the soft open-files rlimit.
+func defaultMaxOpenFiles() int {
+	n := softOpenFilesLimit() / 2
+	if n <= 0 || n > maxDefaultOpenFiles {
+		n = maxDefaultOpenFiles
+	}
+	return n
+}
+
+// acquire blocks until a file may be opened.
+func (l *fileLimiter) acquire() {
+	if l != nil {
+		l.slots <- struct{}{}
+	}
+}
+
+// release returns a slot taken by acquire.
+func (l *fileLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
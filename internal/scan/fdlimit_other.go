@@ -0,0 +1,7 @@
+//go:build !unix
+
+package scan
+
+// softOpenFilesLimit returns 0 on platforms without RLIMIT_NOFILE, so the
+// built-in default applies.
+func softOpenFilesLimit() int { return 0 }
@@ -0,0 +1,20 @@
+//go:build unix
+
+package scan
+
+import (
+	"math"
+	"syscall"
+)
+
+// softOpenFilesLimit returns the soft RLIMIT_NOFILE, or 0 if unknown.
+func softOpenFilesLimit() int {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0
+	}
+	if rl.Cur > math.MaxInt32 { // RLIM_INFINITY
+		return math.MaxInt32
+	}
+	return int(rl.Cur)
+}
@@ -3,26 +3,46 @@ package scan
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 const partialHashBytes = 64 * 1024 // 64 KB
 
-// hashPartial computes the SHA-256 of the first partialHashBytes of the file.
-// Returns hex-encoded hash and bytes read.
-func hashPartial(path string) (hash string, n int64, err error) {
+// Partial hash algorithms. Partial hashes only pre-filter same-size files
+// within a single scan, so a non-cryptographic hash is sufficient there.
+const (
+	PartialHashSHA256 = "sha256"
+	PartialHashXXHash = "xxhash"
+)
+
+// hashPartial hashes the first partialHashBytes of the file with algo
+// (SHA-256 when algo is empty). Returns hex-encoded hash and bytes read.
+// The file is opened only once limiter grants a slot.
+func hashPartial(path, algo string, limiter *fileLimiter) (sum string, n int64, err error) {
+	limiter.acquire()
+	defer limiter.release()
 	f, err := os.Open(path)
 	if err != nil {
 		return "", 0, fmt.Errorf("open: %w", err)
 	}
 	defer f.Close()
 
-	h := sha256.New()
+	var h hash.Hash = sha256.New()
+	if algo == PartialHashXXHash {
+		h = xxhash.New()
+	}
 	n, err = io.CopyN(h, f, partialHashBytes)
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return "", n, fmt.Errorf("read: %w", err)
@@ -30,9 +50,19 @@ func hashPartial(path string) (hash string, n int64, err error) {
 	return hex.EncodeToString(h.Sum(nil)), n, nil
 }
 
-// hashFull computes the SHA-256 of the entire file.
+// errSizeChanged is returned (wrapped) by hashFull when the file no longer
+// has the size recorded at walk time: it is being written to, and its hash
+// would not describe the recorded file.
+var errSizeChanged = errors.New("file changed size while hashing")
+
+// hashFull computes the SHA-256 of the first size bytes of the file — the
+// size recorded at walk time — so the hash always matches the recorded size.
+// A file that turns out shorter, or longer, fails with errSizeChanged.
 // Returns hex-encoded hash and bytes read.
-func hashFull(path string) (hash string, n int64, err error) {
+// The file is opened only once limiter grants a slot.
+func hashFull(path string, size int64, limiter *fileLimiter) (hash string, n int64, err error) {
+	limiter.acquire()
+	defer limiter.release()
 	f, err := os.Open(path)
 	if err != nil {
 		return "", 0, fmt.Errorf("open: %w", err)
@@ -40,21 +70,72 @@ func hashFull(path string) (hash string, n int64, err error) {
 	defer f.Close()
 
 	h := sha256.New()
-	n, err = io.Copy(h, f)
+	n, err = io.CopyN(h, f, size)
+	if err == io.EOF {
+		return "", n, fmt.Errorf("%w: shrank from %d to %d bytes", errSizeChanged, size, n)
+	}
 	if err != nil {
 		return "", n, fmt.Errorf("read: %w", err)
 	}
+	var probe [1]byte
+	if m, _ := f.Read(probe[:]); m > 0 {
+		return "", n, fmt.Errorf("%w: grew past %d bytes", errSizeChanged, size)
+	}
 	return hex.EncodeToString(h.Sum(nil)), n, nil
 }
 
+// ApproximateHashPrefix marks a full hash computed by hashSample. Such
+// hashes are never written to file_cache, and groups keyed by them cannot be
+// deleted until an exact scan confirms them.
+const ApproximateHashPrefix = "approx:"
+
+// IsApproximateHash reports whether hash (or a content_hash derived from it)
+// came from an approximate scan.
+func IsApproximateHash(hash string) bool {
+	return strings.HasPrefix(hash, ApproximateHashPrefix)
+}
+
+// hashSample computes the approximate content key of a file: the SHA-256 of
+// its first and last sample bytes followed by its size, prefixed with
+// ApproximateHashPrefix. Files no larger than 2*sample are hashed whole by
+// hashFull instead, so their hash is exact.
+func hashSample(path string, size, sample int64, limiter *fileLimiter) (hash string, n int64, err error) {
+	if size <= 2*sample {
+		return hashFull(path, size, limiter)
+	}
+	limiter.acquire()
+	defer limiter.release()
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	head, err := io.CopyN(h, f, sample)
+	if err != nil {
+		return "", head, fmt.Errorf("read: %w", err)
+	}
+	tail, err := io.Copy(h, io.NewSectionReader(f, size-sample, sample))
+	if err != nil {
+		return "", head + tail, fmt.Errorf("read: %w", err)
+	}
+	var sizeBuf [8]byte
+	binary.BigEndian.PutUint64(sizeBuf[:], uint64(size))
+	h.Write(sizeBuf[:])
+	return ApproximateHashPrefix + hex.EncodeToString(h.Sum(nil)), head + tail, nil
+}
+
 // RunSizeRouter splits the stream coming out of the partial-hash grouper into
 // two lanes:
-//   - small (Size ≤ partialHashBytes): the partial hash already consumed the
-//     whole file, so it equals the full hash — bypass the full hasher entirely.
-//   - large (Size > partialHashBytes): forward to the full hasher as normal.
+//   - small (Size ≤ threshold): the partial hash already consumed the whole
+//     file, so it equals the full hash — bypass the full hasher entirely.
+//   - large (Size > threshold): forward to the full hasher as normal.
 //
+// threshold is partialHashBytes when partial hashes are SHA-256, and 0 when a
+// different partial algorithm is used (every file then needs a full hash).
 // Both small and large are closed when in is exhausted or ctx is cancelled.
-func RunSizeRouter(ctx context.Context, in <-chan HashedFile, small, large chan<- HashedFile) {
+func RunSizeRouter(ctx context.Context, threshold int64, in <-chan HashedFile, small, large chan<- HashedFile) {
 	go func() {
 		defer close(small)
 		defer close(large)
@@ -64,7 +145,7 @@ func RunSizeRouter(ctx context.Context, in <-chan HashedFile, small, large chan<
 				if !ok {
 					return
 				}
-				if hf.Size <= partialHashBytes {
+				if hf.Size <= threshold {
 					select {
 					case small <- hf:
 					case <-ctx.Done():
@@ -84,11 +165,48 @@ func RunSizeRouter(ctx context.Context, in <-chan HashedFile, small, large chan<
 	}()
 }
 
+// RunPartialHashBypass splits cache misses by size ahead of the partial
+// hashers: files larger than threshold skip the partial stage (and the
+// grouper) and go straight to direct, for the full hasher — in libraries of
+// large media most same-size files are true duplicates, so the partial read
+// is wasted I/O. Smaller files go to partial. Both outputs are closed when in
+// is exhausted or ctx is cancelled.
+func RunPartialHashBypass(ctx context.Context, threshold int64, in <-chan FileInfo, partial chan<- FileInfo, direct chan<- HashedFile) {
+	go func() {
+		defer close(partial)
+		defer close(direct)
+		for {
+			select {
+			case fi, ok := <-in:
+				if !ok {
+					return
+				}
+				if fi.Size > threshold {
+					select {
+					case direct <- HashedFile{FileInfo: fi}:
+					case <-ctx.Done():
+						return
+					}
+				} else {
+					select {
+					case partial <- fi:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
 // RunPartialHashers spawns numWorkers goroutines. Each reads FileInfo from in,
-// computes the partial SHA-256, and sends a HashedFile (with partial hash) to
-// out. out is closed once all workers finish.
+// computes the partial hash using algo, and sends a HashedFile (with partial
+// hash) to out. out is closed once all workers finish.
+// limiter (may be nil) bounds open files shared with the full hashers.
 // report is called for any file that cannot be opened or read.
-func RunPartialHashers(ctx context.Context, numWorkers int, progress *Progress, in <-chan FileInfo, out chan<- HashedFile, report ErrorReporter) {
+func RunPartialHashers(ctx context.Context, numWorkers int, algo string, limiter *fileLimiter, throttle *hashThrottle, progress *Progress, in <-chan FileInfo, out chan<- HashedFile, report ErrorReporter) {
 	var wg sync.WaitGroup
 	for range numWorkers {
 		wg.Add(1)
@@ -103,8 +221,10 @@ func RunPartialHashers(ctx context.Context, numWorkers int, progress *Progress,
 						return
 					}
 					t0 := time.Now()
-					hash, n, err := hashPartial(fi.Path)
-					progress.DiskReadMs.Add(time.Since(t0).Milliseconds())
+					hash, n, err := hashPartial(fi.Path, algo, limiter)
+					busy := time.Since(t0)
+					progress.DiskReadMs.Add(busy.Milliseconds())
+					throttle.pause(ctx, busy)
 					if err != nil {
 						report(fi.Path, "partial_hash", err.Error())
 						continue
@@ -130,8 +250,16 @@ func RunPartialHashers(ctx context.Context, numWorkers int, progress *Progress,
 // (whose Hash field currently holds a partial hash), computes the full
 // SHA-256, and sends an updated HashedFile (with full hash) to out.
 // out is closed once all workers finish.
-// report is called for any file that cannot be opened or read.
-func RunFullHashers(ctx context.Context, numWorkers int, progress *Progress, in <-chan HashedFile, out chan<- HashedFile, report ErrorReporter) {
+// limiter (may be nil) bounds open files shared with the partial hashers;
+// throttle (may be nil) idles each worker between files (see hashThrottle).
+// sample > 0 selects approximate hashing (see hashSample) for files larger
+// than 2*sample.
+// A file whose size changed since the walk is skipped and counted in
+// progress.SizeChangedSkipped. A file that fails otherwise is hashed once
+// more, unless it has vanished, before report is called for it; failed (may
+// be nil) then records its size and partial hash so the groups it may belong
+// to can be flagged incomplete.
+func RunFullHashers(ctx context.Context, numWorkers int, sample int64, limiter *fileLimiter, throttle *hashThrottle, progress *Progress, in <-chan HashedFile, out chan<- HashedFile, report ErrorReporter, failed *failedFiles) {
 	var wg sync.WaitGroup
 	for range numWorkers {
 		wg.Add(1)
@@ -146,9 +274,33 @@ func RunFullHashers(ctx context.Context, numWorkers int, progress *Progress, in
 						return
 					}
 					t0 := time.Now()
-					hash, n, err := hashFull(hf.Path)
-					progress.DiskReadMs.Add(time.Since(t0).Milliseconds())
+					hashOnce := func() (string, int64, error) {
+						if sample > 0 {
+							return hashSample(hf.Path, hf.Size, sample, limiter)
+						}
+						return hashFull(hf.Path, hf.Size, limiter)
+					}
+					hash, n, err := hashOnce()
+					if err != nil && ctx.Err() == nil && !errors.Is(err, errSizeChanged) &&
+						ClassifyError(err.Error()) != ErrCodeNotFound {
+						// Flaky storage often succeeds on a second read.
+						progress.FullHashRetries.Add(1)
+						hash, n, err = hashOnce()
+					}
+					busy := time.Since(t0)
+					progress.DiskReadMs.Add(busy.Milliseconds())
+					throttle.pause(ctx, busy)
+					if errors.Is(err, errSizeChanged) {
+						// Still being written (a log, a recording): skipped
+						// like a recently modified file, not an error.
+						progress.SizeChangedSkipped.Add(1)
+						slog.Warn("file changed size while hashing; skipped", "path", hf.Path, "error", err)
+						continue
+					}
 					if err != nil {
+						if ClassifyError(err.Error()) != ErrCodeNotFound {
+							failed.add(hf.Size, hf.Hash)
+						}
 						report(hf.Path, "full_hash", err.Error())
 						continue
 					}
@@ -0,0 +1,303 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestXXHashPartialKeepsSHA256FullHashes verifies that switching the partial
+// hash to xxhash still records SHA-256 full hashes — including for small
+// files, which would otherwise reuse the partial hash as their full hash.
+func TestXXHashPartialKeepsSHA256FullHashes(t *testing.T) {
+	root := t.TempDir()
+	small := []byte("small duplicate content")
+	large := make([]byte, partialHashBytes+1024)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	for name, data := range map[string][]byte{
+		"small_a.txt": small, "small_b.txt": small,
+		"large_a.bin": large, "large_b.bin": large,
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.PartialHashAlgo = PartialHashXXHash
+	if _, err := New(db, []string{root}, nil, cfg).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	want := map[string]bool{}
+	for _, data := range [][]byte{small, large} {
+		sum := sha256.Sum256(data)
+		want[hex.EncodeToString(sum[:])] = true
+	}
+	rows, err := db.Query(`SELECT content_hash FROM duplicate_groups`)
+	if err != nil {
+		t.Fatalf("query groups: %v", err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		if !want[hash] {
+			t.Errorf("group hash %s is not a SHA-256 of the file content", hash)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("groups: got %d, want 2", n)
+	}
+}
+
+// TestFileLimiterBoundsConcurrency verifies that no more than the configured
+// number of hashers hold a file open at the same time.
+func TestFileLimiterBoundsConcurrency(t *testing.T) {
+	const limit = 3
+	limiter := newFileLimiter(limit)
+
+	var open, peak atomic.Int64
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.acquire()
+			defer limiter.release()
+			n := open.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			open.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("peak concurrently open files: got %d, want ≤ %d", got, limit)
+	}
+}
+
+// TestSkipPartialHashAbove verifies that files above SkipPartialHashAbove are
+// grouped by full hash without being partial-hashed, while smaller files
+// still take the partial-hash path.
+func TestSkipPartialHashAbove(t *testing.T) {
+	root := t.TempDir()
+	large := make([]byte, partialHashBytes*2)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	other := append([]byte{}, large...)
+	other[len(other)-1] ^= 0xff // same size, different content
+	for name, data := range map[string][]byte{
+		"small_a.txt": []byte("small"), "small_b.txt": []byte("small"),
+		"large_a.bin": large, "large_b.bin": large, "large_c.bin": other,
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.SkipPartialHashAbove = partialHashBytes
+	progress := &Progress{}
+	if _, err := New(db, []string{root}, nil, cfg).Run(context.Background(), "manual", progress); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	if got := progress.PartialHashed.Load(); got != 2 {
+		t.Errorf("partial hashed: got %d, want 2 (the small files only)", got)
+	}
+	var groups, files int
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(file_count), 0) FROM duplicate_groups`).Scan(&groups, &files); err != nil {
+		t.Fatal(err)
+	}
+	if groups != 2 || files != 4 {
+		t.Errorf("groups/files: got %d/%d, want 2/4", groups, files)
+	}
+}
+
+// TestApproximateScan verifies that ApproximateSampleBytes groups large files
+// that only share their head, tail and size, flags the group as approximate,
+// and keeps the sampled hashes out of file_cache.
+func TestApproximateScan(t *testing.T) {
+	root := t.TempDir()
+	const sample = partialHashBytes // the partial hash must not see the difference either
+	a := make([]byte, 4*sample)
+	for i := range a {
+		a[i] = byte(i % 251)
+	}
+	b := append([]byte{}, a...)
+	b[2*sample] ^= 0xff // differs only outside the sampled head and tail
+	for name, data := range map[string][]byte{"a.bin": a, "b.bin": b} {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.ApproximateSampleBytes = sample
+	if _, err := New(db, []string{root}, nil, cfg).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	var hash string
+	if err := db.QueryRow(`SELECT content_hash FROM duplicate_groups`).Scan(&hash); err != nil {
+		t.Fatalf("expected one group: %v", err)
+	}
+	if !IsApproximateHash(hash) {
+		t.Errorf("content_hash %q: want %q prefix", hash, ApproximateHashPrefix)
+	}
+	var cached int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM file_cache`).Scan(&cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached != 0 {
+		t.Errorf("file_cache: got %d entries, want none for approximate hashes", cached)
+	}
+
+	// An exact rescan must not group them.
+	if _, err := New(db, []string{root}, nil, DefaultConfig()).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("exact scan: %v", err)
+	}
+	var groups int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM duplicate_groups`).Scan(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if groups != 0 {
+		t.Errorf("after exact scan: got %d groups, want 0", groups)
+	}
+}
+
+// TestHashThrottle verifies the idle time a throttle adds after busy work,
+// that out-of-range percentages disable it, and that cancellation cuts a
+// pause short.
+func TestHashThrottle(t *testing.T) {
+	if newThrottle(0) != nil || newThrottle(100) != nil {
+		t.Fatal("0% and 100% must not throttle")
+	}
+
+	th := newThrottle(50) // idle as long as busy
+	start := time.Now()
+	th.pause(context.Background(), 20*time.Millisecond)
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("pause at 50%% after 20ms busy took %v, want >= 20ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	th.pause(ctx, time.Hour)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("pause ignored cancellation, took %v", d)
+	}
+}
+
+// TestHashFullRecordedSize verifies that hashFull covers exactly the size
+// recorded at walk time and fails with errSizeChanged when the file has since
+// grown or shrunk.
+func TestHashFullRecordedSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "growing.log")
+	data := []byte("recorded content")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	hash, n, err := hashFull(path, int64(len(data)), nil)
+	if err != nil || hash != hex.EncodeToString(sum[:]) || n != int64(len(data)) {
+		t.Fatalf("hashFull = %s, %d, %v; want the SHA-256 of %d bytes", hash, n, err, len(data))
+	}
+	for _, size := range []int64{int64(len(data)) - 1, int64(len(data)) + 1} {
+		if _, _, err := hashFull(path, size, nil); !errors.Is(err, errSizeChanged) {
+			t.Errorf("recorded size %d of a %d-byte file: err = %v, want errSizeChanged", size, len(data), err)
+		}
+	}
+}
+
+// TestExactScanKeepsApproximateGroupsOutsideIt verifies that an exact scan
+// drops only the approximate groups it re-checked: those under a root it
+// walked and outside its exclude paths.
+func TestExactScanKeepsApproximateGroupsOutsideIt(t *testing.T) {
+	const sample = partialHashBytes
+	rootA, rootB := t.TempDir(), t.TempDir()
+	subB := filepath.Join(rootB, "sub")
+	for i, dir := range []string{rootA, subB} {
+		a := make([]byte, 4*sample)
+		for j := range a {
+			a[j] = byte((j + i) % 251)
+		}
+		b := append([]byte{}, a...)
+		b[2*sample] ^= 0xff // differs only outside the sampled head and tail
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string][]byte{"a.bin": a, "b.bin": b} {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.ApproximateSampleBytes = sample
+	if _, err := New(db, []string{rootA, rootB}, nil, cfg).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("approximate scan: %v", err)
+	}
+	groupsUnder := func(dir string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`
+			SELECT COUNT(DISTINCT g.id) FROM duplicate_groups g
+			JOIN duplicate_files f ON f.group_id = g.id
+			WHERE g.content_hash LIKE ? AND f.path LIKE ?`,
+			ApproximateHashPrefix+"%", dir+"/%").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if groupsUnder(rootA) != 1 || groupsUnder(rootB) != 1 {
+		t.Fatalf("approximate groups: %d under a, %d under b; want 1 each", groupsUnder(rootA), groupsUnder(rootB))
+	}
+
+	exact := func(roots, excludes []string) {
+		t.Helper()
+		if _, err := New(db, roots, excludes, DefaultConfig()).Run(context.Background(), "manual", &Progress{}); err != nil {
+			t.Fatalf("exact scan: %v", err)
+		}
+	}
+	exact([]string{rootA}, nil)
+	if groupsUnder(rootA) != 0 || groupsUnder(rootB) != 1 {
+		t.Errorf("after scanning a: %d under a, %d under b; want 0 and 1", groupsUnder(rootA), groupsUnder(rootB))
+	}
+	exact([]string{rootA, rootB}, []string{subB})
+	if groupsUnder(rootB) != 1 {
+		t.Errorf("a scan excluding %s dropped its approximate group", subB)
+	}
+	exact([]string{rootA, rootB}, nil)
+	if groupsUnder(rootB) != 0 {
+		t.Errorf("after scanning b: %d approximate groups under it, want 0", groupsUnder(rootB))
+	}
+}
@@ -0,0 +1,133 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// failedFile is a candidate whose full hash failed: its size and, when the
+// partial stage computed one, its partial hash.
+type failedFile struct {
+	size    int64
+	partial string
+}
+
+// failedFiles collects the candidates whose full hash failed during a scan.
+// Such a file was dropped, so a group of the same size and partial hash may
+// be missing a copy. The zero value is not usable; a nil *failedFiles
+// ignores adds.
+type failedFiles struct {
+	mu    sync.Mutex
+	files map[failedFile]struct{}
+}
+
+func newFailedFiles() *failedFiles {
+	return &failedFiles{files: make(map[failedFile]struct{})}
+}
+
+func (f *failedFiles) add(size int64, partial string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.files[failedFile{size, partial}] = struct{}{}
+	f.mu.Unlock()
+}
+
+func (f *failedFiles) list() []failedFile {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]failedFile, 0, len(f.files))
+	for ff := range f.files {
+		out = append(out, ff)
+	}
+	return out
+}
+
+// markIncompleteGroups flags the groups the failed files may belong to, and
+// clears the flag everywhere else, so it always reflects the latest completed
+// scan. A group of the same size is flagged when the partial hash (with algo)
+// of one of its files matches the failed file's; when either partial hash is
+// unknown — the failed file skipped the partial stage, or none of the group's
+// files can be read — size alone decides, which may flag a group the file
+// did not belong to but never misses one it did. Gone groups are left alone.
+// It returns the number of groups flagged.
+func markIncompleteGroups(ctx context.Context, db *sql.DB, failed []failedFile, algo string) (int64, error) {
+	// Partial hashes of the candidate groups, read before the transaction
+	// so the writer connection is not held across file reads.
+	partials := map[int64]string{} // group id → partial hash, "" if unknown
+	var flag []int64
+	for _, ff := range failed {
+		rows, err := db.QueryContext(ctx, `
+			SELECT g.id, f.path FROM duplicate_groups g
+			JOIN duplicate_files f ON f.group_id = g.id
+			WHERE g.file_size = ? AND g.status != 'gone'
+			ORDER BY g.id, f.id`, ff.size)
+		if err != nil {
+			return 0, err
+		}
+		var ids []int64
+		paths := map[int64][]string{}
+		for rows.Next() {
+			var id int64
+			var path string
+			if err := rows.Scan(&id, &path); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if len(paths[id]) == 0 {
+				ids = append(ids, id)
+			}
+			paths[id] = append(paths[id], path)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			if ff.partial == "" {
+				flag = append(flag, id)
+				continue
+			}
+			partial, known := partials[id]
+			if !known {
+				for _, p := range paths[id] {
+					if sum, _, err := hashPartial(p, algo, nil); err == nil {
+						partial = sum
+						break
+					}
+				}
+				partials[id] = partial
+			}
+			if partial == "" || partial == ff.partial {
+				flag = append(flag, id)
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE duplicate_groups SET incomplete = 0 WHERE incomplete = 1`); err != nil {
+		return 0, err
+	}
+	var flagged int64
+	for _, id := range flag {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE duplicate_groups SET incomplete = 1 WHERE id = ? AND incomplete = 0`, id)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		flagged += n
+	}
+	return flagged, tx.Commit()
+}
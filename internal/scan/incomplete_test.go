@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMarkIncompleteGroups verifies that groups sharing a size with a failed
+// full hash of unknown partial hash are flagged, and that the next pass
+// without failures clears them.
+func TestMarkIncompleteGroups(t *testing.T) {
+	db := mustOpenDB(t)
+	ctx := context.Background()
+	scanID := mustInsertScan(t, db)
+	in := make(chan HashedFile, 4)
+	for _, f := range []struct {
+		path, hash string
+		size       int64
+	}{{"/a1", "aaaa", 100}, {"/a2", "aaaa", 100}, {"/b1", "bbbb", 200}, {"/b2", "bbbb", 200}} {
+		in <- HashedFile{FileInfo: FileInfo{Path: f.path, Size: f.size, MTime: time.Unix(1000, 0)}, Hash: f.hash}
+	}
+	close(in)
+	if _, err := RunDBWriter(ctx, db, scanID, 100, in, nil, WriterOptions{}); err != nil {
+		t.Fatalf("RunDBWriter: %v", err)
+	}
+	incomplete := func(hash string) bool {
+		t.Helper()
+		var v bool
+		if err := db.QueryRow(`SELECT incomplete FROM duplicate_groups WHERE content_hash = ?`, hash).Scan(&v); err != nil {
+			t.Fatalf("read %s: %v", hash, err)
+		}
+		return v
+	}
+
+	failed := newFailedFiles()
+	failed.add(200, "")
+	failed.add(200, "")
+	n, err := markIncompleteGroups(ctx, db, failed.list(), "")
+	if err != nil {
+		t.Fatalf("markIncompleteGroups: %v", err)
+	}
+	if n != 1 || incomplete("aaaa") || !incomplete("bbbb") {
+		t.Errorf("flagged %d; aaaa=%v bbbb=%v, want only bbbb", n, incomplete("aaaa"), incomplete("bbbb"))
+	}
+
+	if _, err := markIncompleteGroups(ctx, db, nil, ""); err != nil {
+		t.Fatalf("markIncompleteGroups: %v", err)
+	}
+	if incomplete("bbbb") {
+		t.Error("a scan without failures should clear the flag")
+	}
+}
+
+// TestMarkIncompleteGroupsByPartialHash verifies that of two groups sharing
+// the failed file's size, only the one whose partial hash matches is
+// flagged, and that a group none of whose files can be read is flagged on
+// size alone.
+func TestMarkIncompleteGroupsByPartialHash(t *testing.T) {
+	db := mustOpenDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	const size = 2 * partialHashBytes
+	contents := map[string]string{
+		"aaaa": strings.Repeat("a", size),
+		"bbbb": strings.Repeat("b", size),
+		"cccc": strings.Repeat("c", size),
+	}
+	in := make(chan HashedFile, 6)
+	for hash, content := range contents {
+		for _, n := range []string{"1", "2"} {
+			p := filepath.Join(dir, hash+n)
+			if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			in <- HashedFile{FileInfo: FileInfo{Path: p, Size: size, MTime: time.Unix(1000, 0)}, Hash: hash}
+		}
+	}
+	close(in)
+	if _, err := RunDBWriter(ctx, db, mustInsertScan(t, db), 100, in, nil, WriterOptions{}); err != nil {
+		t.Fatalf("RunDBWriter: %v", err)
+	}
+	for _, n := range []string{"1", "2"} {
+		if err := os.Remove(filepath.Join(dir, "cccc"+n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A third copy of aaaa whose full hash failed after its partial hash.
+	failedCopy := filepath.Join(dir, "aaaa3")
+	if err := os.WriteFile(failedCopy, []byte(contents["aaaa"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	partial, _, err := hashPartial(failedCopy, PartialHashXXHash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := newFailedFiles()
+	failed.add(size, partial)
+	n, err := markIncompleteGroups(ctx, db, failed.list(), PartialHashXXHash)
+	if err != nil {
+		t.Fatalf("markIncompleteGroups: %v", err)
+	}
+
+	want := map[string]bool{"aaaa": true, "bbbb": false, "cccc": true}
+	for hash, w := range want {
+		var got bool
+		if err := db.QueryRow(`SELECT incomplete FROM duplicate_groups WHERE content_hash = ?`, hash).Scan(&got); err != nil {
+			t.Fatalf("read %s: %v", hash, err)
+		}
+		if got != w {
+			t.Errorf("group %s incomplete = %v, want %v", hash, got, w)
+		}
+	}
+	if n != 2 {
+		t.Errorf("flagged %d groups, want 2", n)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 )
@@ -16,11 +18,17 @@ var ErrAlreadyRunning = errors.New("a scan is already in progress")
 // ErrNoActiveScan is returned when cancel is called with no scan running.
 var ErrNoActiveScan = errors.New("no scan is currently running")
 
+// ErrNothingToResume is returned by Resume when the most recent scan did not
+// fail or get cancelled.
+var ErrNothingToResume = errors.New("no interrupted scan to resume")
+
 // ActiveScan holds live information about the running scan.
 type ActiveScan struct {
 	ID          int64
 	StartedAt   time.Time
 	TriggeredBy string
+	// ResumedFrom is the interrupted scan this one resumes (0 = fresh scan).
+	ResumedFrom int64
 	Progress    *Progress
 }
 
@@ -35,11 +43,16 @@ type Manager struct {
 
 	active   *ActiveScan
 	cancelFn context.CancelFunc
+	done     chan struct{} // closed when the active scan's goroutine exits
+
+	reporting bool // a report-only scan (Report) is running
 }
 
-// NewManager creates a Manager. parentCtx is used as the base for scan
-// contexts; cancelling it cancels any running scan (e.g. on server shutdown).
+// NewManager creates a Manager. The context passed to Start is used as the
+// base for the scan context; on server shutdown call Shutdown to cancel any
+// running scan and wait for it to be finalised.
 func NewManager(db *sql.DB, roots, excludes []string, cfg Config) *Manager {
+	warnInternalDirs(roots, cfg.InternalDirs)
 	return &Manager{
 		db:       db,
 		roots:    roots,
@@ -49,29 +62,145 @@ func NewManager(db *sql.DB, roots, excludes []string, cfg Config) *Manager {
 }
 
 // UpdateConfig replaces the roots/excludes/cfg used for future scans.
-// It does NOT affect a currently running scan.
+// It does NOT affect a currently running scan. A nil cfg.ReadDB keeps the
+// current read pool: it is a process resource, not a setting, so callers
+// rebuilding cfg from config.Config don't have it. The same goes for a nil
+// cfg.InternalDirs, which only change on restart.
 func (m *Manager) UpdateConfig(roots, excludes []string, cfg Config) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if cfg.ReadDB == nil {
+		cfg.ReadDB = m.cfg.ReadDB
+	}
+	if cfg.InternalDirs == nil {
+		cfg.InternalDirs = m.cfg.InternalDirs
+	}
+	if !slices.Equal(roots, m.roots) {
+		warnInternalDirs(roots, cfg.InternalDirs)
+	}
 	m.roots = roots
 	m.excludes = excludes
 	m.cfg = cfg
 }
 
+// warnInternalDirs logs a warning for each internal directory that lies
+// inside (or is) a scan root. Scans skip it anyway, but it usually means
+// trash_dir was put on the scanned volume by mistake.
+func warnInternalDirs(roots, dirs []string) {
+	for _, d := range dirs {
+		d = filepath.Clean(d)
+		for _, root := range roots {
+			root = filepath.Clean(root)
+			if d == root || pathWithin(d, root) {
+				slog.Warn("internal directory is inside a scan path; it will be excluded from scans",
+					"dir", d, "scan_path", root)
+			}
+		}
+	}
+}
+
+// Config returns the pipeline configuration future scans will use.
+func (m *Manager) Config() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg
+}
+
+// Paths returns the roots and exclude paths future scans will use.
+func (m *Manager) Paths() (roots, excludes []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.roots...), append([]string{}, m.excludes...)
+}
+
 // Start launches an asynchronous scan. Returns an ActiveScan snapshot or
 // ErrAlreadyRunning if a scan is already in progress.
 func (m *Manager) Start(parentCtx context.Context, triggeredBy string) (*ActiveScan, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.startLocked(parentCtx, triggeredBy, 0, nil, nil)
+}
 
-	if m.active != nil {
+// StartExcluding is Start with excludes skipped in addition to the
+// configured exclude paths, for this scan only.
+func (m *Manager) StartExcluding(parentCtx context.Context, triggeredBy string, excludes []string) (*ActiveScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.startLocked(parentCtx, triggeredBy, 0, nil, excludes)
+}
+
+// StartStream is Start with stream attached: every duplicate group the scan
+// writes is also sent to stream, which is closed when the scan finishes
+// (or right away when the scan cannot start).
+func (m *Manager) StartStream(parentCtx context.Context, triggeredBy string, stream *GroupStream) (*ActiveScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	active, err := m.startLocked(parentCtx, triggeredBy, 0, stream, nil)
+	if err != nil {
+		stream.close()
+	}
+	return active, err
+}
+
+// Resume starts a scan that continues the most recent scan when it was
+// interrupted (failed — e.g. marked so by MarkStaleScansFailed after a
+// restart — or cancelled). Returns ErrNothingToResume otherwise.
+//
+// The pipeline itself has no persisted intermediate state: a resumed scan
+// walks the roots again. What it reuses is the interrupted scan's discovered
+// set as recorded in file_cache — RunDBWriter flushes every fully hashed file
+// there as it goes, so those files are cache hits (keyed on path, size and
+// mtime) and only the files the interrupted scan never reached are hashed.
+// The new scan_history row records resumed_from_scan_id.
+func (m *Manager) Resume(parentCtx context.Context, triggeredBy string) (*ActiveScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active != nil || m.reporting {
 		return nil, ErrAlreadyRunning
 	}
 
+	var prevID int64
+	var prevStatus string
+	err := m.db.QueryRowContext(parentCtx, `
+		SELECT id, status FROM scan_history
+		ORDER BY started_at DESC, id DESC LIMIT 1`).Scan(&prevID, &prevStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNothingToResume
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find scan to resume: %w", err)
+	}
+	if prevStatus != "failed" && prevStatus != "cancelled" {
+		return nil, ErrNothingToResume
+	}
+
+	var cached int64
+	_ = m.db.QueryRowContext(parentCtx,
+		`SELECT COUNT(*) FROM file_cache WHERE scan_id = ?`, prevID).Scan(&cached)
+	slog.Info("resuming scan", "from_scan_id", prevID, "previous_status", prevStatus, "cached_files", cached)
+
+	return m.startLocked(parentCtx, triggeredBy, prevID, nil, nil)
+}
+
+// startLocked creates the scan record and launches the scan goroutine.
+// stream may be nil. m.mu must be held.
+func (m *Manager) startLocked(parentCtx context.Context, triggeredBy string, resumedFrom int64, stream *GroupStream, extraExcludes []string) (*ActiveScan, error) {
+	if m.active != nil || m.reporting {
+		return nil, ErrAlreadyRunning
+	}
+
+	excludes := m.excludes
+	if len(extraExcludes) > 0 {
+		excludes = append(slices.Clone(m.excludes), extraExcludes...)
+	}
+	scanner := New(m.db, m.roots, excludes, m.cfg)
+	scanner.stream = stream
+
 	// Create the scan_history record NOW so the ID is available immediately
 	// in the HTTP response, before the goroutine begins executing.
 	startedAt := time.Now()
-	scanID, err := insertScanRecord(m.db, startedAt, triggeredBy)
+	scanID, err := insertScanRecord(m.db, startedAt, triggeredBy, resumedFrom, scanner.configSnapshot())
 	if err != nil {
 		return nil, fmt.Errorf("create scan record: %w", err)
 	}
@@ -83,14 +212,19 @@ func (m *Manager) Start(parentCtx context.Context, triggeredBy string) (*ActiveS
 		ID:          scanID,
 		StartedAt:   startedAt,
 		TriggeredBy: triggeredBy,
+		ResumedFrom: resumedFrom,
 		Progress:    progress,
 	}
+	done := make(chan struct{})
 	m.active = active
 	m.cancelFn = cancel
-
-	scanner := New(m.db, m.roots, m.excludes, m.cfg)
+	m.done = done
 
 	go func() {
+		defer close(done)
+		if stream != nil {
+			defer stream.close()
+		}
 		if err := scanner.runScan(scanCtx, scanID, triggeredBy, startedAt, progress); err != nil && !errors.Is(err, context.Canceled) {
 			slog.Error("scan run error", "error", err)
 		}
@@ -98,12 +232,48 @@ func (m *Manager) Start(parentCtx context.Context, triggeredBy string) (*ActiveS
 		m.mu.Lock()
 		m.active = nil
 		m.cancelFn = nil
+		m.done = nil
 		m.mu.Unlock()
 	}()
 
 	return active, nil
 }
 
+// Report runs a report-only scan (see Scanner.Report) synchronously with the
+// current roots and config, and returns the duplicate groups it found. It
+// counts as the active scan for the single-scan invariant, so it returns
+// ErrAlreadyRunning while another scan runs and blocks Start until done, but
+// it is not listed as ActiveScan: it has no scan_history row. Cancel ctx to
+// stop it.
+func (m *Manager) Report(ctx context.Context) ([]ReportGroup, error) {
+	m.mu.Lock()
+	if m.active != nil || m.reporting {
+		m.mu.Unlock()
+		return nil, ErrAlreadyRunning
+	}
+	m.reporting = true
+	scanner := New(m.db, m.roots, m.excludes, m.cfg)
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.reporting = false
+		m.mu.Unlock()
+	}()
+
+	slog.Info("report-only scan started", "roots", scanner.roots)
+	progress := &Progress{}
+	groups, err := scanner.Report(ctx, progress)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("report-only scan finished",
+		"files_discovered", progress.FilesDiscovered.Load(),
+		"duplicate_groups", len(groups),
+		"errors", progress.Errors.Load())
+	return groups, nil
+}
+
 // Cancel stops the currently running scan. Returns ErrNoActiveScan if idle.
 func (m *Manager) Cancel() (*ActiveScan, error) {
 	m.mu.Lock()
@@ -118,6 +288,28 @@ func (m *Manager) Cancel() (*ActiveScan, error) {
 	return &snap, nil
 }
 
+// Shutdown cancels the running scan, if any, and waits until it has been
+// finalised (its scan_history row marked cancelled) or ctx expires. It is
+// meant for process shutdown, so the database is not closed under a scan.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.active == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	slog.Info("cancelling running scan for shutdown", "id", m.active.ID)
+	m.cancelFn()
+	done := m.done
+	m.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ActiveScan returns a snapshot of the running scan, or nil when idle.
 func (m *Manager) ActiveScan() *ActiveScan {
 	m.mu.Lock()
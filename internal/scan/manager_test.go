@@ -0,0 +1,238 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitIdle polls until the manager has no active scan.
+func waitIdle(t *testing.T, m *Manager) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for m.ActiveScan() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("scan did not finish within timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestResumeAfterRestartIsCacheWarm simulates a server restart in the middle
+// of a scan: the files the interrupted scan had already hashed are in
+// file_cache, the rest are not. A resumed scan must hash only the missing
+// files and record which scan it resumed.
+func TestResumeAfterRestartIsCacheWarm(t *testing.T) {
+	db := mustOpenDB(t)
+	root := t.TempDir()
+	const numFiles = 200
+	createSyntheticTree(t, root, numFiles)
+
+	m := NewManager(db, []string{root}, nil, DefaultConfig())
+	ctx := context.Background()
+
+	if _, err := m.Resume(ctx, "manual"); !errors.Is(err, ErrNothingToResume) {
+		t.Fatalf("Resume with no scans: got %v, want ErrNothingToResume", err)
+	}
+
+	first, err := m.Start(ctx, "manual")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitIdle(t, m)
+
+	if _, err := m.Resume(ctx, "manual"); !errors.Is(err, ErrNothingToResume) {
+		t.Fatalf("Resume after completed scan: got %v, want ErrNothingToResume", err)
+	}
+
+	// Pretend the process died before the last 50 files were hashed, then
+	// restarted: the row is left 'running' and startup marks it failed.
+	const unhashed = 50
+	if _, err := db.Exec(`
+		DELETE FROM file_cache WHERE path IN (
+			SELECT path FROM file_cache ORDER BY path DESC LIMIT ?)`, unhashed); err != nil {
+		t.Fatalf("trim file_cache: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE scan_history SET status = 'running' WHERE id = ?`, first.ID); err != nil {
+		t.Fatalf("reset scan status: %v", err)
+	}
+	if err := MarkStaleScansFailed(db); err != nil {
+		t.Fatalf("MarkStaleScansFailed: %v", err)
+	}
+
+	resumed, err := m.Resume(ctx, "manual")
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if resumed.ResumedFrom != first.ID {
+		t.Errorf("ResumedFrom: got %d, want %d", resumed.ResumedFrom, first.ID)
+	}
+	waitIdle(t, m)
+
+	var status string
+	var hits, misses, resumedFrom int64
+	if err := db.QueryRow(`
+		SELECT status, cache_hits, cache_misses, resumed_from_scan_id
+		FROM scan_history WHERE id = ?`, resumed.ID,
+	).Scan(&status, &hits, &misses, &resumedFrom); err != nil {
+		t.Fatalf("query resumed scan: %v", err)
+	}
+	if status != "completed" {
+		t.Errorf("status: got %q, want completed", status)
+	}
+	if hits != numFiles-unhashed || misses != unhashed {
+		t.Errorf("cache hits/misses: got %d/%d, want %d/%d", hits, misses, numFiles-unhashed, unhashed)
+	}
+	if resumedFrom != first.ID {
+		t.Errorf("resumed_from_scan_id: got %d, want %d", resumedFrom, first.ID)
+	}
+}
+
+// TestReportWritesNothing verifies that a report-only scan returns the
+// duplicate groups without touching scan_history, file_cache or the groups.
+func TestReportWritesNothing(t *testing.T) {
+	db := mustOpenDB(t)
+	root := t.TempDir()
+	createSyntheticTree(t, root, 200) // 10 contents × 20 copies
+
+	m := NewManager(db, []string{root}, nil, DefaultConfig())
+	groups, err := m.Report(context.Background())
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(groups) != 10 {
+		t.Fatalf("groups: got %d, want 10", len(groups))
+	}
+	for _, g := range groups {
+		if len(g.Paths) != 20 || g.ReclaimableBytes != 19*1024 {
+			t.Errorf("group %s: %d paths, %d reclaimable; want 20, %d",
+				g.ContentHash, len(g.Paths), g.ReclaimableBytes, 19*1024)
+		}
+	}
+
+	for _, table := range []string{"scan_history", "file_cache", "duplicate_groups", "duplicate_files"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("%s: got %d rows, want 0", table, n)
+		}
+	}
+}
+
+// TestStartRecordsConfigSnapshot verifies that the scan_history row created
+// by Start carries the roots, excludes and settings the scan ran with.
+func TestStartRecordsConfigSnapshot(t *testing.T) {
+	db := mustOpenDB(t)
+	root := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.FullHashers = 3
+	cfg.PartialHashAlgo = PartialHashXXHash
+	m := NewManager(db, []string{root}, []string{root + "/skip"}, cfg)
+
+	active, err := m.Start(context.Background(), "manual")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitIdle(t, m)
+
+	var raw string
+	if err := db.QueryRow(`SELECT config_snapshot FROM scan_history WHERE id = ?`, active.ID).Scan(&raw); err != nil {
+		t.Fatalf("read config_snapshot: %v", err)
+	}
+	var got ConfigSnapshot
+	if err := json.Unmarshal([]byte(raw), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", raw, err)
+	}
+	if len(got.ScanPaths) != 1 || got.ScanPaths[0] != root ||
+		len(got.ExcludePaths) != 1 || got.ExcludePaths[0] != root+"/skip" {
+		t.Errorf("paths = %v excluding %v, want [%s] excluding [%s/skip]", got.ScanPaths, got.ExcludePaths, root, root)
+	}
+	if got.FullHashers != 3 || got.PartialHashAlgo != PartialHashXXHash {
+		t.Errorf("full_hashers = %d, partial_hash_algo = %q; want 3, %q", got.FullHashers, got.PartialHashAlgo, PartialHashXXHash)
+	}
+}
+
+// TestStartExcludingIsOneOff verifies that StartExcluding skips the extra
+// directories for that scan only: the next Start walks them again.
+func TestStartExcludingIsOneOff(t *testing.T) {
+	db := mustOpenDB(t)
+	root := t.TempDir()
+	createSyntheticTree(t, filepath.Join(root, "keep"), 40)
+	createSyntheticTree(t, filepath.Join(root, "busy"), 40)
+
+	m := NewManager(db, []string{root}, nil, DefaultConfig())
+	ctx := context.Background()
+
+	countFiles := func(scanID int64) int64 {
+		t.Helper()
+		var n int64
+		if err := db.QueryRow(`SELECT files_discovered FROM scan_history WHERE id = ?`, scanID).Scan(&n); err != nil {
+			t.Fatalf("read files_discovered: %v", err)
+		}
+		return n
+	}
+
+	busy := filepath.Join(root, "busy")
+	first, err := m.StartExcluding(ctx, "manual", []string{busy})
+	if err != nil {
+		t.Fatalf("StartExcluding: %v", err)
+	}
+	waitIdle(t, m)
+	if n := countFiles(first.ID); n != 40 {
+		t.Errorf("excluding scan discovered %d files, want 40", n)
+	}
+	var raw string
+	if err := db.QueryRow(`SELECT config_snapshot FROM scan_history WHERE id = ?`, first.ID).Scan(&raw); err != nil {
+		t.Fatalf("read config_snapshot: %v", err)
+	}
+	var snap ConfigSnapshot
+	if err := json.Unmarshal([]byte(raw), &snap); err != nil {
+		t.Fatalf("unmarshal %q: %v", raw, err)
+	}
+	if len(snap.ExcludePaths) != 1 || snap.ExcludePaths[0] != busy {
+		t.Errorf("snapshot exclude_paths = %v, want [%s]", snap.ExcludePaths, busy)
+	}
+
+	second, err := m.Start(ctx, "manual")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitIdle(t, m)
+	if n := countFiles(second.ID); n != 80 {
+		t.Errorf("next scan discovered %d files, want 80", n)
+	}
+}
+
+// TestInternalDirsSurviveUpdateConfig verifies that a trash directory inside
+// the scan root is skipped, including after a config update that does not
+// carry InternalDirs.
+func TestInternalDirsSurviveUpdateConfig(t *testing.T) {
+	db := mustOpenDB(t)
+	root := t.TempDir()
+	createSyntheticTree(t, filepath.Join(root, "keep"), 40)
+	trashDir := filepath.Join(root, "trash")
+	createSyntheticTree(t, trashDir, 40)
+
+	cfg := DefaultConfig()
+	cfg.InternalDirs = []string{trashDir + string(filepath.Separator)}
+	m := NewManager(db, []string{root}, nil, cfg)
+	m.UpdateConfig([]string{root}, nil, DefaultConfig())
+
+	active, err := m.Start(context.Background(), "manual")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitIdle(t, m)
+	var n int64
+	if err := db.QueryRow(`SELECT files_discovered FROM scan_history WHERE id = ?`, active.ID).Scan(&n); err != nil {
+		t.Fatalf("read files_discovered: %v", err)
+	}
+	if n != 40 {
+		t.Errorf("scan discovered %d files, want 40 (trash dir skipped)", n)
+	}
+}
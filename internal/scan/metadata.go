@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataHashPrefix marks a key computed by metadataKey in a metadata-only
+// scan. Like approximate hashes, such keys are never written to file_cache,
+// and groups keyed by them cannot be deleted until a content scan confirms
+// them.
+const MetadataHashPrefix = "meta:"
+
+// IsMetadataHash reports whether hash (or a content_hash derived from it)
+// came from a metadata-only scan.
+func IsMetadataHash(hash string) bool {
+	return strings.HasPrefix(hash, MetadataHashPrefix)
+}
+
+// isUnverifiedHash reports whether hash does not prove identical content:
+// it came from an approximate or a metadata-only scan.
+func isUnverifiedHash(hash string) bool {
+	return IsApproximateHash(hash) || IsMetadataHash(hash)
+}
+
+// metadataKey derives the grouping key of a file from its basename, size and
+// mtime (whole seconds, which copies across filesystems usually preserve)
+// without reading it.
+func metadataKey(fi FileInfo) string {
+	h := sha256.New()
+	h.Write([]byte(filepath.Base(fi.Path)))
+	var buf [17]byte // NUL separator, size, mtime
+	binary.BigEndian.PutUint64(buf[1:9], uint64(fi.Size))
+	binary.BigEndian.PutUint64(buf[9:], uint64(fi.MTime.Unix()))
+	h.Write(buf[:])
+	return MetadataHashPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// RunMetadataKeyer stands in for every hashing stage in a metadata-only scan:
+// it keys each candidate from in by metadataKey and sends it to out.
+// out is closed when in is exhausted or ctx is cancelled.
+func RunMetadataKeyer(ctx context.Context, in <-chan FileInfo, out chan<- HashedFile) {
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case fi, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- HashedFile{FileInfo: fi, Hash: metadataKey(fi)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMetadataOnlyScan verifies that MetadataOnly groups files by name, size
+// and mtime without reading them, keeps the keys out of file_cache, and that
+// the next content scan drops the metadata groups.
+func TestMetadataOnlyScan(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for path, data := range map[string]string{
+		"a/photo.jpg": "same size, different bytes 1",
+		"b/photo.jpg": "same size, different bytes 2",
+		"c/other.jpg": "same size, different bytes 3",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.MetadataOnly = true
+	if _, err := New(db, []string{root}, nil, cfg).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("metadata scan: %v", err)
+	}
+
+	var hash string
+	var count int
+	if err := db.QueryRow(`SELECT content_hash, file_count FROM duplicate_groups`).Scan(&hash, &count); err != nil {
+		t.Fatalf("expected one group: %v", err)
+	}
+	if !IsMetadataHash(hash) || count != 2 {
+		t.Errorf("group %q with %d files: want %q prefix and the two photo.jpg", hash, count, MetadataHashPrefix)
+	}
+	var cached int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM file_cache`).Scan(&cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached != 0 {
+		t.Errorf("file_cache: got %d entries, want none for metadata keys", cached)
+	}
+
+	// A content scan must drop the group: the files differ.
+	if _, err := New(db, []string{root}, nil, DefaultConfig()).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("content scan: %v", err)
+	}
+	var groups int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM duplicate_groups`).Scan(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if groups != 0 {
+		t.Errorf("after content scan: got %d groups, want 0", groups)
+	}
+}
+
+// TestContentScanKeepsMetadataGroupsOutsideIt verifies that a content scan
+// of one subtree leaves the metadata groups of another alone.
+func TestContentScanKeepsMetadataGroupsOutsideIt(t *testing.T) {
+	rootA, rootB := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, root := range []string{rootA, rootB} {
+		for path, data := range map[string]string{
+			"x/photo.jpg": "same size, different bytes 1",
+			"y/photo.jpg": "same size, different bytes 2",
+		} {
+			full := filepath.Join(root, path)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(full, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	db := mustOpenDB(t)
+	cfg := DefaultConfig()
+	cfg.MetadataOnly = true
+	if _, err := New(db, []string{rootA}, nil, cfg).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("metadata scan: %v", err)
+	}
+	if _, err := New(db, []string{rootB}, nil, DefaultConfig()).Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("content scan: %v", err)
+	}
+	var groups int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM duplicate_groups WHERE content_hash LIKE ?`,
+		MetadataHashPrefix+"%").Scan(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if groups != 1 {
+		t.Errorf("metadata groups after scanning another root: %d, want 1", groups)
+	}
+}
@@ -8,6 +8,7 @@ import "sync/atomic"
 type Progress struct {
 	// Phase 1 — hashing pipeline
 	FilesDiscovered atomic.Int64
+	BytesDiscovered atomic.Int64 // total size of all discovered files
 	CandidatesFound atomic.Int64
 	PartialHashed   atomic.Int64
 	FullHashed      atomic.Int64
@@ -15,6 +16,31 @@ type Progress struct {
 	CacheHits       atomic.Int64
 	CacheMisses     atomic.Int64
 	Errors          atomic.Int64
+	// RecentlyModifiedSkipped counts discovered files left out because their
+	// mtime fell inside Config.SkipRecentlyModified.
+	RecentlyModifiedSkipped atomic.Int64
+	// HeadFiltered counts size candidates dropped by RunHeadFilter because
+	// no other file of their size shared their leading bytes.
+	HeadFiltered atomic.Int64
+	// QuarantineSkipped counts walked files left out because their path is
+	// quarantined after repeated hash failures (Config.QuarantineAfter).
+	QuarantineSkipped atomic.Int64
+	// SizeChangedSkipped counts candidates left out because their size
+	// changed between the walk and their full hash (see hashFull).
+	SizeChangedSkipped atomic.Int64
+	// FullHashRetries counts full hashes tried a second time after an error
+	// (see RunFullHashers).
+	FullHashRetries atomic.Int64
+	// IncompleteGroups is the number of groups flagged incomplete at the end
+	// of the scan (see markIncompleteGroups).
+	IncompleteGroups atomic.Int64
+	// FilesAdded counts candidates with no file_cache row at all, i.e. not
+	// hashed by an earlier scan: new files, or files that just gained a
+	// same-size partner (see cachedHashes).
+	FilesAdded atomic.Int64
+	// WalkFinishedAt is a Unix timestamp set when every discovered file has
+	// been counted (0 = walk still running).
+	WalkFinishedAt atomic.Int64
 	// Phase 2 — DB write
 	// Phase2StartedAt is a Unix timestamp set when Phase 2 begins (0 = not started).
 	Phase2StartedAt atomic.Int64
@@ -30,3 +56,13 @@ type Progress struct {
 // counter, emits a structured warning log, and persists the event to the
 // scan_errors table so it is visible via GET /api/scans/:id.
 type ErrorReporter func(path, stage, errMsg string)
+
+// Phase2Percent returns the share of duplicate groups written so far as an
+// integer percentage (0–100). It is 0 before Phase 2 starts.
+func (p *Progress) Phase2Percent() int64 {
+	total := p.GroupsTotal.Load()
+	if p.Phase2StartedAt.Load() == 0 || total == 0 {
+		return 0
+	}
+	return p.GroupsWritten.Load() * 100 / total
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+// recordHashFailure notes in hash_failures that path failed to hash in scan
+// scanID. Failures count once per scan; when a path has failed in after
+// consecutive scans it is quarantined and later scans skip it. A vanished
+// file (NOT_FOUND) is not a failure of the file and is not recorded.
+func recordHashFailure(db *sql.DB, scanID int64, after int, path, stage, code, errMsg string, now int64) {
+	if code == ErrCodeNotFound {
+		return
+	}
+	if _, err := db.Exec(`
+		INSERT INTO hash_failures
+		    (path, stage, code, error, failures, first_failed_at, last_failed_at, last_scan_id)
+		VALUES (?, ?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+		    stage = excluded.stage, code = excluded.code, error = excluded.error,
+		    last_failed_at = excluded.last_failed_at,
+		    failures = failures + (last_scan_id != excluded.last_scan_id),
+		    last_scan_id = excluded.last_scan_id`,
+		path, stage, code, errMsg, now, now, scanID); err != nil {
+		slog.Warn("record hash failure", "path", path, "error", err)
+		return
+	}
+	res, err := db.Exec(`
+		UPDATE hash_failures SET quarantined_at = ?
+		WHERE path = ? AND quarantined_at IS NULL AND failures >= ?`,
+		now, path, after)
+	if err != nil {
+		slog.Warn("quarantine path", "path", path, "error", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		slog.Warn("path quarantined after repeated hash failures", "path", path, "scans", after, "code", code)
+	}
+}
+
+// clearRecoveredHashFailures drops the failure streaks of paths that did not
+// fail in the completed scan scanID. Quarantined paths were not attempted and
+// stay quarantined.
+func clearRecoveredHashFailures(ctx context.Context, db *sql.DB, scanID int64) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM hash_failures
+		WHERE quarantined_at IS NULL AND last_scan_id != ?`, scanID)
+	return err
+}
+
+// loadQuarantined returns the set of quarantined paths.
+func loadQuarantined(ctx context.Context, db *sql.DB) (map[string]struct{}, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT path FROM hash_failures WHERE quarantined_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	paths := make(map[string]struct{})
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths[p] = struct{}{}
+	}
+	return paths, rows.Err()
+}
+
+// RunQuarantineFilter forwards the walked files from in to out, dropping the
+// quarantined paths and counting them in progress.QuarantineSkipped. They
+// are not counted as discovered. out is closed when in is exhausted or ctx
+// is cancelled.
+func RunQuarantineFilter(ctx context.Context, quarantined map[string]struct{}, progress *Progress, in <-chan FileInfo, out chan<- FileInfo) {
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case fi, ok := <-in:
+				if !ok {
+					return
+				}
+				if _, skip := quarantined[fi.Path]; skip {
+					progress.QuarantineSkipped.Add(1)
+					continue
+				}
+				select {
+				case out <- fi:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
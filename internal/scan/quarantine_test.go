@@ -0,0 +1,73 @@
+package scan
+
+import (
+	"context"
+	"testing"
+)
+
+// TestHashFailureQuarantine verifies that a path is quarantined after failing
+// in the configured number of consecutive scans (counting once per scan),
+// that a streak broken by a completed scan is dropped, and that the walk
+// filter skips quarantined paths.
+func TestHashFailureQuarantine(t *testing.T) {
+	db := mustOpenDB(t)
+	ctx := context.Background()
+	s1, s2 := mustInsertScan(t, db), mustInsertScan(t, db)
+	failures := func(path string) (n int, quarantined bool) {
+		t.Helper()
+		err := db.QueryRow(`SELECT failures, quarantined_at IS NOT NULL FROM hash_failures WHERE path = ?`, path).
+			Scan(&n, &quarantined)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		return n, quarantined
+	}
+
+	recordHashFailure(db, s1, 2, "/bad", "full_hash", ErrCodeIO, "read: input/output error", 100)
+	recordHashFailure(db, s1, 2, "/bad", "full_hash", ErrCodeIO, "read: input/output error", 101)
+	recordHashFailure(db, s1, 2, "/flaky", "partial_hash", ErrCodeIO, "read: input/output error", 100)
+	recordHashFailure(db, s1, 2, "/gone", "partial_hash", ErrCodeNotFound, "open /gone: no such file or directory", 100)
+	if n, q := failures("/bad"); n != 1 || q {
+		t.Errorf("/bad after one scan = %d failures, quarantined %v; want 1, false", n, q)
+	}
+	var gone int
+	db.QueryRow(`SELECT COUNT(*) FROM hash_failures WHERE path = '/gone'`).Scan(&gone)
+	if gone != 0 {
+		t.Error("a vanished file should not be recorded")
+	}
+
+	recordHashFailure(db, s2, 2, "/bad", "full_hash", ErrCodeIO, "read: input/output error", 200)
+	if n, q := failures("/bad"); n != 2 || !q {
+		t.Errorf("/bad after two scans = %d failures, quarantined %v; want 2, true", n, q)
+	}
+	if err := clearRecoveredHashFailures(ctx, db, s2); err != nil {
+		t.Fatalf("clearRecoveredHashFailures: %v", err)
+	}
+	var left int
+	db.QueryRow(`SELECT COUNT(*) FROM hash_failures`).Scan(&left)
+	if left != 1 {
+		t.Errorf("%d rows left, want only the quarantined /bad", left)
+	}
+
+	quarantined, err := loadQuarantined(ctx, db)
+	if err != nil {
+		t.Fatalf("loadQuarantined: %v", err)
+	}
+	in := make(chan FileInfo, 2)
+	out := make(chan FileInfo, 2)
+	in <- FileInfo{Path: "/bad", Size: 1}
+	in <- FileInfo{Path: "/good", Size: 1}
+	close(in)
+	progress := &Progress{}
+	RunQuarantineFilter(ctx, quarantined, progress, in, out)
+	var got []string
+	for fi := range out {
+		got = append(got, fi.Path)
+	}
+	if len(got) != 1 || got[0] != "/good" {
+		t.Errorf("filter passed %v, want [/good]", got)
+	}
+	if n := progress.QuarantineSkipped.Load(); n != 1 {
+		t.Errorf("QuarantineSkipped = %d, want 1", n)
+	}
+}
@@ -0,0 +1,187 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	internaldb "github.com/eargollo/ditto/internal/db"
+)
+
+// vanishedGroup is a group the scan did not find again, with the files it
+// lists that no longer exist on disk.
+type vanishedGroup struct {
+	id      int64
+	size    int64
+	files   int
+	missing []int64  // duplicate_files ids
+	paths   []string // paths of missing, for the audit log
+}
+
+// walkedRoots tracks which scan roots the walker could read. A root it could
+// not (unmounted, permission denied) yielded no files, so its files must not
+// be taken for deleted. A nil *walkedRoots ignores reports.
+type walkedRoots struct {
+	mu     sync.Mutex
+	roots  []string
+	failed map[string]struct{}
+}
+
+func newWalkedRoots(roots []string) *walkedRoots {
+	cleaned := make([]string, len(roots))
+	for i, r := range roots {
+		cleaned[i] = filepath.Clean(r)
+	}
+	return &walkedRoots{roots: cleaned, failed: make(map[string]struct{})}
+}
+
+// watch wraps report so that a walk error on a root marks it as not walked.
+func (w *walkedRoots) watch(report ErrorReporter) ErrorReporter {
+	if w == nil {
+		return report
+	}
+	return func(path, stage, errMsg string) {
+		for _, r := range w.roots {
+			if path == r {
+				w.mu.Lock()
+				w.failed[r] = struct{}{}
+				w.mu.Unlock()
+				break
+			}
+		}
+		report(path, stage, errMsg)
+	}
+}
+
+// list returns the roots that were walked: those neither failed themselves
+// nor nested under a root that failed, whose walk would have covered them.
+func (w *walkedRoots) list() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []string
+	for _, r := range w.roots {
+		if !underAny(r, w.failed) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// underAny reports whether path is one of dirs or lies below one of them.
+func underAny(path string, dirs map[string]struct{}) bool {
+	for dir := range dirs {
+		if path == dir || pathWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileVanishedGroups revisits the groups a completed scan did not write
+// — typically because files were deleted outside ditto — and drops the files
+// that no longer exist from them. Only files under roots, the roots the scan
+// walked, are checked: a file under an unreadable root may still exist. A
+// group left with one file is resolved; a group left with none gets status
+// 'gone'. Groups whose files all still exist (e.g. now under an excluded
+// path) are left alone, as are resolved and gone groups. Each change is
+// recorded in audit_log with actor "scan".
+func reconcileVanishedGroups(ctx context.Context, db *sql.DB, scanID int64, roots []string) error {
+	walked := make(map[string]struct{}, len(roots))
+	for _, r := range roots {
+		walked[filepath.Clean(r)] = struct{}{}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.file_size, f.id, f.path
+		FROM duplicate_groups g JOIN duplicate_files f ON f.group_id = g.id
+		WHERE (g.last_seen_scan_id IS NULL OR g.last_seen_scan_id != ?)
+		  AND g.status NOT IN ('resolved','gone')
+		ORDER BY g.id`, scanID)
+	if err != nil {
+		return err
+	}
+	var groups []*vanishedGroup
+	for rows.Next() {
+		var groupID, size, fileID int64
+		var path string
+		if err := rows.Scan(&groupID, &size, &fileID, &path); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].id != groupID {
+			groups = append(groups, &vanishedGroup{id: groupID, size: size})
+		}
+		g := groups[len(groups)-1]
+		g.files++
+		if !underAny(path, walked) {
+			continue
+		}
+		if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			g.missing = append(g.missing, fileID)
+			g.paths = append(g.paths, path)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var changed []*vanishedGroup
+	for _, g := range groups {
+		if len(g.missing) == 0 {
+			continue
+		}
+		for _, fileID := range g.missing {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM duplicate_files WHERE id = ?`, fileID); err != nil {
+				return err
+			}
+		}
+		left := int64(g.files - len(g.missing))
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE duplicate_groups
+			SET file_count = ?, reclaimable_bytes = ?, updated_at = ?,
+			    status = CASE WHEN ? = 0 THEN 'gone'
+			                  WHEN ? = 1 THEN 'resolved'
+			                  ELSE status END,
+			    resolved_at = CASE WHEN ? = 1 THEN ? ELSE resolved_at END
+			WHERE id = ?`,
+			left, g.size*max(left-1, 0), now, left, left, left, now, g.id); err != nil {
+			return err
+		}
+		changed = append(changed, g)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, g := range changed {
+		action := "prune_missing"
+		switch g.files - len(g.missing) {
+		case 0:
+			action = "gone"
+		case 1:
+			action = "auto_resolve"
+		}
+		if err := internaldb.RecordAudit(ctx, db, g.id, action, "scan",
+			map[string]any{"missing_paths": g.paths}); err != nil {
+			slog.Warn("reconcile: record audit", "group_id", g.id, "error", err)
+		}
+	}
+	if len(changed) > 0 {
+		slog.Info("reconciled groups with files deleted outside ditto", "groups", len(changed))
+	}
+	return nil
+}
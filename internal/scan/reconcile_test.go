@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReconcileVanishedGroups verifies that files deleted outside ditto are
+// dropped from groups the scan did not see, that a group left with one file
+// is resolved and one left with none is gone, and that a gone group found
+// again by a later scan is unresolved once more.
+func TestReconcileVanishedGroups(t *testing.T) {
+	db := mustOpenDB(t)
+	dir := t.TempDir()
+	groups := map[string][]string{
+		"aaaa": {"a1", "a2"},       // loses one file → resolved
+		"bbbb": {"b1", "b2"},       // loses both → gone
+		"cccc": {"c1", "c2", "c3"}, // loses one of three → still unresolved
+	}
+	write := func(hashes map[string][]string) {
+		t.Helper()
+		scanID := mustInsertScan(t, db)
+		in := make(chan HashedFile, 16)
+		for h, names := range hashes {
+			for _, n := range names {
+				p := filepath.Join(dir, n)
+				if err := os.WriteFile(p, []byte(h), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				in <- HashedFile{FileInfo: FileInfo{Path: p, Size: 4, MTime: time.Unix(1000, 0)}, Hash: h}
+			}
+		}
+		close(in)
+		if _, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, WriterOptions{}); err != nil {
+			t.Fatalf("RunDBWriter: %v", err)
+		}
+	}
+	type state struct {
+		status string
+		files  int
+	}
+	groupState := func(hash string) state {
+		t.Helper()
+		var s state
+		if err := db.QueryRow(`SELECT status, file_count FROM duplicate_groups WHERE content_hash = ?`, hash).
+			Scan(&s.status, &s.files); err != nil {
+			t.Fatalf("read group %s: %v", hash, err)
+		}
+		return s
+	}
+
+	write(groups)
+	for _, n := range []string{"a2", "b1", "b2", "c3"} {
+		if err := os.Remove(filepath.Join(dir, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reconcileVanishedGroups(context.Background(), db, mustInsertScan(t, db), []string{dir}); err != nil {
+		t.Fatalf("reconcileVanishedGroups: %v", err)
+	}
+
+	want := map[string]state{
+		"aaaa": {"resolved", 1},
+		"bbbb": {"gone", 0},
+		"cccc": {"unresolved", 2},
+	}
+	for h, w := range want {
+		if got := groupState(h); got != w {
+			t.Errorf("group %s = %+v, want %+v", h, got, w)
+		}
+	}
+	var audits int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE actor = 'scan'`).Scan(&audits); err != nil {
+		t.Fatalf("count audit_log: %v", err)
+	}
+	if audits != 3 {
+		t.Errorf("audit entries = %d, want 3", audits)
+	}
+
+	write(map[string][]string{"bbbb": {"b1", "b2"}})
+	if got := groupState("bbbb"); got != (state{"unresolved", 2}) {
+		t.Errorf("gone group found again = %+v, want unresolved with 2 files", got)
+	}
+}
+
+// TestReconcileSkipsUnwalkedRoots verifies that a scan with one of its two
+// roots missing (e.g. an unmounted disk) reconciles only groups under the
+// root it walked, leaving the files under the missing root alone.
+func TestReconcileSkipsUnwalkedRoots(t *testing.T) {
+	db := mustOpenDB(t)
+	dir := t.TempDir()
+	rootA, rootB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	files := map[string]string{
+		filepath.Join(rootA, "x"):  "spans both roots",
+		filepath.Join(rootB, "x"):  "spans both roots",
+		filepath.Join(rootA, "y1"): "only under a",
+		filepath.Join(rootA, "y2"): "only under a",
+	}
+	for p, content := range files {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.ReconcileVanishedGroups = true
+	scanner := New(db, []string{rootA, rootB}, nil, cfg)
+	if _, err := scanner.Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+
+	if err := os.RemoveAll(rootB); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(rootA, "y2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanner.Run(context.Background(), "manual", &Progress{}); err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+
+	groupOf := func(path string) (status string, files int) {
+		t.Helper()
+		if err := db.QueryRow(`
+			SELECT g.status, g.file_count FROM duplicate_groups g
+			JOIN duplicate_files f ON f.group_id = g.id WHERE f.path = ?`, path).Scan(&status, &files); err != nil {
+			t.Fatalf("group of %s: %v", path, err)
+		}
+		return status, files
+	}
+	if status, n := groupOf(filepath.Join(rootB, "x")); status != "unresolved" || n != 2 {
+		t.Errorf("group under the missing root = %s with %d files, want unresolved with 2", status, n)
+	}
+	if status, n := groupOf(filepath.Join(rootA, "y1")); status != "resolved" || n != 1 {
+		t.Errorf("group under the walked root = %s with %d files, want resolved with 1", status, n)
+	}
+}
+
+func TestWalkedRoots(t *testing.T) {
+	w := newWalkedRoots([]string{"/data", "/data/photos", "/backup/", "/other"})
+	report := w.watch(func(string, string, string) {})
+	report("/data", "walk", "no such file or directory")
+	report("/other/file", "walk", "permission denied")
+	if got := fmt.Sprint(w.list()); got != "[/backup /other]" {
+		t.Errorf("walked roots = %s, want [/backup /other]", got)
+	}
+}
@@ -3,8 +3,10 @@ package scan
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"sync"
 	"time"
 )
@@ -14,6 +16,7 @@ import (
 // and corpus sizes without querying the database.
 func logTelemetry(scanID, durationSecs int64, p *Progress) {
 	filesDiscovered := p.FilesDiscovered.Load()
+	bytesDiscovered := p.BytesDiscovered.Load()
 	candidates := p.CandidatesFound.Load()
 	cacheHits := p.CacheHits.Load()
 	cacheMisses := p.CacheMisses.Load()
@@ -50,9 +53,11 @@ func logTelemetry(scanID, durationSecs int64, p *Progress) {
 		"scan_id", scanID,
 		"duration_secs", durationSecs,
 		"files_discovered", filesDiscovered,
+		"bytes_discovered_mb", fmt.Sprintf("%.1f", float64(bytesDiscovered)/1024/1024),
 		"files_per_sec", fmt.Sprintf("%.1f", filesPerSec),
 		"candidate_pct", fmt.Sprintf("%.1f%%", candidatePct),
 		"cache_hit_pct", fmt.Sprintf("%.1f%%", cacheHitPct),
+		"files_added", p.FilesAdded.Load(),
 		"duplicate_groups", dupGroups,
 		"bytes_read_mb", fmt.Sprintf("%.1f", float64(bytesRead)/1024/1024),
 		"hash_throughput_mbps", fmt.Sprintf("%.1f", hashThroughputMBps),
@@ -67,16 +72,23 @@ func logTelemetry(scanID, durationSecs int64, p *Progress) {
 
 // newErrorReporter returns an ErrorReporter that:
 //  1. increments p.Errors
-//  2. emits a slog.Warn with stage, path, and error message
+//  2. emits a slog.Warn with stage, path, code, and error message
 //  3. inserts a row into scan_errors so the error is visible via the API
-func newErrorReporter(db *sql.DB, scanID int64, p *Progress) ErrorReporter {
+//  4. with quarantineAfter > 0, records hashing errors in hash_failures
+//     (see recordHashFailure)
+func newErrorReporter(db *sql.DB, scanID int64, quarantineAfter int, p *Progress) ErrorReporter {
 	return func(path, stage, errMsg string) {
 		p.Errors.Add(1)
-		slog.Warn("scan error", "stage", stage, "path", path, "error", errMsg)
+		code := ClassifyError(errMsg)
+		now := time.Now().Unix()
+		slog.Warn("scan error", "stage", stage, "path", path, "code", code, "error", errMsg)
 		_, _ = db.Exec(
-			`INSERT INTO scan_errors (scan_id, path, stage, error, occurred_at)
-			 VALUES (?, ?, ?, ?, ?)`,
-			scanID, path, stage, errMsg, time.Now().Unix())
+			`INSERT INTO scan_errors (scan_id, path, stage, code, error, occurred_at)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			scanID, path, stage, code, errMsg, now)
+		if quarantineAfter > 0 && stage != "walk" {
+			recordHashFailure(db, scanID, quarantineAfter, path, stage, code, errMsg, now)
+		}
 	}
 }
 
@@ -100,11 +112,83 @@ type Config struct {
 	PartialHashers int
 	FullHashers    int
 	BatchSize      int
+	// CacheBatchSize is the number of paths per cache-check SELECT … IN (…)
+	// query (0 = 500, clamped to 999).
+	CacheBatchSize int
+	// PartialHashAlgo selects the partial-hash algorithm: PartialHashSHA256
+	// (default when empty) or PartialHashXXHash. Full hashes are always SHA-256.
+	PartialHashAlgo string
+	// MaxOpenFiles bounds the files held open at once across the partial and
+	// full hashers. 0 derives a limit from the soft RLIMIT_NOFILE.
+	MaxOpenFiles int
+	// SniffContentTypes classifies files with unknown extensions by their
+	// leading bytes when writing groups (one extra 512-byte read per file).
+	SniffContentTypes bool
+	// GroupByExtension adds the lower-cased file extension to the grouping
+	// key so same-content files with different extensions form separate groups.
+	GroupByExtension bool
+	// SizeTolerance, when > 0, replaces exact-size candidate matching with
+	// RunSizeToleranceAccumulator: sizes within this fraction (0.01 = 1%)
+	// of each other pair up. Expect many more files to be hashed, and no
+	// more groups: grouping still needs equal content.
+	SizeTolerance float64
+	// CPUPercent, when between 1 and 99, makes every partial and full hash
+	// worker idle between files so it hashes at most this share of the time
+	// (see hashThrottle). It multiplies with the worker counts.
+	CPUPercent int
+	// HeadBytes, when > 0, refines exact-size candidates by their first
+	// HeadBytes bytes before the cache check (see RunHeadFilter): one small
+	// read per candidate, including cached ones, in exchange for fewer
+	// partial hashes. Ignored with SizeTolerance, whose candidates differ in
+	// size.
+	HeadBytes int
+	// SkipPartialHashAbove sends candidates larger than this many bytes
+	// straight to the full hasher, skipping the partial hash (0 = off).
+	SkipPartialHashAbove int64
+	// ApproximateSampleBytes, when > 0, makes the full hash of larger files
+	// cover only their first and last this many bytes plus their size (see
+	// hashSample). Fast, but the resulting groups are approximate.
+	ApproximateSampleBytes int64
+	// MetadataOnly skips every hashing stage: files sharing a size are
+	// grouped by basename, size and mtime instead (see metadataKey). Nothing
+	// is read, but the resulting groups are unverified.
+	MetadataOnly bool
+	// SummarizePermissionErrors collapses the walker's permission-denied
+	// errors into a single summary error reported when the walk ends, so a
+	// protected tree does not flood scan_errors (see summarizePermissionErrors).
+	SummarizePermissionErrors bool
+	// FileInventory stores the full hash of every fully hashed file that is
+	// in no duplicate group in file_inventory (see writeInventory).
+	FileInventory bool
+	// ReconcileVanishedGroups drops files deleted outside ditto from the
+	// groups a completed scan did not find again, resolving groups left with
+	// one file and marking those left with none 'gone' (see
+	// reconcileVanishedGroups).
+	ReconcileVanishedGroups bool
+	// CacheFirst looks every walked file up in file_cache before size
+	// accumulation, sending hits straight to the writer (see
+	// RunCacheFirstCheck and RunCacheFirstAccumulator). Faster for warm
+	// scans of a mostly static tree; ignored with SizeTolerance or HeadBytes,
+	// whose candidate filters cannot see the cached files.
+	CacheFirst bool
+	// QuarantineAfter, when > 0, tracks per-path hash failures across scans
+	// in hash_failures and skips a path once it has failed to hash in this
+	// many consecutive scans, until its row is cleared (see
+	// recordHashFailure and RunQuarantineFilter).
+	QuarantineAfter int
+	// SkipRecentlyModified excludes files whose mtime is within this window
+	// of the scan start — they are likely still being written and would hash
+	// to a transient state (0 = off).
+	SkipRecentlyModified time.Duration
 	// ReadDB is an optional separate connection pool for read-only cache
 	// lookups. When non-nil it allows CacheCheckers to run truly in parallel
 	// (the main DB is locked to MaxOpenConns(1) for write safety).
 	// If nil, the scanner's main DB is used as a fallback.
 	ReadDB *sql.DB
+	// InternalDirs are directories ditto writes to itself (trash_dir,
+	// archive_dir). Every scan skips them on top of its exclude paths, so
+	// files already pending deletion never form duplicate groups.
+	InternalDirs []string
 }
 
 // DefaultConfig returns sensible defaults.
@@ -124,6 +208,7 @@ type Scanner struct {
 	roots        []string
 	excludePaths []string
 	cfg          Config
+	stream       *GroupStream // optional; receives groups as they are written
 }
 
 // New creates a Scanner.
@@ -141,7 +226,7 @@ func (s *Scanner) runScan(ctx context.Context, scanID int64, triggeredBy string,
 // pipeline, and returns the row ID. Intended for direct use in tests.
 func (s *Scanner) Run(ctx context.Context, triggeredBy string, progress *Progress) (int64, error) {
 	startedAt := time.Now()
-	scanID, err := insertScanRecord(s.db, startedAt, triggeredBy)
+	scanID, err := insertScanRecord(s.db, startedAt, triggeredBy, 0, s.configSnapshot())
 	if err != nil {
 		return 0, fmt.Errorf("create scan record: %w", err)
 	}
@@ -186,6 +271,13 @@ func (s *Scanner) execute(ctx context.Context, scanID int64, triggeredBy string,
 		"files_hashed", progress.FullHashed.Load(),
 		"cache_hits", progress.CacheHits.Load(),
 		"cache_misses", progress.CacheMisses.Load(),
+		"files_added", progress.FilesAdded.Load(),
+		"skipped_recently_modified", progress.RecentlyModifiedSkipped.Load(),
+		"head_filtered", progress.HeadFiltered.Load(),
+		"skipped_quarantined", progress.QuarantineSkipped.Load(),
+		"skipped_size_changed", progress.SizeChangedSkipped.Load(),
+		"full_hash_retries", progress.FullHashRetries.Load(),
+		"incomplete_groups", progress.IncompleteGroups.Load(),
 		"errors", progress.Errors.Load())
 
 	return runErr
@@ -194,10 +286,119 @@ func (s *Scanner) execute(ctx context.Context, scanID int64, triggeredBy string,
 // runPipeline wires all pipeline stages and blocks until the DB writer
 // finishes or ctx is cancelled.
 func (s *Scanner) runPipeline(ctx context.Context, scanID int64, progress *Progress) error {
-	excludes := make(map[string]struct{}, len(s.excludePaths))
+	// Wire the error reporter: logs warnings and persists to scan_errors.
+	failed := newFailedFiles()
+	walked := newWalkedRoots(s.roots)
+	finalOut := s.startStages(ctx, progress, newErrorReporter(s.db, scanID, s.cfg.QuarantineAfter, progress), failed, walked)
+
+	// Progress reporter — flushes counters to DB every second. Its final
+	// flush completes before the scan is finalised.
+	reporterStop := make(chan struct{})
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		progressReporter(ctx, s.db, scanID, progress, reporterStop)
+	}()
+	defer func() {
+		close(reporterStop)
+		<-reporterDone
+	}()
+
+	stats, err := RunDBWriter(ctx, s.db, scanID, s.cfg.BatchSize, finalOut, progress,
+		WriterOptions{SniffContentTypes: s.cfg.SniffContentTypes, GroupByExtension: s.cfg.GroupByExtension, Stream: s.stream, FileInventory: s.cfg.FileInventory})
+	if err != nil {
+		return err
+	}
+	if !s.cfg.MetadataOnly {
+		// This scan re-grouped every file it found by content; what an
+		// earlier metadata-only scan found there is now confirmed or
+		// disproved.
+		scope := &scanScope{roots: walked.list(), excludes: s.walkExcludes()}
+		if err := dropUnverifiedGroups(ctx, s.db, MetadataHashPrefix, scope); err != nil {
+			return fmt.Errorf("drop metadata groups: %w", err)
+		}
+		if s.cfg.ApproximateSampleBytes == 0 {
+			// Likewise for an exact scan and approximate groups.
+			if err := dropUnverifiedGroups(ctx, s.db, ApproximateHashPrefix, scope); err != nil {
+				return fmt.Errorf("drop approximate groups: %w", err)
+			}
+		}
+	}
+	// A scan that found nothing (e.g. its roots are unmounted) must not take
+	// every file for deleted.
+	if s.cfg.ReconcileVanishedGroups && progress.FilesDiscovered.Load() > 0 {
+		if err := reconcileVanishedGroups(ctx, s.db, scanID, walked.list()); err != nil {
+			return fmt.Errorf("reconcile vanished groups: %w", err)
+		}
+	}
+	if s.cfg.QuarantineAfter > 0 && progress.FilesDiscovered.Load() > 0 {
+		if err := clearRecoveredHashFailures(ctx, s.db, scanID); err != nil {
+			return fmt.Errorf("clear recovered hash failures: %w", err)
+		}
+	}
+	if progress.FilesDiscovered.Load() > 0 {
+		n, err := markIncompleteGroups(ctx, s.db, failed.list(), s.cfg.PartialHashAlgo)
+		if err != nil {
+			return fmt.Errorf("mark incomplete groups: %w", err)
+		}
+		progress.IncompleteGroups.Store(n)
+		if n > 0 {
+			slog.Warn("groups may be missing a copy that failed to hash", "scan_id", scanID, "groups", n)
+		}
+	}
+
+	// Store final aggregate stats back into progress so finaliseScanRecord
+	// can write them.
+	progress.FullHashed.Store(stats.FilesHashed)
+	// Update duplicate counters via a dedicated field — reuse CandidatesFound
+	// temporarily to carry group count (written to DB by finaliseScanRecord).
+	_ = stats // finaliseScanRecord queries the DB for final counts
+
+	return nil
+}
+
+// Report runs the pipeline in report-only mode: the duplicate groups found
+// are returned instead of written, and nothing is recorded — no scan_history
+// row, no file_cache updates, no scan_errors (per-file errors are only
+// logged and counted in progress). file_cache is still read, so files
+// hashed by earlier scans are not hashed again.
+func (s *Scanner) Report(ctx context.Context, progress *Progress) ([]ReportGroup, error) {
+	report := func(path, stage, errMsg string) {
+		progress.Errors.Add(1)
+		slog.Warn("scan error", "stage", stage, "path", path, "error", errMsg)
+	}
+	finalOut := s.startStages(ctx, progress, report, nil, nil)
+
+	stats, err := RunDBWriter(ctx, s.db, 0, s.cfg.BatchSize, finalOut, progress, WriterOptions{
+		SniffContentTypes: s.cfg.SniffContentTypes,
+		GroupByExtension:  s.cfg.GroupByExtension,
+		ReportOnly:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	progress.FullHashed.Store(stats.FilesHashed)
+	return stats.Report, nil
+}
+
+// walkExcludes returns the paths the walk skips: the exclude paths and the
+// internal directories (database, trash, archive).
+func (s *Scanner) walkExcludes() map[string]struct{} {
+	excludes := make(map[string]struct{}, len(s.excludePaths)+len(s.cfg.InternalDirs))
 	for _, p := range s.excludePaths {
 		excludes[p] = struct{}{}
 	}
+	for _, p := range s.cfg.InternalDirs {
+		excludes[filepath.Clean(p)] = struct{}{}
+	}
+	return excludes
+}
+
+// startStages launches every stage from the walk to the final merge and
+// returns the channel of fully hashed candidates for the DB writer. failed
+// (may be nil) collects the sizes of candidates whose full hash failed.
+func (s *Scanner) startStages(ctx context.Context, progress *Progress, report ErrorReporter, failed *failedFiles, walked *walkedRoots) <-chan HashedFile {
+	excludes := s.walkExcludes()
 
 	// walkOut is large so walkers can run far ahead of the hashing pipeline,
 	// decoupling walk throughput from hash throughput (~48 MB for 1M FileInfos).
@@ -220,9 +421,6 @@ func (s *Scanner) runPipeline(ctx context.Context, scanID int64, progress *Progr
 	fullOut     := make(chan HashedFile, finalBufSize)
 	finalOut    := make(chan HashedFile, finalBufSize)
 
-	// Wire the error reporter: logs warnings and persists to scan_errors.
-	report := newErrorReporter(s.db, scanID, progress)
-
 	// Use a dedicated read pool for cache lookups when available — lets N
 	// CacheCheckers run truly in parallel (main DB is MaxOpenConns(1)).
 	cacheDB := s.db
@@ -230,37 +428,96 @@ func (s *Scanner) runPipeline(ctx context.Context, scanID int64, progress *Progr
 		cacheDB = s.cfg.ReadDB
 	}
 
+	// One limiter shared by both hashing stages so their combined open files
+	// stay below the process rlimit.
+	limiter := newFileLimiter(s.cfg.MaxOpenFiles)
+	throttle := newThrottle(s.cfg.CPUPercent)
+
 	// Start pipeline stages (each manages its own goroutine(s)).
-	go Walk(ctx, s.roots, excludes, s.cfg.Walkers, walkOut, report)
-	RunSizeAccumulator(ctx, progress, walkOut, candidates)
-	RunCacheCheck(ctx, cacheDB, progress, s.cfg.CacheCheckers, candidates, cacheHits, cacheMisses)
-	RunPartialHashers(ctx, s.cfg.PartialHashers, progress, cacheMisses, partialOut, report)
+	// Quarantined paths are dropped between the walkers and walkOut.
+	walkerOut := walkOut
+	if s.cfg.QuarantineAfter > 0 {
+		quarantined, err := loadQuarantined(ctx, s.db)
+		if err != nil {
+			slog.Warn("load quarantined paths; none skipped", "error", err)
+		}
+		if len(quarantined) > 0 {
+			walkerOut = make(chan FileInfo, pipelineBufSize)
+			RunQuarantineFilter(ctx, quarantined, progress, walkerOut, walkOut)
+		}
+	}
+	// walked sees each walk error before it can be summarized away.
+	if s.cfg.SummarizePermissionErrors {
+		walkReport, flush := summarizePermissionErrors(report)
+		go func() {
+			Walk(ctx, s.roots, excludes, s.cfg.Walkers, walkerOut, walked.watch(walkReport))
+			flush()
+		}()
+	} else {
+		go Walk(ctx, s.roots, excludes, s.cfg.Walkers, walkerOut, walked.watch(report))
+	}
+	if s.cfg.MetadataOnly {
+		// No file is read: candidates go straight from the size
+		// accumulator to the writer, keyed by their metadata.
+		RunSizeAccumulator(ctx, progress, s.cfg.SkipRecentlyModified, walkOut, candidates)
+		RunMetadataKeyer(ctx, candidates, finalOut)
+		return finalOut
+	}
+	// cachedDirect carries cache-first misses that share a size with a
+	// cached file to the full hasher.
+	var cachedDirect chan HashedFile
+	if s.cfg.CacheFirst && s.cfg.SizeTolerance <= 0 && s.cfg.HeadBytes <= 0 {
+		cached := NewCachedSizes()
+		walkMisses := make(chan FileInfo, pipelineBufSize)
+		cachedDirect = make(chan HashedFile, pipelineBufSize)
+		RunCacheFirstCheck(ctx, cacheDB, progress, s.cfg.CacheCheckers, s.cfg.CacheBatchSize, s.cfg.SkipRecentlyModified, cached, walkOut, cacheHits, walkMisses)
+		RunCacheFirstAccumulator(ctx, progress, s.cfg.SkipRecentlyModified, cached, walkMisses, cacheMisses, cachedDirect)
+	} else if s.cfg.SizeTolerance > 0 {
+		RunSizeToleranceAccumulator(ctx, progress, s.cfg.SkipRecentlyModified, s.cfg.SizeTolerance, walkOut, candidates)
+	} else if s.cfg.HeadBytes > 0 {
+		sized := make(chan FileInfo, pipelineBufSize)
+		RunSizeAccumulator(ctx, progress, s.cfg.SkipRecentlyModified, walkOut, sized)
+		RunHeadFilter(ctx, s.cfg.PartialHashers, s.cfg.HeadBytes, limiter, progress, sized, candidates)
+	} else {
+		RunSizeAccumulator(ctx, progress, s.cfg.SkipRecentlyModified, walkOut, candidates)
+	}
+	if cachedDirect == nil {
+		RunCacheCheck(ctx, cacheDB, progress, s.cfg.CacheCheckers, s.cfg.CacheBatchSize, candidates, cacheHits, cacheMisses)
+	}
+	// Files above SkipPartialHashAbove bypass partial hashing entirely and
+	// join the large files on their way to the full hasher.
+	partialIn := cacheMisses
+	var directOut chan HashedFile
+	if s.cfg.SkipPartialHashAbove > 0 {
+		partialIn = make(chan FileInfo, pipelineBufSize)
+		directOut = make(chan HashedFile, pipelineBufSize)
+		RunPartialHashBypass(ctx, s.cfg.SkipPartialHashAbove, cacheMisses, partialIn, directOut)
+	}
+	RunPartialHashers(ctx, s.cfg.PartialHashers, s.cfg.PartialHashAlgo, limiter, throttle, progress, partialIn, partialOut, report)
 	RunPartialHashGrouper(ctx, partialOut, filteredOut)
 	// Route: files ≤ 64KB already fully hashed at partial stage → bypass full hasher.
 	// Larger files go through the priority queue (smallest first) then full hash.
-	RunSizeRouter(ctx, filteredOut, smallOut, largeOut)
-	RunSizePriorityQueue(ctx, largeOut, priorityOut)
-	RunFullHashers(ctx, s.cfg.FullHashers, progress, priorityOut, fullOut, report)
-	mergeHashedFiles(ctx, finalOut, cacheHits, fullOut, smallOut)
-
-	// Progress reporter — flushes counters to DB every second.
-	reporterStop := make(chan struct{})
-	go progressReporter(ctx, s.db, scanID, progress, reporterStop)
-	defer close(reporterStop)
-
-	stats, err := RunDBWriter(ctx, s.db, scanID, s.cfg.BatchSize, finalOut, progress)
-	if err != nil {
-		return err
+	// A non-SHA-256 partial hash is not a valid full hash, so nothing bypasses.
+	var smallThreshold int64 = partialHashBytes
+	if s.cfg.PartialHashAlgo != "" && s.cfg.PartialHashAlgo != PartialHashSHA256 {
+		smallThreshold = 0
 	}
-
-	// Store final aggregate stats back into progress so finaliseScanRecord
-	// can write them.
-	progress.FullHashed.Store(stats.FilesHashed)
-	// Update duplicate counters via a dedicated field — reuse CandidatesFound
-	// temporarily to carry group count (written to DB by finaliseScanRecord).
-	_ = stats // finaliseScanRecord queries the DB for final counts
-
-	return nil
+	RunSizeRouter(ctx, smallThreshold, filteredOut, smallOut, largeOut)
+	fullIn := largeOut
+	if directOut != nil || cachedDirect != nil {
+		ins := []<-chan HashedFile{largeOut}
+		for _, c := range []chan HashedFile{directOut, cachedDirect} {
+			if c != nil {
+				ins = append(ins, c)
+			}
+		}
+		fullIn = make(chan HashedFile, pipelineBufSize)
+		mergeHashedFiles(ctx, fullIn, ins...)
+	}
+	RunSizePriorityQueue(ctx, fullIn, priorityOut)
+	RunFullHashers(ctx, s.cfg.FullHashers, s.cfg.ApproximateSampleBytes, limiter, throttle, progress, priorityOut, fullOut, report, failed)
+	mergeHashedFiles(ctx, finalOut, cacheHits, fullOut, smallOut)
+	return finalOut
 }
 
 // mergeHashedFiles fans in all ins channels into out. out is closed when
@@ -295,71 +552,227 @@ func mergeHashedFiles(ctx context.Context, out chan<- HashedFile, ins ...<-chan
 	}()
 }
 
+// progressSampleEvery is how many reporter ticks (seconds) pass between rows
+// appended to scan_progress_samples.
+const progressSampleEvery = 5
+
+// The final progress write is retried so end-of-scan counters are not lost to
+// a transient error such as SQLITE_BUSY: up to finalFlushAttempts tries,
+// waiting finalFlushBackoff after the first failure and twice as long after
+// each further one, all within finalFlushTimeout.
+const (
+	finalFlushAttempts = 5
+	finalFlushBackoff  = 50 * time.Millisecond
+	finalFlushTimeout  = 5 * time.Second
+)
+
+// execer is the ExecContext shared by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // progressReporter writes the current progress counters to scan_history every
-// second until reporterStop is closed.
+// second until reporterStop is closed, and appends a throughput sample to
+// scan_progress_samples every progressSampleEvery seconds. A failed periodic
+// write is skipped — the next tick carries the same counters. When stop is
+// closed or ctx is done, the counters and a last sample are written in one
+// transaction that is retried (see finalFlushAttempts) and runs even though
+// ctx may already be cancelled.
 func progressReporter(ctx context.Context, db *sql.DB, scanID int64, p *Progress, stop <-chan struct{}) {
-	flush := func() {
-		_, err := db.ExecContext(ctx, `
-			UPDATE scan_history
-			SET files_discovered          = ?,
-			    progress_candidates_found = ?,
-			    progress_partial_hashed   = ?,
-			    progress_full_hashed      = ?,
-			    progress_bytes_read       = ?,
-			    cache_hits                = ?,
-			    cache_misses              = ?,
-			    errors                    = ?,
-			    progress_groups_written   = ?,
-			    progress_groups_total     = ?,
-			    phase2_started_at         = ?,
-			    disk_read_ms             = ?,
-			    db_read_ms               = ?,
-			    db_write_ms              = ?
-			WHERE id = ?`,
-			p.FilesDiscovered.Load(),
-			p.CandidatesFound.Load(),
-			p.PartialHashed.Load(),
-			p.FullHashed.Load(),
-			p.BytesRead.Load(),
-			p.CacheHits.Load(),
-			p.CacheMisses.Load(),
-			p.Errors.Load(),
-			p.GroupsWritten.Load(),
-			p.GroupsTotal.Load(),
-			p.Phase2StartedAt.Load(),
-			p.DiskReadMs.Load(),
-			p.DBReadMs.Load(),
-			p.DBWriteMs.Load(),
-			scanID)
-		if err != nil && ctx.Err() == nil {
-			slog.Warn("progress reporter: update failed", "error", err)
-		}
-	}
-
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
-	for {
+	for tick := 1; ; tick++ {
 		select {
 		case <-ticker.C:
-			flush()
+			if err := flushProgress(ctx, db, scanID, p); err != nil && ctx.Err() == nil {
+				slog.Warn("progress reporter: update failed", "error", err)
+			}
+			if tick%progressSampleEvery == 0 {
+				if err := sampleProgress(ctx, db, scanID, p); err != nil && ctx.Err() == nil {
+					slog.Warn("progress reporter: sample failed", "error", err)
+				}
+			}
 		case <-stop:
-			flush() // final flush
+			finalFlushProgress(ctx, db, scanID, p)
 			return
 		case <-ctx.Done():
+			finalFlushProgress(ctx, db, scanID, p)
 			return
 		}
 	}
 }
 
+// finalFlushProgress writes the end-of-scan counters and sample, retrying
+// with backoff. ctx only supplies values: its cancellation is ignored.
+func finalFlushProgress(ctx context.Context, db *sql.DB, scanID int64, p *Progress) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalFlushTimeout)
+	defer cancel()
+
+	write := func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := flushProgress(ctx, tx, scanID, p); err != nil {
+			return err
+		}
+		if err := sampleProgress(ctx, tx, scanID, p); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	backoff := finalFlushBackoff
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil {
+			return
+		}
+		if attempt == finalFlushAttempts || ctx.Err() != nil {
+			slog.Error("progress reporter: final update failed", "scan_id", scanID, "attempts", attempt, "error", err)
+			return
+		}
+		slog.Debug("progress reporter: retrying final update", "scan_id", scanID, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// sampleProgress appends the current throughput counters to
+// scan_progress_samples.
+func sampleProgress(ctx context.Context, db execer, scanID int64, p *Progress) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO scan_progress_samples (scan_id, t, files_discovered, full_hashed, bytes_read)
+		VALUES (?, ?, ?, ?, ?)`,
+		scanID, time.Now().Unix(),
+		p.FilesDiscovered.Load(), p.FullHashed.Load(), p.BytesRead.Load())
+	return err
+}
+
+// flushProgress copies the progress counters to the scan_history row.
+func flushProgress(ctx context.Context, db execer, scanID int64, p *Progress) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE scan_history
+		SET files_discovered          = ?,
+		    bytes_discovered          = ?,
+		    progress_candidates_found = ?,
+		    progress_partial_hashed   = ?,
+		    progress_full_hashed      = ?,
+		    progress_bytes_read       = ?,
+		    cache_hits                = ?,
+		    cache_misses              = ?,
+		    errors                    = ?,
+		    progress_groups_written   = ?,
+		    progress_groups_total     = ?,
+		    phase2_started_at         = ?,
+		    walk_finished_at          = ?,
+		    disk_read_ms             = ?,
+		    db_read_ms               = ?,
+		    db_write_ms              = ?,
+		    files_added              = ?
+		WHERE id = ?`,
+		p.FilesDiscovered.Load(),
+		p.BytesDiscovered.Load(),
+		p.CandidatesFound.Load(),
+		p.PartialHashed.Load(),
+		p.FullHashed.Load(),
+		p.BytesRead.Load(),
+		p.CacheHits.Load(),
+		p.CacheMisses.Load(),
+		p.Errors.Load(),
+		p.GroupsWritten.Load(),
+		p.GroupsTotal.Load(),
+		p.Phase2StartedAt.Load(),
+		p.WalkFinishedAt.Load(),
+		p.DiskReadMs.Load(),
+		p.DBReadMs.Load(),
+		p.DBWriteMs.Load(),
+		p.FilesAdded.Load(),
+		scanID)
+	return err
+}
+
+// ConfigSnapshot is the effective configuration a scan ran with, stored as
+// JSON in scan_history.config_snapshot.
+type ConfigSnapshot struct {
+	ScanPaths    []string `json:"scan_paths"`
+	ExcludePaths []string `json:"exclude_paths"`
+
+	Walkers        int `json:"walkers"`
+	CacheCheckers  int `json:"cache_checkers"`
+	PartialHashers int `json:"partial_hashers"`
+	FullHashers    int `json:"full_hashers"`
+	MaxOpenFiles   int `json:"max_open_files"`
+
+	PartialHashAlgo             string  `json:"partial_hash_algo"`
+	GroupByExtension            bool    `json:"group_by_extension"`
+	SizeTolerance               float64 `json:"size_tolerance"`
+	HeadBytes                   int     `json:"head_bytes"`
+	CPUPercent                  int     `json:"cpu_percent"`
+	SkipPartialHashAbove        int64   `json:"skip_partial_hash_above"`
+	ApproximateSampleBytes      int64   `json:"approximate_sample_bytes"`
+	MetadataOnly                bool    `json:"metadata_only"`
+	SkipRecentlyModifiedSeconds int64   `json:"skip_recently_modified_seconds"`
+}
+
+// configSnapshot returns the JSON ConfigSnapshot of s. Worker counts are the
+// configured values; 0 means the pipeline's default.
+func (s *Scanner) configSnapshot() []byte {
+	algo := s.cfg.PartialHashAlgo
+	if algo == "" {
+		algo = PartialHashSHA256
+	}
+	// Empty lists are stored as [] rather than null.
+	roots := append([]string{}, s.roots...)
+	excludes := append([]string{}, s.excludePaths...)
+	b, err := json.Marshal(ConfigSnapshot{
+		ScanPaths:                   roots,
+		ExcludePaths:                excludes,
+		Walkers:                     s.cfg.Walkers,
+		CacheCheckers:               s.cfg.CacheCheckers,
+		PartialHashers:              s.cfg.PartialHashers,
+		FullHashers:                 s.cfg.FullHashers,
+		MaxOpenFiles:                s.cfg.MaxOpenFiles,
+		PartialHashAlgo:             algo,
+		GroupByExtension:            s.cfg.GroupByExtension,
+		SizeTolerance:               s.cfg.SizeTolerance,
+		HeadBytes:                   s.cfg.HeadBytes,
+		CPUPercent:                  s.cfg.CPUPercent,
+		SkipPartialHashAbove:        s.cfg.SkipPartialHashAbove,
+		ApproximateSampleBytes:      s.cfg.ApproximateSampleBytes,
+		MetadataOnly:                s.cfg.MetadataOnly,
+		SkipRecentlyModifiedSeconds: int64(s.cfg.SkipRecentlyModified / time.Second),
+	})
+	if err != nil {
+		slog.Warn("marshal scan config snapshot", "error", err)
+		return nil
+	}
+	return b
+}
+
 // ── DB helpers ────────────────────────────────────────────────────────────────
 
-func insertScanRecord(db *sql.DB, startedAt time.Time, triggeredBy string) (int64, error) {
+// insertScanRecord creates a 'running' scan_history row. resumedFrom links the
+// row to the interrupted scan it resumes (0 = a fresh scan); snapshot is the
+// JSON ConfigSnapshot (nil = NULL).
+func insertScanRecord(db *sql.DB, startedAt time.Time, triggeredBy string, resumedFrom int64, snapshot []byte) (int64, error) {
 	now := startedAt.Unix()
+	var resumed, config interface{}
+	if resumedFrom != 0 {
+		resumed = resumedFrom
+	}
+	if snapshot != nil {
+		config = string(snapshot)
+	}
 	res, err := db.Exec(`
 		INSERT INTO scan_history
-			(started_at, status, triggered_by, created_at)
-		VALUES (?, 'running', ?, ?)`,
-		now, triggeredBy, now)
+			(started_at, status, triggered_by, resumed_from_scan_id, config_snapshot, created_at)
+		VALUES (?, 'running', ?, ?, ?, ?)`,
+		now, triggeredBy, resumed, config, now)
 	if err != nil {
 		return 0, err
 	}
@@ -381,6 +794,7 @@ func finaliseScanRecord(db *sql.DB, scanID int64, status string, finishedAt, dur
 		    finished_at       = ?,
 		    duration_seconds  = ?,
 		    files_discovered  = ?,
+		    bytes_discovered  = ?,
 		    files_hashed      = ?,
 		    cache_hits        = ?,
 		    cache_misses      = ?,
@@ -390,10 +804,12 @@ func finaliseScanRecord(db *sql.DB, scanID int64, status string, finishedAt, dur
 		    errors            = ?,
 		    disk_read_ms      = ?,
 		    db_read_ms        = ?,
-		    db_write_ms       = ?
+		    db_write_ms       = ?,
+		    files_added       = ?
 		WHERE id = ?`,
 		status, finishedAt, durationSecs,
 		p.FilesDiscovered.Load(),
+		p.BytesDiscovered.Load(),
 		p.FullHashed.Load(),
 		p.CacheHits.Load(),
 		p.CacheMisses.Load(),
@@ -402,6 +818,7 @@ func finaliseScanRecord(db *sql.DB, scanID int64, status string, finishedAt, dur
 		p.DiskReadMs.Load(),
 		p.DBReadMs.Load(),
 		p.DBWriteMs.Load(),
+		p.FilesAdded.Load(),
 		scanID)
 	return err
 }
@@ -3,6 +3,8 @@ package scan
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -69,53 +71,132 @@ func BenchmarkPipelineWarm(b *testing.B) {
 }
 
 // BenchmarkCacheCheck measures cache-check throughput at different worker
-// counts. Since db.Open sets MaxOpenConns(1), queries serialize at the pool
-// level regardless of worker count — this establishes a baseline and will
-// show real gains if MaxOpenConns is increased in future.
+// counts, against the main connection and against a read pool. db.Open sets
+// MaxOpenConns(1), so on the main connection the queries serialize whatever
+// the worker count; the read pool (Config.ReadDB) gives each worker its own
+// connection.
 // Run with: go test -bench=BenchmarkCacheCheck -benchtime=5x ./internal/scan/
 func BenchmarkCacheCheck(b *testing.B) {
 	const numCandidates = 500
 
-	for _, numWorkers := range []int{1, 2, 4} {
-		b.Run(fmt.Sprintf("workers=%d", numWorkers), func(b *testing.B) {
-			db := mustOpenDB(b)
-			scanID := mustInsertScan(b, db)
-			seedFileCache(b, db, scanID, numCandidates)
+	for _, pool := range []string{"main", "read"} {
+		for _, numWorkers := range []int{1, 2, 4} {
+			b.Run(fmt.Sprintf("db=%s/workers=%d", pool, numWorkers), func(b *testing.B) {
+				benchCacheCheck(b, pool == "read", numWorkers, numCandidates)
+			})
+		}
+	}
+}
+
+func benchCacheCheck(b *testing.B, readPool bool, numWorkers, numCandidates int) {
+	db := mustOpenDB(b)
+	scanID := mustInsertScan(b, db)
+	seedFileCache(b, db, scanID, numCandidates)
+	cacheDB := db
+	if readPool {
+		cacheDB = mustOpenReadPool(b, db, numWorkers)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in := make(chan FileInfo, numCandidates)
+		hits := make(chan HashedFile, numCandidates)
+		misses := make(chan FileInfo, numCandidates)
+
+		progress := &Progress{}
+		RunCacheCheck(context.Background(), cacheDB, progress, numWorkers, 0, in, hits, misses)
+
+		for j := 0; j < numCandidates; j++ {
+			in <- FileInfo{
+				Path:  fmt.Sprintf("/cached/file%04d.txt", j),
+				Size:  int64(j*100 + 1),
+				MTime: time.Unix(int64(1000+j), 0),
+			}
+		}
+		close(in)
+
+		hDone := make(chan struct{})
+		mDone := make(chan struct{})
+		go func() {
+			for range hits {
+			}
+			close(hDone)
+		}()
+		go func() {
+			for range misses {
+			}
+			close(mDone)
+		}()
+		<-hDone
+		<-mDone
+
+		b.SetBytes(int64(numCandidates))
+	}
+}
+
+// BenchmarkHashPartial compares the CPU cost of the partial-hash algorithms
+// on a single 64 KB read (the file stays in the page cache, so this measures
+// hashing rather than disk I/O).
+// Run with: go test -bench=BenchmarkHashPartial -run=^$ ./internal/scan/
+func BenchmarkHashPartial(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "partial.bin")
+	data := make([]byte, partialHashBytes)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, algo := range []string{PartialHashSHA256, PartialHashXXHash} {
+		b.Run(algo, func(b *testing.B) {
+			b.SetBytes(partialHashBytes)
+			for i := 0; i < b.N; i++ {
+				if _, _, err := hashPartial(path, algo, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHeadBytes compares a cold scan with and without the head filter
+// on a tree where every file has the same size but only a few are duplicates
+// — the case candidate_head_bytes is meant for.
+// Run with: go test -bench=BenchmarkHeadBytes -run=^$ ./internal/scan/
+func BenchmarkHeadBytes(b *testing.B) {
+	root := b.TempDir()
+	const numFiles, size = 400, 256 << 10
+	data := make([]byte, size)
+	for i := 0; i < numFiles; i++ {
+		// Pairs (i, i+1) for i%20 == 0 are duplicates; the rest differ from
+		// their first byte on.
+		seed := i
+		if i%20 == 1 {
+			seed = i - 1
+		}
+		for j := range data {
+			data[j] = byte(seed*31 + j)
+		}
+		data[0], data[1] = byte(seed), byte(seed>>8)
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%04d.bin", i)), data, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	db := mustOpenDB(b)
 
-			b.ResetTimer()
+	for _, head := range []int{0, 512} {
+		b.Run(fmt.Sprintf("head=%d", head), func(b *testing.B) {
+			cfg := DefaultConfig()
+			cfg.HeadBytes = head
+			s := New(db, []string{root}, nil, cfg)
 			for i := 0; i < b.N; i++ {
-				in := make(chan FileInfo, numCandidates)
-				hits := make(chan HashedFile, numCandidates)
-				misses := make(chan FileInfo, numCandidates)
-
-				progress := &Progress{}
-				RunCacheCheck(context.Background(), db, progress, numWorkers, in, hits, misses)
-
-				for j := 0; j < numCandidates; j++ {
-					in <- FileInfo{
-						Path:  fmt.Sprintf("/cached/file%04d.txt", j),
-						Size:  int64(j*100 + 1),
-						MTime: time.Unix(int64(1000+j), 0),
-					}
+				db.Exec("DELETE FROM file_cache")
+				p := &Progress{}
+				if _, err := s.Run(context.Background(), "manual", p); err != nil {
+					b.Fatalf("scan failed: %v", err)
 				}
-				close(in)
-
-				hDone := make(chan struct{})
-				mDone := make(chan struct{})
-				go func() {
-					for range hits {
-					}
-					close(hDone)
-				}()
-				go func() {
-					for range misses {
-					}
-					close(mDone)
-				}()
-				<-hDone
-				<-mDone
-
-				b.SetBytes(int64(numCandidates))
+				b.ReportMetric(float64(p.PartialHashed.Load()), "partial_hashed/op")
 			}
 		})
 	}
@@ -0,0 +1,39 @@
+package scan
+
+import (
+	"context"
+	"testing"
+)
+
+// TestProgressReporterFinalFlushAfterCancel verifies that the final progress
+// write lands even when the scan context is already cancelled.
+func TestProgressReporterFinalFlushAfterCancel(t *testing.T) {
+	db := mustOpenDB(t)
+	scanID := mustInsertScan(t, db)
+
+	p := &Progress{}
+	p.FilesDiscovered.Store(42)
+	p.FullHashed.Store(7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stop := make(chan struct{})
+	close(stop)
+	progressReporter(ctx, db, scanID, p, stop)
+
+	var discovered, hashed int64
+	if err := db.QueryRow(`SELECT files_discovered, progress_full_hashed FROM scan_history WHERE id = ?`,
+		scanID).Scan(&discovered, &hashed); err != nil {
+		t.Fatalf("read scan_history: %v", err)
+	}
+	if discovered != 42 || hashed != 7 {
+		t.Errorf("files_discovered/full_hashed = %d/%d, want 42/7", discovered, hashed)
+	}
+	var samples int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM scan_progress_samples WHERE scan_id = ?`, scanID).Scan(&samples); err != nil {
+		t.Fatalf("count samples: %v", err)
+	}
+	if samples != 1 {
+		t.Errorf("samples = %d, want 1", samples)
+	}
+}
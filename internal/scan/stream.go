@@ -0,0 +1,33 @@
+package scan
+
+import "sync/atomic"
+
+// GroupStream delivers duplicate groups to an external consumer as the DB
+// writer commits them. Sends never block: when the buffer is full the group
+// is dropped and counted, so a slow consumer cannot stall a scan.
+type GroupStream struct {
+	ch      chan ReportGroup
+	dropped atomic.Int64
+}
+
+// NewGroupStream returns a stream buffering up to buffer groups.
+func NewGroupStream(buffer int) *GroupStream {
+	return &GroupStream{ch: make(chan ReportGroup, buffer)}
+}
+
+// Groups returns the channel of written groups. It is closed when the scan
+// the stream is attached to has finished.
+func (s *GroupStream) Groups() <-chan ReportGroup { return s.ch }
+
+// Dropped returns the number of groups discarded because the buffer was full.
+func (s *GroupStream) Dropped() int64 { return s.dropped.Load() }
+
+func (s *GroupStream) send(g ReportGroup) {
+	select {
+	case s.ch <- g:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *GroupStream) close() { close(s.ch) }
@@ -27,6 +27,22 @@ func mustOpenDB(tb testing.TB) *sql.DB {
 	return db
 }
 
+// mustOpenReadPool opens a read-only pool of maxConns connections to the
+// database behind db, as the scanner's Config.ReadDB.
+func mustOpenReadPool(tb testing.TB, db *sql.DB, maxConns int) *sql.DB {
+	tb.Helper()
+	var path string
+	if err := db.QueryRow(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&path); err != nil {
+		tb.Fatalf("database path: %v", err)
+	}
+	rdb, err := internaldb.OpenReadPool(path, maxConns)
+	if err != nil {
+		tb.Fatalf("open read pool: %v", err)
+	}
+	tb.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
 // mustInsertScan inserts a scan_history row and returns its ID.
 func mustInsertScan(tb testing.TB, db *sql.DB) int64 {
 	tb.Helper()
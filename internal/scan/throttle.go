@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"context"
+	"time"
+)
+
+// hashThrottle caps the share of wall time each hashing worker spends hashing:
+// after hashing a file for d, the worker idles for d*(100-p)/p, so it works
+// at most p% of the time. It acts per worker, so N workers throttled to p%
+// keep roughly N*p/100 cores busy. A nil *hashThrottle never pauses.
+type hashThrottle struct {
+	idlePerBusy float64
+}
+
+// newThrottle returns a throttle limiting workers to percent of the time, or
+// nil when percent is outside 1–99 (no throttling).
+func newThrottle(percent int) *hashThrottle {
+	if percent <= 0 || percent >= 100 {
+		return nil
+	}
+	return &hashThrottle{idlePerBusy: float64(100-percent) / float64(percent)}
+}
+
+// pause idles for the time that balances busy, or until ctx is done.
+func (t *hashThrottle) pause(ctx context.Context, busy time.Duration) {
+	if t == nil {
+		return
+	}
+	d := time.Duration(float64(busy) * t.idlePerBusy)
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
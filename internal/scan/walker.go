@@ -2,9 +2,12 @@ package scan
 
 import (
 	"context"
+	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -75,11 +78,13 @@ func (q *dirQueue) Done() {
 
 // Walk traverses roots concurrently using numWorkers goroutines and sends
 // every regular file it finds to out. Walk closes out when done.
-// Directories and files matching excludePaths are skipped.
+// Directories and files matching excludePaths are skipped. Overlapping roots
+// are collapsed first (see collapseRoots) so no file is emitted twice.
 // report is called for any filesystem errors encountered during traversal.
 func Walk(ctx context.Context, roots []string, excludePaths map[string]struct{}, numWorkers int, out chan<- FileInfo, report ErrorReporter) {
 	defer close(out)
 
+	roots = collapseRoots(roots, excludePaths)
 	q := newDirQueue()
 
 	// Seed the queue with root directories.
@@ -99,6 +104,57 @@ func Walk(ctx context.Context, roots []string, excludePaths map[string]struct{},
 	wg.Wait()
 }
 
+// collapseRoots cleans roots and drops repeats and roots nested inside another
+// root (e.g. /data/photos when /data is also configured), which the walk of
+// the outer root already covers; each dropped root is logged. A nested root
+// under an excluded directory is kept, since the outer walk never reaches it.
+func collapseRoots(roots []string, excludePaths map[string]struct{}) []string {
+	cleaned := make([]string, len(roots))
+	for i, r := range roots {
+		cleaned[i] = filepath.Clean(r)
+	}
+
+	var kept []string
+	for i, r := range cleaned {
+		dropped := false
+		for j, outer := range cleaned {
+			if i == j {
+				continue
+			}
+			repeated := r == outer && j < i // of two equal roots, keep the first
+			nested := pathWithin(r, outer) && !excludedBetween(r, outer, excludePaths)
+			if repeated || nested {
+				slog.Warn("scan root overlaps another root; skipping it", "root", roots[i], "covered_by", roots[j])
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// pathWithin reports whether path lies strictly below dir.
+func pathWithin(path, dir string) bool {
+	if dir == string(filepath.Separator) {
+		return path != dir && strings.HasPrefix(path, dir)
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// excludedBetween reports whether path, or a directory between it and the
+// outer root, is excluded.
+func excludedBetween(path, outer string, excludePaths map[string]struct{}) bool {
+	for p := path; p != outer && pathWithin(p, outer); p = filepath.Dir(p) {
+		if _, ok := excludePaths[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // walkerWorker pops directories from q, reads their entries, enqueues
 // sub-directories (incrementing pending first), sends files to out, then
 // calls q.Done() to decrement pending.
@@ -165,3 +221,37 @@ func walkerWorker(ctx context.Context, q *dirQueue, excludePaths map[string]stru
 		q.Done()
 	}
 }
+
+// summarizePermissionErrors wraps report so that permission-denied errors
+// are counted instead of reported one by one; every other error passes
+// straight through. Calling flush afterwards reports the count as a single
+// error against the first denied path, and logs it.
+func summarizePermissionErrors(report ErrorReporter) (wrapped ErrorReporter, flush func()) {
+	var (
+		mu    sync.Mutex
+		first string
+		count int
+	)
+	wrapped = func(path, stage, errMsg string) {
+		if !strings.HasSuffix(errMsg, fs.ErrPermission.Error()) {
+			report(path, stage, errMsg)
+			return
+		}
+		mu.Lock()
+		if count == 0 {
+			first = path
+		}
+		count++
+		mu.Unlock()
+	}
+	flush = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if count == 0 {
+			return
+		}
+		slog.Warn("scan skipped unreadable paths", "count", count, "first", first)
+		report(first, "walk", fmt.Sprintf("permission denied on %d path(s), including this one", count))
+	}
+	return wrapped, flush
+}
@@ -171,3 +171,67 @@ func TestWalkCancellation(t *testing.T) {
 		t.Fatal("Walk did not return after context cancel")
 	}
 }
+
+// TestWalkOverlappingRoots verifies that a root nested in another root, or
+// listed twice, does not make Walk emit its files twice — unless the nested
+// root sits under an excluded directory, where only it reaches the files.
+func TestWalkOverlappingRoots(t *testing.T) {
+	root := t.TempDir()
+	photos := filepath.Join(root, "photos")
+	private := filepath.Join(root, "private")
+	docs := filepath.Join(private, "docs")
+	for _, dir := range []string{photos, docs} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{
+		filepath.Join(root, "a.txt"),
+		filepath.Join(photos, "b.jpg"),
+		filepath.Join(docs, "c.pdf"),
+	} {
+		_ = os.WriteFile(p, []byte("x"), 0644)
+	}
+
+	excludes := map[string]struct{}{private: {}}
+	out := make(chan FileInfo, 10)
+	roots := []string{photos, root, root + "/", docs}
+	Walk(context.Background(), roots, excludes, 2, out, noErrors(t))
+
+	seen := make(map[string]int)
+	for fi := range out {
+		seen[fi.Path]++
+	}
+	if len(seen) != 3 {
+		t.Errorf("files: got %v, want a.txt, b.jpg and c.pdf", seen)
+	}
+	for p, n := range seen {
+		if n != 1 {
+			t.Errorf("%s emitted %d times, want 1", p, n)
+		}
+	}
+}
+
+// TestSummarizePermissionErrors verifies permission-denied errors collapse
+// into one report on flush while other errors pass straight through.
+func TestSummarizePermissionErrors(t *testing.T) {
+	type reported struct{ path, msg string }
+	var got []reported
+	report := func(path, _, errMsg string) { got = append(got, reported{path, errMsg}) }
+
+	wrapped, flush := summarizePermissionErrors(report)
+	wrapped("/a", "walk", "open /a: permission denied")
+	wrapped("/b", "walk", "lstat /b: no such file or directory")
+	wrapped("/c", "walk", "open /c: permission denied")
+	if len(got) != 1 || got[0].path != "/b" {
+		t.Fatalf("before flush: got %v, want only the /b error", got)
+	}
+
+	flush()
+	if len(got) != 2 {
+		t.Fatalf("after flush: got %d reports, want 2", len(got))
+	}
+	if got[1].path != "/a" || got[1].msg != "permission denied on 2 path(s), including this one" {
+		t.Errorf("summary = %+v", got[1])
+	}
+}
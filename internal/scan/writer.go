@@ -3,8 +3,12 @@ package scan
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/eargollo/ditto/internal/media"
@@ -19,6 +23,61 @@ type WriteStats struct {
 	DuplicateFiles   int64
 	ReclaimableBytes int64
 	FilesHashed      int64 // total files in duplicate groups (includes cache hits)
+	// Report holds the groups found when WriterOptions.ReportOnly is set,
+	// largest reclaimable first. Nil otherwise.
+	Report []ReportGroup
+}
+
+// ReportGroup is a duplicate group found by a report-only scan, or written
+// by a regular one and sent to a GroupStream.
+type ReportGroup struct {
+	GroupID          int64 // 0 in report-only scans
+	ContentHash      string
+	FileSize         int64
+	FileType         media.FileType
+	ReclaimableBytes int64
+	Paths            []string // sorted
+}
+
+// WriterOptions tunes how RunDBWriter classifies and stores groups.
+type WriterOptions struct {
+	// SniffContentTypes reads the leading bytes of files whose extension is
+	// unknown so extensionless images/videos/PDFs get the right file_type.
+	SniffContentTypes bool
+	// GroupByExtension splits groups by lower-cased file extension, so that
+	// byte-identical photo.jpg and photo.png are not duplicates (photo.JPG
+	// still groups with photo.jpg).
+	GroupByExtension bool
+	// ReportOnly returns the groups in WriteStats.Report instead of writing
+	// them, and leaves file_cache untouched: the scan changes nothing.
+	ReportOnly bool
+	// Stream, when non-nil, receives each group once its batch is committed.
+	Stream *GroupStream
+	// FileInventory replaces file_inventory with the files that reached the
+	// writer but matched no other file (see writeInventory).
+	FileInventory bool
+}
+
+// groupKey is the key files are grouped under — and the content_hash stored
+// for the group. With GroupByExtension it is "<hash>:<ext>" for files that
+// have an extension; otherwise it is the hash itself.
+func (o WriterOptions) groupKey(hash, path string) string {
+	if !o.GroupByExtension {
+		return hash
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "" {
+		return hash
+	}
+	return hash + ":" + ext
+}
+
+// fileType classifies path according to the options.
+func (o WriterOptions) fileType(path string) media.FileType {
+	if o.SniffContentTypes {
+		return media.DetectWithSniff(path)
+	}
+	return media.Detect(path)
 }
 
 // groupBatchSize is the number of duplicate groups written per SQLite transaction.
@@ -29,6 +88,21 @@ const groupBatchSize = 100
 type groupEntry struct {
 	hash  string
 	files []HashedFile
+	// types holds the file type of each file, filled by detectFileTypes
+	// before the batch's transaction begins.
+	types []media.FileType
+}
+
+// detectFileTypes classifies every file of batch. It runs outside the write
+// transaction: with SniffContentTypes each detection may read the file.
+func detectFileTypes(batch []groupEntry, opts WriterOptions) {
+	for i := range batch {
+		g := &batch[i]
+		g.types = make([]media.FileType, len(g.files))
+		for j, f := range g.files {
+			g.types[j] = opts.fileType(f.Path)
+		}
+	}
 }
 
 // RunDBWriter collects all HashedFile results from in, then writes duplicate
@@ -36,14 +110,15 @@ type groupEntry struct {
 // It updates file_cache progressively (every batchSize items) so that a
 // cancelled scan still preserves partial hashing work for subsequent runs.
 // Returns aggregate stats for updating scan_history.
-func RunDBWriter(ctx context.Context, db *sql.DB, scanID int64, batchSize int, in <-chan HashedFile, progress *Progress) (WriteStats, error) {
-	// Phase 1: accumulate all results into a map keyed by full hash.
+func RunDBWriter(ctx context.Context, db *sql.DB, scanID int64, batchSize int, in <-chan HashedFile, progress *Progress, opts WriterOptions) (WriteStats, error) {
+	// Phase 1: accumulate all results into a map keyed by full hash (plus
+	// extension when opts.GroupByExtension is set).
 	// Write file_cache entries progressively so cancelled scans preserve work.
 	groups := make(map[string][]HashedFile)
 	var cacheBuf []HashedFile
 
 	flushCache := func() {
-		if len(cacheBuf) == 0 {
+		if len(cacheBuf) == 0 || opts.ReportOnly {
 			return
 		}
 		// Use Background so the flush survives context cancellation.
@@ -54,7 +129,12 @@ func RunDBWriter(ctx context.Context, db *sql.DB, scanID int64, batchSize int, i
 	}
 
 	for hf := range in {
-		groups[hf.Hash] = append(groups[hf.Hash], hf)
+		key := opts.groupKey(hf.Hash, hf.Path)
+		groups[key] = append(groups[key], hf)
+		if isUnverifiedHash(hf.Hash) {
+			// Never cached: an exact scan must not take it for a full hash.
+			continue
+		}
 		cacheBuf = append(cacheBuf, hf)
 		if len(cacheBuf) >= batchSize {
 			flushCache()
@@ -68,23 +148,30 @@ func RunDBWriter(ctx context.Context, db *sql.DB, scanID int64, batchSize int, i
 		return WriteStats{}, ctx.Err()
 	}
 
+	if opts.ReportOnly {
+		return reportGroups(groups, opts), nil
+	}
+
 	// Phase 2: write duplicate groups to the database.
-	return persistGroups(ctx, db, scanID, groups, progress)
+	return persistGroups(ctx, db, scanID, groups, progress, opts)
 }
 
 // persistGroups writes all duplicate groups and their files to the DB.
 // Groups are batched groupBatchSize per transaction to minimise fsync overhead
 // on spinning-disk storage (reduces ~307K individual statements to ~620 transactions).
-func persistGroups(ctx context.Context, db *sql.DB, scanID int64, groups map[string][]HashedFile, progress *Progress) (WriteStats, error) {
+func persistGroups(ctx context.Context, db *sql.DB, scanID int64, groups map[string][]HashedFile, progress *Progress, opts WriterOptions) (WriteStats, error) {
 	var stats WriteStats
 	now := time.Now().Unix()
 
 	// Separate duplicates (≥2 files) from singletons; count all FilesHashed.
 	var dupGroups []groupEntry
+	var singles []HashedFile
 	for hash, files := range groups {
 		stats.FilesHashed += int64(len(files))
 		if len(files) >= 2 {
-			dupGroups = append(dupGroups, groupEntry{hash, files})
+			dupGroups = append(dupGroups, groupEntry{hash: hash, files: files})
+		} else if opts.FileInventory && !isUnverifiedHash(files[0].Hash) {
+			singles = append(singles, files[0])
 		}
 	}
 
@@ -95,17 +182,29 @@ func persistGroups(ctx context.Context, db *sql.DB, scanID int64, groups map[str
 	}
 
 	// Write in batches of groupBatchSize, each a single SQLite transaction.
-	for i := 0; i < len(dupGroups); i += groupBatchSize {
+	// File types of the next batch are detected while the current one is
+	// written, so the write lock is only held for the statements themselves.
+	batches := make(chan []groupEntry, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(batches)
+		for i := 0; i < len(dupGroups); i += groupBatchSize {
+			batch := dupGroups[i:min(i+groupBatchSize, len(dupGroups))]
+			detectFileTypes(batch, opts)
+			select {
+			case batches <- batch:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for batch := range batches {
 		if ctx.Err() != nil {
 			return stats, ctx.Err()
 		}
-		end := i + groupBatchSize
-		if end > len(dupGroups) {
-			end = len(dupGroups)
-		}
-		batch := dupGroups[i:end]
-
-		if err := writeGroupBatch(ctx, db, scanID, batch, now, &stats, progress); err != nil {
+		if err := writeGroupBatch(ctx, db, scanID, batch, now, &stats, progress, opts); err != nil {
 			return stats, err
 		}
 		if progress != nil {
@@ -113,12 +212,97 @@ func persistGroups(ctx context.Context, db *sql.DB, scanID int64, groups map[str
 		}
 	}
 
+	if opts.FileInventory {
+		if err := writeInventory(ctx, db, scanID, singles, progress); err != nil {
+			return stats, fmt.Errorf("write file inventory: %w", err)
+		}
+	}
+
 	return stats, nil
 }
 
+// writeInventory replaces the contents of file_inventory with files, in one
+// transaction. Only files that shared their size (and partial hash) with
+// another file are fully hashed, so files of a unique size are never in the
+// inventory: it complements duplicate_files rather than listing every file.
+func writeInventory(ctx context.Context, db *sql.DB, scanID int64, files []HashedFile, progress *Progress) error {
+	t0 := time.Now()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM file_inventory`); err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO file_inventory (path, content_hash, size, mtime, scan_id)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, f := range files {
+		if _, err := stmt.ExecContext(ctx, f.Path, f.Hash, f.Size, f.MTime.Unix(), scanID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress.DBWriteMs.Add(wallMs(t0))
+	}
+	slog.Info("file inventory written", "files", len(files))
+	return nil
+}
+
+// reportGroups builds the in-memory result of a report-only scan.
+func reportGroups(groups map[string][]HashedFile, opts WriterOptions) WriteStats {
+	var stats WriteStats
+	for key, files := range groups {
+		stats.FilesHashed += int64(len(files))
+		if len(files) < 2 {
+			continue
+		}
+		g := newReportGroup(0, key, files, opts.fileType(files[0].Path))
+		stats.Report = append(stats.Report, g)
+		stats.DuplicateGroups++
+		stats.DuplicateFiles += int64(len(files))
+		stats.ReclaimableBytes += g.ReclaimableBytes
+	}
+	sort.Slice(stats.Report, func(i, j int) bool {
+		a, b := stats.Report[i], stats.Report[j]
+		if a.ReclaimableBytes != b.ReclaimableBytes {
+			return a.ReclaimableBytes > b.ReclaimableBytes
+		}
+		return a.ContentHash < b.ContentHash
+	})
+	return stats
+}
+
+// newReportGroup describes the duplicate group of files stored under key;
+// fileType is the type of files[0], which the group takes.
+func newReportGroup(groupID int64, key string, files []HashedFile, fileType media.FileType) ReportGroup {
+	g := ReportGroup{
+		GroupID:          groupID,
+		ContentHash:      key,
+		FileSize:         files[0].Size,
+		FileType:         fileType,
+		ReclaimableBytes: files[0].Size * int64(len(files)-1),
+		Paths:            make([]string, len(files)),
+	}
+	for i, f := range files {
+		g.Paths[i] = f.Path
+	}
+	sort.Strings(g.Paths)
+	return g
+}
+
 // writeGroupBatch writes a slice of duplicate groups within a single transaction,
 // reusing prepared statements across all groups in the batch.
-func writeGroupBatch(ctx context.Context, db *sql.DB, scanID int64, batch []groupEntry, now int64, stats *WriteStats, progress *Progress) error {
+func writeGroupBatch(ctx context.Context, db *sql.DB, scanID int64, batch []groupEntry, now int64, stats *WriteStats, progress *Progress, opts WriterOptions) error {
 	t0 := time.Now()
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -131,8 +315,8 @@ func writeGroupBatch(ctx context.Context, db *sql.DB, scanID int64, batch []grou
 		INSERT OR IGNORE INTO duplicate_groups
 			(content_hash, file_size, file_type,
 			 first_seen_scan_id, last_seen_scan_id,
-			 created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+			 created_at, updated_at, last_verified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return fmt.Errorf("prepare insert_group: %w", err)
 	}
@@ -153,26 +337,54 @@ func writeGroupBatch(ctx context.Context, db *sql.DB, scanID int64, batch []grou
 	}
 	defer stmtInsertFile.Close()
 
+	// A path already listed under another group (its content changed, or
+	// group_by_extension was toggled) is moved: drop the old row first, since
+	// duplicate_files.path is unique.
+	stmtDisplaceFile, err := tx.PrepareContext(ctx, `
+		DELETE FROM duplicate_files WHERE path = ? AND group_id != ?
+		RETURNING group_id`)
+	if err != nil {
+		return fmt.Errorf("prepare displace_file: %w", err)
+	}
+	defer stmtDisplaceFile.Close()
+
+	// A user override in group_overrides takes precedence over the detected type.
 	stmtUpdateGroup, err := tx.PrepareContext(ctx, `
 		UPDATE duplicate_groups
 		SET file_count = ?, reclaimable_bytes = ?,
-		    file_type = ?, last_seen_scan_id = ?, updated_at = ?
+		    file_type = COALESCE(
+		        (SELECT o.file_type FROM group_overrides o
+		         WHERE o.content_hash = duplicate_groups.content_hash), ?),
+		    last_seen_scan_id = ?, updated_at = ?, last_verified_at = ?,
+		    status = CASE WHEN status = 'gone' THEN 'unresolved' ELSE status END
 		WHERE id = ?`)
 	if err != nil {
 		return fmt.Errorf("prepare update_group: %w", err)
 	}
 	defer stmtUpdateGroup.Close()
 
-	for _, g := range batch {
-		if err := writeGroupInTx(ctx, tx, scanID, g.hash, g.files, now, stats,
-			stmtInsertGroup, stmtDeleteFiles, stmtInsertFile, stmtUpdateGroup); err != nil {
+	displaced := make(map[int64]bool)
+	groupIDs := make([]int64, len(batch))
+	for i, g := range batch {
+		id, err := writeGroupInTx(ctx, tx, scanID, g, now, stats, displaced,
+			stmtInsertGroup, stmtDeleteFiles, stmtInsertFile, stmtDisplaceFile, stmtUpdateGroup)
+		if err != nil {
 			return err
 		}
+		groupIDs[i] = id
+	}
+	if err := refreshDisplacedGroups(ctx, tx, displaced, now); err != nil {
+		return err
 	}
 	err = tx.Commit()
 	if progress != nil {
 		progress.DBWriteMs.Add(wallMs(t0))
 	}
+	if err == nil && opts.Stream != nil {
+		for i, g := range batch {
+			opts.Stream.send(newReportGroup(groupIDs[i], g.hash, g.files, g.types[0]))
+		}
+	}
 	return err
 }
 
@@ -182,54 +394,169 @@ func writeGroupInTx(
 	ctx context.Context,
 	tx *sql.Tx,
 	scanID int64,
-	hash string,
-	files []HashedFile,
+	g groupEntry,
 	now int64,
 	stats *WriteStats,
-	stmtInsertGroup, stmtDeleteFiles, stmtInsertFile, stmtUpdateGroup *sql.Stmt,
-) error {
+	displaced map[int64]bool,
+	stmtInsertGroup, stmtDeleteFiles, stmtInsertFile, stmtDisplaceFile, stmtUpdateGroup *sql.Stmt,
+) (int64, error) {
+	hash, files := g.hash, g.files
 	fileSize := files[0].Size
-	fileType := string(media.Detect(files[0].Path))
+	fileType := string(g.types[0])
 
 	if _, err := stmtInsertGroup.ExecContext(ctx,
-		hash, fileSize, fileType, scanID, scanID, now, now,
+		hash, fileSize, fileType, scanID, scanID, now, now, now,
 	); err != nil {
-		return fmt.Errorf("insert group %s: %w", hash[:8], err)
+		return 0, fmt.Errorf("insert group %s: %w", hash[:8], err)
 	}
 
 	var groupID int64
 	if err := tx.QueryRowContext(ctx,
 		`SELECT id FROM duplicate_groups WHERE content_hash = ?`, hash,
 	).Scan(&groupID); err != nil {
-		return fmt.Errorf("get group id %s: %w", hash[:8], err)
+		return 0, fmt.Errorf("get group id %s: %w", hash[:8], err)
 	}
 
 	if _, err := stmtDeleteFiles.ExecContext(ctx, groupID); err != nil {
-		return fmt.Errorf("delete old files group %d: %w", groupID, err)
+		return 0, fmt.Errorf("delete old files group %d: %w", groupID, err)
 	}
 
-	for _, f := range files {
-		ft := string(media.Detect(f.Path))
+	for i, f := range files {
+		var oldGroupID int64
+		err := stmtDisplaceFile.QueryRowContext(ctx, f.Path, groupID).Scan(&oldGroupID)
+		switch {
+		case err == nil:
+			displaced[oldGroupID] = true
+		case !errors.Is(err, sql.ErrNoRows):
+			return 0, fmt.Errorf("displace file %s: %w", f.Path, err)
+		}
+
 		if _, err := stmtInsertFile.ExecContext(ctx,
-			groupID, scanID, f.Path, f.Size, f.MTime.Unix(), ft,
+			groupID, scanID, f.Path, f.Size, f.MTime.Unix(), string(g.types[i]),
 		); err != nil {
-			return fmt.Errorf("insert file %s: %w", f.Path, err)
+			return 0, fmt.Errorf("insert file %s: %w", f.Path, err)
 		}
 	}
 
 	reclaimable := fileSize * int64(len(files)-1)
 	if _, err := stmtUpdateGroup.ExecContext(ctx,
-		len(files), reclaimable, fileType, scanID, now, groupID,
+		len(files), reclaimable, fileType, scanID, now, now, groupID,
 	); err != nil {
-		return fmt.Errorf("update group %d: %w", groupID, err)
+		return 0, fmt.Errorf("update group %d: %w", groupID, err)
 	}
 
 	stats.DuplicateGroups++
 	stats.DuplicateFiles += int64(len(files))
 	stats.ReclaimableBytes += reclaimable
+	return groupID, nil
+}
+
+// refreshDisplacedGroups recomputes file_count and reclaimable_bytes of groups
+// that lost files to another group in this batch, deleting any left empty
+// and resolving any left with one file, which is no longer a duplicate.
+func refreshDisplacedGroups(ctx context.Context, tx *sql.Tx, displaced map[int64]bool, now int64) error {
+	for id := range displaced {
+		var count int64
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM duplicate_files WHERE group_id = ?`, id,
+		).Scan(&count); err != nil {
+			return fmt.Errorf("count files group %d: %w", id, err)
+		}
+		if count == 0 {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM duplicate_groups WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("delete emptied group %d: %w", id, err)
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE duplicate_groups
+			SET file_count = ?, reclaimable_bytes = file_size * ?, updated_at = ?,
+			    status = CASE WHEN ? = 1 THEN 'resolved' ELSE status END,
+			    resolved_at = CASE WHEN ? = 1 AND status != 'resolved' THEN ? ELSE resolved_at END
+			WHERE id = ?`, count, count-1, now, count, count, now, id); err != nil {
+			return fmt.Errorf("refresh group %d: %w", id, err)
+		}
+	}
 	return nil
 }
 
+// scanScope is the part of the tree a scan re-checked: the roots it walked,
+// less the paths it excluded.
+type scanScope struct {
+	roots    []string
+	excludes map[string]struct{}
+}
+
+// covers reports whether the scan would have found the file at path.
+func (sc *scanScope) covers(path string) bool {
+	for _, r := range sc.roots {
+		if (path == r || pathWithin(path, r)) && !excludedBetween(path, r, sc.excludes) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropUnverifiedGroups deletes the groups whose content_hash starts with
+// prefix (ApproximateHashPrefix or MetadataHashPrefix), with their files.
+// Called once a scan that supersedes them has written its groups. Only
+// groups whose files all lie within scope are dropped: the scan re-grouped
+// those by content, while a group with a file it never looked at is left as
+// it is.
+func dropUnverifiedGroups(ctx context.Context, db *sql.DB, prefix string, scope *scanScope) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, f.path FROM duplicate_groups g
+		LEFT JOIN duplicate_files f ON f.group_id = g.id
+		WHERE g.content_hash LIKE ?
+		ORDER BY g.id`, prefix+"%")
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	outside := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		var path sql.NullString
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(ids) == 0 || ids[len(ids)-1] != id {
+			ids = append(ids, id)
+		}
+		if path.Valid && !scope.covers(path.String) {
+			outside[id] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var dropped int
+	for _, id := range ids {
+		if outside[id] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM duplicate_files WHERE group_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM duplicate_groups WHERE id = ?`, id); err != nil {
+			return err
+		}
+		dropped++
+	}
+	if dropped > 0 {
+		slog.Info("dropped unverified groups", "kind", strings.TrimSuffix(prefix, ":"), "groups", dropped)
+	}
+	return tx.Commit()
+}
+
 // updateCache upserts file_cache entries for all files that passed through
 // the full hash stage.
 func updateCache(ctx context.Context, db *sql.DB, scanID int64, files []HashedFile, batchSize int, progress *Progress) error {
@@ -2,6 +2,7 @@ package scan
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -31,7 +32,7 @@ func TestRunDBWriterFindsDuplicates(t *testing.T) {
 	}
 	close(in)
 
-	stats, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil)
+	stats, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, WriterOptions{})
 	if err != nil {
 		t.Fatalf("RunDBWriter: %v", err)
 	}
@@ -83,7 +84,7 @@ func TestProgressiveCacheUpdateSurvivesCancellation(t *testing.T) {
 	}
 	close(in)
 
-	_, err := RunDBWriter(ctx, db, scanID, batchSize, in, nil)
+	_, err := RunDBWriter(ctx, db, scanID, batchSize, in, nil, WriterOptions{})
 	if err == nil {
 		t.Fatal("expected a non-nil error from cancelled context, got nil")
 	}
@@ -106,3 +107,194 @@ func TestProgressiveCacheUpdateSurvivesCancellation(t *testing.T) {
 		t.Errorf("duplicate_groups: got %d after cancel, want 0", groupCount)
 	}
 }
+
+// TestRunDBWriterGroupByExtension verifies that GroupByExtension splits
+// same-hash files by lower-cased extension, and that turning it off again
+// folds them back into one group without tripping duplicate_files' unique
+// path constraint. A group the split leaves with one file is resolved.
+func TestRunDBWriterGroupByExtension(t *testing.T) {
+	db := mustOpenDB(t)
+	paths := []string{"/vol1/a.jpg", "/vol1/b.JPG", "/vol1/c.png", "/vol1/d.png"}
+
+	write := func(opts WriterOptions) WriteStats {
+		t.Helper()
+		scanID := mustInsertScan(t, db)
+		in := make(chan HashedFile, len(paths))
+		for _, p := range paths {
+			in <- HashedFile{
+				FileInfo: FileInfo{Path: p, Size: 1024, MTime: time.Unix(1000, 0)},
+				Hash:     "cafebabe0001",
+			}
+		}
+		close(in)
+		stats, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, opts)
+		if err != nil {
+			t.Fatalf("RunDBWriter(%+v): %v", opts, err)
+		}
+		return stats
+	}
+
+	if stats := write(WriterOptions{GroupByExtension: true}); stats.DuplicateGroups != 2 {
+		t.Errorf("with GroupByExtension: got %d groups, want 2 (jpg, png)", stats.DuplicateGroups)
+	}
+
+	if stats := write(WriterOptions{}); stats.DuplicateGroups != 1 {
+		t.Errorf("without GroupByExtension: got %d groups, want 1", stats.DuplicateGroups)
+	}
+	var groups, fileCount int
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(file_count),0) FROM duplicate_groups`).Scan(&groups, &fileCount); err != nil {
+		t.Fatalf("count groups: %v", err)
+	}
+	if groups != 1 || fileCount != len(paths) {
+		t.Errorf("after folding back: %d groups holding %d files, want 1 holding %d", groups, fileCount, len(paths))
+	}
+
+	// Turning it on over a.jpg, b.jpg and c.jpeg moves the two .jpg files to
+	// a new group, leaving c.jpeg alone in the one created with it off.
+	paths = []string{"/vol2/a.jpg", "/vol2/b.jpg", "/vol2/c.jpeg"}
+	write(WriterOptions{})
+	var oldGroup int64
+	if err := db.QueryRow(`SELECT group_id FROM duplicate_files WHERE path = '/vol2/c.jpeg'`).Scan(&oldGroup); err != nil {
+		t.Fatal(err)
+	}
+	write(WriterOptions{GroupByExtension: true})
+	var status string
+	var count int
+	var resolvedAt sql.NullInt64
+	if err := db.QueryRow(`SELECT status, file_count, resolved_at FROM duplicate_groups WHERE id = ?`, oldGroup).
+		Scan(&status, &count, &resolvedAt); err != nil {
+		t.Fatalf("read group left with c.jpeg: %v", err)
+	}
+	if status != "resolved" || count != 1 || !resolvedAt.Valid {
+		t.Errorf("group left with c.jpeg: %s with %d files (resolved_at %v), want resolved with 1", status, count, resolvedAt)
+	}
+}
+
+// TestRunDBWriterStreamDropsWhenFull verifies that written groups are sent to
+// a GroupStream without blocking the writer: once the buffer is full the rest
+// are dropped and counted.
+func TestRunDBWriterStreamDropsWhenFull(t *testing.T) {
+	db := mustOpenDB(t)
+	scanID := mustInsertScan(t, db)
+
+	const numHashes = 5
+	in := make(chan HashedFile, numHashes*2)
+	for i := 0; i < numHashes*2; i++ {
+		in <- HashedFile{
+			FileInfo: FileInfo{Path: fmt.Sprintf("/vol1/file%04d.txt", i), Size: 10, MTime: time.Unix(1000, 0)},
+			Hash:     fmt.Sprintf("deadbeef%04d", i%numHashes),
+		}
+	}
+	close(in)
+
+	stream := NewGroupStream(2)
+	if _, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, WriterOptions{Stream: stream}); err != nil {
+		t.Fatalf("RunDBWriter: %v", err)
+	}
+	stream.close()
+
+	var got int
+	for g := range stream.Groups() {
+		got++
+		if g.GroupID == 0 || len(g.Paths) != 2 || g.ReclaimableBytes != 10 {
+			t.Errorf("group %+v: want an id, 2 paths and 10 reclaimable bytes", g)
+		}
+	}
+	if got != 2 || stream.Dropped() != numHashes-2 {
+		t.Errorf("received %d, dropped %d; want 2 and %d", got, stream.Dropped(), numHashes-2)
+	}
+}
+
+// TestRunDBWriterFileTypes verifies that each file row keeps its own detected
+// type and the group takes the type of its first file.
+func TestRunDBWriterFileTypes(t *testing.T) {
+	db := mustOpenDB(t)
+	scanID := mustInsertScan(t, db)
+
+	paths := []string{"/vol1/a.jpg", "/vol1/b.pdf", "/vol1/c.mp4"}
+	in := make(chan HashedFile, len(paths))
+	for _, p := range paths {
+		in <- HashedFile{
+			FileInfo: FileInfo{Path: p, Size: 10, MTime: time.Unix(1000, 0)},
+			Hash:     "deadbeef0001",
+		}
+	}
+	close(in)
+
+	if _, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, WriterOptions{}); err != nil {
+		t.Fatalf("RunDBWriter: %v", err)
+	}
+
+	want := map[string]string{"/vol1/a.jpg": "image", "/vol1/b.pdf": "document", "/vol1/c.mp4": "video"}
+	rows, err := db.Query(`SELECT path, file_type FROM duplicate_files`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path, ft string
+		if err := rows.Scan(&path, &ft); err != nil {
+			t.Fatal(err)
+		}
+		if ft != want[path] {
+			t.Errorf("%s: file_type %q, want %q", path, ft, want[path])
+		}
+	}
+
+	var groupType string
+	if err := db.QueryRow(`SELECT file_type FROM duplicate_groups`).Scan(&groupType); err != nil {
+		t.Fatal(err)
+	}
+	if groupType != "image" {
+		t.Errorf("group file_type %q, want image (type of the first file)", groupType)
+	}
+}
+
+// TestRunDBWriterFileInventory verifies that FileInventory stores the files
+// left out of duplicate groups, and that the next scan replaces them.
+func TestRunDBWriterFileInventory(t *testing.T) {
+	db := mustOpenDB(t)
+
+	write := func(hashes map[string]string) {
+		t.Helper()
+		scanID := mustInsertScan(t, db)
+		in := make(chan HashedFile, len(hashes))
+		for p, h := range hashes {
+			in <- HashedFile{
+				FileInfo: FileInfo{Path: p, Size: 1024, MTime: time.Unix(1000, 0)},
+				Hash:     h,
+			}
+		}
+		close(in)
+		if _, err := RunDBWriter(context.Background(), db, scanID, 100, in, nil, WriterOptions{FileInventory: true}); err != nil {
+			t.Fatalf("RunDBWriter: %v", err)
+		}
+	}
+	inventory := func() map[string]string {
+		t.Helper()
+		rows, err := db.Query(`SELECT path, content_hash FROM file_inventory`)
+		if err != nil {
+			t.Fatalf("query file_inventory: %v", err)
+		}
+		defer rows.Close()
+		got := map[string]string{}
+		for rows.Next() {
+			var p, h string
+			if err := rows.Scan(&p, &h); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			got[p] = h
+		}
+		return got
+	}
+
+	write(map[string]string{"/a": "aaaa", "/b": "aaaa", "/c": "cccc", "/d": "dddd"})
+	if got := inventory(); len(got) != 2 || got["/c"] != "cccc" || got["/d"] != "dddd" {
+		t.Errorf("first scan: inventory = %v, want /c and /d", got)
+	}
+
+	write(map[string]string{"/a": "aaaa", "/c": "dddd", "/d": "dddd"})
+	if got := inventory(); len(got) != 1 || got["/a"] != "aaaa" {
+		t.Errorf("second scan: inventory = %v, want only /a", got)
+	}
+}
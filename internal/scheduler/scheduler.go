@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -9,24 +10,33 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
+// ScanJob is the name of the job installed by SetJob.
+const ScanJob = "scan"
+
+// ErrUnknownJob is returned by RunNow for a name no job is registered under.
+var ErrUnknownJob = errors.New("no scheduled job with that name")
+
 // Scheduler wraps robfig/cron and tracks the next scheduled run.
 type Scheduler struct {
 	mu       sync.RWMutex
 	c        *cron.Cron
 	entryID  cron.EntryID
 	cronExpr string
+	jobs     map[string]func() error // by name, for RunNow
 }
 
 // New creates a stopped Scheduler. Call Start to activate it.
 func New() *Scheduler {
 	return &Scheduler{
-		c: cron.New(),
+		c:    cron.New(),
+		jobs: map[string]func() error{},
 	}
 }
 
-// SetJob replaces the current cron job with the given expression and callback.
-// If the scheduler is already running, the new job takes effect immediately.
-func (s *Scheduler) SetJob(expr string, fn func()) error {
+// SetJob replaces the current cron job with the given expression and callback,
+// registered as ScanJob. If the scheduler is already running, the new job
+// takes effect immediately.
+func (s *Scheduler) SetJob(expr string, fn func() error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -34,27 +44,55 @@ func (s *Scheduler) SetJob(expr string, fn func()) error {
 		s.c.Remove(s.entryID)
 	}
 
-	id, err := s.c.AddFunc(expr, fn)
+	id, err := s.c.AddFunc(expr, logFailure(ScanJob, fn))
 	if err != nil {
 		return err
 	}
 	s.entryID = id
 	s.cronExpr = expr
+	s.jobs[ScanJob] = fn
 	slog.Info("scheduler: job set", "cron", expr)
 	return nil
 }
 
-// AddJob adds a background job that fires on the given cron expression.
-// Unlike SetJob, this does not replace the tracked scan job.
-func (s *Scheduler) AddJob(expr string, fn func()) error {
-	_, err := s.c.AddFunc(expr, fn)
+// AddJob adds a background job named name that fires on the given cron
+// expression. Unlike SetJob, this does not replace the tracked scan job.
+func (s *Scheduler) AddJob(name, expr string, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.c.AddFunc(expr, logFailure(name, fn))
 	if err != nil {
 		return fmt.Errorf("invalid cron expression %q: %w", expr, err)
 	}
-	slog.Info("scheduler: background job added", "cron", expr)
+	s.jobs[name] = fn
+	slog.Info("scheduler: background job added", "name", name, "cron", expr)
 	return nil
 }
 
+// RunNow runs the job registered under name immediately, in the caller's
+// goroutine, and returns its error — e.g. scan.ErrAlreadyRunning when the
+// scan job finds a scan in progress. Unknown names return ErrUnknownJob.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.RLock()
+	fn, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	slog.Info("scheduler: job run on demand", "name", name)
+	return fn()
+}
+
+// logFailure adapts fn to cron, which ignores results, by logging its error.
+func logFailure(name string, fn func() error) func() {
+	return func() {
+		if err := fn(); err != nil {
+			slog.Warn("scheduler: job failed", "name", name, "error", err)
+		}
+	}
+}
+
 // Start begins the cron loop.
 func (s *Scheduler) Start() {
 	s.c.Start()
@@ -0,0 +1,127 @@
+package trash
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// precompressedExts are formats that are already compressed; gzipping them
+// costs CPU and saves next to nothing, so compress_trash moves them as is.
+var precompressedExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+	".heic": true, ".heif": true, ".avif": true,
+	".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".wmv": true,
+	".flv": true, ".webm": true, ".m4v": true,
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".opus": true, ".flac": true,
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true,
+	".zst": true, ".7z": true, ".rar": true,
+	".pdf": true, ".docx": true, ".xlsx": true, ".pptx": true,
+	".odt": true, ".ods": true, ".odp": true, ".epub": true, ".jar": true, ".apk": true,
+}
+
+// compresses reports whether moving path into dir gzips it: compress_trash
+// is on, dir is the trash, and the file is not already compressed.
+func (m *Manager) compresses(dir, path string) bool {
+	return m.compress && dir == m.trashDir &&
+		!precompressedExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// gzipFile writes src gzipped to dst, then removes src. dst is the name
+// buildTrashPath reserved, so it is overwritten. dst gets src's permission
+// bits and, where the process may set them, its owner and group; the gzip
+// header keeps the name and modification time for gunzipFile. dst is cleaned
+// up on error.
+func gzipFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(src)
+	zw.ModTime = info.ModTime()
+	if _, err = io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = out.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	copyOwner(out, info)
+	if err = out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// gunzipFile reverses gzipFile: it writes the decompressed src to dst with
+// src's permission bits and owner and the modification time from the gzip
+// header, then removes src. dst is cleaned up on error.
+func gunzipFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	if _, err = io.Copy(out, zr); err != nil {
+		return err
+	}
+	if err = zr.Close(); err != nil {
+		return err
+	}
+	if err = out.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	copyOwner(out, info)
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if mtime := zr.ModTime; !mtime.IsZero() {
+		_ = os.Chtimes(dst, time.Time{}, mtime)
+	}
+	in.Close()
+	return os.Remove(src)
+}
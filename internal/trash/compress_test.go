@@ -0,0 +1,87 @@
+package trash
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompressedRoundTrip verifies that with compress_trash a file is stored
+// gzipped and restored with its original bytes, permission bits and
+// modification time.
+func TestCompressedRoundTrip(t *testing.T) {
+	db := mustOpenDB(t)
+	m := New(db, filepath.Join(t.TempDir(), "trash"), "", 0, true, "")
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	content := bytes.Repeat([]byte("compress me\n"), 1000)
+	if err := os.WriteFile(path, content, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// The gzip header stores whole seconds.
+	mtime := time.Unix(1_600_000_000, 0)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.MoveToTrash(context.Background(), path, 0, "aaaa", 30)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	var trashPath string
+	var compressed bool
+	if err := db.QueryRow(`SELECT trash_path, compressed FROM trash WHERE id = ?`, id).Scan(&trashPath, &compressed); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.Stat(trashPath)
+	if err != nil {
+		t.Fatalf("stat trashed file: %v", err)
+	}
+	if !compressed || !strings.HasSuffix(trashPath, ".gz") || stored.Size() >= int64(len(content)) {
+		t.Errorf("trashed as %s (%d bytes, compressed %v), want a smaller .gz", trashPath, stored.Size(), compressed)
+	}
+	var fileSize, storedSize int64
+	if err := db.QueryRow(`SELECT file_size, stored_size FROM trash WHERE id = ?`, id).Scan(&fileSize, &storedSize); err != nil {
+		t.Fatal(err)
+	}
+	if fileSize != int64(len(content)) || storedSize != stored.Size() {
+		t.Errorf("file_size %d, stored_size %d; want the original %d and the .gz's %d",
+			fileSize, storedSize, len(content), stored.Size())
+	}
+
+	restored, err := m.Restore(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored != path {
+		t.Errorf("restored to %s, want %s", restored, path)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read restored file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("restored content differs: %d bytes, want %d", len(got), len(content))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 || !info.ModTime().Equal(mtime) {
+		t.Errorf("restored mode %v, mtime %v; want -rw-r----- and %v", info.Mode().Perm(), info.ModTime(), mtime)
+	}
+	if _, err := os.Stat(trashPath); !os.IsNotExist(err) {
+		t.Errorf("trashed copy should be removed (stat: %v)", err)
+	}
+
+	// Purging frees the .gz, not the original size.
+	if _, err := m.MoveToTrash(context.Background(), path, 0, "aaaa", 30); err != nil {
+		t.Fatalf("MoveToTrash again: %v", err)
+	}
+	if _, freed, err := m.PurgeAll(context.Background()); err != nil || freed != stored.Size() {
+		t.Errorf("PurgeAll freed %d bytes (err %v), want the .gz's %d", freed, err, stored.Size())
+	}
+}
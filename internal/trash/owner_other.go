@@ -0,0 +1,8 @@
+//go:build !unix
+
+package trash
+
+import "os"
+
+// copyOwner is a no-op where files have no Unix owner.
+func copyOwner(f *os.File, info os.FileInfo) {}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package trash
+
+import (
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// copyOwner gives f the owner and group recorded in info. Only a privileged
+// process may give a file away, so failure is logged and otherwise ignored:
+// the copy then belongs to the user ditto runs as.
+func copyOwner(f *os.File, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil {
+		slog.Debug("trash: keep file owner", "path", f.Name(), "uid", st.Uid, "gid", st.Gid, "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestCopyKeepsModeAndOwner verifies that the copying moves — the
+// cross-device fallback and compress_trash's gzip — give the trashed copy
+// the source's permission bits, past the umask, and, when the test runs as
+// root, its owner and group.
+func TestCopyKeepsModeAndOwner(t *testing.T) {
+	const uid, gid = 65534, 65534 // nobody/nogroup
+	asRoot := os.Geteuid() == 0
+	tests := []struct {
+		name string
+		move func(src, dst string) error
+	}{
+		{"copyThenDelete", copyThenDelete},
+		{"gzipFile", gzipFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+			mustWriteFile(t, src, "keep my mode")
+			// 0o666 is narrowed by the usual 022 umask unless set explicitly.
+			if err := os.Chmod(src, 0o666); err != nil {
+				t.Fatal(err)
+			}
+			if asRoot {
+				if err := os.Chown(src, uid, gid); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.move(src, dst); err != nil {
+				t.Fatalf("move: %v", err)
+			}
+			info, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0o666 {
+				t.Errorf("mode = %v, want -rw-rw-rw-", info.Mode().Perm())
+			}
+			if !asRoot {
+				t.Log("not root: owner not checked")
+				return
+			}
+			st := info.Sys().(*syscall.Stat_t)
+			if st.Uid != uid || st.Gid != gid {
+				t.Errorf("owner = %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+			}
+		})
+	}
+}
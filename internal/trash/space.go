@@ -0,0 +1,82 @@
+package trash
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrInsufficientSpace is returned when moving files would leave less than
+// the configured minimum free space on the trash (or archive) filesystem.
+type ErrInsufficientSpace struct {
+	Dir     string
+	Free    int64
+	Need    int64
+	MinFree int64
+}
+
+func (e *ErrInsufficientSpace) Error() string {
+	return fmt.Sprintf("not enough free space on %q: %d bytes free, moving needs %d and %d must stay free",
+		e.Dir, e.Free, e.Need, e.MinFree)
+}
+
+// CheckSpace reports, before anything is moved, whether the files at paths
+// fit in the trash directory (or the archive directory when archive is set)
+// while keeping the minimum free space. Only files on another filesystem, or
+// that compress_trash will gzip, count: a same-device move is a rename and
+// takes no space, and neither does moving a symlink. Returns
+// *ErrInsufficientSpace when they do not fit; files that cannot be stat'ed
+// are left for the move itself to report.
+func (m *Manager) CheckSpace(paths []string, archive bool) error {
+	dir := m.trashDir
+	if archive {
+		dir = m.archiveDir
+	}
+	return m.checkSpace(dir, paths...)
+}
+
+// checkSpace implements CheckSpace for destination directory dir. It is a
+// no-op on platforms where free space cannot be queried.
+func (m *Manager) checkSpace(dir string, paths ...string) error {
+	if dir == "" {
+		return nil
+	}
+	dest := dir
+	dir = existingAncestor(dir)
+	free, dev, ok := m.space(dir)
+	if !ok {
+		return nil
+	}
+	var need int64
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if err != nil || info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		if d, ok := fileDevice(info); ok && d == dev && !m.compresses(dest, p) {
+			continue
+		}
+		need += info.Size()
+	}
+	if need > 0 && free-need < m.minFreeBytes {
+		return &ErrInsufficientSpace{Dir: dir, Free: free, Need: need, MinFree: m.minFreeBytes}
+	}
+	return nil
+}
+
+// existingAncestor returns dir, or its nearest parent that exists — the
+// trash date directories are only created on first use.
+func existingAncestor(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+package trash
+
+import "os"
+
+// fsSpace reports ok=false where free space cannot be queried, which turns
+// the free-space guard off.
+func fsSpace(dir string) (free int64, dev uint64, ok bool) { return 0, 0, false }
+
+func fileDevice(info os.FileInfo) (uint64, bool) { return 0, false }
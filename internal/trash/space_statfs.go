@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd
+
+package trash
+
+import (
+	"os"
+	"syscall"
+)
+
+// fsSpace returns the bytes available to unprivileged users on the
+// filesystem holding dir, and that filesystem's device number.
+func fsSpace(dir string) (free int64, dev uint64, ok bool) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, false
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, 0, false
+	}
+	dev, ok = fileDevice(info)
+	return int64(fs.Bavail) * int64(fs.Bsize), dev, ok
+}
+
+// fileDevice returns the device number of the filesystem info was read from.
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,85 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSpace makes m see free bytes on a filesystem with device dev.
+func fakeSpace(m *Manager, free int64, dev uint64) {
+	m.space = func(string) (int64, uint64, bool) { return free, dev, true }
+}
+
+func TestCheckSpace(t *testing.T) {
+	db := mustOpenDB(t)
+	dir := t.TempDir()
+	small, large := filepath.Join(dir, "small.jpg"), filepath.Join(dir, "large.jpg")
+	mustWriteFile(t, small, string(make([]byte, 100)))
+	mustWriteFile(t, large, string(make([]byte, 400)))
+	info, err := os.Stat(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localDev, ok := fileDevice(info)
+	if !ok {
+		t.Skip("file devices are not available on this platform")
+	}
+	otherDev := localDev + 1
+
+	tests := []struct {
+		name     string
+		free     int64
+		dev      uint64
+		minFree  int64
+		compress bool
+		paths    []string
+		wantErr  bool
+	}{
+		{"fits", 1000, otherDev, 400, false, []string{small, large}, false},
+		{"would cross min free", 1000, otherDev, 600, false, []string{small, large}, true},
+		{"does not fit at all", 300, otherDev, 0, false, []string{large}, true},
+		{"same device is a rename", 0, localDev, 600, false, []string{small, large}, false},
+		{"compressed move needs space on the same device", 100, localDev, 0, true, []string{filepath.Join(dir, "a.txt")}, true},
+		{"missing files are left to the move", 0, otherDev, 600, false, []string{filepath.Join(dir, "gone")}, false},
+	}
+	mustWriteFile(t, filepath.Join(dir, "a.txt"), string(make([]byte, 200)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(db, filepath.Join(t.TempDir(), "trash"), "", tt.minFree, tt.compress, "")
+			fakeSpace(m, tt.free, tt.dev)
+			err := m.CheckSpace(tt.paths, false)
+			var insufficient *ErrInsufficientSpace
+			if got := errors.As(err, &insufficient); got != tt.wantErr {
+				t.Errorf("CheckSpace = %v, want insufficient space: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestMoveToTrashInsufficientSpace verifies that a move that would leave
+// less than the minimum free space is refused before the file is touched.
+func TestMoveToTrashInsufficientSpace(t *testing.T) {
+	db := mustOpenDB(t)
+	path := filepath.Join(t.TempDir(), "f.jpg")
+	mustWriteFile(t, path, string(make([]byte, 400)))
+	m := New(db, filepath.Join(t.TempDir(), "trash"), "", 800, false, "")
+	fakeSpace(m, 1000, ^uint64(0))
+
+	_, err := m.MoveToTrash(context.Background(), path, 0, "aaaa", 30)
+	var insufficient *ErrInsufficientSpace
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("MoveToTrash = %v, want *ErrInsufficientSpace", err)
+	}
+	if insufficient.Free != 1000 || insufficient.Need != 400 || insufficient.MinFree != 800 {
+		t.Errorf("error = %+v, want free 1000, need 400, min free 800", insufficient)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file should be left in place: %v", err)
+	}
+	if n := countTrash(t, db); n != 0 {
+		t.Errorf("trash rows = %d, want 0", n)
+	}
+}
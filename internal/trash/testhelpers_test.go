@@ -0,0 +1,46 @@
+package trash
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	internaldb "github.com/eargollo/ditto/internal/db"
+)
+
+// mustOpenDB opens a temp file SQLite database with the full schema applied.
+func mustOpenDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := internaldb.Open(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatalf("open test DB: %v", err)
+	}
+	if err := internaldb.RunMigrations(db); err != nil {
+		db.Close()
+		tb.Fatalf("run migrations: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// mustWriteFile creates path, and its parent directories, holding content.
+func mustWriteFile(tb testing.TB, path, content string) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// countTrash returns the number of rows in the trash table.
+func countTrash(tb testing.TB, db *sql.DB) int {
+	tb.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM trash`).Scan(&n); err != nil {
+		tb.Fatalf("count trash: %v", err)
+	}
+	return n
+}
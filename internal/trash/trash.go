@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -26,40 +28,122 @@ func (e *ErrRestoreConflict) Error() string {
 	return fmt.Sprintf("a file already exists at %q", e.Path)
 }
 
-// Manager handles moving files to/from/purging the trash directory.
+// ErrRestoreUnwritable is returned when the restore target's directory cannot
+// be written — no permission, a read-only filesystem, or no space left — and
+// no restore_fallback_dir is configured or it could not be written either.
+// Path is the last target tried.
+type ErrRestoreUnwritable struct {
+	Path    string
+	NoSpace bool
+	Err     error
+}
+
+func (e *ErrRestoreUnwritable) Error() string {
+	if e.NoSpace {
+		return fmt.Sprintf("no space left to restore to %q: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("cannot write restore target %q: %v", e.Path, e.Err)
+}
+
+func (e *ErrRestoreUnwritable) Unwrap() error { return e.Err }
+
+// ErrArchiveDisabled is returned by MoveToArchive when no archive_dir is configured.
+var ErrArchiveDisabled = errors.New("archive_dir is not configured")
+
+// ErrArchiveConflict is returned when the archive target path is already occupied.
+type ErrArchiveConflict struct {
+	Path string
+}
+
+func (e *ErrArchiveConflict) Error() string {
+	return fmt.Sprintf("a file already exists in the archive at %q", e.Path)
+}
+
+// Manager handles moving files to/from/purging the trash directory, and
+// moving files into the permanent archive tree.
 type Manager struct {
-	db       *sql.DB
-	trashDir string
+	db         *sql.DB
+	trashDir   string
+	archiveDir string // "" disables MoveToArchive
+	// minFreeBytes is the free space that must remain on the destination
+	// filesystem after a cross-device move (see CheckSpace).
+	minFreeBytes int64
+	// compress gzips files that are not already compressed as they are
+	// moved into the trash (compress_trash).
+	compress bool
+	// restoreFallbackDir receives restores whose original directory cannot
+	// be written, mirroring the original path ("" = fail instead).
+	restoreFallbackDir string
+	// space looks up the free space and device of a directory: fsSpace,
+	// replaced in tests.
+	space func(dir string) (free int64, dev uint64, ok bool)
 }
 
-// New creates a trash Manager.
-func New(db *sql.DB, trashDir string) *Manager {
-	return &Manager{db: db, trashDir: trashDir}
+// New creates a trash Manager. archiveDir may be empty to disable archiving.
+// minFreeBytes is the free space moves into the trash or archive must leave
+// on their filesystem (0 = only refuse moves that cannot fit at all).
+// compress gzips trashed files, except already compressed formats.
+// restoreFallbackDir may be empty to make restores into an unwritable
+// directory fail.
+func New(db *sql.DB, trashDir, archiveDir string, minFreeBytes int64, compress bool, restoreFallbackDir string) *Manager {
+	return &Manager{db: db, trashDir: trashDir, archiveDir: archiveDir, minFreeBytes: minFreeBytes,
+		compress: compress, restoreFallbackDir: restoreFallbackDir, space: fsSpace}
 }
 
+// ArchiveEnabled reports whether an archive directory is configured.
+func (m *Manager) ArchiveEnabled() bool { return m.archiveDir != "" }
+
 // MoveToTrash moves the file at originalPath into the trash directory,
 // records it in the trash table, and returns the new trash row ID.
-// groupID == 0 is stored as NULL.
+// groupID == 0 is stored as NULL. With compress_trash the file is stored
+// gzipped as <trash name>.gz unless it is an already compressed format.
+// A symlink is moved as a link (never compressed); its target is untouched.
 func (m *Manager) MoveToTrash(ctx context.Context, originalPath string, groupID int64, contentHash string, retentionDays int) (int64, error) {
 	// Verify the file exists and capture its current size.
-	info, err := os.Stat(originalPath)
+	info, err := os.Lstat(originalPath)
 	if err != nil {
 		return 0, fmt.Errorf("stat %q: %w", originalPath, err)
 	}
 	fileSize := info.Size()
 
-	// Build a unique trash path (trashDir/YYYY-MM-DD/<nanoseconds>_<basename>).
-	trashPath := m.buildTrashPath(originalPath)
+	if err := m.checkSpace(m.trashDir, originalPath); err != nil {
+		return 0, err
+	}
 
-	// Ensure the date subdirectory exists.
-	if err := os.MkdirAll(filepath.Dir(trashPath), 0o755); err != nil {
+	// Build a unique trash path (trashDir/YYYY-MM-DD/<nanoseconds>_<hash>_<basename>).
+	// The date subdirectory must exist first so the path can be checked as free.
+	dateDir := filepath.Join(m.trashDir, time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(dateDir, 0o755); err != nil {
 		return 0, fmt.Errorf("create trash subdir: %w", err)
 	}
+	compressed := info.Mode().IsRegular() && m.compresses(m.trashDir, originalPath)
+	suffix := ""
+	if compressed {
+		suffix = ".gz"
+	}
+	trashPath, err := buildTrashPath(dateDir, originalPath, contentHash, suffix, time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
 
-	// Move the file (with cross-device fallback).
-	if err := moveFile(originalPath, trashPath); err != nil {
+	// Move the file (with cross-device fallback), or gzip it into place,
+	// over the reserved name.
+	if compressed {
+		err = gzipFile(originalPath, trashPath)
+	} else {
+		err = moveOntoReserved(originalPath, trashPath)
+	}
+	if err != nil {
+		os.Remove(trashPath) // the reservation, if the move did not clean it up
 		return 0, fmt.Errorf("move to trash: %w", err)
 	}
+	// A gzipped file frees (and takes) its compressed size, not fileSize.
+	storedSize := fileSize
+	if compressed {
+		if gz, err := os.Stat(trashPath); err == nil {
+			storedSize = gz.Size()
+		}
+	}
 
 	// Persist to DB. On failure try to move the file back.
 	now := time.Now()
@@ -72,14 +156,14 @@ func (m *Manager) MoveToTrash(ctx context.Context, originalPath string, groupID
 
 	res, err := m.db.ExecContext(ctx, `
 		INSERT INTO trash
-			(group_id, original_path, trash_path, file_size, content_hash,
-			 trashed_at, expires_at, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 'trashed')`,
-		gid, originalPath, trashPath, fileSize, contentHash,
-		now.Unix(), expiresAt.Unix())
+			(group_id, original_path, trash_path, file_size, stored_size, content_hash,
+			 trashed_at, expires_at, status, compressed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'trashed', ?)`,
+		gid, originalPath, trashPath, fileSize, storedSize, contentHash,
+		now.Unix(), expiresAt.Unix(), compressed)
 	if err != nil {
 		// Best-effort rollback.
-		if rerr := moveFile(trashPath, originalPath); rerr != nil {
+		if rerr := restoreFile(trashPath, originalPath, compressed); rerr != nil {
 			slog.Error("rollback move-to-trash failed", "path", originalPath, "error", rerr)
 		}
 		return 0, fmt.Errorf("insert trash record: %w", err)
@@ -90,33 +174,100 @@ func (m *Manager) MoveToTrash(ctx context.Context, originalPath string, groupID
 	return id, nil
 }
 
-// Restore moves a trashed file back to its original path.
-func (m *Manager) Restore(ctx context.Context, trashID int64) error {
+// MoveToArchive moves the file at originalPath into the archive directory,
+// mirroring its absolute path (archiveDir/<originalPath>), and records it in
+// the archive table. Archived files are kept indefinitely — they are never
+// auto-purged. Returns the archive row ID and the new path.
+func (m *Manager) MoveToArchive(ctx context.Context, originalPath string, groupID int64, contentHash string) (int64, string, error) {
+	if m.archiveDir == "" {
+		return 0, "", ErrArchiveDisabled
+	}
+	info, err := os.Lstat(originalPath)
+	if err != nil {
+		return 0, "", fmt.Errorf("stat %q: %w", originalPath, err)
+	}
+
+	archivePath := filepath.Join(m.archiveDir, filepath.Clean(originalPath))
+	if _, err := os.Lstat(archivePath); err == nil {
+		return 0, "", &ErrArchiveConflict{Path: archivePath}
+	}
+	if err := m.checkSpace(m.archiveDir, originalPath); err != nil {
+		return 0, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0o755); err != nil {
+		return 0, "", fmt.Errorf("create archive subdir: %w", err)
+	}
+	if err := moveFile(originalPath, archivePath); err != nil {
+		return 0, "", fmt.Errorf("move to archive: %w", err)
+	}
+
+	var gid interface{}
+	if groupID != 0 {
+		gid = groupID
+	}
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO archive
+			(group_id, original_path, archive_path, file_size, content_hash, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		gid, originalPath, archivePath, info.Size(), contentHash, time.Now().Unix())
+	if err != nil {
+		// Best-effort rollback.
+		if rerr := moveFile(archivePath, originalPath); rerr != nil {
+			slog.Error("rollback move-to-archive failed", "path", originalPath, "error", rerr)
+		}
+		return 0, "", fmt.Errorf("insert archive record: %w", err)
+	}
+
+	id, _ := res.LastInsertId()
+	slog.Info("file archived", "path", originalPath, "archive_id", id, "archive_path", archivePath)
+	return id, archivePath, nil
+}
+
+// Restore moves a trashed file back to its original path, decompressing it
+// if it was stored gzipped, and returns the path it was restored to. A
+// missing original directory is recreated. When the original directory
+// cannot be written (permissions, read-only filesystem, no space) and
+// restore_fallback_dir is set, the file is restored under that directory
+// instead, mirroring its original path; otherwise *ErrRestoreUnwritable is
+// returned. An occupied target yields *ErrRestoreConflict.
+func (m *Manager) Restore(ctx context.Context, trashID int64) (string, error) {
 	var originalPath, trashPath string
+	var compressed bool
 	err := m.db.QueryRowContext(ctx,
-		`SELECT original_path, trash_path FROM trash WHERE id = ? AND status = 'trashed'`,
+		`SELECT original_path, trash_path, compressed FROM trash WHERE id = ? AND status = 'trashed'`,
 		trashID,
-	).Scan(&originalPath, &trashPath)
+	).Scan(&originalPath, &trashPath, &compressed)
 	if errors.Is(err, sql.ErrNoRows) {
-		return ErrNotTrashed
+		return "", ErrNotTrashed
 	}
 	if err != nil {
-		return fmt.Errorf("lookup trash item %d: %w", trashID, err)
+		return "", fmt.Errorf("lookup trash item %d: %w", trashID, err)
 	}
 
 	// Refuse if the original path is already occupied.
 	if _, err := os.Stat(originalPath); err == nil {
-		return &ErrRestoreConflict{Path: originalPath}
+		return "", &ErrRestoreConflict{Path: originalPath}
 	}
 
-	// Recreate any missing parent directories.
-	if err := os.MkdirAll(filepath.Dir(originalPath), 0o755); err != nil {
-		return fmt.Errorf("recreate restore dir: %w", err)
+	restoredPath := originalPath
+	err = restoreTo(trashPath, originalPath, compressed)
+	if noSpace, ok := unwritable(err); ok {
+		if m.restoreFallbackDir == "" {
+			return "", &ErrRestoreUnwritable{Path: originalPath, NoSpace: noSpace, Err: err}
+		}
+		slog.Warn("restore: original directory not writable, using fallback",
+			"path", originalPath, "fallback_dir", m.restoreFallbackDir, "error", err)
+		restoredPath = filepath.Join(m.restoreFallbackDir, filepath.Clean(originalPath))
+		if _, serr := os.Stat(restoredPath); serr == nil {
+			return "", &ErrRestoreConflict{Path: restoredPath}
+		}
+		err = restoreTo(trashPath, restoredPath, compressed)
+		if noSpace, ok := unwritable(err); ok {
+			return "", &ErrRestoreUnwritable{Path: restoredPath, NoSpace: noSpace, Err: err}
+		}
 	}
-
-	// Move back.
-	if err := moveFile(trashPath, originalPath); err != nil {
-		return fmt.Errorf("restore file: %w", err)
+	if err != nil {
+		return "", err
 	}
 
 	now := time.Now().Unix()
@@ -127,14 +278,14 @@ func (m *Manager) Restore(ctx context.Context, trashID int64) error {
 		slog.Error("update trash status after restore", "trash_id", trashID, "error", err)
 	}
 
-	slog.Info("file restored", "path", originalPath, "trash_id", trashID)
-	return nil
+	slog.Info("file restored", "path", restoredPath, "trash_id", trashID)
+	return restoredPath, nil
 }
 
 // PurgeAll immediately purges all active trash items (trigger = "user").
 func (m *Manager) PurgeAll(ctx context.Context) (count int64, bytesFreed int64, err error) {
 	rows, err := m.db.QueryContext(ctx,
-		`SELECT id, original_path, trash_path, file_size, content_hash
+		`SELECT id, original_path, trash_path, file_size, COALESCE(stored_size, file_size), content_hash
 		 FROM trash WHERE status = 'trashed'`)
 	if err != nil {
 		return 0, 0, fmt.Errorf("query trash: %w", err)
@@ -143,35 +294,64 @@ func (m *Manager) PurgeAll(ctx context.Context) (count int64, bytesFreed int64,
 }
 
 // AutoPurge purges all trash items whose expires_at is in the past (trigger = "auto").
-// Intended to be called by the scheduler.
-func (m *Manager) AutoPurge(ctx context.Context) error {
+// Called by the scheduler and by POST /api/trash/purge-expired.
+func (m *Manager) AutoPurge(ctx context.Context) (count int64, bytesFreed int64, err error) {
 	rows, err := m.db.QueryContext(ctx,
-		`SELECT id, original_path, trash_path, file_size, content_hash
+		`SELECT id, original_path, trash_path, file_size, COALESCE(stored_size, file_size), content_hash
 		 FROM trash WHERE status = 'trashed' AND expires_at < ?`,
 		time.Now().Unix())
 	if err != nil {
-		return fmt.Errorf("query expired trash: %w", err)
+		return 0, 0, fmt.Errorf("query expired trash: %w", err)
 	}
-	count, bytes, err := m.purgeRows(ctx, rows, "auto")
+	count, bytesFreed, err = m.purgeRows(ctx, rows, "auto")
 	if err != nil {
-		return err
+		return count, bytesFreed, err
 	}
 	if count > 0 {
-		slog.Info("auto-purge complete", "files_purged", count, "bytes_freed", bytes)
+		slog.Info("auto-purge complete", "files_purged", count, "bytes_freed", bytesFreed)
 	}
-	return nil
+	return count, bytesFreed, nil
 }
 
 // ── private helpers ────────────────────────────────────────────────────────
 
-// buildTrashPath returns a unique path inside trashDir for the given original file.
-// Format: trashDir/YYYY-MM-DD/<unix_nano>_<basename>
-func (m *Manager) buildTrashPath(originalPath string) string {
-	now := time.Now()
-	dateDir := now.Format("2006-01-02")
+// trashHashPrefixLen is how much of the content hash trash file names carry.
+const trashHashPrefixLen = 12
+
+// buildTrashPath reserves an unused path inside dateDir for the given
+// original file and returns it. Format: <stamp>_<hash prefix>_<basename><suffix>
+// (stamp is a Unix time in nanoseconds), so a trashed file can be traced back
+// to its content from its name alone. The name is reserved by creating it
+// empty with O_EXCL, so two moves can never pick the same one; should it
+// already be taken, a counter is appended to the stamp (<stamp>-1_...).
+// The caller moves the file onto the reservation or removes it.
+func buildTrashPath(dateDir, originalPath, contentHash, suffix string, stamp int64) (string, error) {
+	prefix := contentHash
+	if len(prefix) > trashHashPrefixLen {
+		prefix = prefix[:trashHashPrefixLen]
+	}
 	basename := filepath.Base(originalPath)
-	filename := fmt.Sprintf("%d_%s", now.UnixNano(), basename)
-	return filepath.Join(m.trashDir, dateDir, filename)
+
+	const maxAttempts = 100
+	for i := 0; i < maxAttempts; i++ {
+		name := strconv.FormatInt(stamp, 10)
+		if i > 0 {
+			name += "-" + strconv.Itoa(i)
+		}
+		if prefix != "" {
+			name += "_" + prefix
+		}
+		path := filepath.Join(dateDir, name+"_"+basename+suffix)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("reserve trash path: %w", err)
+		}
+		f.Close()
+		return path, nil
+	}
+	return "", fmt.Errorf("no free trash name for %q after %d attempts", basename, maxAttempts)
 }
 
 type purgeItem struct {
@@ -179,6 +359,7 @@ type purgeItem struct {
 	originalPath string
 	trashPath    string
 	fileSize     int64
+	storedSize   int64 // bytes it takes in the trash; see MoveToTrash
 	contentHash  string
 }
 
@@ -188,7 +369,7 @@ func (m *Manager) purgeRows(ctx context.Context, rows *sql.Rows, trigger string)
 	var items []purgeItem
 	for rows.Next() {
 		var it purgeItem
-		if err := rows.Scan(&it.id, &it.originalPath, &it.trashPath, &it.fileSize, &it.contentHash); err != nil {
+		if err := rows.Scan(&it.id, &it.originalPath, &it.trashPath, &it.fileSize, &it.storedSize, &it.contentHash); err != nil {
 			return count, bytesFreed, fmt.Errorf("scan trash row: %w", err)
 		}
 		items = append(items, it)
@@ -224,32 +405,136 @@ func (m *Manager) purgeRows(ctx context.Context, rows *sql.Rows, trigger string)
 		}
 
 		count++
-		bytesFreed += it.fileSize
+		bytesFreed += it.storedSize
 	}
 
+	m.removeEmptyDateDirs()
 	return count, bytesFreed, nil
 }
 
-// moveFile tries os.Rename first; falls back to copy+delete on cross-device errors.
+// removeEmptyDateDirs deletes trashDir/YYYY-MM-DD directories left empty by
+// purging. Today's directory is kept because MoveToTrash may be about to move
+// a file into it; it is cleaned up by a later purge.
+func (m *Manager) removeEmptyDateDirs() {
+	entries, err := os.ReadDir(m.trashDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("purge: read trash dir", "dir", m.trashDir, "error", err)
+		}
+		return
+	}
+	today := time.Now().Format("2006-01-02")
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == today {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", e.Name()); err != nil {
+			continue // not a date directory — leave it alone
+		}
+		// os.Remove refuses non-empty directories, which is exactly the check we want.
+		_ = os.Remove(filepath.Join(m.trashDir, e.Name()))
+	}
+}
+
+// restoreTo recreates the missing parent directories of dst and restores the
+// trashed file there.
+func restoreTo(trashPath, dst string, compressed bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("recreate restore dir: %w", err)
+	}
+	if err := restoreFile(trashPath, dst, compressed); err != nil {
+		return fmt.Errorf("restore file: %w", err)
+	}
+	return nil
+}
+
+// unwritable reports whether err means the restore target cannot be written
+// — permission denied or a read-only filesystem, or (noSpace) a full disk or
+// exhausted quota — as opposed to a problem with the trashed file itself.
+func unwritable(err error) (noSpace, ok bool) {
+	switch {
+	case err == nil:
+		return false, false
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return true, true
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.EROFS):
+		return false, true
+	}
+	return false, false
+}
+
+// restoreFile moves trashPath back to originalPath, decompressing it when it
+// was stored gzipped.
+func restoreFile(trashPath, originalPath string, compressed bool) error {
+	if compressed {
+		return gunzipFile(trashPath, originalPath)
+	}
+	return moveFile(trashPath, originalPath)
+}
+
+// moveFile tries os.Rename first; falls back to copy+delete on cross-device
+// errors. A symlink is moved as a link: renamed, or recreated at dst.
 func moveFile(src, dst string) error {
 	if err := os.Rename(src, dst); err == nil {
 		return nil
 	} else if le, ok := err.(*os.LinkError); ok && errors.Is(le.Err, syscall.EXDEV) {
+		if info, err := os.Lstat(src); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return moveSymlink(src, dst)
+		}
 		return copyThenDelete(src, dst)
 	} else {
 		return err
 	}
 }
 
+// moveOntoReserved moves src onto dst, the empty file buildTrashPath created
+// to reserve the name. A rename or copy replaces it; a symlink recreated on
+// another device cannot, so the reservation is removed for it first.
+func moveOntoReserved(src, dst string) error {
+	err := moveFile(src, dst)
+	if errors.Is(err, fs.ErrExist) {
+		if info, lerr := os.Lstat(src); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+			return moveSymlink(src, dst)
+		}
+	}
+	return err
+}
+
+// moveSymlink recreates the symlink src at dst, pointing at the same target,
+// then removes src.
+func moveSymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
 // copyThenDelete copies src to dst then removes src. dst is cleaned up on error.
+// dst gets src's permission bits and, where the process may set them, its
+// owner and group, as a rename would have kept them.
 func copyThenDelete(src, dst string) (err error) {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
 
-	out, err := os.Create(dst)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
@@ -263,6 +548,11 @@ func copyThenDelete(src, dst string) (err error) {
 	if _, err = io.Copy(out, in); err != nil {
 		return err
 	}
+	// OpenFile's mode is filtered through the umask; set it explicitly.
+	if err = out.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	copyOwner(out, info)
 	if err = out.Close(); err != nil {
 		return err
 	}
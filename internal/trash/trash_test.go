@@ -0,0 +1,173 @@
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mustTrash writes a file at path and moves it to m's trash, returning the
+// trash row ID.
+func mustTrash(tb testing.TB, m *Manager, path string) int64 {
+	tb.Helper()
+	mustWriteFile(tb, path, "trashed content")
+	id, err := m.MoveToTrash(context.Background(), path, 0, "aaaa", 30)
+	if err != nil {
+		tb.Fatalf("MoveToTrash: %v", err)
+	}
+	return id
+}
+
+// trashStatus returns the status of trash row id.
+func trashStatus(tb testing.TB, db *sql.DB, id int64) string {
+	tb.Helper()
+	var status string
+	if err := db.QueryRow(`SELECT status FROM trash WHERE id = ?`, id).Scan(&status); err != nil {
+		tb.Fatalf("read trash row %d: %v", id, err)
+	}
+	return status
+}
+
+// TestRestoreRecreatesDir verifies that a file whose original directory has
+// gone is restored to its original path, the directory recreated.
+func TestRestoreRecreatesDir(t *testing.T) {
+	db := mustOpenDB(t)
+	m := New(db, filepath.Join(t.TempDir(), "trash"), "", 0, false, filepath.Join(t.TempDir(), "fallback"))
+	dir := filepath.Join(t.TempDir(), "photos")
+	path := filepath.Join(dir, "2020", "f.txt")
+	id := mustTrash(t, m, path)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := m.Restore(context.Background(), id)
+	if err != nil || restored != path {
+		t.Fatalf("Restore = %q, %v; want %s", restored, err, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("restored file: %v", err)
+	}
+	if status := trashStatus(t, db, id); status != "restored" {
+		t.Errorf("trash status = %s, want restored", status)
+	}
+}
+
+// TestRestoreOccupied verifies that a restore onto an occupied path is
+// refused, leaving both the file in the way and the trashed copy alone.
+func TestRestoreOccupied(t *testing.T) {
+	db := mustOpenDB(t)
+	m := New(db, filepath.Join(t.TempDir(), "trash"), "", 0, false, filepath.Join(t.TempDir(), "fallback"))
+	path := filepath.Join(t.TempDir(), "f.txt")
+	id := mustTrash(t, m, path)
+	mustWriteFile(t, path, "a new file")
+
+	_, err := m.Restore(context.Background(), id)
+	var conflict *ErrRestoreConflict
+	if !errors.As(err, &conflict) || conflict.Path != path {
+		t.Fatalf("Restore = %v, want *ErrRestoreConflict for %s", err, path)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "a new file" {
+		t.Errorf("file in the way = %q, want it untouched", got)
+	}
+	if status := trashStatus(t, db, id); status != "trashed" {
+		t.Errorf("trash status = %s, want trashed", status)
+	}
+}
+
+// TestRestoreFallback verifies where a file lands when its original
+// directory cannot be written: under restore_fallback_dir, mirroring its
+// original path; nowhere, with *ErrRestoreUnwritable, without a fallback
+// dir; and nowhere, with *ErrRestoreConflict, when the fallback path is
+// taken.
+func TestRestoreFallback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "readonly")
+	path := filepath.Join(dir, "f.txt")
+	fallback := filepath.Join(t.TempDir(), "fallback")
+	fallbackPath := filepath.Join(fallback, path)
+
+	tests := []struct {
+		name     string
+		fallback string
+		occupied bool
+		wantPath string
+		wantErr  any
+	}{
+		{"fallback", fallback, false, fallbackPath, nil},
+		{"no fallback", "", false, "", new(*ErrRestoreUnwritable)},
+		{"fallback occupied", fallback, true, "", new(*ErrRestoreConflict)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := mustOpenDB(t)
+			m := New(db, filepath.Join(t.TempDir(), "trash"), "", 0, false, tt.fallback)
+			id := mustTrash(t, m, path)
+			os.RemoveAll(fallback)
+			if tt.occupied {
+				mustWriteFile(t, fallbackPath, "in the way")
+			}
+			if err := os.Chmod(dir, 0o555); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(dir, 0o755) })
+			if probe, err := os.Create(filepath.Join(dir, "probe")); err == nil {
+				probe.Close()
+				os.Remove(probe.Name())
+				t.Skip("directory permissions are not enforced for this user")
+			}
+
+			restored, err := m.Restore(context.Background(), id)
+			if tt.wantErr != nil {
+				if !errors.As(err, tt.wantErr) {
+					t.Fatalf("Restore = %q, %v; want %T", restored, err, tt.wantErr)
+				}
+				if status := trashStatus(t, db, id); status != "trashed" {
+					t.Errorf("trash status = %s, want trashed", status)
+				}
+				return
+			}
+			if err != nil || restored != tt.wantPath {
+				t.Fatalf("Restore = %q, %v; want %s", restored, err, tt.wantPath)
+			}
+			if got, _ := os.ReadFile(tt.wantPath); string(got) != "trashed content" {
+				t.Errorf("restored content = %q", got)
+			}
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Errorf("nothing should be written to the original path (stat: %v)", err)
+			}
+		})
+	}
+}
+
+// TestBuildTrashPathReserves verifies that buildTrashPath reserves each name
+// it returns, so calls with the same stamp get distinct names through the
+// -n counter, skipping a name already taken on disk.
+func TestBuildTrashPathReserves(t *testing.T) {
+	dir := t.TempDir()
+	const stamp = 1_700_000_000_000_000_000
+	taken := filepath.Join(dir, "1700000000000000000-2_abcdef012345_f.txt")
+	mustWriteFile(t, taken, "someone else's")
+
+	want := []string{
+		"1700000000000000000_abcdef012345_f.txt",
+		"1700000000000000000-1_abcdef012345_f.txt",
+		"1700000000000000000-3_abcdef012345_f.txt",
+	}
+	for _, name := range want {
+		got, err := buildTrashPath(dir, "/photos/f.txt", "abcdef0123456789", "", stamp)
+		if err != nil {
+			t.Fatalf("buildTrashPath: %v", err)
+		}
+		if got != filepath.Join(dir, name) {
+			t.Errorf("path = %s, want %s", got, name)
+		}
+		if info, err := os.Lstat(got); err != nil || info.Size() != 0 {
+			t.Errorf("%s should be reserved as an empty file (stat: %v)", got, err)
+		}
+	}
+	if b, err := os.ReadFile(taken); err != nil || string(b) != "someone else's" {
+		t.Errorf("taken name was overwritten: %q, %v", b, err)
+	}
+}
@@ -0,0 +1,47 @@
+package regression_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// effectiveWorkers returns the scan manager's worker counts from GET /api/status.
+func effectiveWorkers(t *testing.T, ts *testServer) (walkers, cacheCheckers int) {
+	t.Helper()
+	resp := ts.get(t, "/api/status")
+	requireStatus(t, resp, 200)
+	var body struct {
+		ScanWorkers *struct {
+			Walkers       int `json:"walkers"`
+			CacheCheckers int `json:"cache_checkers"`
+		} `json:"scan_workers"`
+	}
+	decodeJSON(t, resp, &body)
+	if body.ScanWorkers == nil {
+		t.Fatal("expected scan_workers in /api/status")
+	}
+	return body.ScanWorkers.Walkers, body.ScanWorkers.CacheCheckers
+}
+
+// TestConfigPatch_PreservesCacheCheckers verifies that a runtime config change
+// that does not touch cache_checkers leaves the scan manager's cache-checker
+// count intact (it used to be reset to 0, so scans found nothing).
+func TestConfigPatch_PreservesCacheCheckers(t *testing.T) {
+	ts := newTestServer(t)
+
+	walkers, before := effectiveWorkers(t, ts)
+	if before < 1 {
+		t.Fatalf("expected cache_checkers ≥ 1 before PATCH, got %d", before)
+	}
+
+	// Re-submit the current walker count: a no-op change that still rebuilds
+	// the scan config.
+	resp := ts.patch(t, "/api/config", strings.NewReader(fmt.Sprintf(`{"scan_workers":{"walkers":%d}}`, walkers)))
+	requireStatus(t, resp, 200)
+	resp.Body.Close()
+
+	if _, after := effectiveWorkers(t, ts); after != before {
+		t.Errorf("cache_checkers after PATCH: got %d, want %d", after, before)
+	}
+}
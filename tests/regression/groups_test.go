@@ -211,6 +211,97 @@ func TestGroupDelete_Success(t *testing.T) {
 	}
 }
 
+// TestGroupDelete_SymlinkRefused verifies that, with the default
+// symlink_delete: refuse, a duplicate replaced by a symlink since the scan is
+// neither deleted nor followed: whether it is the file to delete or the
+// keeper, validation fails and the link's target is left in place.
+func TestGroupDelete_SymlinkRefused(t *testing.T) {
+	ts := newTestServer(t)
+
+	dir := t.TempDir()
+	content := []byte("duplicate content for symlink test")
+	target := filepath.Join(dir, "target.txt")
+	os.WriteFile(target, content, 0o644)
+	os.WriteFile(filepath.Join(dir, "file_a.txt"), content, 0o644)
+	os.WriteFile(filepath.Join(dir, "file_b.txt"), content, 0o644)
+
+	prevMax := maxGroupID(t, ts)
+	waitForScan(t, ts, dir)
+	groupID, _, _ := firstNewGroup(t, ts, prevMax)
+
+	resp := ts.get(t, fmt.Sprintf("/api/groups/%d", groupID))
+	requireStatus(t, resp, 200)
+	var detail struct {
+		Files []struct {
+			ID   int64  `json:"id"`
+			Path string `json:"path"`
+		} `json:"files"`
+	}
+	decodeJSON(t, resp, &detail)
+	var linkID, otherID int64
+	for _, f := range detail.Files {
+		switch filepath.Base(f.Path) {
+		case "file_a.txt":
+			linkID = f.ID
+		case "file_b.txt":
+			otherID = f.ID
+		}
+	}
+	if linkID == 0 || otherID == 0 {
+		t.Fatalf("expected file_a.txt and file_b.txt in group %d, got %+v", groupID, detail.Files)
+	}
+
+	// Replace file_a.txt by a link to target.txt.
+	link := filepath.Join(dir, "file_a.txt")
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		deleteID   int64
+		wantReason string
+	}{
+		{deleteID: linkID, wantReason: "FILE_SYMLINK"},
+		{deleteID: otherID, wantReason: "KEEPER_SYMLINK"},
+	} {
+		body := fmt.Sprintf(`{"delete_file_ids":[%d]}`, tc.deleteID)
+		delResp := ts.post(t, fmt.Sprintf("/api/groups/%d/delete", groupID), strings.NewReader(body))
+		requireStatus(t, delResp, 409)
+		var errBody struct {
+			Error struct {
+				Code     string `json:"code"`
+				Failures []struct {
+					FileID int64  `json:"file_id"`
+					Reason string `json:"reason"`
+				} `json:"failures"`
+			} `json:"error"`
+		}
+		decodeJSON(t, delResp, &errBody)
+		if errBody.Error.Code != "VALIDATION_FAILED" {
+			t.Errorf("delete %d: expected VALIDATION_FAILED, got %q", tc.deleteID, errBody.Error.Code)
+		}
+		found := false
+		for _, f := range errBody.Error.Failures {
+			if f.FileID == linkID && f.Reason == tc.wantReason {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("delete %d: expected failure %s for the link, got %+v", tc.deleteID, tc.wantReason, errBody.Error.Failures)
+		}
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link should still be in place: %v", err)
+	}
+	if got, err := os.ReadFile(target); err != nil || string(got) != string(content) {
+		t.Errorf("link target should be untouched: %q, %v", got, err)
+	}
+}
+
 // TestGroupIgnore_Hash verifies that ignoring by hash sets the group to ignored.
 func TestGroupIgnore_Hash(t *testing.T) {
 	ts := newTestServer(t)
@@ -343,3 +434,78 @@ func TestGroupIgnore_PathPair(t *testing.T) {
 		t.Errorf("expected group %d to appear under status=watching filter", groupID)
 	}
 }
+
+// TestGroupList_Sort verifies the sort/order params and that values outside
+// the allowlist are rejected.
+func TestGroupList_Sort(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.get(t, "/api/groups?status=all&sort=file_count&order=asc&limit=200")
+	requireStatus(t, resp, 200)
+	var body struct {
+		Items []struct {
+			ID        int64 `json:"id"`
+			FileCount int   `json:"file_count"`
+		} `json:"items"`
+	}
+	decodeJSON(t, resp, &body)
+	for i := 1; i < len(body.Items); i++ {
+		prev, cur := body.Items[i-1], body.Items[i]
+		if cur.FileCount < prev.FileCount || (cur.FileCount == prev.FileCount && cur.ID < prev.ID) {
+			t.Fatalf("items %d and %d out of order: %+v then %+v", i-1, i, prev, cur)
+		}
+	}
+
+	for _, q := range []string{"sort=id%3BDROP+TABLE+x", "sort=file_size&order=sideways"} {
+		resp := ts.get(t, "/api/groups?"+q)
+		requireStatus(t, resp, 400)
+		resp.Body.Close()
+	}
+}
+
+// TestGroupList_Cursor walks every group with ?after= and checks the pages
+// are in ID order, do not overlap, and together match the offset listing.
+func TestGroupList_Cursor(t *testing.T) {
+	ts := newTestServer(t)
+
+	type page struct {
+		Items []struct {
+			ID int64 `json:"id"`
+		} `json:"items"`
+		Total      int    `json:"total"`
+		NextCursor *int64 `json:"next_cursor"`
+	}
+
+	var ids []int64
+	var total int
+	after := int64(0)
+	for pages := 0; ; pages++ {
+		if pages > 10000 {
+			t.Fatal("cursor never ended")
+		}
+		resp := ts.get(t, fmt.Sprintf("/api/groups?status=all&limit=7&after=%d", after))
+		requireStatus(t, resp, 200)
+		var p page
+		decodeJSON(t, resp, &p)
+		total = p.Total
+		for _, it := range p.Items {
+			if len(ids) > 0 && it.ID <= ids[len(ids)-1] {
+				t.Fatalf("id %d after %d: cursor pages out of order", it.ID, ids[len(ids)-1])
+			}
+			ids = append(ids, it.ID)
+		}
+		if p.NextCursor == nil {
+			break
+		}
+		after = *p.NextCursor
+	}
+	if len(ids) != total {
+		t.Errorf("cursor walk returned %d groups, total is %d", len(ids), total)
+	}
+
+	for _, q := range []string{"after=-1", "after=abc", "after=0&offset=10", "after=0&sort=file_size"} {
+		resp := ts.get(t, "/api/groups?"+q)
+		requireStatus(t, resp, 400)
+		resp.Body.Close()
+	}
+}
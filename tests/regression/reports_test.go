@@ -0,0 +1,66 @@
+package regression_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// TestReportNameVariants verifies that files whose names differ only by case,
+// a " (1)" counter or a "copy" marker are reported together, even though their
+// contents differ.
+func TestReportNameVariants(t *testing.T) {
+	ts := newTestServer(t)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Holiday.jpg":        "original",
+		"holiday (1).jpg":    "slightly edited",
+		"Holiday - Copy.jpg": "another edit",
+		"holiday.png":        "different extension",
+		"unrelated.jpg":      "unrelated",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := ts.get(t, "/api/reports/name-variants?path="+url.QueryEscape(dir))
+	requireStatus(t, resp, 200)
+	var body struct {
+		Items []struct {
+			Dir   string `json:"dir"`
+			Key   string `json:"key"`
+			Files []struct {
+				Name string `json:"name"`
+			} `json:"files"`
+		} `json:"items"`
+		Total int `json:"total"`
+	}
+	decodeJSON(t, resp, &body)
+
+	if body.Total != 1 || len(body.Items) != 1 {
+		t.Fatalf("expected exactly 1 variant set, got total=%d items=%d", body.Total, len(body.Items))
+	}
+	set := body.Items[0]
+	if set.Key != "holiday.jpg" {
+		t.Errorf("expected key holiday.jpg, got %q", set.Key)
+	}
+	var names []string
+	for _, f := range set.Files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"Holiday - Copy.jpg", "Holiday.jpg", "holiday (1).jpg"}
+	if len(names) != len(want) {
+		t.Fatalf("expected files %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("expected files %v, got %v", want, names)
+			break
+		}
+	}
+}
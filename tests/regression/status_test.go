@@ -39,3 +39,36 @@ func TestStatus_Shape(t *testing.T) {
 		t.Error("expected schedule.cron to be non-empty")
 	}
 }
+
+// TestAdminMetrics_RecordsRoutePattern verifies that a request shows up in
+// GET /api/admin/metrics under its route pattern, not its concrete path.
+func TestAdminMetrics_RecordsRoutePattern(t *testing.T) {
+	ts := newTestServer(t)
+	ts.get(t, "/api/scans/999999999").Body.Close()
+
+	resp := ts.get(t, "/api/admin/metrics")
+	requireStatus(t, resp, 200)
+	var body struct {
+		BucketBoundsMs []float64 `json:"bucket_bounds_ms"`
+		Routes         []struct {
+			Route   string  `json:"route"`
+			Count   int64   `json:"count"`
+			Buckets []int64 `json:"buckets"`
+		} `json:"routes"`
+	}
+	decodeJSON(t, resp, &body)
+
+	for _, rt := range body.Routes {
+		if rt.Route != "GET /api/scans/{id}" {
+			continue
+		}
+		if rt.Count < 1 {
+			t.Errorf("count = %d, want >= 1", rt.Count)
+		}
+		if len(rt.Buckets) != len(body.BucketBoundsMs)+1 {
+			t.Errorf("%d buckets for %d bounds, want one more", len(rt.Buckets), len(body.BucketBoundsMs))
+		}
+		return
+	}
+	t.Errorf("route GET /api/scans/{id} missing from %+v", body.Routes)
+}